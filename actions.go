@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"text/template"
+)
+
+// ActionConfig customises the Poppit payload for PR messages announcing a given event action.
+// Unset fields keep the payload VibeMerge would otherwise build.
+type ActionConfig struct {
+	// Skip ignores reactions on messages for this action entirely
+	Skip   bool    `json:"skip,omitempty"`
+	Type   *string `json:"type,omitempty"`
+	Dir    *string `json:"dir,omitempty"`
+	Branch *string `json:"branch,omitempty"`
+	// ExtraCommands are command templates run after the emoji's commands
+	ExtraCommands []string `json:"extra_commands,omitempty"`
+
+	extraCommands []*template.Template
+}
+
+// loadActionConfigs reads the per-event-action payload settings file, keyed by event action
+func loadActionConfigs(path string) (map[string]ActionConfig, error) {
+	actions := make(map[string]ActionConfig)
+	if path == "" {
+		return actions, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACTIONS_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return nil, fmt.Errorf("failed to parse ACTIONS_FILE: %w", err)
+	}
+
+	for name, action := range actions {
+		if len(action.ExtraCommands) == 0 {
+			continue
+		}
+		templates, err := parseCommandTemplates(map[string][]string{name: action.ExtraCommands})
+		if err != nil {
+			return nil, fmt.Errorf("ACTIONS_FILE: %w", err)
+		}
+		action.extraCommands = templates[name]
+		actions[name] = action
+	}
+	return actions, nil
+}
+
+// applyAction adjusts a Poppit payload for the event action of the PR message it was built from
+func (c *Config) applyAction(payload *PoppitPayload, metadata *PRMetadata) error {
+	action, ok := c.Actions[metadata.EventAction]
+	if !ok {
+		return nil
+	}
+
+	if action.Type != nil {
+		payload.Type = *action.Type
+	}
+	if action.Dir != nil {
+		payload.Dir = *action.Dir
+	}
+	if action.Branch != nil {
+		payload.Branch = *action.Branch
+	}
+
+	extra, err := renderCommands(action.extraCommands, metadata)
+	if err != nil {
+		return err
+	}
+	payload.Commands = append(payload.Commands, extra...)
+	return nil
+}
+
+// skipsAction reports whether reactions on messages for an event action are ignored, because it is skipped in
+// ACTIONS_FILE or missing from EVENT_ACTIONS. Messages without an action, such as PR links, are always acted on.
+func (c *Config) skipsAction(eventAction string) bool {
+	if len(c.EventActions) > 0 && eventAction != "" && !slices.Contains(c.EventActions, eventAction) {
+		return true
+	}
+	return c.Actions[eventAction].Skip
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AdminCommand is an operator command received on the admin channel
+type AdminCommand struct {
+	Command      string `json:"command"`
+	Reason       string `json:"reason,omitempty"`
+	RequestID    string `json:"request_id,omitempty"`
+	ReplyChannel string `json:"reply_channel,omitempty"`
+	User         string `json:"user,omitempty"`
+}
+
+// AdminReply reports the outcome of an admin command
+type AdminReply struct {
+	RequestID string      `json:"request_id,omitempty"`
+	Command   string      `json:"command"`
+	OK        bool        `json:"ok"`
+	Message   string      `json:"message"`
+	State     *AdminState `json:"state,omitempty"`
+}
+
+// AdminState is a snapshot of the running instance returned by dump-state
+type AdminState struct {
+	Paused            bool       `json:"paused"`
+	PauseReason       string     `json:"pause_reason,omitempty"`
+	StartedAt         time.Time  `json:"started_at"`
+	BlackoutUntil     *time.Time `json:"blackout_until,omitempty"`
+	PoppitQueueLength int64      `json:"poppit_queue_length"`
+	DeferredMerges    int64      `json:"deferred_merges"`
+	Timezone          string     `json:"timezone"`
+	Config            *Config    `json:"config"`
+}
+
+var startedAt = time.Now()
+
+// drainRequested is signalled when an operator asks the instance to finish its work and exit
+var drainRequested = make(chan struct{}, 1)
+
+func processAdminCommands(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	receiveMessages(ctx, redisClient, clients, currentConfig().AdminChannel, func(payload string) {
+		if err := handleAdminCommand(context.WithoutCancel(ctx), payload, redisClient, currentConfig()); err != nil {
+			logError("Error handling admin command: %v", err)
+		}
+	})
+}
+
+func handleAdminCommand(ctx context.Context, payload string, redisClient *redis.Client, config *Config) error {
+	var cmd AdminCommand
+	if err := json.Unmarshal([]byte(payload), &cmd); err != nil {
+		return fmt.Errorf("failed to unmarshal admin command: %w", err)
+	}
+
+	logInfo("Processing admin command %q from %q", cmd.Command, cmd.User)
+
+	reply := AdminReply{RequestID: cmd.RequestID, Command: cmd.Command, OK: true}
+
+	switch strings.ToLower(cmd.Command) {
+	case "pause":
+		reason := "paused via admin channel"
+		if cmd.User != "" {
+			reason = fmt.Sprintf("paused by %s", cmd.User)
+		}
+		if cmd.Reason != "" {
+			reason = fmt.Sprintf("%s: %s", reason, cmd.Reason)
+		}
+		if err := pauseMerging(ctx, redisClient, config, reason); err != nil {
+			reply.OK = false
+			reply.Message = err.Error()
+			break
+		}
+		reply.Message = "merging paused"
+
+	case "resume":
+		if err := resumeMerging(ctx, redisClient, config); err != nil {
+			reply.OK = false
+			reply.Message = err.Error()
+			break
+		}
+		reply.Message = "merging resumed"
+
+	case "reload-config":
+		newConfig, err := loadConfig()
+		if err != nil {
+			reply.OK = false
+			reply.Message = fmt.Sprintf("config not reloaded: %v", err)
+			break
+		}
+		activeConfig.Store(newConfig)
+		currentLogLevel.Store(int32(parseLogLevel(newConfig.LogLevel)))
+		configureSlackLimiter(newConfig)
+		logInfo("Configuration reloaded")
+		reply.Message = "configuration reloaded; Redis and channel subscriptions require a restart to change"
+
+	case "drain":
+		select {
+		case drainRequested <- struct{}{}:
+		default:
+		}
+		reply.Message = "draining: finishing in-flight work and shutting down"
+
+	case "dump-state":
+		state, err := dumpState(ctx, redisClient, config)
+		if err != nil {
+			reply.OK = false
+			reply.Message = err.Error()
+			break
+		}
+		reply.Message = "state dumped"
+		reply.State = state
+
+	default:
+		reply.OK = false
+		reply.Message = fmt.Sprintf("unknown command %q", cmd.Command)
+	}
+
+	replyChannel := cmd.ReplyChannel
+	if replyChannel == "" {
+		replyChannel = config.AdminReplyChannel
+	}
+	return publishAdminReply(ctx, redisClient, replyChannel, reply)
+}
+
+func dumpState(ctx context.Context, redisClient *redis.Client, config *Config) (*AdminState, error) {
+	paused, reason, err := pauseState(ctx, redisClient, config)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &AdminState{
+		Paused:      paused,
+		PauseReason: reason,
+		StartedAt:   startedAt,
+		Timezone:    config.Timezone.String(),
+		Config:      config,
+	}
+
+	if until, blocked := config.frozenUntil(time.Now().In(config.Timezone)); blocked {
+		state.BlackoutUntil = &until
+	}
+
+	queued, err := readPoppitQueueLength(ctx, redisClient, config)
+	if err != nil {
+		return nil, err
+	}
+	state.PoppitQueueLength = queued
+
+	deferred, err := redisClient.ZCard(ctx, config.DeferredQueue).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read length of %s: %w", config.DeferredQueue, err)
+	}
+	state.DeferredMerges = deferred
+
+	return state, nil
+}
+
+func publishAdminReply(ctx context.Context, redisClient *redis.Client, channel string, reply AdminReply) error {
+	replyJSON, err := json.Marshal(reply)
+	if err != nil {
+		return fmt.Errorf("failed to marshal admin reply: %w", err)
+	}
+
+	if err := replyNotifier(redisClient).Publish(ctx, channel, string(replyJSON)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", channel, err)
+	}
+	return nil
+}
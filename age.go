@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// checkMessageAge ignores a reaction on a message older than the repository's max_message_age, since whatever the
+// message says about the PR is likely stale. With MESSAGE_AGE_REPLY the thread is pointed at the PR instead.
+func checkMessageAge(config *Config, metadata *PRMetadata, timestamp string, now time.Time) (Decision, bool) {
+	maxAge := config.repoSettings(metadata.Repository).MaxMessageAge
+	if maxAge <= 0 || timestamp == "" {
+		return Decision{}, false
+	}
+	seconds, err := strconv.ParseFloat(timestamp, 64)
+	if err != nil {
+		logWarning("Invalid message timestamp %q, not checking its age: %v", timestamp, err)
+		return Decision{}, false
+	}
+	age := now.Sub(time.Unix(int64(seconds), 0))
+	if age <= time.Duration(maxAge)*time.Second {
+		return Decision{}, false
+	}
+
+	logInfo("Message %s about PR %d in %s is %s old, ignoring", timestamp, metadata.PRNumber, metadata.Repository, formatWindow(age.Truncate(time.Hour)))
+	decision := Decision{
+		Outcome: OutcomeIgnored,
+		Reason:  fmt.Sprintf("message older than %s", formatWindow(time.Duration(maxAge)*time.Second)),
+	}
+	if config.MessageAgeReply {
+		pr := metadata.PRURL
+		if pr == "" {
+			pr = fmt.Sprintf("PR #%d in %s", metadata.PRNumber, metadata.Repository)
+		}
+		decision.Note = fmt.Sprintf(":calendar: This message is too old to act on, and PR #%d has likely changed since. Please check it on GitHub: %s", metadata.PRNumber, pr)
+	}
+	return decision, true
+}
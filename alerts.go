@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"golang.org/x/time/rate"
+)
+
+// opsErrorLimiter bounds error alerts so a failure repeated on every event doesn't flood OPS_ALERT_CHANNEL
+var opsErrorLimiter = rate.NewLimiter(rate.Every(6*time.Second), 10)
+
+// opsErrors holds error alerts until processOpsErrors posts them, so logging never waits on Slack
+var opsErrors = make(chan string, 100)
+
+// opsErrorsRunning is set while processOpsErrors is posting error alerts
+var opsErrorsRunning atomic.Bool
+
+// alertOps posts a message to OPS_ALERT_CHANNEL with the SLACK_BOT_TOKEN bot, logging rather than failing on error
+func alertOps(ctx context.Context, clients *slackClients, config *Config, text string) {
+	if config.OpsAlertChannel == "" {
+		return
+	}
+
+	slackClient := clients.forWorkspace(config.workspace(""))
+	if slackClient == nil {
+		logWarning("Can't post ops alert without SLACK_BOT_TOKEN: %s", text)
+		return
+	}
+
+	err := callSlack(ctx, "chat.postMessage", func() error {
+		_, _, err := slackClient.PostMessageContext(ctx, config.OpsAlertChannel, slack.MsgOptionText(text, false))
+		return err
+	})
+	if err != nil {
+		logWarning("Failed to post ops alert to %s: %v", config.OpsAlertChannel, err)
+	}
+}
+
+// alertOpsError queues an error alert for OPS_ALERT_CHANNEL when OPS_ERROR_ALERTS is on. Alerts over the rate
+// limit, or while the queue is full, are dropped and counted in ops_alerts_dropped.
+func alertOpsError(text string) {
+	if !opsErrorsRunning.Load() {
+		return
+	}
+	if !opsErrorLimiter.Allow() {
+		metrics.Add("ops_alerts_dropped", 1)
+		return
+	}
+	select {
+	case opsErrors <- text:
+	default:
+		metrics.Add("ops_alerts_dropped", 1)
+	}
+}
+
+// alertOpsFailure reports a failed event or merge from its audit entry, with a link to its Slack message
+func alertOpsFailure(entry AuditEntry) {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":x: VibeMerge `%s` failed", entry.Source)
+	if entry.Repository != "" {
+		fmt.Fprintf(&b, " for %s", entry.Repository)
+		if entry.PRNumber != 0 {
+			fmt.Fprintf(&b, "#%d", entry.PRNumber)
+		}
+	}
+	if entry.User != "" {
+		fmt.Fprintf(&b, " requested by <@%s>", entry.User)
+	}
+	fmt.Fprintf(&b, ": %s", entry.Reason)
+	if entry.CorrelationID != "" {
+		fmt.Fprintf(&b, "\nCorrelation ID: `%s`", entry.CorrelationID)
+	}
+	if entry.Channel != "" && entry.Ts != "" {
+		fmt.Fprintf(&b, "\n<%s|Original message>", messageLink(entry.Channel, entry.Ts))
+	}
+	alertOpsError(b.String())
+}
+
+// messageLink is the permalink of a Slack message, which Slack redirects to the viewer's workspace
+func messageLink(channel, ts string) string {
+	return fmt.Sprintf("https://slack.com/archives/%s/p%s", channel, strings.ReplaceAll(ts, ".", ""))
+}
+
+// processOpsErrors posts the queued error alerts to OPS_ALERT_CHANNEL while OPS_ERROR_ALERTS is on
+func processOpsErrors(ctx context.Context, clients *slackClients) {
+	config := currentConfig()
+	if !flagEnabled(FlagOpsErrorAlerts, config.OpsErrorAlerts) || config.OpsAlertChannel == "" {
+		return
+	}
+	opsErrorsRunning.Store(true)
+	defer opsErrorsRunning.Store(false)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case text := <-opsErrors:
+			alertCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			alertOps(alertCtx, clients, currentConfig(), text)
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxAPIEvents bounds the number of audit entries GET /api/events returns
+const maxAPIEvents = 1000
+
+// APIPendingMerge is a merge VibeMerge has accepted that Poppit hasn't reported on yet
+type APIPendingMerge struct {
+	// State is queued (in the Poppit queue), running (holding its repository's merge lock), waiting (behind
+	// another merge in the repository), deferred (held back by a blackout window or rate limit) or scheduled (for a
+	// time requested in the PR message's thread)
+	State         string     `json:"state"`
+	CorrelationID string     `json:"correlation_id"`
+	Repository    string     `json:"repository"`
+	PRNumber      int        `json:"pr_number,omitempty"`
+	RequestedBy   string     `json:"requested_by,omitempty"`
+	GitHubUser    string     `json:"github_user,omitempty"`
+	ReleaseAt     *time.Time `json:"release_at,omitempty"`
+}
+
+// APIMergeRequest is the body of POST /api/merge
+type APIMergeRequest struct {
+	PRURL string `json:"pr_url"`
+	// User is the Slack user ID the merge is requested for, checked against AUTHORIZED_USERS
+	User   string `json:"user"`
+	TeamID string `json:"team_id,omitempty"`
+}
+
+// APIMergeResponse reports what was decided for a merge requested through the API
+type APIMergeResponse struct {
+	Decision      string `json:"decision"`
+	Reason        string `json:"reason,omitempty"`
+	CorrelationID string `json:"correlation_id"`
+	Repository    string `json:"repository"`
+	PRNumber      int    `json:"pr_number"`
+}
+
+// APIPauseRequest is the body of POST /api/pause
+type APIPauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// registerAPI adds the REST API endpoints to the HTTP listener
+func registerAPI(redisClient *redis.Client, clients *slackClients) {
+	http.Handle("GET /api/events", apiAuth(apiEventsHandler(redisClient)))
+	http.Handle("GET /api/pending", apiAuth(apiPendingHandler(redisClient)))
+	http.Handle("POST /api/merge", apiAuth(apiMergeHandler(redisClient, clients)))
+	http.Handle("POST /api/pause", apiAuth(apiPauseHandler(redisClient)))
+	http.Handle("POST /api/resume", apiAuth(apiResumeHandler(redisClient)))
+}
+
+// apiAuth rejects requests without API_TOKEN as a bearer token
+func apiAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		expected := currentConfig().APIToken
+		if !ok || expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			metrics.Add("api_unauthorized", 1)
+			writeAPIError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiEventsHandler lists the latest audit entries, newest first, e.g. GET /api/events?limit=50&repo=owner/name
+func apiEventsHandler(redisClient *redis.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := currentConfig()
+		query := r.URL.Query()
+		limit := 50
+		if value := query.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxAPIEvents {
+				writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAPIEvents))
+				return
+			}
+			limit = n
+		}
+		repo := query.Get("repo")
+		decision := query.Get("decision")
+
+		// Filtered entries are read in pages until enough match or the stream is exhausted
+		events := make([]AuditEntry, 0, limit)
+		end := "+"
+		for len(events) < limit {
+			messages, err := redisClient.XRevRangeN(r.Context(), config.AuditStream, end, "-", int64(limit)).Result()
+			if err != nil {
+				logError("Error reading %s for the API: %v", config.AuditStream, err)
+				writeAPIError(w, http.StatusInternalServerError, "failed to read the audit log")
+				return
+			}
+			for _, msg := range messages {
+				entry := parseAuditEntry(msg)
+				if (repo == "" || entry.Repository == repo) && (decision == "" || entry.Decision == decision) && len(events) < limit {
+					events = append(events, entry)
+				}
+			}
+			if len(messages) < limit {
+				break
+			}
+			end = "(" + messages[len(messages)-1].ID
+		}
+		writeAPIJSON(w, http.StatusOK, events)
+	})
+}
+
+// apiPendingHandler lists the merges Poppit hasn't reported on: queued, running, waiting, deferred and scheduled
+func apiPendingHandler(redisClient *redis.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pending, err := listPendingMerges(r.Context(), redisClient, currentConfig())
+		if err != nil {
+			logError("Error listing pending merges for the API: %v", err)
+			writeAPIError(w, http.StatusInternalServerError, "failed to list pending merges")
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, pending)
+	})
+}
+
+// listPendingMerges reads the Poppit queues, the deferred queue and the repository merge locks and queues
+func listPendingMerges(ctx context.Context, redisClient *redis.Client, config *Config) ([]APIPendingMerge, error) {
+	pending := []APIPendingMerge{}
+
+	queued, err := readPoppitQueues(ctx, redisClient, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range queued {
+		var payload PoppitPayload
+		if err := json.Unmarshal([]byte(entry), &payload); err != nil || payload.CorrelationID == "" {
+			continue
+		}
+		pending = append(pending, APIPendingMerge{
+			State:         "queued",
+			CorrelationID: payload.CorrelationID,
+			Repository:    payload.Repo,
+			GitHubUser:    payload.GitHubUser,
+		})
+	}
+
+	if config.serializeMerges() {
+		prefix := config.RepoLockPrefix + ":"
+		iter := redisClient.Scan(ctx, 0, prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			correlationID, err := redisClient.Get(ctx, iter.Val()).Result()
+			if err != nil {
+				continue
+			}
+			pending = append(pending, APIPendingMerge{
+				State:         "running",
+				CorrelationID: correlationID,
+				Repository:    strings.TrimPrefix(iter.Val(), prefix),
+			})
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to scan merge locks: %w", err)
+		}
+
+		prefix = config.RepoQueuePrefix + ":"
+		iter = redisClient.Scan(ctx, 0, prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			waiting, err := redisClient.LRange(ctx, iter.Val(), 0, -1).Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", iter.Val(), err)
+			}
+			for _, entry := range waiting {
+				var job MergeJob
+				if err := json.Unmarshal([]byte(entry), &job); err != nil {
+					continue
+				}
+				pending = append(pending, pendingJob("waiting", job, nil))
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to scan merge queues: %w", err)
+		}
+	}
+
+	deferred, err := redisClient.ZRangeWithScores(ctx, config.DeferredQueue, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", config.DeferredQueue, err)
+	}
+	for _, entry := range deferred {
+		var job MergeJob
+		member, _ := entry.Member.(string)
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			continue
+		}
+		releaseAt := time.Unix(int64(entry.Score), 0).UTC()
+		pending = append(pending, pendingJob("deferred", job, &releaseAt))
+	}
+
+	scheduled, err := redisClient.ZRangeWithScores(ctx, config.ScheduledQueue, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", config.ScheduledQueue, err)
+	}
+	for _, entry := range scheduled {
+		var job MergeJob
+		member, _ := entry.Member.(string)
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			continue
+		}
+		releaseAt := time.Unix(int64(entry.Score), 0).UTC()
+		pending = append(pending, pendingJob("scheduled", job, &releaseAt))
+	}
+	return pending, nil
+}
+
+func pendingJob(state string, job MergeJob, releaseAt *time.Time) APIPendingMerge {
+	return APIPendingMerge{
+		State:         state,
+		CorrelationID: job.Payload.CorrelationID,
+		Repository:    job.Payload.Repo,
+		PRNumber:      job.PRNumber,
+		RequestedBy:   job.RequestedBy,
+		GitHubUser:    job.Payload.GitHubUser,
+		ReleaseAt:     releaseAt,
+	}
+}
+
+// apiMergeHandler requests a merge of a PR by URL for a Slack user, going through the same checks as a reaction
+func apiMergeHandler(redisClient *redis.Client, clients *slackClients) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request APIMergeRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		if request.User == "" {
+			writeAPIError(w, http.StatusBadRequest, "user is required")
+			return
+		}
+
+		config := currentConfig()
+		metadata, err := parsePRURL(request.PRURL, config)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx, cancel := eventContext(r.Context(), config)
+		defer cancel()
+		decision, job, err := requestManualMerge(ctx, redisClient, clients, config, metadata, "api", request.TeamID, request.User, "")
+		if err != nil {
+			logError("Error requesting merge of %s through the API: %v", request.PRURL, err)
+			writeAPIError(w, http.StatusInternalServerError, "failed to request the merge")
+			return
+		}
+		logInfo("Merge of %s#%d requested through the API for %s: %s", metadata.Repository, metadata.PRNumber, request.User, decision.Outcome)
+		writeAPIJSON(w, http.StatusOK, APIMergeResponse{
+			Decision:      decision.Outcome,
+			Reason:        decision.Reason,
+			CorrelationID: job.Payload.CorrelationID,
+			Repository:    metadata.Repository,
+			PRNumber:      metadata.PRNumber,
+		})
+	})
+}
+
+// apiPauseHandler pauses merging, with an optional reason in the body
+func apiPauseHandler(redisClient *redis.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request APIPauseRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+				return
+			}
+		}
+		reason := "paused through the API"
+		if request.Reason != "" {
+			reason = fmt.Sprintf("%s: %s", reason, request.Reason)
+		}
+
+		config := currentConfig()
+		if err := pauseMerging(r.Context(), redisClient, config, reason); err != nil {
+			logError("Error pausing merging through the API: %v", err)
+			writeAPIError(w, http.StatusInternalServerError, "failed to pause merging")
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, map[string]any{"paused": true, "reason": reason})
+	})
+}
+
+// apiResumeHandler resumes merging
+func apiResumeHandler(redisClient *redis.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := resumeMerging(r.Context(), redisClient, currentConfig()); err != nil {
+			logError("Error resuming merging through the API: %v", err)
+			writeAPIError(w, http.StatusInternalServerError, "failed to resume merging")
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, map[string]any{"paused": false})
+	})
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, map[string]string{"error": message})
+}
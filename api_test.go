@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"missing header", "s3cret", "", http.StatusUnauthorized},
+		{"not a bearer token", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"empty token accepts nothing", "", "Bearer ", http.StatusUnauthorized},
+	}
+
+	previous := activeConfig.Load()
+	defer activeConfig.Store(previous)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activeConfig.Store(&Config{APIToken: tt.token})
+			handler := apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
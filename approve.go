@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// checkApprover denies an approval that can't be attributed to the requester's GitHub account, or that would have
+// the PR's author approve their own PR, which GitHub refuses
+func checkApprover(job MergeJob) (Decision, bool) {
+	if job.Payload.GitHubUser == "" {
+		return Decision{
+			Outcome: OutcomeDenied,
+			Reason:  "approval: requester has no GitHub identity mapping",
+			Note: fmt.Sprintf(":wave: Sorry <@%s>, I couldn't work out your GitHub account, so PR #%d was not approved. Ask an admin to add you to the identity mapping.",
+				job.RequestedBy, job.PRNumber),
+		}, true
+	}
+	if strings.EqualFold(job.Payload.GitHubUser, job.Author) {
+		return Decision{
+			Outcome: OutcomeDenied,
+			Reason:  "approval: requester is the PR author",
+			Note:    fmt.Sprintf(":wave: Sorry <@%s>, GitHub doesn't let authors approve their own PRs, so PR #%d was not approved.", job.RequestedBy, job.PRNumber),
+		}, true
+	}
+	return Decision{}, false
+}
+
+// submitApprove hands Poppit the commands that approve a PR on behalf of the requester's GitHub account
+func submitApprove(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, error) {
+	return submitPRCommand(ctx, redisClient, config, job, "approval", true, checkApprover)
+}
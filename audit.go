@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AuditEntry records a single merge decision
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	EventTime  time.Time `json:"event_time"`
+	Source     string    `json:"source"`
+	User       string    `json:"user"`
+	GitHubUser string    `json:"github_user,omitempty"`
+	TeamID     string    `json:"team_id,omitempty"`
+	Reaction   string    `json:"reaction,omitempty"`
+	Channel    string    `json:"channel"`
+	Ts         string    `json:"ts"`
+	Repository string    `json:"repository"`
+	PRNumber   int       `json:"pr_number"`
+	Decision   string    `json:"decision"`
+	Reason     string    `json:"reason"`
+	// CorrelationID matches the correlation_id of the Poppit payload, when one was built
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// LatencySeconds is the time from the reaction to the merge, on merged entries
+	LatencySeconds float64 `json:"latency_seconds,omitempty"`
+}
+
+// finish fills in the decision from the handler's outcome and writes the entry to the audit log
+func (entry *AuditEntry) finish(ctx context.Context, redisClient *redis.Client, config *Config, decision Decision, err error) {
+	entry.Time = time.Now().UTC()
+	entry.Decision = decision.Outcome
+	entry.Reason = decision.Reason
+	if err != nil {
+		entry.Decision = OutcomeFailed
+		entry.Reason = err.Error()
+		reportFailure(*entry, err)
+	}
+
+	if err := recordAudit(ctx, redisClient, config, *entry); err != nil {
+		logError("Failed to write audit entry for %s#%d: %v", entry.Repository, entry.PRNumber, err)
+	}
+}
+
+// auditLookback bounds how many of the latest audit entries findAuditEntry searches
+const auditLookback = 1000
+
+// findAuditEntry returns the latest audit entry with the given correlation ID, searching the latest auditLookback
+// entries
+func findAuditEntry(ctx context.Context, redisClient *redis.Client, config *Config, correlationID string) (AuditEntry, bool, error) {
+	messages, err := redisClient.XRevRangeN(ctx, config.AuditStream, "+", "-", auditLookback).Result()
+	if err != nil {
+		return AuditEntry{}, false, fmt.Errorf("failed to read %s: %w", config.AuditStream, err)
+	}
+	for _, msg := range messages {
+		if entry := parseAuditEntry(msg); entry.CorrelationID == correlationID {
+			return entry, true, nil
+		}
+	}
+	return AuditEntry{}, false, nil
+}
+
+// recordAudit appends an entry to the audit stream and, when configured, the audit log file
+func recordAudit(ctx context.Context, redisClient *redis.Client, config *Config, entry AuditEntry) error {
+	if entry.Decision == OutcomeFailed {
+		alertOpsFailure(entry)
+	}
+
+	// Write to the durable store first, so the decision is kept even when Redis is unavailable
+	if store != nil {
+		if err := store.RecordDecision(ctx, entry); err != nil {
+			logError("Error writing to the durable store: %v", err)
+		}
+	}
+
+	values := map[string]interface{}{
+		"time":           entry.Time.Format(time.RFC3339Nano),
+		"event_time":     entry.EventTime.Format(time.RFC3339Nano),
+		"source":         entry.Source,
+		"user":           entry.User,
+		"github_user":    entry.GitHubUser,
+		"team_id":        entry.TeamID,
+		"reaction":       entry.Reaction,
+		"channel":        entry.Channel,
+		"ts":             entry.Ts,
+		"repository":     entry.Repository,
+		"pr_number":      entry.PRNumber,
+		"decision":       entry.Decision,
+		"reason":         entry.Reason,
+		"correlation_id": entry.CorrelationID,
+	}
+	if entry.LatencySeconds > 0 {
+		values["latency_seconds"] = strconv.FormatFloat(entry.LatencySeconds, 'f', 3, 64)
+	}
+	args := &redis.XAddArgs{Stream: config.AuditStream, Values: values}
+	if config.AuditMaxLength > 0 {
+		args.MaxLen = int64(config.AuditMaxLength)
+		args.Approx = true
+	}
+	if err := redisClient.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("failed to add to %s: %w", config.AuditStream, err)
+	}
+
+	if config.AuditLogFile == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	file, err := os.OpenFile(config.AuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log file: %w", err)
+	}
+	return nil
+}
+
+// parseAuditEntry converts a stream message back into an AuditEntry
+func parseAuditEntry(msg redis.XMessage) AuditEntry {
+	field := func(name string) string {
+		value, _ := msg.Values[name].(string)
+		return value
+	}
+
+	entry := AuditEntry{
+		Source:        field("source"),
+		User:          field("user"),
+		GitHubUser:    field("github_user"),
+		TeamID:        field("team_id"),
+		Reaction:      field("reaction"),
+		Channel:       field("channel"),
+		Ts:            field("ts"),
+		Repository:    field("repository"),
+		Decision:      field("decision"),
+		Reason:        field("reason"),
+		CorrelationID: field("correlation_id"),
+	}
+	entry.Time, _ = time.Parse(time.RFC3339Nano, field("time"))
+	entry.EventTime, _ = time.Parse(time.RFC3339Nano, field("event_time"))
+	entry.PRNumber, _ = strconv.Atoi(field("pr_number"))
+	entry.LatencySeconds, _ = strconv.ParseFloat(field("latency_seconds"), 64)
+	return entry
+}
+
+// runAuditCommand implements `vibemerge audit`, printing matching entries as JSON lines
+func runAuditCommand(args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	repo := flags.String("repo", "", "only show entries for this repository (owner/name)")
+	since := flags.String("since", "", "only show entries at or after this time (RFC3339 or YYYY-MM-DD)")
+	until := flags.String("until", "", "only show entries before this time (RFC3339 or YYYY-MM-DD)")
+	flags.Parse(args)
+
+	start := "-"
+	if *since != "" {
+		t, err := parseAuditTime(*since)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+		start = strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	end := "+"
+	if *until != "" {
+		t, err := parseAuditTime(*until)
+		if err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+		// Exclusive end: the stream IDs are millisecond timestamps
+		end = "(" + strconv.FormatInt(t.UnixMilli(), 10)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	redisClient := newRedisClient(config)
+	defer redisClient.Close()
+
+	messages, err := redisClient.XRange(ctx, config.AuditStream, start, end).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.AuditStream, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, msg := range messages {
+		entry := parseAuditEntry(msg)
+		if *repo != "" && entry.Repository != *repo {
+			continue
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// loadAWSConfig loads AWS_REGION and the AWS SDK's credential chain: static keys from the environment, shared
+// config and profiles, a web identity token such as the one EKS projects for IAM roles for service accounts, and
+// container and instance roles. Temporary credentials are cached and refreshed before they expire.
+func loadAWSConfig(ctx context.Context, config *Config, optFns ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
+	optFns = append([]func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(config.AWSRegion)}, optFns...)
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return awsConfig, nil
+}
+
+// awsSecrets reads secrets from an AWS Secrets Manager secret holding a JSON object, whose keys are the
+// environment variable names they replace
+type awsSecrets struct {
+	secretID string
+	client   *secretsmanager.Client
+}
+
+func newAWSSecrets(config *Config) (*awsSecrets, error) {
+	if config.AWSRegion == "" || config.AWSSecretID == "" {
+		return nil, fmt.Errorf("SECRETS_PROVIDER=%s requires AWS_REGION and AWS_SECRET_ID", SecretsProviderAWS)
+	}
+	awsConfig, err := loadAWSConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	return &awsSecrets{
+		secretID: config.AWSSecretID,
+		client:   secretsmanager.NewFromConfig(awsConfig),
+	}, nil
+}
+
+// Secrets reads the current version of the secret with GetSecretValue
+func (a *awsSecrets) Secrets(ctx context.Context) (map[string]string, error) {
+	output, err := a.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(a.secretID)})
+	if err != nil {
+		return nil, fmt.Errorf("GetSecretValue for %s failed: %w", a.secretID, err)
+	}
+	return parseSecretJSON([]byte(aws.ToString(output.SecretString)), a.secretID)
+}
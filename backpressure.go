@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// What POPPIT_BACKPRESSURE_MODE does with a merge while the Poppit queue is backed up
+const (
+	BackpressureModeReject = "reject"
+	BackpressureModeDefer  = "defer"
+)
+
+// checkBackpressure rejects an unknown POPPIT_BACKPRESSURE_MODE and a POPPIT_BACKPRESSURE_DELAY that would retry a
+// deferred merge straight away
+func (c *Config) checkBackpressure() error {
+	if c.PoppitBackpressureMode != BackpressureModeReject && c.PoppitBackpressureMode != BackpressureModeDefer {
+		return fmt.Errorf("POPPIT_BACKPRESSURE_MODE must be %q or %q, got %q", BackpressureModeReject, BackpressureModeDefer, c.PoppitBackpressureMode)
+	}
+	if c.PoppitBackpressureDelay <= 0 {
+		return fmt.Errorf("POPPIT_BACKPRESSURE_DELAY must be positive, got %d", c.PoppitBackpressureDelay)
+	}
+	return nil
+}
+
+// poppitBackedUp reports whether the Poppit queue a merge would be pushed to holds at least POPPIT_MAX_QUEUE_LENGTH
+// commands, along with the queue and its length
+func poppitBackedUp(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (string, int64, bool, error) {
+	if config.PoppitMaxQueueLength <= 0 {
+		return "", 0, false, nil
+	}
+	queue, _ := config.mergeQueue(job)
+	length, err := redisClient.LLen(ctx, queue).Result()
+	if err != nil {
+		return "", 0, false, fmt.Errorf("failed to read the length of %s: %w", queue, err)
+	}
+	return queue, length, length >= int64(config.PoppitMaxQueueLength), nil
+}
+
+// holdForBackpressure defers or rejects a merge while the Poppit queue it would be pushed to is backed up
+func holdForBackpressure(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, queue string, length int64) (Decision, error) {
+	metrics.Add("poppit_backpressure", 1)
+
+	if config.PoppitBackpressureMode == BackpressureModeDefer {
+		until := time.Now().Add(time.Duration(config.PoppitBackpressureDelay) * time.Second)
+		if err := deferMerge(ctx, redisClient, config, job, until); err != nil {
+			return Decision{}, err
+		}
+		resume := until.In(config.Timezone).Format("15:04 MST")
+		logInfo("Deferred merge of PR %d in %s until %s (%d commands in %s)", job.PRNumber, job.Payload.Repo, resume, length, queue)
+		return Decision{
+			Outcome: OutcomeDeferred,
+			Reason:  fmt.Sprintf("poppit queue backed up with %d commands", length),
+			Note:    fmt.Sprintf(":construction: The merge backend is backed up with %d commands waiting. PR #%d will be queued once it catches up, from %s.", length, job.PRNumber, resume),
+		}, nil
+	}
+
+	logInfo("Rejected merge of PR %d in %s, %d commands in %s", job.PRNumber, job.Payload.Repo, length, queue)
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  fmt.Sprintf("poppit queue backed up with %d commands", length),
+		Note:    fmt.Sprintf(":construction: The merge backend is backed up with %d commands waiting, so PR #%d was not queued. Please react again later.", length, job.PRNumber),
+	}, nil
+}
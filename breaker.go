@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Slack circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// errSlackCircuitOpen is returned for Slack calls made while the circuit breaker is open
+var errSlackCircuitOpen = errors.New("slack circuit breaker is open")
+
+// slackBreakerState is the breaker's state, published under "slack_breaker_state" in the metrics
+var slackBreakerState = new(expvar.String)
+
+func init() {
+	slackBreakerState.Set(BreakerClosed)
+	metrics.Set("slack_breaker_state", slackBreakerState)
+}
+
+// slackBreaker stops Slack calls for SLACK_BREAKER_COOLDOWN seconds after SLACK_BREAKER_THRESHOLD consecutive
+// failures, then lets a single call through to probe whether Slack has recovered
+var slackBreaker = &circuitBreaker{state: BreakerClosed}
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	// alert, when set, is called in its own goroutine when the breaker opens or closes
+	alert func(text string)
+}
+
+// bypassBreakerKey marks a context whose Slack calls skip the breaker, such as the alerts about the breaker itself
+type bypassBreakerKey struct{}
+
+// allow reports whether a Slack call may be made, moving an open breaker to half-open once its cooldown is over.
+// Only one probe is let through while half-open.
+func (b *circuitBreaker) allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < cooldown {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		return true
+	case BreakerHalfOpen:
+		return false
+	}
+	return true
+}
+
+// record counts the result of a Slack call, opening the breaker after threshold consecutive outages and
+// closing it again after a successful probe. A threshold of 0 never opens it.
+func (b *circuitBreaker) record(ctx context.Context, err error, threshold int) {
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// The event ran out of time, which says nothing about Slack, so a probe is simply retried later
+		if b.state == BreakerHalfOpen {
+			b.setState(BreakerOpen)
+		}
+	case isSlackOutage(err):
+		b.failures++
+		if b.state == BreakerHalfOpen || (threshold > 0 && b.failures >= threshold) {
+			b.openedAt = time.Now()
+			b.setState(BreakerOpen)
+		}
+	default:
+		b.failures = 0
+		b.setState(BreakerClosed)
+	}
+	to, failures, alert := b.state, b.failures, b.alert
+	b.mu.Unlock()
+
+	var text string
+	switch {
+	case from == BreakerClosed && to == BreakerOpen:
+		metrics.Add("slack_breaker_opened", 1)
+		logError("Slack circuit breaker opened after %d consecutive failures: %v", failures, err)
+		text = fmt.Sprintf(":rotating_light: VibeMerge stopped calling Slack after %d consecutive failures (%v). Reactions are parked until a probe succeeds.", failures, err)
+	case from != BreakerClosed && to == BreakerClosed:
+		logInfo("Slack circuit breaker closed, Slack calls succeed again")
+		text = ":white_check_mark: VibeMerge is calling Slack again and handling parked reactions."
+	}
+	if text != "" && alert != nil {
+		go alert(text)
+	}
+}
+
+// setState must be called with the lock held
+func (b *circuitBreaker) setState(state string) {
+	b.state = state
+	slackBreakerState.Set(state)
+}
+
+// isOpen reports whether Slack calls are currently being refused, not counting a breaker due for a probe
+func (b *circuitBreaker) isOpen(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == BreakerHalfOpen || (b.state == BreakerOpen && time.Since(b.openedAt) < cooldown)
+}
+
+// isSlackOutage reports whether a Slack call failed because of Slack itself: rate limiting that outlasted the
+// retries, server errors or no response at all. Errors Slack answered with, such as channel_not_found, are not.
+func isSlackOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		return false
+	}
+	var statusErr slack.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500
+	}
+	return true
+}
+
+// parkReaction adds a reaction event that couldn't be handled while the breaker was open to DEAD_LETTER_QUEUE
+func parkReaction(ctx context.Context, redisClient *redis.Client, config *Config, payload string) {
+	if err := redisClient.RPush(ctx, config.DeadLetterQueue, payload).Err(); err != nil {
+		logError("Error parking reaction in %s, it is lost: %v", config.DeadLetterQueue, err)
+		return
+	}
+	metrics.Add("reactions_parked", 1)
+	logWarning("Slack circuit breaker is open, parked reaction in %s", config.DeadLetterQueue)
+	sendDeadLetterWebhooks(config, payload, "Slack circuit breaker open")
+}
+
+// processParkedReactions handles the reactions parked in DEAD_LETTER_QUEUE once the breaker lets Slack calls
+// through again. The first of them is the probe for a breaker whose cooldown is over.
+func processParkedReactions(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	ticker := time.NewTicker(time.Duration(currentConfig().SlackBreakerCooldown) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		config := currentConfig()
+		parked, err := redisClient.LLen(ctx, config.DeadLetterQueue).Result()
+		if err != nil {
+			logError("Error reading %s: %v", config.DeadLetterQueue, err)
+			continue
+		}
+		// Only the reactions parked so far, so one parked again isn't picked straight back up
+		for range parked {
+			if ctx.Err() != nil || slackBreaker.isOpen(time.Duration(config.SlackBreakerCooldown)*time.Second) {
+				break
+			}
+			payload, err := redisClient.LPop(ctx, config.DeadLetterQueue).Result()
+			if errors.Is(err, redis.Nil) {
+				break
+			}
+			if err != nil {
+				logError("Error reading %s: %v", config.DeadLetterQueue, err)
+				break
+			}
+			metrics.Add("reactions_unparked", 1)
+			handleReaction(ctx, payload, redisClient, clients)
+		}
+	}
+}
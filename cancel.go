@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PendingMerge tracks a merge that has been handed off but not yet executed, so it can be cancelled
+type PendingMerge struct {
+	CorrelationID string `json:"correlation_id"`
+	Repository    string `json:"repository"`
+	PRNumber      int    `json:"pr_number"`
+	RequestedBy   string `json:"requested_by"`
+	// QueuedPayload is the exact entry pushed to the Poppit queue, if queued
+	QueuedPayload string `json:"queued_payload,omitempty"`
+	// Queue is the Poppit queue of the merge's priority it was pushed to, empty for POPPIT_QUEUE
+	Queue string `json:"queue,omitempty"`
+	// PollMember is the exact member added to the merge poll queue alongside QueuedPayload, with MERGE_POLL
+	PollMember string `json:"poll_member,omitempty"`
+	// DeferredMember is the exact member added to the deferred queue, if deferred
+	DeferredMember string `json:"deferred_member,omitempty"`
+	// WaitingMember is the exact entry parked behind another merge in the same repository, if waiting
+	WaitingMember string `json:"waiting_member,omitempty"`
+	// UpdatingMember is the exact member added to the branch update queue, if its branch is being updated
+	UpdatingMember string `json:"updating_member,omitempty"`
+	// ScheduledMember is the exact member added to the scheduled queue, if scheduled
+	ScheduledMember string    `json:"scheduled_member,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TimeBombCancel asks TimeBomb to forget a previously requested TTL
+type TimeBombCancel struct {
+	Channel string `json:"channel"`
+	Ts      string `json:"ts"`
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func pendingKey(config *Config, channel, timestamp string) string {
+	return fmt.Sprintf("%s:%s:%s", config.PendingKeyPrefix, channel, timestamp)
+}
+
+// queue returns the Poppit queue a queued merge was pushed to
+func (p PendingMerge) queue(config *Config) string {
+	if p.Queue != "" {
+		return p.Queue
+	}
+	return config.PoppitQueue
+}
+
+// trackPendingMerge remembers a queued or deferred merge against its Slack message
+func trackPendingMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, pending PendingMerge) error {
+	// Merges without a message of their own can't be cancelled by reaction
+	if job.Ts == "" || job.Batch {
+		return nil
+	}
+
+	pending.CorrelationID = job.Payload.CorrelationID
+	pending.Repository = job.Payload.Repo
+	pending.PRNumber = job.PRNumber
+	pending.RequestedBy = job.RequestedBy
+	pending.CreatedAt = time.Now().UTC()
+
+	pendingJSON, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending merge: %w", err)
+	}
+
+	key := pendingKey(config, job.Channel, job.Ts)
+	ttl := time.Duration(config.PendingTTL) * time.Second
+	if err := redisClient.Set(ctx, key, string(pendingJSON), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// handleCancelReaction withdraws the pending merge for a message when an authorized user adds the cancel emoji
+func handleCancelReaction(ctx context.Context, redisClient *redis.Client, config *Config, reactionEvent ReactionEvent, audit *AuditEntry) (Decision, error) {
+	channel := reactionEvent.Event.Item.Channel
+	timestamp := reactionEvent.Event.Item.Ts
+	user := reactionEvent.Event.User
+	key := pendingKey(config, channel, timestamp)
+
+	pendingJSON, err := redisClient.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		logDebug("No pending merge for message %s in channel %s, ignoring cancel", timestamp, channel)
+		return Decision{Outcome: OutcomeIgnored, Reason: "no pending merge to cancel"}, nil
+	}
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	var pending PendingMerge
+	if err := json.Unmarshal([]byte(pendingJSON), &pending); err != nil {
+		return Decision{}, fmt.Errorf("failed to unmarshal pending merge: %w", err)
+	}
+	audit.Repository = pending.Repository
+	audit.PRNumber = pending.PRNumber
+	audit.CorrelationID = pending.CorrelationID
+
+	if user != pending.RequestedBy && !config.isAuthorized(user) {
+		logInfo("User %s is not allowed to cancel merge %s", user, pending.CorrelationID)
+		return Decision{Outcome: OutcomeDenied, Reason: "not authorized to cancel"}, nil
+	}
+
+	var removed int64
+	if pending.QueuedPayload != "" {
+		queue := pending.queue(config)
+		removed, err = redisClient.LRem(ctx, queue, 1, pending.QueuedPayload).Result()
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to remove from %s: %w", queue, err)
+		}
+	} else if pending.DeferredMember != "" {
+		removed, err = redisClient.ZRem(ctx, config.DeferredQueue, pending.DeferredMember).Result()
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to remove from %s: %w", config.DeferredQueue, err)
+		}
+	} else if pending.WaitingMember != "" {
+		queueKey := repoQueueKey(config, pending.Repository)
+		removed, err = redisClient.LRem(ctx, queueKey, 1, pending.WaitingMember).Result()
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to remove from %s: %w", queueKey, err)
+		}
+	} else if pending.UpdatingMember != "" {
+		removed, err = redisClient.ZRem(ctx, config.UpdateBranchQueue, pending.UpdatingMember).Result()
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to remove from %s: %w", config.UpdateBranchQueue, err)
+		}
+	} else if pending.ScheduledMember != "" {
+		removed, err = redisClient.ZRem(ctx, config.ScheduledQueue, pending.ScheduledMember).Result()
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to remove from %s: %w", config.ScheduledQueue, err)
+		}
+	}
+
+	if err := redisClient.Del(ctx, key).Err(); err != nil {
+		logWarning("Failed to delete %s: %v", key, err)
+	}
+
+	if removed == 0 {
+		logInfo("Merge %s of PR %d in %s was already picked up, too late to cancel", pending.CorrelationID, pending.PRNumber, pending.Repository)
+		return Decision{
+			Outcome: OutcomeIgnored,
+			Reason:  "merge already picked up",
+			Note:    fmt.Sprintf(":hourglass: Too late, <@%s>: the merge of PR #%d has already been picked up.", user, pending.PRNumber),
+		}, nil
+	}
+
+	if pending.QueuedPayload != "" {
+		if pending.PollMember != "" {
+			if err := redisClient.ZRem(ctx, config.MergePollQueue, pending.PollMember).Err(); err != nil {
+				logWarning("Failed to stop polling cancelled merge %s: %v", pending.CorrelationID, err)
+			}
+		}
+		if _, err := dropCleanup(ctx, redisClient, config, pending.CorrelationID); err != nil {
+			logWarning("Failed to drop held back cleanup of message: %v", err)
+		}
+		if err := publishTimeBombCancel(ctx, redisClient, config, reactionEvent.TeamID, channel, timestamp); err != nil {
+			logWarning("Failed to cancel TTL on message: %v", err)
+		}
+	}
+	if err := releaseCancelledMerge(ctx, redisClient, config, pending); err != nil {
+		logWarning("Failed to release the repository lock of cancelled merge %s: %v", pending.CorrelationID, err)
+	}
+
+	logInfo("Cancelled merge %s of PR %d in %s at the request of %s", pending.CorrelationID, pending.PRNumber, pending.Repository, user)
+	return Decision{
+		Outcome: OutcomeCancelled,
+		Reason:  fmt.Sprintf("cancelled merge %s", pending.CorrelationID),
+		Note:    fmt.Sprintf(":no_entry: <@%s> cancelled the pending merge of PR #%d.", user, pending.PRNumber),
+	}, nil
+}
+
+// releaseCancelledMerge hands the repository's merge lock on to the next waiting merge when the cancelled merge held
+// it, as no Poppit result will arrive to release it
+func releaseCancelledMerge(ctx context.Context, redisClient *redis.Client, config *Config, pending PendingMerge) error {
+	if !config.serializeMerges() {
+		return nil
+	}
+	lockKey := repoLockKey(config, pending.Repository)
+	holder, err := redisClient.Get(ctx, lockKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", lockKey, err)
+	}
+	if holder != pending.CorrelationID {
+		return nil
+	}
+	return releaseRepo(ctx, redisClient, config, pending.Repository, pending.CorrelationID)
+}
+
+func publishTimeBombCancel(ctx context.Context, redisClient *redis.Client, config *Config, teamID, channel, timestamp string) error {
+	msgJSON, err := json.Marshal(TimeBombCancel{Channel: channel, Ts: timestamp})
+	if err != nil {
+		return fmt.Errorf("failed to marshal timebomb cancel message: %w", err)
+	}
+
+	cancelChannel := config.workspace(teamID).TimeBombCancelChannel
+	if err := serviceNotifier(redisClient).Publish(ctx, cancelChannel, string(msgJSON)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", cancelChannel, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCancelReleasesRepoLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		holder   string
+		wantHeld bool
+	}{
+		{"cancelled merge holds the lock", "corr-1", false},
+		{"another merge holds the lock", "corr-2", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer redisClient.Close()
+
+			config := &Config{
+				SerializeMerges:       true,
+				PoppitQueue:           "poppit:commands",
+				PendingKeyPrefix:      "vibemerge:pending",
+				PauseKey:              "vibemerge:paused",
+				RepoLockPrefix:        "vibemerge:repo-lock",
+				RepoQueuePrefix:       "vibemerge:repo-queue",
+				TimeBombPendingKey:    "vibemerge:timebomb-pending",
+				TimeBombCancelChannel: "timebomb-cancel",
+			}
+			ctx := context.Background()
+
+			const payload = `{"repo":"org/repo"}`
+			redisClient.RPush(ctx, config.PoppitQueue, payload)
+			mr.Set(repoLockKey(config, "org/repo"), tt.holder)
+			pendingJSON, err := json.Marshal(PendingMerge{
+				CorrelationID: "corr-1",
+				Repository:    "org/repo",
+				PRNumber:      42,
+				RequestedBy:   "U_REQUESTER",
+				QueuedPayload: payload,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mr.Set(pendingKey(config, "C123", "1.000"), string(pendingJSON))
+
+			var event ReactionEvent
+			event.Event.User = "U_REQUESTER"
+			event.Event.Item.Channel = "C123"
+			event.Event.Item.Ts = "1.000"
+			decision, err := handleCancelReaction(ctx, redisClient, config, event, &AuditEntry{})
+			if err != nil {
+				t.Fatalf("handleCancelReaction() error = %v", err)
+			}
+			if decision.Outcome != OutcomeCancelled {
+				t.Fatalf("outcome = %v, want %v", decision.Outcome, OutcomeCancelled)
+			}
+			if held := mr.Exists(repoLockKey(config, "org/repo")); held != tt.wantHeld {
+				t.Errorf("lock held = %v, want %v", held, tt.wantHeld)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Cleanup modes select what happens to a merged PR's Slack message
+const (
+	// CleanupModeTimeBomb has TimeBomb delete the message after its TTL
+	CleanupModeTimeBomb = "timebomb"
+	// CleanupModeUpdate replaces the message with a one-line summary of the merge
+	CleanupModeUpdate = "update"
+	// CleanupModeDelete deletes the message straight away
+	CleanupModeDelete = "delete"
+)
+
+// MessageCleanup is the cleanup of a merged PR's Slack message. It is held back until the merge is confirmed, so a
+// failed merge doesn't take its message with it. With Status set, it is a merge status to show on the message instead.
+type MessageCleanup struct {
+	TeamID      string `json:"team_id,omitempty"`
+	Repository  string `json:"repository"`
+	PRNumber    int    `json:"pr_number"`
+	RequestedBy string `json:"requested_by,omitempty"`
+	Channel     string `json:"channel"`
+	Ts          string `json:"ts"`
+	// Batch is set for merges from a digest, whose message is shared with other PRs
+	Batch bool `json:"batch,omitempty"`
+	// Merged is set once the merge is confirmed, rather than cleaned up after TIMEBOMB_MAX_DELAY or on queueing
+	Merged bool `json:"merged,omitempty"`
+	// Status and StatusDetail are the merge status shown by MERGE_STATUS_UPDATES, see status.go
+	Status       string `json:"status,omitempty"`
+	StatusDetail string `json:"status_detail,omitempty"`
+}
+
+func newMessageCleanup(job MergeJob) MessageCleanup {
+	return MessageCleanup{
+		TeamID:      job.TeamID,
+		Repository:  job.Payload.Repo,
+		PRNumber:    job.PRNumber,
+		RequestedBy: job.RequestedBy,
+		Channel:     job.Channel,
+		Ts:          job.Ts,
+		Batch:       job.Batch,
+	}
+}
+
+// cleanupMode resolves the cleanup mode for a Slack channel from CLEANUP_CHANNEL_MODES and CLEANUP_MODE
+func (c *Config) cleanupMode(channel string) string {
+	if mode, ok := c.CleanupChannelModes[channel]; ok {
+		return mode
+	}
+	return c.CleanupMode
+}
+
+func validCleanupMode(mode string) bool {
+	return mode == CleanupModeTimeBomb || mode == CleanupModeUpdate || mode == CleanupModeDelete
+}
+
+// parseChannelModes parses comma-separated channel=mode pairs, e.g. "C0123=update,C0456=delete"
+func parseChannelModes(value string) (map[string]string, error) {
+	modes := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		channel, mode, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid CLEANUP_CHANNEL_MODES entry %q, expected channel=mode", part)
+		}
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		if !validCleanupMode(mode) {
+			return nil, fmt.Errorf("invalid CLEANUP_CHANNEL_MODES entry %q, mode must be timebomb, update or delete", part)
+		}
+		modes[strings.TrimSpace(channel)] = mode
+	}
+	return modes, nil
+}
+
+// cleanUpMessage cleans up a merged PR's message in its channel's mode. TimeBomb is published to directly; updates
+// and deletes need a Slack client, so they are queued on CLEANUP_QUEUE for processMessageCleanups. A digest's
+// message is shared with the other PRs in it, so it is always left to TimeBomb.
+func cleanUpMessage(ctx context.Context, redisClient *redis.Client, config *Config, cleanup MessageCleanup) error {
+	if cleanup.Batch || config.cleanupMode(cleanup.Channel) == CleanupModeTimeBomb {
+		return publishTimeBombMessage(ctx, redisClient, config, cleanup.TeamID, cleanup.Repository, cleanup.Channel, cleanup.Ts)
+	}
+	return queueMessageChange(ctx, redisClient, config, cleanup)
+}
+
+// queueMessageChange queues a cleanup or status update for processMessageCleanups. A single queue keeps the changes
+// to a message in order, so a merged status doesn't land after the message was deleted.
+func queueMessageChange(ctx context.Context, redisClient *redis.Client, config *Config, cleanup MessageCleanup) error {
+	cleanupJSON, err := json.Marshal(cleanup)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message cleanup: %w", err)
+	}
+	if err := redisClient.RPush(ctx, config.CleanupQueue, string(cleanupJSON)).Err(); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", config.CleanupQueue, err)
+	}
+	return nil
+}
+
+// processMessageCleanups updates or deletes the messages queued by cleanUpMessage
+func processMessageCleanups(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	ticker := time.NewTicker(time.Duration(currentConfig().DeferredPollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		config := currentConfig()
+		queued, err := redisClient.LLen(ctx, config.CleanupQueue).Result()
+		if err != nil {
+			logError("Error reading %s: %v", config.CleanupQueue, err)
+			continue
+		}
+		// Only the cleanups queued so far, so one put back while Slack is down isn't picked straight back up
+		for range queued {
+			if ctx.Err() != nil {
+				break
+			}
+			cleanupJSON, err := redisClient.LPop(ctx, config.CleanupQueue).Result()
+			if errors.Is(err, redis.Nil) {
+				break
+			}
+			if err != nil {
+				logError("Error reading %s: %v", config.CleanupQueue, err)
+				break
+			}
+			if !runMessageCleanup(context.WithoutCancel(ctx), redisClient, clients, config, cleanupJSON) {
+				break
+			}
+		}
+	}
+}
+
+// runMessageCleanup updates or deletes one queued message, reporting false when Slack is down and the cleanup was
+// put back for later
+func runMessageCleanup(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, cleanupJSON string) bool {
+	var cleanup MessageCleanup
+	if err := json.Unmarshal([]byte(cleanupJSON), &cleanup); err != nil {
+		logError("Error unmarshalling message cleanup: %v", err)
+		return true
+	}
+
+	slackClient := clients.forWorkspace(config.workspace(cleanup.TeamID))
+	if slackClient == nil {
+		logWarning("No bot token for workspace %s, leaving message %s to TimeBomb", cleanup.TeamID, cleanup.Ts)
+		if err := publishTimeBombMessage(ctx, redisClient, config, cleanup.TeamID, cleanup.Repository, cleanup.Channel, cleanup.Ts); err != nil {
+			logWarning("Failed to set TTL on message: %v", err)
+		}
+		return true
+	}
+
+	mode := config.cleanupMode(cleanup.Channel)
+	if cleanup.Status != "" {
+		mode = statusChange
+	}
+	var err error
+	switch mode {
+	case statusChange:
+		err = showMergeStatus(ctx, slackClient, cleanup)
+	case CleanupModeUpdate:
+		// The summary is also sent as the only block, replacing the blocks the message was posted with
+		summary := cleanupSummary(cleanup)
+		err = callSlack(ctx, "chat.update", func() error {
+			_, _, _, err := slackClient.UpdateMessageContext(ctx, cleanup.Channel, cleanup.Ts,
+				slack.MsgOptionText(summary, false),
+				slack.MsgOptionBlocks(slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, summary, false, false), nil, nil)))
+			return err
+		})
+	case CleanupModeDelete:
+		err = callSlack(ctx, "chat.delete", func() error {
+			_, _, err := slackClient.DeleteMessageContext(ctx, cleanup.Channel, cleanup.Ts)
+			return err
+		})
+	default:
+		// The channel was switched back to TimeBomb since the cleanup was queued
+		err = publishTimeBombMessage(ctx, redisClient, config, cleanup.TeamID, cleanup.Repository, cleanup.Channel, cleanup.Ts)
+	}
+
+	var slackErr slack.SlackErrorResponse
+	switch {
+	case err == nil && mode == statusChange:
+		logDebug("Showing merge status %s on message %s in channel %s", cleanup.Status, cleanup.Ts, cleanup.Channel)
+	case err == nil:
+		if mode != CleanupModeTimeBomb {
+			metrics.Add("messages_cleaned_up", 1)
+			logInfo("Cleaned up message %s in channel %s (%s)", cleanup.Ts, cleanup.Channel, mode)
+		}
+	case errors.Is(err, errSlackCircuitOpen):
+		if err := redisClient.RPush(ctx, config.CleanupQueue, cleanupJSON).Err(); err != nil {
+			logError("Error putting back the cleanup of message %s: %v", cleanup.Ts, err)
+		}
+		return false
+	case errors.As(err, &slackErr) && slackErr.Err == "message_not_found":
+		logInfo("Message %s in channel %s was already deleted", cleanup.Ts, cleanup.Channel)
+	case mode == statusChange && errors.As(err, &slackErr) && slackErr.Err == "cant_update_message":
+		logWarning("Can't show merge status on message %s in channel %s, it wasn't posted by VibeMerge's bot", cleanup.Ts, cleanup.Channel)
+	case errors.As(err, &slackErr) && (slackErr.Err == "cant_update_message" || slackErr.Err == "cant_delete_message"):
+		// Only messages posted by VibeMerge's own bot can be changed, so other messages fall back to TimeBomb
+		logWarning("Can't %s message %s in channel %s, leaving it to TimeBomb", mode, cleanup.Ts, cleanup.Channel)
+		if err := publishTimeBombMessage(ctx, redisClient, config, cleanup.TeamID, cleanup.Repository, cleanup.Channel, cleanup.Ts); err != nil {
+			logWarning("Failed to set TTL on message: %v", err)
+		}
+	default:
+		logError("Error cleaning up message %s in channel %s: %v", cleanup.Ts, cleanup.Channel, err)
+	}
+	return true
+}
+
+// cleanupSummary is the one-line text a message is updated to
+func cleanupSummary(cleanup MessageCleanup) string {
+	summary := fmt.Sprintf(":white_check_mark: Merged PR #%d in %s", cleanup.PRNumber, cleanup.Repository)
+	if !cleanup.Merged {
+		summary = fmt.Sprintf(":rocket: Queued the merge of PR #%d in %s", cleanup.PRNumber, cleanup.Repository)
+	}
+	if cleanup.RequestedBy != "" {
+		summary += fmt.Sprintf(" by <@%s>", cleanup.RequestedBy)
+	}
+	return summary
+}
+
+// cleanupDeadlinesKey is the sorted set of held back cleanups, scored by when they go ahead unconfirmed
+func cleanupDeadlinesKey(config *Config) string {
+	return config.TimeBombPendingKey + ":deadlines"
+}
+
+// deferCleanup holds back the cleanup of a queued merge's message until Poppit or GitHub confirms the merge, or
+// TIMEBOMB_MAX_DELAY passes
+func deferCleanup(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) error {
+	pendingJSON, err := json.Marshal(newMessageCleanup(job))
+	if err != nil {
+		return fmt.Errorf("failed to marshal message cleanup: %w", err)
+	}
+
+	deadline := time.Now().Add(time.Duration(config.TimeBombMaxDelay) * time.Second)
+	_, err = redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, config.TimeBombPendingKey, job.Payload.CorrelationID, string(pendingJSON))
+		pipe.ZAdd(ctx, cleanupDeadlinesKey(config), redis.Z{Score: float64(deadline.Unix()), Member: job.Payload.CorrelationID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to hold back cleanup in %s: %w", config.TimeBombPendingKey, err)
+	}
+	logDebug("Holding back cleanup of message %s in channel %s until merge %s is confirmed", job.Ts, job.Channel, job.Payload.CorrelationID)
+	return nil
+}
+
+// releaseCleanup cleans up the message held back for a merge, merged telling whether the merge was confirmed. Only
+// the caller that removes it from the pending hash cleans up, so a merge confirmed by both Poppit and GitHub is
+// cleaned up once.
+func releaseCleanup(ctx context.Context, redisClient *redis.Client, config *Config, correlationID string, merged bool, reason string) error {
+	pendingJSON, err := redisClient.HGet(ctx, config.TimeBombPendingKey, correlationID).Result()
+	if errors.Is(err, redis.Nil) {
+		return redisClient.ZRem(ctx, cleanupDeadlinesKey(config), correlationID).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.TimeBombPendingKey, err)
+	}
+
+	claimed, err := dropCleanup(ctx, redisClient, config, correlationID)
+	if err != nil || !claimed {
+		return err
+	}
+
+	var cleanup MessageCleanup
+	if err := json.Unmarshal([]byte(pendingJSON), &cleanup); err != nil {
+		return fmt.Errorf("failed to unmarshal message cleanup: %w", err)
+	}
+	cleanup.Merged = merged
+	logInfo("Cleaning up message %s for merge %s: %s", cleanup.Ts, correlationID, reason)
+	return cleanUpMessage(ctx, redisClient, config, cleanup)
+}
+
+// dropCleanup forgets the cleanup held back for a merge, reporting whether it was still pending
+func dropCleanup(ctx context.Context, redisClient *redis.Client, config *Config, correlationID string) (bool, error) {
+	removed, err := redisClient.HDel(ctx, config.TimeBombPendingKey, correlationID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove from %s: %w", config.TimeBombPendingKey, err)
+	}
+	if err := redisClient.ZRem(ctx, cleanupDeadlinesKey(config), correlationID).Err(); err != nil {
+		logWarning("Failed to remove %s from %s: %v", correlationID, cleanupDeadlinesKey(config), err)
+	}
+	return removed > 0, nil
+}
+
+// releaseMergedCleanups cleans up the messages held back for a PR that GitHub reported as merged
+func releaseMergedCleanups(ctx context.Context, redisClient *redis.Client, config *Config, repo string, prNumber int) error {
+	pending, err := redisClient.HGetAll(ctx, config.TimeBombPendingKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.TimeBombPendingKey, err)
+	}
+	for correlationID, pendingJSON := range pending {
+		var cleanup MessageCleanup
+		if err := json.Unmarshal([]byte(pendingJSON), &cleanup); err != nil {
+			logWarning("Skipping unreadable message cleanup %s: %v", correlationID, err)
+			continue
+		}
+		if cleanup.Repository != repo || cleanup.PRNumber != prNumber {
+			continue
+		}
+		if err := releaseCleanup(ctx, redisClient, config, correlationID, true, "merged on GitHub"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processCleanupDeadlines cleans up the messages of merges that weren't confirmed within TIMEBOMB_MAX_DELAY, for
+// setups where Poppit results or GitHub webhooks don't arrive
+func processCleanupDeadlines(ctx context.Context, redisClient *redis.Client) {
+	ticker := time.NewTicker(time.Duration(currentConfig().DeferredPollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			config := currentConfig()
+			due, err := redisClient.ZRangeByScore(ctx, cleanupDeadlinesKey(config), &redis.ZRangeBy{
+				Min: "-inf",
+				Max: strconv.FormatInt(time.Now().Unix(), 10),
+			}).Result()
+			if err != nil {
+				logError("Error reading %s: %v", cleanupDeadlinesKey(config), err)
+				continue
+			}
+			for _, correlationID := range due {
+				if err := releaseCleanup(context.WithoutCancel(ctx), redisClient, config, correlationID, false, "merge not confirmed within TIMEBOMB_MAX_DELAY"); err != nil {
+					logError("Error cleaning up the message of merge %s: %v", correlationID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// parseCloseComment parses CLOSE_COMMENT, the comment posted before a PR is closed with the close emoji. It's a
+// template like the comments in COMMENTS_FILE; an empty value closes PRs without a comment.
+func parseCloseComment(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("close").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLOSE_COMMENT template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// submitClose hands Poppit the commands that close a PR, after its comment when CLOSE_COMMENT is set
+func submitClose(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, error) {
+	return submitPRCommand(ctx, redisClient, config, job, "close", true)
+}
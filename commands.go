@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// defaultMergeCommands are run for the target emoji unless COMMANDS_FILE or the repository overrides them
+var defaultMergeCommands = []string{
+	"gh pr --repo {{.Repository}} ready {{.PRNumber}}",
+	"gh pr --repo {{.Repository}} merge {{.PRNumber}} --squash{{with .SquashFlags}} {{.}}{{end}}",
+}
+
+// defaultReadyCommands are run for the ready emoji unless COMMANDS_FILE or the repository overrides them
+var defaultReadyCommands = []string{
+	"gh pr --repo {{.Repository}} ready {{.PRNumber}}",
+}
+
+// defaultApproveCommands are run for the approve emoji unless COMMANDS_FILE or the repository overrides them
+var defaultApproveCommands = []string{
+	`gh pr --repo {{.Repository}} review {{.PRNumber}} --approve --body "Approved in Slack by @{{.GitHubUser}}"`,
+}
+
+// defaultCloseCommands are run for the close emoji unless COMMANDS_FILE or the repository overrides them
+var defaultCloseCommands = []string{
+	"gh pr --repo {{.Repository}} close {{.PRNumber}}",
+}
+
+// defaultRevertCommands are run for the revert emoji unless COMMANDS_FILE or the repository overrides them. They
+// revert the merge commit on a fresh clone and open a PR with the revert.
+var defaultRevertCommands = []string{
+	"rm -rf vibemerge-revert-{{.PRNumber}}",
+	"gh repo clone {{.Repository}} vibemerge-revert-{{.PRNumber}}",
+	"git -C vibemerge-revert-{{.PRNumber}} checkout -b vibemerge/revert-{{.PRNumber}}{{with .BaseBranch}} origin/{{.}}{{end}}",
+	"git -C vibemerge-revert-{{.PRNumber}} revert --no-edit {{.MergeSHA}}",
+	"git -C vibemerge-revert-{{.PRNumber}} push --force origin vibemerge/revert-{{.PRNumber}}",
+	`gh pr --repo {{.Repository}} create --head vibemerge/revert-{{.PRNumber}}{{with .BaseBranch}} --base {{.}}{{end}} --title "Revert #{{.PRNumber}}" --body "Reverts #{{.PRNumber}} (merge commit {{.MergeSHA}}){{with .GitHubUser}}, requested in Slack by @{{.}}{{end}}."`,
+	"rm -rf vibemerge-revert-{{.PRNumber}}",
+}
+
+// CommandTemplates maps an emoji to the Poppit command templates it runs
+type CommandTemplates map[string][]*template.Template
+
+// parseCommandTemplates parses command templates keyed by emoji. Templates are executed with the PR's metadata,
+// so they can use {{.Repository}}, {{.PRNumber}}, {{.Title}}, {{.Branch}}, {{.Author}}, {{.PRURL}}, the requester's
+// {{.GitHubUser}}, which is empty when they have no identity mapping, {{.SquashFlags}} from SQUASH_SUBJECT and
+// SQUASH_BODY, and for the revert emoji the PR's {{.MergeSHA}}.
+func parseCommandTemplates(commands map[string][]string) (CommandTemplates, error) {
+	templates := make(CommandTemplates, len(commands))
+	for emoji, lines := range commands {
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("no commands for emoji %q", emoji)
+		}
+		for i, line := range lines {
+			tmpl, err := template.New(fmt.Sprintf("%s[%d]", emoji, i)).Option("missingkey=error").Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command template for emoji %q: %w", emoji, err)
+			}
+			templates[emoji] = append(templates[emoji], tmpl)
+		}
+	}
+	return templates, nil
+}
+
+// loadCommandTemplates reads the per-emoji command templates file
+func loadCommandTemplates(path string) (CommandTemplates, error) {
+	commands := make(map[string][]string)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read COMMANDS_FILE: %w", err)
+		}
+		if err := json.Unmarshal(data, &commands); err != nil {
+			return nil, fmt.Errorf("failed to parse COMMANDS_FILE: %w", err)
+		}
+	}
+
+	templates, err := parseCommandTemplates(commands)
+	if err != nil {
+		return nil, fmt.Errorf("COMMANDS_FILE: %w", err)
+	}
+	return templates, nil
+}
+
+// isMergeEmoji reports whether a reaction requests a merge, either as the workspace's target emoji or
+// because commands are configured for it globally or for any repository
+func (c *Config) isMergeEmoji(workspace WorkspaceSettings, reaction string) bool {
+	if reaction == workspace.TargetEmoji || (c.SizeOverrideEmoji != "" && reaction == c.SizeOverrideEmoji) || (c.UrgentEmoji != "" && reaction == c.UrgentEmoji) ||
+		(c.StackEmoji != "" && reaction == c.StackEmoji) {
+		return true
+	}
+	if _, ok := c.Commands[reaction]; ok {
+		return true
+	}
+	for _, repo := range c.Repos {
+		if _, ok := repo.commands[reaction]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// commandTemplates finds the commands an emoji runs for a repository: the repository's own, then COMMANDS_FILE,
+// then fallback. It reports false when the emoji does nothing for the repository.
+func (c *Config) commandTemplates(repo, emoji string, fallback []*template.Template) ([]*template.Template, bool) {
+	if templates, ok := c.Repos[repo].commands[emoji]; ok {
+		return templates, true
+	}
+	if templates, ok := c.Commands[emoji]; ok {
+		return templates, true
+	}
+	return fallback, fallback != nil
+}
+
+// renderCommands executes command templates against a PR's metadata
+func renderCommands(templates []*template.Template, metadata *PRMetadata) ([]string, error) {
+	if err := checkTemplateFields(templates, metadata); err != nil {
+		return nil, err
+	}
+	commands := make([]string, 0, len(templates))
+	for _, tmpl := range templates {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, metadata); err != nil {
+			return nil, fmt.Errorf("failed to render command %s: %w", tmpl.Name(), err)
+		}
+		commands = append(commands, b.String())
+	}
+	return commands, nil
+}
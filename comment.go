@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/slack-go/slack"
+)
+
+// CommentTemplates maps an emoji to the template of the comment it posts on the PR
+type CommentTemplates map[string]*template.Template
+
+// loadCommentTemplates reads the per-emoji comment templates file, e.g. {"eyes": "Taking a look"}
+func loadCommentTemplates(path string) (CommentTemplates, error) {
+	templates := make(CommentTemplates)
+	if path == "" {
+		return templates, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read COMMENTS_FILE: %w", err)
+	}
+	var comments map[string]string
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return nil, fmt.Errorf("failed to parse COMMENTS_FILE: %w", err)
+	}
+	for emoji, text := range comments {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("COMMENTS_FILE: empty comment for emoji %q", emoji)
+		}
+		tmpl, err := template.New(emoji).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("COMMENTS_FILE: invalid comment template for emoji %q: %w", emoji, err)
+		}
+		templates[emoji] = tmpl
+	}
+	return templates, nil
+}
+
+// CommentContext is what comment templates are executed with: the PR's metadata, so {{.Repository}}, {{.PRNumber}},
+// {{.PRURL}}, {{.Author}}, {{.Branch}} and {{.GitHubUser}} work, plus the Slack user who reacted
+type CommentContext struct {
+	*PRMetadata
+	// SlackUser is the reacting user's Slack ID
+	SlackUser string
+
+	ctx         context.Context
+	slackClient *slack.Client
+}
+
+// SlackName is the reacting user's Slack display name, or their ID when it can't be looked up. It's a method so
+// Slack is only asked when a template uses it.
+func (c CommentContext) SlackName() string {
+	if c.slackClient == nil {
+		return c.SlackUser
+	}
+	var user *slack.User
+	err := callSlack(c.ctx, "users.info", func() error {
+		var err error
+		user, err = c.slackClient.GetUserInfoContext(c.ctx, c.SlackUser)
+		return err
+	})
+	if err != nil {
+		logWarning("Failed to look up Slack user %s for a comment: %v", c.SlackUser, err)
+		return c.SlackUser
+	}
+	if user.Profile.DisplayName != "" {
+		return user.Profile.DisplayName
+	}
+	if user.RealName != "" {
+		return user.RealName
+	}
+	return user.Name
+}
+
+// commentCommand renders an emoji's comment and the Poppit command that posts it on the PR
+func commentCommand(ctx context.Context, slackClient *slack.Client, tmpl *template.Template, metadata *PRMetadata, slackUser string) (string, error) {
+	var b bytes.Buffer
+	data := CommentContext{PRMetadata: metadata, SlackUser: slackUser, ctx: ctx, slackClient: slackClient}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render comment for %s: %w", tmpl.Name(), err)
+	}
+	return fmt.Sprintf("gh pr --repo %s comment %d --body %s", metadata.Repository, metadata.PRNumber, shellQuote(b.String())), nil
+}
+
+// shellQuote quotes a string as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
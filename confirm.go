@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Action IDs of the buttons on a merge confirmation
+const (
+	ConfirmActionApprove = "vibemerge_confirm_approve"
+	ConfirmActionCancel  = "vibemerge_confirm_cancel"
+)
+
+// maxInteractionBody bounds the size of a Slack interaction payload read into memory
+const maxInteractionBody = 1 << 20
+
+func confirmKey(config *Config, correlationID string) string {
+	return fmt.Sprintf("%s:%s", config.ConfirmKeyPrefix, correlationID)
+}
+
+// requestConfirmation holds a merge for CONFIRM_TTL seconds and asks for it to be approved with Approve and Cancel
+// buttons in the message thread, rather than queueing it
+func requestConfirmation(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, job MergeJob) (Decision, error) {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to marshal merge job: %w", err)
+	}
+	key := confirmKey(config, job.Payload.CorrelationID)
+	if err := redisClient.Set(ctx, key, string(jobJSON), time.Duration(config.ConfirmTTL)*time.Second).Err(); err != nil {
+		return Decision{}, fmt.Errorf("failed to set %s: %w", key, err)
+	}
+
+	text := fmt.Sprintf(":raised_hand: <@%s> asked to merge PR #%d in %s. An authorized user needs to approve it within %s.",
+		job.RequestedBy, job.PRNumber, job.Payload.Repo, formatWindow(time.Duration(config.ConfirmTTL)*time.Second))
+	approve := slack.NewButtonBlockElement(ConfirmActionApprove, job.Payload.CorrelationID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false)).WithStyle(slack.StylePrimary)
+	cancel := slack.NewButtonBlockElement(ConfirmActionCancel, job.Payload.CorrelationID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false))
+	err = callSlack(ctx, "chat.postMessage", func() error {
+		_, _, err := slackClient.PostMessageContext(ctx, job.Channel,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionBlocks(
+				slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+				slack.NewActionBlock("vibemerge_confirm", approve, cancel),
+			),
+			slack.MsgOptionTS(job.Ts),
+		)
+		return err
+	})
+	if err != nil {
+		redisClient.Del(ctx, key)
+		return Decision{}, fmt.Errorf("failed to post merge confirmation: %w", err)
+	}
+
+	logInfo("Asked for confirmation of merge %s of PR %d in %s", job.Payload.CorrelationID, job.PRNumber, job.Payload.Repo)
+	return Decision{Outcome: OutcomeDeferred, Reason: "awaiting confirmation"}, nil
+}
+
+func processInteractions(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	receiveMessages(ctx, redisClient, clients, currentConfig().InteractionChannel, func(payload string) {
+		if err := handleInteraction(context.WithoutCancel(ctx), payload, redisClient, clients, currentConfig()); err != nil {
+			logError("Error handling Slack interaction: %v", err)
+		}
+	})
+}
+
+// slackInteractionsHandler receives Slack interaction payloads directly, verifying them with SLACK_SIGNING_SECRET
+func slackInteractionsHandler(redisClient *redis.Client, clients *slackClients) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		config := currentConfig()
+		verifier, err := slack.NewSecretsVerifier(r.Header, config.SlackSigningSecret)
+		if err != nil {
+			logWarning("Rejected Slack interaction without a valid signature from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(io.TeeReader(io.LimitReader(r.Body, maxInteractionBody), &verifier))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if err := verifier.Ensure(); err != nil {
+			logWarning("Rejected Slack interaction with an invalid signature from %s", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil || form.Get("payload") == "" {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		// Slack expects an answer within 3 seconds, before the merge gates have run
+		w.WriteHeader(http.StatusOK)
+		go func() {
+			if err := handleInteraction(context.WithoutCancel(r.Context()), form.Get("payload"), redisClient, clients, config); err != nil {
+				logError("Error handling Slack interaction: %v", err)
+			}
+		}()
+	})
+}
+
+// handleInteraction routes the block actions of a Slack interaction payload to their handlers
+func handleInteraction(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, config *Config) error {
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(payload), &callback); err != nil {
+		return fmt.Errorf("failed to unmarshal interaction: %w", err)
+	}
+	if callback.Type != slack.InteractionTypeBlockActions {
+		logDebug("Ignoring Slack %s interaction", callback.Type)
+		return nil
+	}
+
+	ctx, cancel := eventContext(ctx, config)
+	defer cancel()
+	for _, action := range callback.ActionCallback.BlockActions {
+		switch action.ActionID {
+		case ConfirmActionApprove, ConfirmActionCancel:
+			return handleConfirmAction(ctx, redisClient, clients, config, callback, action)
+		case DelayActionCancel:
+			return handleDelayCancel(ctx, redisClient, config, callback, action)
+		}
+	}
+	return nil
+}
+
+// handleConfirmAction submits or drops a merge held for confirmation when its Approve or Cancel button is clicked.
+// Only authorized users other than the requester may approve; the requester may cancel too.
+func handleConfirmAction(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, callback slack.InteractionCallback, action *slack.BlockAction) error {
+	user := callback.User.ID
+	key := confirmKey(config, action.Value)
+	jobJSON, err := redisClient.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return respondToInteraction(ctx, callback, false, ":hourglass: This merge was already approved, cancelled or has expired.")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	var job MergeJob
+	if err := json.Unmarshal([]byte(jobJSON), &job); err != nil {
+		return fmt.Errorf("failed to unmarshal merge job: %w", err)
+	}
+
+	approve := action.ActionID == ConfirmActionApprove
+	if !config.isAuthorized(user) && (approve || user != job.RequestedBy) {
+		logInfo("User %s is not allowed to confirm merge %s", user, job.Payload.CorrelationID)
+		return respondToInteraction(ctx, callback, false, fmt.Sprintf(":no_entry_sign: Sorry <@%s>, you are not authorized to do that.", user))
+	}
+	if approve && user == job.RequestedBy {
+		logInfo("User %s tried to approve their own merge %s", user, job.Payload.CorrelationID)
+		return respondToInteraction(ctx, callback, false, ":no_entry_sign: You can't approve your own merge, someone else needs to.")
+	}
+
+	// Only the click that removes the confirmation gets to act on it
+	removed, err := redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	if removed == 0 {
+		return respondToInteraction(ctx, callback, false, ":hourglass: This merge was already approved, cancelled or has expired.")
+	}
+
+	audit := AuditEntry{
+		EventTime:     time.Now().UTC(),
+		Source:        "confirm",
+		User:          user,
+		GitHubUser:    job.Payload.GitHubUser,
+		TeamID:        job.TeamID,
+		Channel:       job.Channel,
+		Ts:            job.Ts,
+		Repository:    job.Payload.Repo,
+		PRNumber:      job.PRNumber,
+		CorrelationID: job.Payload.CorrelationID,
+	}
+	if !approve {
+		logInfo("User %s cancelled merge %s of PR %d in %s before it was confirmed", user, job.Payload.CorrelationID, job.PRNumber, job.Payload.Repo)
+		audit.finish(ctx, redisClient, config, Decision{Outcome: OutcomeCancelled, Reason: "cancelled before confirmation"}, nil)
+		return respondToInteraction(ctx, callback, true, fmt.Sprintf(":no_entry: <@%s> cancelled the merge of PR #%d.", user, job.PRNumber))
+	}
+
+	logInfo("User %s approved merge %s of PR %d in %s", user, job.Payload.CorrelationID, job.PRNumber, job.Payload.Repo)
+	job.ApprovedBy = user
+	decision, err := submitMerge(ctx, redisClient, config, job)
+	audit.finish(ctx, redisClient, config, decision, err)
+	if err != nil {
+		return err
+	}
+	if err := respondToInteraction(ctx, callback, true, fmt.Sprintf(":white_check_mark: <@%s> approved the merge of PR #%d.", user, job.PRNumber)); err != nil {
+		logWarning("Failed to update the confirmation of merge %s: %v", job.Payload.CorrelationID, err)
+	}
+	if decision.Note != "" && job.Ts != "" {
+		if slackClient := clients.forWorkspace(config.workspace(job.TeamID)); slackClient != nil {
+			postDecisionNote(ctx, slackClient, job.Channel, job.Ts, decision)
+		}
+	}
+	return nil
+}
+
+// respondToInteraction replies to a button click via its response_url, replacing the confirmation message or
+// answering only the user who clicked
+func respondToInteraction(ctx context.Context, callback slack.InteractionCallback, replace bool, text string) error {
+	msg := &slack.WebhookMessage{Text: text, ReplaceOriginal: replace}
+	if !replace {
+		msg.ResponseType = slack.ResponseTypeEphemeral
+	}
+	if err := slack.PostWebhookContext(ctx, callback.ResponseURL, msg); err != nil {
+		return fmt.Errorf("failed to respond to interaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackInteractionsHandlerSignature(t *testing.T) {
+	const secret = "signing-secret"
+	body := url.Values{"payload": {`{"type":"view_closed"}`}}.Encode()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	sign := func(secret, timestamp, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      string
+		want      int
+	}{
+		{"valid", now, sign(secret, now, body), body, http.StatusOK},
+		{"wrong secret", now, sign("other", now, body), body, http.StatusUnauthorized},
+		{"tampered body", now, sign(secret, now, body), body + "&x=1", http.StatusUnauthorized},
+		{"stale timestamp", stale, sign(secret, stale, body), body, http.StatusUnauthorized},
+		{"missing signature", now, "", body, http.StatusUnauthorized},
+	}
+
+	previous := activeConfig.Load()
+	defer activeConfig.Store(previous)
+	activeConfig.Store(&Config{SlackSigningSecret: secret})
+	handler := slackInteractionsHandler(nil, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-Slack-Request-Timestamp", tt.timestamp)
+			if tt.signature != "" {
+				req.Header.Set("X-Slack-Signature", tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
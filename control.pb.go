@@ -0,0 +1,656 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: vibemerge/v1/control.proto
+
+package main
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{0}
+}
+
+type GetStatusResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	InstanceId string                 `protobuf:"bytes,1,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`
+	// leader is set when leader election is disabled or this instance holds the lease
+	Leader      bool                   `protobuf:"varint,2,opt,name=leader,proto3" json:"leader,omitempty"`
+	Paused      bool                   `protobuf:"varint,3,opt,name=paused,proto3" json:"paused,omitempty"`
+	PauseReason string                 `protobuf:"bytes,4,opt,name=pause_reason,json=pauseReason,proto3" json:"pause_reason,omitempty"`
+	StartedAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	// blackout_until is set while a merge blackout window is in effect
+	BlackoutUntil     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=blackout_until,json=blackoutUntil,proto3" json:"blackout_until,omitempty"`
+	PoppitQueueLength int64                  `protobuf:"varint,7,opt,name=poppit_queue_length,json=poppitQueueLength,proto3" json:"poppit_queue_length,omitempty"`
+	DeferredMerges    int64                  `protobuf:"varint,8,opt,name=deferred_merges,json=deferredMerges,proto3" json:"deferred_merges,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *GetStatusResponse) Reset() {
+	*x = GetStatusResponse{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusResponse) ProtoMessage() {}
+
+func (x *GetStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetStatusResponse) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetStatusResponse) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetLeader() bool {
+	if x != nil {
+		return x.Leader
+	}
+	return false
+}
+
+func (x *GetStatusResponse) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *GetStatusResponse) GetPauseReason() string {
+	if x != nil {
+		return x.PauseReason
+	}
+	return ""
+}
+
+func (x *GetStatusResponse) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetBlackoutUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.BlackoutUntil
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetPoppitQueueLength() int64 {
+	if x != nil {
+		return x.PoppitQueueLength
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetDeferredMerges() int64 {
+	if x != nil {
+		return x.DeferredMerges
+	}
+	return 0
+}
+
+type GetConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigRequest) Reset() {
+	*x = GetConfigRequest{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigRequest) ProtoMessage() {}
+
+func (x *GetConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigRequest) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{2}
+}
+
+type GetConfigResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// config_json is the configuration as JSON, as returned by the admin dump-state command
+	ConfigJson    string `protobuf:"bytes,1,opt,name=config_json,json=configJson,proto3" json:"config_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigResponse) Reset() {
+	*x = GetConfigResponse{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigResponse) ProtoMessage() {}
+
+func (x *GetConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigResponse.ProtoReflect.Descriptor instead.
+func (*GetConfigResponse) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetConfigResponse) GetConfigJson() string {
+	if x != nil {
+		return x.ConfigJson
+	}
+	return ""
+}
+
+type PauseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseRequest) Reset() {
+	*x = PauseRequest{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseRequest) ProtoMessage() {}
+
+func (x *PauseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseRequest.ProtoReflect.Descriptor instead.
+func (*PauseRequest) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PauseRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type PauseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseResponse) Reset() {
+	*x = PauseResponse{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseResponse) ProtoMessage() {}
+
+func (x *PauseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseResponse.ProtoReflect.Descriptor instead.
+func (*PauseResponse) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PauseResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ResumeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeRequest) Reset() {
+	*x = ResumeRequest{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeRequest) ProtoMessage() {}
+
+func (x *ResumeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeRequest.ProtoReflect.Descriptor instead.
+func (*ResumeRequest) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{6}
+}
+
+type ResumeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeResponse) Reset() {
+	*x = ResumeResponse{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeResponse) ProtoMessage() {}
+
+func (x *ResumeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeResponse.ProtoReflect.Descriptor instead.
+func (*ResumeResponse) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{7}
+}
+
+type RequestMergeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	PrUrl string                 `protobuf:"bytes,1,opt,name=pr_url,json=prUrl,proto3" json:"pr_url,omitempty"`
+	// user is the Slack user ID the merge is requested for, checked against AUTHORIZED_USERS
+	User          string `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	TeamId        string `protobuf:"bytes,3,opt,name=team_id,json=teamId,proto3" json:"team_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestMergeRequest) Reset() {
+	*x = RequestMergeRequest{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestMergeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestMergeRequest) ProtoMessage() {}
+
+func (x *RequestMergeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestMergeRequest.ProtoReflect.Descriptor instead.
+func (*RequestMergeRequest) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RequestMergeRequest) GetPrUrl() string {
+	if x != nil {
+		return x.PrUrl
+	}
+	return ""
+}
+
+func (x *RequestMergeRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *RequestMergeRequest) GetTeamId() string {
+	if x != nil {
+		return x.TeamId
+	}
+	return ""
+}
+
+type RequestMergeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// decision is queued, deferred, denied or ignored
+	Decision      string `protobuf:"bytes,1,opt,name=decision,proto3" json:"decision,omitempty"`
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	CorrelationId string `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	Repository    string `protobuf:"bytes,4,opt,name=repository,proto3" json:"repository,omitempty"`
+	PrNumber      int32  `protobuf:"varint,5,opt,name=pr_number,json=prNumber,proto3" json:"pr_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestMergeResponse) Reset() {
+	*x = RequestMergeResponse{}
+	mi := &file_vibemerge_v1_control_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestMergeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestMergeResponse) ProtoMessage() {}
+
+func (x *RequestMergeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_vibemerge_v1_control_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestMergeResponse.ProtoReflect.Descriptor instead.
+func (*RequestMergeResponse) Descriptor() ([]byte, []int) {
+	return file_vibemerge_v1_control_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RequestMergeResponse) GetDecision() string {
+	if x != nil {
+		return x.Decision
+	}
+	return ""
+}
+
+func (x *RequestMergeResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *RequestMergeResponse) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *RequestMergeResponse) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *RequestMergeResponse) GetPrNumber() int32 {
+	if x != nil {
+		return x.PrNumber
+	}
+	return 0
+}
+
+var File_vibemerge_v1_control_proto protoreflect.FileDescriptor
+
+const file_vibemerge_v1_control_proto_rawDesc = "" +
+	"\n" +
+	"\x1avibemerge/v1/control.proto\x12\fvibemerge.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x12\n" +
+	"\x10GetStatusRequest\"\xde\x02\n" +
+	"\x11GetStatusResponse\x12\x1f\n" +
+	"\vinstance_id\x18\x01 \x01(\tR\n" +
+	"instanceId\x12\x16\n" +
+	"\x06leader\x18\x02 \x01(\bR\x06leader\x12\x16\n" +
+	"\x06paused\x18\x03 \x01(\bR\x06paused\x12!\n" +
+	"\fpause_reason\x18\x04 \x01(\tR\vpauseReason\x129\n" +
+	"\n" +
+	"started_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12A\n" +
+	"\x0eblackout_until\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\rblackoutUntil\x12.\n" +
+	"\x13poppit_queue_length\x18\a \x01(\x03R\x11poppitQueueLength\x12'\n" +
+	"\x0fdeferred_merges\x18\b \x01(\x03R\x0edeferredMerges\"\x12\n" +
+	"\x10GetConfigRequest\"4\n" +
+	"\x11GetConfigResponse\x12\x1f\n" +
+	"\vconfig_json\x18\x01 \x01(\tR\n" +
+	"configJson\"&\n" +
+	"\fPauseRequest\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\"'\n" +
+	"\rPauseResponse\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\"\x0f\n" +
+	"\rResumeRequest\"\x10\n" +
+	"\x0eResumeResponse\"Y\n" +
+	"\x13RequestMergeRequest\x12\x15\n" +
+	"\x06pr_url\x18\x01 \x01(\tR\x05prUrl\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x17\n" +
+	"\ateam_id\x18\x03 \x01(\tR\x06teamId\"\xae\x01\n" +
+	"\x14RequestMergeResponse\x12\x1a\n" +
+	"\bdecision\x18\x01 \x01(\tR\bdecision\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12%\n" +
+	"\x0ecorrelation_id\x18\x03 \x01(\tR\rcorrelationId\x12\x1e\n" +
+	"\n" +
+	"repository\x18\x04 \x01(\tR\n" +
+	"repository\x12\x1b\n" +
+	"\tpr_number\x18\x05 \x01(\x05R\bprNumber2\x8a\x03\n" +
+	"\x0eControlService\x12L\n" +
+	"\tGetStatus\x12\x1e.vibemerge.v1.GetStatusRequest\x1a\x1f.vibemerge.v1.GetStatusResponse\x12L\n" +
+	"\tGetConfig\x12\x1e.vibemerge.v1.GetConfigRequest\x1a\x1f.vibemerge.v1.GetConfigResponse\x12@\n" +
+	"\x05Pause\x12\x1a.vibemerge.v1.PauseRequest\x1a\x1b.vibemerge.v1.PauseResponse\x12C\n" +
+	"\x06Resume\x12\x1b.vibemerge.v1.ResumeRequest\x1a\x1c.vibemerge.v1.ResumeResponse\x12U\n" +
+	"\fRequestMerge\x12!.vibemerge.v1.RequestMergeRequest\x1a\".vibemerge.v1.RequestMergeResponseB(Z&github.com/its-the-vibe/VibeMerge;mainb\x06proto3"
+
+var (
+	file_vibemerge_v1_control_proto_rawDescOnce sync.Once
+	file_vibemerge_v1_control_proto_rawDescData []byte
+)
+
+func file_vibemerge_v1_control_proto_rawDescGZIP() []byte {
+	file_vibemerge_v1_control_proto_rawDescOnce.Do(func() {
+		file_vibemerge_v1_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_vibemerge_v1_control_proto_rawDesc), len(file_vibemerge_v1_control_proto_rawDesc)))
+	})
+	return file_vibemerge_v1_control_proto_rawDescData
+}
+
+var file_vibemerge_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_vibemerge_v1_control_proto_goTypes = []any{
+	(*GetStatusRequest)(nil),      // 0: vibemerge.v1.GetStatusRequest
+	(*GetStatusResponse)(nil),     // 1: vibemerge.v1.GetStatusResponse
+	(*GetConfigRequest)(nil),      // 2: vibemerge.v1.GetConfigRequest
+	(*GetConfigResponse)(nil),     // 3: vibemerge.v1.GetConfigResponse
+	(*PauseRequest)(nil),          // 4: vibemerge.v1.PauseRequest
+	(*PauseResponse)(nil),         // 5: vibemerge.v1.PauseResponse
+	(*ResumeRequest)(nil),         // 6: vibemerge.v1.ResumeRequest
+	(*ResumeResponse)(nil),        // 7: vibemerge.v1.ResumeResponse
+	(*RequestMergeRequest)(nil),   // 8: vibemerge.v1.RequestMergeRequest
+	(*RequestMergeResponse)(nil),  // 9: vibemerge.v1.RequestMergeResponse
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_vibemerge_v1_control_proto_depIdxs = []int32{
+	10, // 0: vibemerge.v1.GetStatusResponse.started_at:type_name -> google.protobuf.Timestamp
+	10, // 1: vibemerge.v1.GetStatusResponse.blackout_until:type_name -> google.protobuf.Timestamp
+	0,  // 2: vibemerge.v1.ControlService.GetStatus:input_type -> vibemerge.v1.GetStatusRequest
+	2,  // 3: vibemerge.v1.ControlService.GetConfig:input_type -> vibemerge.v1.GetConfigRequest
+	4,  // 4: vibemerge.v1.ControlService.Pause:input_type -> vibemerge.v1.PauseRequest
+	6,  // 5: vibemerge.v1.ControlService.Resume:input_type -> vibemerge.v1.ResumeRequest
+	8,  // 6: vibemerge.v1.ControlService.RequestMerge:input_type -> vibemerge.v1.RequestMergeRequest
+	1,  // 7: vibemerge.v1.ControlService.GetStatus:output_type -> vibemerge.v1.GetStatusResponse
+	3,  // 8: vibemerge.v1.ControlService.GetConfig:output_type -> vibemerge.v1.GetConfigResponse
+	5,  // 9: vibemerge.v1.ControlService.Pause:output_type -> vibemerge.v1.PauseResponse
+	7,  // 10: vibemerge.v1.ControlService.Resume:output_type -> vibemerge.v1.ResumeResponse
+	9,  // 11: vibemerge.v1.ControlService.RequestMerge:output_type -> vibemerge.v1.RequestMergeResponse
+	7,  // [7:12] is the sub-list for method output_type
+	2,  // [2:7] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_vibemerge_v1_control_proto_init() }
+func file_vibemerge_v1_control_proto_init() {
+	if File_vibemerge_v1_control_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_vibemerge_v1_control_proto_rawDesc), len(file_vibemerge_v1_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_vibemerge_v1_control_proto_goTypes,
+		DependencyIndexes: file_vibemerge_v1_control_proto_depIdxs,
+		MessageInfos:      file_vibemerge_v1_control_proto_msgTypes,
+	}.Build()
+	File_vibemerge_v1_control_proto = out.File
+	file_vibemerge_v1_control_proto_goTypes = nil
+	file_vibemerge_v1_control_proto_depIdxs = nil
+}
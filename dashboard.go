@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dashboardEntries is how many of the latest audit entries the dashboard reads
+const dashboardEntries = 200
+
+// dashboardRows bounds each table on the dashboard
+const dashboardRows = 50
+
+// dashboardData is what the dashboard template renders
+type dashboardData struct {
+	Now      time.Time
+	State    *AdminState
+	Config   string
+	Recent   []AuditEntry
+	Queued   []AuditEntry
+	Failures []AuditEntry
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>VibeMerge</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; font-size: 0.9em; }
+th { background: #f4f4f4; }
+.paused { background: #fdd; padding: 0.5em 1em; }
+.running { background: #dfd; padding: 0.5em 1em; }
+.failed { color: #b00; }
+.queued { color: #070; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>VibeMerge</h1>
+<p>Up {{since .State.StartedAt}} · {{.Now.Format "2006-01-02 15:04:05 MST"}} · refreshes every 30 seconds</p>
+
+{{if .State.Paused}}<p class="paused">Merging is paused: {{.State.PauseReason}}</p>
+{{else}}<p class="running">Merging is running</p>{{end}}
+{{with .State.BlackoutUntil}}<p class="paused">Blackout window until {{.Format "2006-01-02 15:04 MST"}}</p>{{end}}
+<p>Poppit queue: {{.State.PoppitQueueLength}} · Deferred merges: {{.State.DeferredMerges}}</p>
+
+<h2>Merges queued</h2>
+{{template "entries" .Queued}}
+<h2>Failures</h2>
+{{template "entries" .Failures}}
+<h2>Recent activity</h2>
+{{template "entries" .Recent}}
+
+<h2>Configuration</h2>
+<pre>{{.Config}}</pre>
+</body>
+</html>
+{{define "entries"}}{{if .}}<table>
+<tr><th>Time</th><th>Source</th><th>User</th><th>Repository</th><th>PR</th><th>Decision</th><th>Reason</th></tr>
+{{range .}}<tr>
+<td>{{.Time.Format "2006-01-02 15:04:05 MST"}}</td>
+<td>{{.Source}}</td>
+<td>{{if .GitHubUser}}{{.GitHubUser}}{{else}}{{.User}}{{end}}</td>
+<td>{{.Repository}}</td>
+<td>{{if .PRNumber}}#{{.PRNumber}}{{end}}</td>
+<td class="{{.Decision}}">{{.Decision}}</td>
+<td>{{.Reason}}</td>
+</tr>
+{{end}}</table>{{else}}<p>None in the latest entries.</p>{{end}}{{end}}
+`))
+
+// dashboardHandler serves a read-only page of recent activity from the audit stream, the pause state and the
+// configuration without its secrets
+func dashboardHandler(redisClient *redis.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		config := currentConfig()
+		state, err := dumpState(r.Context(), redisClient, config)
+		if err != nil {
+			logError("Error reading state for the dashboard: %v", err)
+			http.Error(w, "failed to read state", http.StatusInternalServerError)
+			return
+		}
+		messages, err := redisClient.XRevRangeN(r.Context(), config.AuditStream, "+", "-", dashboardEntries).Result()
+		if err != nil {
+			logError("Error reading %s for the dashboard: %v", config.AuditStream, err)
+			http.Error(w, "failed to read the audit log", http.StatusInternalServerError)
+			return
+		}
+		configJSON, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			logError("Error marshalling config for the dashboard: %v", err)
+			http.Error(w, "failed to render the configuration", http.StatusInternalServerError)
+			return
+		}
+
+		data := dashboardData{Now: time.Now().In(config.Timezone), State: state, Config: string(configJSON)}
+		for _, msg := range messages {
+			entry := parseAuditEntry(msg)
+			data.Recent = append(data.Recent, entry)
+			switch entry.Decision {
+			case OutcomeQueued:
+				data.Queued = append(data.Queued, entry)
+			case OutcomeFailed:
+				data.Failures = append(data.Failures, entry)
+			}
+		}
+		for _, entries := range []*[]AuditEntry{&data.Recent, &data.Queued, &data.Failures} {
+			*entries = (*entries)[:min(len(*entries), dashboardRows)]
+		}
+
+		var page bytes.Buffer
+		if err := dashboardTemplate.Execute(&page, data); err != nil {
+			logError("Error rendering the dashboard: %v", err)
+			http.Error(w, "failed to render the dashboard", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	})
+}
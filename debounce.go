@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func triggerKey(config *Config, channel, timestamp string) string {
+	return fmt.Sprintf("%s:%s:%s", config.TriggerLockPrefix, channel, timestamp)
+}
+
+// claimTrigger takes a message's trigger lock for TRIGGER_DEBOUNCE seconds, so when several people stack merge
+// reactions on it only the first acts. It reports false when an earlier reaction holds the lock.
+func claimTrigger(ctx context.Context, redisClient *redis.Client, config *Config, channel, timestamp, user string) (bool, error) {
+	key := triggerKey(config, channel, timestamp)
+	claimed, err := redisClient.SetNX(ctx, key, user, time.Duration(config.TriggerDebounce)*time.Second).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire %s: %w", key, err)
+	}
+	return claimed, nil
+}
+
+// releaseTrigger gives up a message's trigger lock, so a reaction that wasn't acted on doesn't hold back the next
+func releaseTrigger(ctx context.Context, redisClient *redis.Client, config *Config, channel, timestamp string) {
+	key := triggerKey(config, channel, timestamp)
+	if err := redisClient.Del(ctx, key).Err(); err != nil {
+		logWarning("Failed to delete %s: %v", key, err)
+	}
+}
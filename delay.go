@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// DelayActionCancel is the action ID of the Cancel button on a delayed merge's note
+const DelayActionCancel = "vibemerge_delay_cancel"
+
+// interactive reports whether VibeMerge posts buttons and so handles Slack interactions
+func (c *Config) interactive() bool {
+	return c.ConfirmMerges || c.MergeDelay > 0
+}
+
+// delayMerge holds a merge that passed every check in the deferred queue for MERGE_DELAY_SECONDS, so it can still be
+// cancelled with the cancel emoji or the note's Cancel button. Merges without a message of their own can't be
+// cancelled and go ahead straight away.
+func delayMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, bool, error) {
+	if config.MergeDelay <= 0 || job.Ts == "" || job.Batch {
+		return Decision{}, false, nil
+	}
+
+	delay := time.Duration(config.MergeDelay) * time.Second
+	if err := deferMerge(ctx, redisClient, config, job, time.Now().Add(delay)); err != nil {
+		return Decision{}, false, err
+	}
+	logInfo("Delayed merge of PR %d in %s by %s", job.PRNumber, job.Payload.Repo, delay)
+	howToCancel := "Click Cancel to stop it."
+	if emoji := config.workspace(job.TeamID).CancelEmoji; emoji != "" {
+		howToCancel = fmt.Sprintf("React with :%s: or click Cancel to stop it.", emoji)
+	}
+	return Decision{
+		Outcome:      OutcomeDeferred,
+		Reason:       fmt.Sprintf("merge delay of %s", formatWindow(delay)),
+		Note:         fmt.Sprintf(":stopwatch: Merging PR #%d in %s unless cancelled. %s", job.PRNumber, formatWindow(delay), howToCancel),
+		CancelButton: true,
+	}, true, nil
+}
+
+// postDecisionNote replies in the thread with a decision's note, with a Cancel button when the merge can still be
+// withdrawn
+func postDecisionNote(ctx context.Context, slackClient *slack.Client, channel, timestamp string, decision Decision) {
+	if !decision.CancelButton {
+		notifyThread(ctx, slackClient, channel, timestamp, decision.Note)
+		return
+	}
+
+	button := slack.NewButtonBlockElement(DelayActionCancel, timestamp,
+		slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false)).WithStyle(slack.StyleDanger)
+	err := callSlack(ctx, "chat.postMessage", func() error {
+		_, _, err := slackClient.PostMessageContext(ctx, channel,
+			slack.MsgOptionText(decision.Note, false),
+			slack.MsgOptionBlocks(
+				slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, decision.Note, false, false), nil, nil),
+				slack.NewActionBlock("vibemerge_delay", button),
+			),
+			slack.MsgOptionTS(timestamp),
+		)
+		return err
+	})
+	if err != nil {
+		logWarning("Failed to post thread reply on message %s in channel %s: %v", timestamp, channel, err)
+	}
+}
+
+// handleDelayCancel withdraws a delayed merge when the Cancel button on its note is clicked, exactly as the cancel
+// emoji on the PR message would
+func handleDelayCancel(ctx context.Context, redisClient *redis.Client, config *Config, callback slack.InteractionCallback, action *slack.BlockAction) error {
+	var reactionEvent ReactionEvent
+	reactionEvent.TeamID = callback.Team.ID
+	reactionEvent.Event.User = callback.User.ID
+	reactionEvent.Event.Item.Channel = callback.Channel.ID
+	reactionEvent.Event.Item.Ts = action.Value
+
+	audit := AuditEntry{
+		EventTime: time.Now().UTC(),
+		Source:    "cancel",
+		User:      callback.User.ID,
+		TeamID:    callback.Team.ID,
+		Channel:   callback.Channel.ID,
+		Ts:        action.Value,
+	}
+	decision, err := handleCancelReaction(ctx, redisClient, config, reactionEvent, &audit)
+	audit.finish(ctx, redisClient, config, decision, err)
+	if err != nil {
+		return err
+	}
+
+	switch decision.Outcome {
+	case OutcomeCancelled:
+		return respondToInteraction(ctx, callback, true, decision.Note)
+	case OutcomeDenied:
+		return respondToInteraction(ctx, callback, false, fmt.Sprintf(":no_entry_sign: Sorry <@%s>, only the requester or an authorized user can cancel this merge.", callback.User.ID))
+	case OutcomeIgnored:
+		if decision.Note != "" {
+			return respondToInteraction(ctx, callback, true, decision.Note)
+		}
+		return respondToInteraction(ctx, callback, false, ":hourglass: There is no pending merge to cancel.")
+	}
+	return nil
+}
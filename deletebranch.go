@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// checkDeleteBranch rejects DELETE_BRANCH, or delete_branch for any repository, without GITHUB_TOKEN or a GitHub App
+// to delete with
+func (c *Config) checkDeleteBranch() error {
+	if c.hasGitHubAPI() {
+		return nil
+	}
+	if c.DeleteBranch {
+		return fmt.Errorf("DELETE_BRANCH requires GITHUB_TOKEN or a GitHub App")
+	}
+	for name, repo := range c.Repos {
+		if repo.DeleteBranch != nil && *repo.DeleteBranch {
+			return fmt.Errorf("REPO_CONFIG_FILE %s: delete_branch requires GITHUB_TOKEN or a GitHub App", name)
+		}
+	}
+	return nil
+}
+
+// deleteMergedBranch deletes the branch of a PR VibeMerge merged, when its repository has delete_branch set. PRs
+// from forks are skipped, since their branch isn't in the repository, and so are PRs GitHub doesn't report as
+// merged, whatever Poppit's commands did.
+func deleteMergedBranch(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) error {
+	if !config.repoSettings(result.Repo).DeleteBranch {
+		return nil
+	}
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		return err
+	}
+	// Ready, approve, close and action results don't merge anything
+	if !found || !mergeRequest(requested) {
+		return nil
+	}
+
+	pr, err := getPullRequest(ctx, config, result.Repo, requested.PRNumber)
+	if err != nil {
+		return err
+	}
+	if !pr.Merged {
+		logDebug("PR %d in %s isn't merged, keeping its branch", requested.PRNumber, result.Repo)
+		return nil
+	}
+	if pr.Head.Repo == nil || pr.Head.Repo.FullName != result.Repo {
+		logDebug("PR %d in %s is from a fork, not deleting its branch", requested.PRNumber, result.Repo)
+		return nil
+	}
+
+	err = githubRequest(ctx, config, http.MethodDelete, fmt.Sprintf("/repos/%s/git/refs/heads/%s", result.Repo, pr.Head.Ref), nil, nil)
+	var apiErr *githubAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		// GitHub's own "automatically delete head branches" got there first
+		logDebug("Branch %s of PR %d in %s is already deleted", pr.Head.Ref, requested.PRNumber, result.Repo)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", pr.Head.Ref, err)
+	}
+	logInfo("Deleted branch %s of merged PR %d in %s", pr.Head.Ref, requested.PRNumber, result.Repo)
+	return nil
+}
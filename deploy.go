@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// deployTimeout bounds each call to a deploy webhook
+const deployTimeout = 10 * time.Second
+
+var deployHTTPClient = &http.Client{Timeout: deployTimeout}
+
+// DeployConfig is a repository's deploy trigger, sent once Poppit reports one of its PRs merged. The payload goes to
+// any of a Redis list, a Redis channel and a webhook.
+type DeployConfig struct {
+	Queue   string `json:"queue,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Webhook string `json:"webhook,omitempty"`
+	// Payload is a template of the JSON payload, executed with a DeployEvent; empty sends the DeployEvent itself
+	Payload string `json:"payload,omitempty"`
+
+	payload *template.Template
+}
+
+// DeployEvent describes a merge that triggers a deploy
+type DeployEvent struct {
+	Repository    string    `json:"repository"`
+	PRNumber      int       `json:"pr_number"`
+	SHA           string    `json:"sha,omitempty"`
+	CorrelationID string    `json:"correlation_id"`
+	SlackUser     string    `json:"slack_user,omitempty"`
+	GitHubUser    string    `json:"github_user,omitempty"`
+	MergedAt      time.Time `json:"merged_at"`
+}
+
+// MarshalJSON redacts the webhook URL, which often carries a token, wherever the configuration is shown
+func (d DeployConfig) MarshalJSON() ([]byte, error) {
+	type deployConfig DeployConfig
+	redacted := deployConfig(d)
+	if redacted.Webhook != "" {
+		redacted.Webhook = "REDACTED"
+	}
+	return json.Marshal(redacted)
+}
+
+// deployFuncs are the functions available to deploy payload templates. json quotes a value, so strings land in
+// the payload as valid JSON.
+var deployFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// parse checks a repository's deploy trigger and parses its payload template
+func (d *DeployConfig) parse() error {
+	if d.Queue == "" && d.Channel == "" && d.Webhook == "" {
+		return fmt.Errorf("deploy needs a queue, channel or webhook")
+	}
+	if d.Webhook != "" {
+		u, err := url.Parse(d.Webhook)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("deploy webhook %q must be an http or https URL", d.Webhook)
+		}
+	}
+	if d.Payload == "" {
+		return nil
+	}
+	tmpl, err := template.New("deploy").Option("missingkey=error").Funcs(deployFuncs).Parse(d.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid deploy payload template: %w", err)
+	}
+	d.payload = tmpl
+	return nil
+}
+
+// render builds the deploy payload for a merge, which must be valid JSON
+func (d *DeployConfig) render(event DeployEvent) (string, error) {
+	if d.payload == nil {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal deploy event: %w", err)
+		}
+		return string(b), nil
+	}
+
+	var b bytes.Buffer
+	if err := d.payload.Execute(&b, event); err != nil {
+		return "", fmt.Errorf("failed to render deploy payload: %w", err)
+	}
+	if !json.Valid(b.Bytes()) {
+		return "", fmt.Errorf("deploy payload is not valid JSON: %s", b.String())
+	}
+	return b.String(), nil
+}
+
+// triggerDeploy sends the deploy payload of a merge Poppit completed, when its repository has a deploy trigger
+func triggerDeploy(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) error {
+	deploy := config.repoSettings(result.Repo).Deploy
+	if deploy == nil {
+		return nil
+	}
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		return err
+	}
+	// Ready, approve, close, revert and action results don't merge anything
+	if !found || !mergeRequest(requested) {
+		return nil
+	}
+
+	payload, err := deploy.render(DeployEvent{
+		Repository:    result.Repo,
+		PRNumber:      requested.PRNumber,
+		SHA:           result.SHA,
+		CorrelationID: result.CorrelationID,
+		SlackUser:     requested.User,
+		GitHubUser:    requested.GitHubUser,
+		MergedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if deploy.Queue != "" {
+		if err := serviceSink(redisClient).Push(ctx, deploy.Queue, payload); err != nil {
+			return fmt.Errorf("failed to push to %s: %w", deploy.Queue, err)
+		}
+	}
+	if deploy.Channel != "" {
+		if err := serviceNotifier(redisClient).Publish(ctx, deploy.Channel, payload); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", deploy.Channel, err)
+		}
+	}
+	if deploy.Webhook != "" {
+		if err := postDeployWebhook(ctx, deploy.Webhook, payload); err != nil {
+			return err
+		}
+	}
+	logInfo("Triggered deploy of %s after merging PR %d", result.Repo, requested.PRNumber)
+	return nil
+}
+
+func postDeployWebhook(ctx context.Context, webhook, payload string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewBufferString(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build deploy webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := deployHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call deploy webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("deploy webhook returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// digestMetadata keeps the valid PR entries of a digest message, which inherit the digest's event action
+func digestMetadata(metadata *PRMetadata) *PRMetadata {
+	var entries []PRMetadata
+	for _, entry := range metadata.PRs {
+		if entry.PRNumber == 0 || entry.Repository == "" {
+			logWarning("Skipping digest entry without a repository and PR number: %+v", entry)
+			continue
+		}
+		if entry.EventAction == "" {
+			entry.EventAction = metadata.EventAction
+		}
+		entry.PRs = nil
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return &PRMetadata{EventAction: metadata.EventAction, PRs: entries, AllOrNothing: metadata.AllOrNothing}
+}
+
+// handleDigestReaction submits every PR of a digest in order and replies in the thread with the result for each
+func handleDigestReaction(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, reactionEvent ReactionEvent, metadata *PRMetadata, audit AuditEntry) {
+	entries := metadata.PRs
+	if metadata.AllOrNothing {
+		logInfo("Found all-or-nothing digest of %d PRs", len(entries))
+		// The fan-out is kept in Redis, which has no use for the event's verification token
+		reactionEvent.Token = ""
+		fanOut := FanOut{Event: reactionEvent, Audit: audit, Entries: entries}
+		if err := fanOut.run(ctx, redisClient, slackClient, config); err != nil {
+			logEventError("Error handling all-or-nothing digest: %v", err)
+		}
+		return
+	}
+	logInfo("Found digest of %d PRs", len(entries))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Requested %d PRs from this digest:\n", len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		entryAudit := audit
+		entryAudit.Source = "digest"
+
+		decision, err := requestPR(ctx, redisClient, slackClient, config, reactionEvent, entry, true, &entryAudit)
+		entryAudit.finish(context.WithoutCancel(ctx), redisClient, config, decision, err)
+		if err != nil {
+			logEventError("Error handling PR %d in %s from digest: %v", entry.PRNumber, entry.Repository, err)
+		}
+
+		fmt.Fprintf(&b, "• %s#%d: %s", entry.Repository, entry.PRNumber, entryAudit.Decision)
+		if entryAudit.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", entryAudit.Reason)
+		}
+		b.WriteString("\n")
+	}
+
+	notifyThread(ctx, slackClient, audit.Channel, audit.Ts, b.String())
+}
+
+// FanOut is an all-or-nothing digest in progress, kept in Redis while Poppit merges one of its PRs. Each PR is only
+// requested once the one before it has merged, and the rest are abandoned as soon as one isn't.
+type FanOut struct {
+	Event   ReactionEvent `json:"event"`
+	Audit   AuditEntry    `json:"audit"`
+	Entries []PRMetadata  `json:"entries"`
+	// Next is the entry to request next; the one before it is the PR being merged while the fan-out waits
+	Next int `json:"next"`
+	// Merged is set once the fan-out has waited on a merge, so its PRs are merged rather than only requested
+	Merged bool `json:"merged,omitempty"`
+	// Source is the audit source of its PRs' requests, digest or stack
+	Source string `json:"source,omitempty"`
+}
+
+func fanOutKey(config *Config, correlationID string) string {
+	return fmt.Sprintf("%s:%s", config.FanOutKeyPrefix, correlationID)
+}
+
+// run requests the fan-out's PRs from Next on until one is queued for a merge, which advanceFanOut picks up from once
+// Poppit reports on it, or until every PR has been requested or one is refused
+func (f FanOut) run(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config) error {
+	for ; f.Next < len(f.Entries); f.Next++ {
+		entry := &f.Entries[f.Next]
+		entryAudit := f.Audit
+		entryAudit.Source = f.Source
+		if entryAudit.Source == "" {
+			entryAudit.Source = "digest"
+		}
+
+		decision, err := requestPR(ctx, redisClient, slackClient, config, f.Event, entry, true, &entryAudit)
+		entryAudit.finish(context.WithoutCancel(ctx), redisClient, config, decision, err)
+		if err != nil {
+			logEventError("Error handling PR %d in %s from %s: %v", entry.PRNumber, entry.Repository, entryAudit.Source, err)
+		}
+		if entryAudit.Decision != OutcomeQueued && entryAudit.Decision != OutcomeDeferred {
+			f.abort(ctx, slackClient, fmt.Sprintf("%s (%s)", entryAudit.Decision, entryAudit.Reason))
+			return nil
+		}
+		if !f.awaitsMerge(config, entryAudit) {
+			continue
+		}
+
+		f.Next++
+		f.Merged = true
+		fanOutJSON, err := json.Marshal(f)
+		if err != nil {
+			return fmt.Errorf("failed to marshal fan-out: %w", err)
+		}
+		key := fanOutKey(config, entryAudit.CorrelationID)
+		if err := redisClient.Set(ctx, key, string(fanOutJSON), time.Duration(config.FanOutTTL)*time.Second).Err(); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		if f.Next < len(f.Entries) {
+			logInfo("Waiting for PR %d in %s to merge before requesting the remaining %d PRs of the digest", entry.PRNumber, entry.Repository, len(f.Entries)-f.Next)
+		}
+		return nil
+	}
+	outcome := "requested"
+	if f.Merged {
+		outcome = "merged"
+	}
+	logInfo("All %d PRs of an all-or-nothing digest were %s", len(f.Entries), outcome)
+	if slackClient != nil && f.Audit.Ts != "" {
+		notifyThread(ctx, slackClient, f.Audit.Channel, f.Audit.Ts, fmt.Sprintf(":white_check_mark: All %d PRs of this change were %s.", len(f.Entries), outcome))
+	}
+	return nil
+}
+
+// awaitsMerge reports whether a request Poppit reports a merge result for, so the fan-out waits on it: a merge, or
+// a workflow with a merge step. Other emoji, such as the ready emoji, have nothing to wait for.
+func (f FanOut) awaitsMerge(config *Config, entryAudit AuditEntry) bool {
+	if !mergeRequest(entryAudit) || entryAudit.CorrelationID == "" {
+		return false
+	}
+	if entryAudit.Source != "workflow" {
+		return true
+	}
+	for _, step := range config.Workflows[f.Event.Event.Reaction] {
+		if step.Type == WorkflowStepMerge {
+			return true
+		}
+	}
+	return false
+}
+
+// abort abandons the PRs after the current one, saying why in the thread
+func (f FanOut) abort(ctx context.Context, slackClient *slack.Client, reason string) {
+	failed := f.Entries[f.Next]
+	var skipped []string
+	for _, entry := range f.Entries[f.Next+1:] {
+		skipped = append(skipped, fmt.Sprintf("%s#%d", entry.Repository, entry.PRNumber))
+	}
+	logWarning("PR %d in %s of an all-or-nothing digest wasn't merged (%s), abandoning %d PRs", failed.PRNumber, failed.Repository, reason, len(skipped))
+
+	text := fmt.Sprintf(":x: %s#%d wasn't merged: %s.", failed.Repository, failed.PRNumber, reason)
+	if len(skipped) > 0 {
+		text += fmt.Sprintf(" The rest of this change wasn't requested: %s.", strings.Join(skipped, ", "))
+	}
+	if slackClient != nil && f.Audit.Ts != "" {
+		notifyThread(ctx, slackClient, f.Audit.Channel, f.Audit.Ts, text)
+	}
+}
+
+// advanceFanOut carries on with the all-or-nothing digest waiting for a Poppit result, if any
+func advanceFanOut(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, result PoppitResult) error {
+	key := fanOutKey(config, result.CorrelationID)
+	fanOutJSON, err := redisClient.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	// Only the caller that removes the fan-out gets to advance it
+	removed, err := redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	if removed == 0 {
+		return nil
+	}
+
+	var f FanOut
+	if err := json.Unmarshal([]byte(fanOutJSON), &f); err != nil {
+		return fmt.Errorf("failed to unmarshal fan-out: %w", err)
+	}
+	slackClient := clients.forWorkspace(config.workspace(f.Event.TeamID))
+	if !result.Success {
+		f.Next--
+		f.abort(ctx, slackClient, failureReason(result))
+		return nil
+	}
+	return f.run(ctx, redisClient, slackClient, config)
+}
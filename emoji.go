@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// parseEmojiAliases parses EMOJI_ALIASES, a comma-separated list of alias=emoji pairs such as `thumbsup=+1`
+func parseEmojiAliases(value string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		alias, emoji, ok := strings.Cut(part, "=")
+		alias, emoji = trimEmoji(alias), trimEmoji(emoji)
+		if !ok || alias == "" || emoji == "" {
+			return nil, fmt.Errorf("invalid EMOJI_ALIASES entry %q, expected alias=emoji", part)
+		}
+		aliases[alias] = emoji
+	}
+	return aliases, nil
+}
+
+// trimEmoji drops the spaces and colons around an emoji name, so `:ship:` and `ship` are the same emoji
+func trimEmoji(name string) string {
+	return strings.Trim(strings.TrimSpace(name), ":")
+}
+
+// normalizeReaction turns a reaction's name as Slack sends it into the emoji it is configured as. The skin tone is
+// dropped, so `+1::skin-tone-3` matches `+1`, then the workspace's aliases are resolved, so with `thumbsup=+1` a
+// `thumbsup::skin-tone-4` reaction matches `+1` too.
+func (w WorkspaceSettings) normalizeReaction(reaction string) string {
+	base, _, _ := strings.Cut(trimEmoji(reaction), "::skin-tone-")
+	if emoji, ok := w.EmojiAliases[base]; ok {
+		return emoji
+	}
+	return base
+}
+
+// emojiAliases merges a workspace's aliases, from its target_emoji list and emoji_aliases, over the global ones
+func (c *Config) emojiAliases(overrides ...map[string]string) map[string]string {
+	aliases := c.EmojiAliases
+	for _, override := range overrides {
+		if len(override) == 0 {
+			continue
+		}
+		aliases = maps.Clone(aliases)
+		if aliases == nil {
+			aliases = make(map[string]string, len(override))
+		}
+		for alias, emoji := range override {
+			aliases[trimEmoji(alias)] = trimEmoji(emoji)
+		}
+	}
+	return aliases
+}
+
+// splitTargetEmoji splits a comma-separated TARGET_EMOJI into the target emoji, the first, and aliases that make
+// the others count as it, so `heart_eyes_cat,shipit` merges on either
+func splitTargetEmoji(value string) (string, map[string]string) {
+	var target string
+	aliases := make(map[string]string)
+	for _, emoji := range strings.Split(value, ",") {
+		emoji = trimEmoji(emoji)
+		switch {
+		case emoji == "":
+		case target == "":
+			target = emoji
+		case emoji != target:
+			aliases[emoji] = target
+		}
+	}
+	return target, aliases
+}
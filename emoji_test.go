@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+func TestNormalizeReaction(t *testing.T) {
+	workspace := WorkspaceSettings{EmojiAliases: map[string]string{"thumbsup": "+1", "shipit": "ship"}}
+
+	tests := []struct {
+		name     string
+		reaction string
+		want     string
+	}{
+		{"plain", "ship", "ship"},
+		{"colons", ":ship:", "ship"},
+		{"skin tone", "+1::skin-tone-3", "+1"},
+		{"alias", "thumbsup", "+1"},
+		{"alias with skin tone", "thumbsup::skin-tone-4", "+1"},
+		{"alias with colons", ":shipit:", "ship"},
+		{"unknown", "tada", "tada"},
+		{"skin tone without alias", "wave::skin-tone-6", "wave"},
+		{"alias target is not aliased back", "+1", "+1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workspace.normalizeReaction(tt.reaction); got != tt.want {
+				t.Errorf("normalizeReaction(%q) = %q, want %q", tt.reaction, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeReactionWithoutAliases(t *testing.T) {
+	if got := (WorkspaceSettings{}).normalizeReaction("thumbsup::skin-tone-2"); got != "thumbsup" {
+		t.Errorf("normalizeReaction = %q, want %q", got, "thumbsup")
+	}
+}
+
+func TestParseEmojiAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"single", "thumbsup=+1", map[string]string{"thumbsup": "+1"}, false},
+		{"colons and spaces", " :thumbsup: = :+1: , shipit=ship", map[string]string{"thumbsup": "+1", "shipit": "ship"}, false},
+		{"trailing comma", "thumbsup=+1,", map[string]string{"thumbsup": "+1"}, false},
+		{"missing emoji", "thumbsup=", nil, true},
+		{"missing alias", "=+1", nil, true},
+		{"no separator", "thumbsup", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEmojiAliases(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEmojiAliases(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseEmojiAliases(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			for alias, emoji := range tt.want {
+				if got[alias] != emoji {
+					t.Errorf("parseEmojiAliases(%q)[%q] = %q, want %q", tt.value, alias, got[alias], emoji)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitTargetEmoji(t *testing.T) {
+	target, aliases := splitTargetEmoji(":heart_eyes_cat:, shipit,heart_eyes_cat,")
+	if target != "heart_eyes_cat" {
+		t.Errorf("target = %q, want %q", target, "heart_eyes_cat")
+	}
+	if len(aliases) != 1 || aliases["shipit"] != "heart_eyes_cat" {
+		t.Errorf("aliases = %v, want map[shipit:heart_eyes_cat]", aliases)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Feature flags that can be flipped at runtime in the FLAGS_KEY hash, e.g.
+// `redis-cli HSET vibemerge:flags parse_pr_links true`. A flag missing from the hash falls back to its setting.
+const (
+	FlagParsePRLinks       = "parse_pr_links"
+	FlagIdentityEmailMatch = "identity_email_match"
+	FlagSerializeMerges    = "serialize_merges"
+	FlagOpsErrorAlerts     = "ops_error_alerts"
+	FlagMergeTrain         = "merge_train"
+)
+
+// knownFlags are the flags consulted at decision points
+var knownFlags = []string{FlagParsePRLinks, FlagIdentityEmailMatch, FlagSerializeMerges, FlagOpsErrorAlerts, FlagMergeTrain}
+
+// featureFlags holds the flags last read from FLAGS_KEY
+var featureFlags atomic.Pointer[map[string]bool]
+
+// flagValues are the raw values last read from FLAGS_KEY, so a bad value is only warned about when it's set.
+// Only the goroutine refreshing the flags touches it.
+var flagValues map[string]string
+
+// flagEnabled reports whether a feature flag is on, or fallback when the hash doesn't set it
+func flagEnabled(name string, fallback bool) bool {
+	flags := featureFlags.Load()
+	if flags == nil {
+		return fallback
+	}
+	if enabled, ok := (*flags)[name]; ok {
+		return enabled
+	}
+	return fallback
+}
+
+// processFeatureFlags re-reads the feature flags every FLAGS_REFRESH_INTERVAL seconds
+func processFeatureFlags(ctx context.Context, redisClient *redis.Client) {
+	ticker := time.NewTicker(time.Duration(currentConfig().FlagsRefreshInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := refreshFeatureFlags(ctx, redisClient, currentConfig()); err != nil {
+				logError("Error refreshing feature flags: %v", err)
+			}
+		}
+	}
+}
+
+// refreshFeatureFlags reads the FLAGS_KEY hash and logs every flag that changed since the last read. Values that
+// aren't booleans and flags VibeMerge doesn't know are ignored with a warning.
+func refreshFeatureFlags(ctx context.Context, redisClient *redis.Client, config *Config) error {
+	values, err := redisClient.HGetAll(ctx, config.FlagsKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.FlagsKey, err)
+	}
+
+	flags := make(map[string]bool, len(values))
+	for name, value := range values {
+		last, seen := flagValues[name]
+		warn := !seen || last != value
+		if !slices.Contains(knownFlags, name) {
+			if warn {
+				logWarning("Ignoring unknown feature flag %s in %s", name, config.FlagsKey)
+			}
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			if warn {
+				logWarning("Ignoring feature flag %s in %s: invalid boolean %q", name, config.FlagsKey, value)
+			}
+			continue
+		}
+		flags[name] = enabled
+	}
+	flagValues = values
+
+	previous := featureFlags.Swap(&flags)
+	if previous == nil {
+		for _, name := range slices.Sorted(maps.Keys(flags)) {
+			logInfo("Feature flag %s is %t", name, flags[name])
+		}
+		return nil
+	}
+	for _, name := range knownFlags {
+		before, wasSet := (*previous)[name]
+		after, isSet := flags[name]
+		switch {
+		case wasSet && !isSet:
+			logInfo("Feature flag %s was removed, falling back to its setting", name)
+		case isSet && (!wasSet || before != after):
+			logInfo("Feature flag %s changed to %t", name, after)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// freezeCalendarTimeout bounds a single fetch of FREEZE_CALENDAR_URL
+const freezeCalendarTimeout = 30 * time.Second
+
+// Freeze is a one-off period during which no merges are queued, such as a change-control period or a holiday
+type Freeze struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Summary string    `json:"summary,omitempty"`
+}
+
+func (f Freeze) contains(t time.Time) bool {
+	return !t.Before(f.Start) && t.Before(f.End)
+}
+
+// calendarFreezes holds the freezes last read from FREEZE_CALENDAR_URL
+var calendarFreezes atomic.Pointer[[]Freeze]
+
+// parseFreezeDates parses a comma-separated list of days and inclusive day ranges in loc, such as
+// "2026-12-24..2027-01-02,2027-03-31"
+func parseFreezeDates(spec string, loc *time.Location) ([]Freeze, error) {
+	var freezes []Freeze
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "..")
+		if !isRange {
+			last = first
+		}
+		start, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(first), loc)
+		if err != nil {
+			return nil, fmt.Errorf("freeze %q must be a day like 2026-12-24 or a range like 2026-12-24..2027-01-02", part)
+		}
+		end, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(last), loc)
+		if err != nil {
+			return nil, fmt.Errorf("freeze %q must be a day like 2026-12-24 or a range like 2026-12-24..2027-01-02", part)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("freeze %q ends before it starts", part)
+		}
+		freezes = append(freezes, Freeze{Start: start, End: end.AddDate(0, 0, 1), Summary: part})
+	}
+	return freezes, nil
+}
+
+// parseICalendar reads the events of an iCalendar feed as freezes. All-day events in loc cover their days; events
+// without an end, recurring events and durations aren't supported and are skipped.
+func parseICalendar(r io.Reader, loc *time.Location) ([]Freeze, error) {
+	// Long lines are folded onto continuation lines starting with a space or tab
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+
+	var freezes []Freeze
+	var event *Freeze
+	var skip bool
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				event, skip = &Freeze{}, false
+			}
+		case "END":
+			if strings.EqualFold(value, "VEVENT") && event != nil {
+				if !skip && !event.Start.IsZero() && event.End.After(event.Start) {
+					freezes = append(freezes, *event)
+				}
+				event = nil
+			}
+		case "DTSTART", "DTEND":
+			if event == nil {
+				continue
+			}
+			t, allDay, err := parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(name, "DTSTART") {
+				event.Start = t
+				// An all-day event without DTEND lasts its day
+				if allDay && event.End.IsZero() {
+					event.End = t.AddDate(0, 0, 1)
+				}
+			} else {
+				event.End = t
+			}
+		case "SUMMARY":
+			if event != nil {
+				event.Summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+			}
+		case "RRULE", "DURATION":
+			skip = true
+		}
+	}
+	return freezes, nil
+}
+
+// parseICalTime parses an iCalendar DATE or DATE-TIME, in UTC, its TZID or otherwise loc, reporting whether it's a
+// whole day
+func parseICalTime(value, params string, loc *time.Location) (time.Time, bool, error) {
+	for _, param := range strings.Split(params, ";") {
+		if tzid, ok := strings.CutPrefix(param, "TZID="); ok {
+			zone, err := time.LoadLocation(strings.Trim(tzid, `"`))
+			if err != nil {
+				return time.Time{}, false, fmt.Errorf("unknown calendar time zone %q", tzid)
+			}
+			loc = zone
+		}
+	}
+	if len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid calendar date %q", value)
+		}
+		return t, true, nil
+	}
+	if utc, ok := strings.CutSuffix(value, "Z"); ok {
+		value, loc = utc, time.UTC
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid calendar time %q", value)
+	}
+	return t, false, nil
+}
+
+// processFreezeCalendar reads FREEZE_CALENDAR_URL on startup and every FREEZE_CALENDAR_REFRESH seconds. A failed
+// fetch keeps the freezes last read.
+func processFreezeCalendar(ctx context.Context) {
+	if currentConfig().FreezeCalendarURL == "" {
+		return
+	}
+	if err := refreshFreezeCalendar(ctx, currentConfig()); err != nil {
+		logError("Error reading freeze calendar: %v", err)
+	}
+
+	ticker := time.NewTicker(time.Duration(currentConfig().FreezeCalendarRefresh) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := refreshFreezeCalendar(ctx, currentConfig()); err != nil {
+				logError("Error reading freeze calendar: %v", err)
+			}
+		}
+	}
+}
+
+func refreshFreezeCalendar(ctx context.Context, config *Config) error {
+	ctx, cancel := context.WithTimeout(ctx, freezeCalendarTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.FreezeCalendarURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch calendar: HTTP %d", resp.StatusCode)
+	}
+
+	freezes, err := parseICalendar(io.LimitReader(resp.Body, 16<<20), config.Timezone)
+	if err != nil {
+		return err
+	}
+	calendarFreezes.Store(&freezes)
+	logDebug("Read %d freezes from the freeze calendar", len(freezes))
+	return nil
+}
+
+// freezes lists FREEZE_DATES and the freezes from FREEZE_CALENDAR_URL, by start
+func (c *Config) freezes() []Freeze {
+	freezes := append([]Freeze(nil), c.FreezeDates...)
+	if calendar := calendarFreezes.Load(); calendar != nil {
+		freezes = append(freezes, *calendar...)
+	}
+	sort.Slice(freezes, func(i, j int) bool { return freezes[i].Start.Before(freezes[j].Start) })
+	return freezes
+}
+
+// frozenUntil reports whether t is inside a blackout window or a freeze and, if so, when merging may resume.
+// Overlapping or back-to-back windows and freezes are followed through to the end of the last one.
+func (c *Config) frozenUntil(t time.Time) (time.Time, bool) {
+	freezes := c.freezes()
+	until, blocked := blackoutUntil(c.BlackoutWindows, t)
+
+	// Each iteration moves past at least one freeze, so this terminates after at most len(freezes) steps
+	for i := 0; i <= len(freezes); i++ {
+		moved := false
+		for _, freeze := range freezes {
+			if freeze.contains(until) {
+				until, moved = freeze.End.In(t.Location()), true
+			}
+		}
+		if !moved {
+			break
+		}
+		blocked = true
+		until, _ = blackoutUntil(c.BlackoutWindows, until)
+	}
+	return until, blocked
+}
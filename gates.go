@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// isAuthorized reports whether a Slack user may trigger merge actions. An empty AUTHORIZED_USERS allows everyone.
+func (c *Config) isAuthorized(user string) bool {
+	if len(c.AuthorizedUsers) == 0 {
+		return true
+	}
+	return slices.Contains(c.AuthorizedUsers, user)
+}
+
+// checkAuthorized denies merges requested by users outside AUTHORIZED_USERS
+func checkAuthorized(job MergeJob, config *Config) (Decision, bool) {
+	if config.isAuthorized(job.RequestedBy) {
+		return Decision{}, false
+	}
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  "requester not authorized",
+		Note:    fmt.Sprintf(":lock: Sorry <@%s>, you're not on the list of people who can merge with VibeMerge, so PR #%d was not queued.", job.RequestedBy, job.PRNumber),
+	}, true
+}
+
+// checkSelfMerge denies a merge requested by the PR's own author when the repository doesn't allow self-merges
+func checkSelfMerge(job MergeJob, settings RepoSettings) (Decision, bool) {
+	if settings.AllowSelfMerge || job.Author == "" {
+		return Decision{}, false
+	}
+
+	if job.Payload.GitHubUser == "" {
+		return Decision{
+			Outcome: OutcomeDenied,
+			Reason:  "self-merge check: requester has no GitHub identity mapping",
+			Note: fmt.Sprintf(":wave: Sorry, %s doesn't allow authors to merge their own PRs and I couldn't work out your GitHub account, so PR #%d was not queued. Ask an admin to add you to the identity mapping.",
+				job.Payload.Repo, job.PRNumber),
+		}, true
+	}
+
+	if strings.EqualFold(job.Payload.GitHubUser, job.Author) {
+		return Decision{
+			Outcome: OutcomeDenied,
+			Reason:  "self-merge not allowed",
+			Note: fmt.Sprintf(":wave: Thanks for the enthusiasm! %s doesn't allow authors to merge their own PRs, so PR #%d needs a reaction from someone else.",
+				job.Payload.Repo, job.PRNumber),
+		}, true
+	}
+
+	return Decision{}, false
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// gcpSecrets reads secrets from a Google Cloud Secret Manager secret version holding a JSON object, whose keys
+// are the environment variable names they replace
+type gcpSecrets struct {
+	// name is the secret version resource, e.g. projects/my-project/secrets/vibemerge/versions/latest
+	name string
+}
+
+func newGCPSecrets(config *Config) (*gcpSecrets, error) {
+	if config.GCPSecret == "" {
+		return nil, fmt.Errorf("SECRETS_PROVIDER=%s requires GCP_SECRET", SecretsProviderGCP)
+	}
+	name := config.GCPSecret
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	return &gcpSecrets{name: name}, nil
+}
+
+// Secrets reads the secret version with AccessSecretVersion. The client finds Application Default Credentials:
+// GOOGLE_APPLICATION_CREDENTIALS, gcloud's user credentials, workload identity federation, or the service account
+// of a GCE VM or, with Workload Identity, a GKE pod. A client is opened per fetch, as a provider is per refresh.
+func (g *gcpSecrets) Secrets(ctx context.Context) (map[string]string, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+	defer client.Close()
+
+	version, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: g.name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", g.name, err)
+	}
+	return parseSecretJSON(version.GetPayload().GetData(), g.name)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultGitHubHost is the host gh and the GitHub API use unless GH_HOST points at a GitHub Enterprise Server
+const defaultGitHubHost = "github.com"
+
+// gitHubAPIURL returns the REST API base URL of a GitHub host. GitHub Enterprise Server serves it under /api/v3.
+func gitHubAPIURL(host string) string {
+	if host == "" || host == defaultGitHubHost {
+		return "https://api.github.com"
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// checkGitHubHost rejects a GH_HOST given as a URL rather than a host name
+func checkGitHubHost(host string) error {
+	if strings.Contains(host, "/") {
+		return fmt.Errorf("GH_HOST must be a host name such as github.example.com, got %q", host)
+	}
+	return nil
+}
+
+// loadGitHubHTTPClient builds the client for the GitHub API trusting GITHUB_CA_FILE as well as the system roots, or
+// returns nil when it isn't set
+func loadGitHubHTTPClient(config *Config) (*http.Client, error) {
+	if config.GitHubCAFile == "" {
+		return nil, nil
+	}
+	caPEM, err := os.ReadFile(config.GitHubCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GITHUB_CA_FILE: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in GITHUB_CA_FILE %s", config.GitHubCAFile)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	return &http.Client{Timeout: githubTimeout, Transport: transport}, nil
+}
+
+// ghEnv returns the environment gh and git commands among Poppit commands run with: GH_HOST, since commands only
+// pass owner/repo and gh would otherwise talk to github.com, and the GitHub App's token when there is one. gh reads
+// the token for an Enterprise Server host from GH_ENTERPRISE_TOKEN rather than GH_TOKEN.
+func (c *Config) ghEnv(token string) string {
+	var env []string
+	enterprise := c.GitHubHost != "" && c.GitHubHost != defaultGitHubHost
+	if enterprise {
+		env = append(env, "GH_HOST="+c.GitHubHost)
+	}
+	if token != "" {
+		if enterprise {
+			env = append(env, "GH_ENTERPRISE_TOKEN="+token)
+		} else {
+			env = append(env, "GH_TOKEN="+token)
+		}
+	}
+	return strings.Join(env, " ")
+}
+
+// ghCommands prefixes the gh and git commands among Poppit commands with env
+func ghCommands(commands []string, env string) []string {
+	if env == "" {
+		return commands
+	}
+	prefixed := make([]string, len(commands))
+	for i, command := range commands {
+		if strings.HasPrefix(command, "gh ") || strings.HasPrefix(command, "git ") {
+			command = env + " " + command
+		}
+		prefixed[i] = command
+	}
+	return prefixed
+}
+
+// marshalPoppitPayload marshals a payload for Poppit, with its gh and git commands run in the environment ghEnv sets
+func marshalPoppitPayload(ctx context.Context, config *Config, payload PoppitPayload) ([]byte, error) {
+	var token string
+	if config.GitHubAppKey != nil {
+		var err error
+		if token, err = githubAppTokens.get(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+	payload.Commands = ghCommands(payload.Commands, config.ghEnv(token))
+	return json.Marshal(payload)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PR states recorded from GitHub webhooks
+const (
+	PRStateMerged = "merged"
+	PRStateClosed = "closed"
+)
+
+// maxWebhookBody bounds the size of a GitHub webhook delivery read into memory
+const maxWebhookBody = 5 << 20
+
+// PullRequestEvent holds the fields VibeMerge needs from a GitHub pull_request webhook
+type PullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Merged bool `json:"merged"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func prStateKey(config *Config, repo string, prNumber int) string {
+	return fmt.Sprintf("%s:%s#%d", config.PRStateKeyPrefix, repo, prNumber)
+}
+
+// githubWebhookHandler receives GitHub pull_request events and records PRs closed or merged outside VibeMerge
+func githubWebhookHandler(redisClient *redis.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		config := currentConfig()
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if !validWebhookSignature(config.GitHubWebhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+			logWarning("Rejected GitHub webhook with an invalid signature from %s", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		if event := r.Header.Get("X-GitHub-Event"); event != "pull_request" {
+			logDebug("Ignoring GitHub %s event", event)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var event PullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+
+		if err := recordPullRequestEvent(r.Context(), redisClient, config, event); err != nil {
+			logError("Error handling GitHub pull_request event: %v", err)
+			http.Error(w, "failed to record event", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// validWebhookSignature checks the X-Hub-Signature-256 header against the HMAC of the body
+func validWebhookSignature(secret string, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func recordPullRequestEvent(ctx context.Context, redisClient *redis.Client, config *Config, event PullRequestEvent) error {
+	key := prStateKey(config, event.Repository.FullName, event.Number)
+
+	switch event.Action {
+	case "closed":
+		state := PRStateClosed
+		if event.PullRequest.Merged {
+			state = PRStateMerged
+		}
+		ttl := time.Duration(config.PRStateTTL) * time.Second
+		if err := redisClient.Set(ctx, key, state, ttl).Err(); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		logInfo("PR %d in %s was %s on GitHub", event.Number, event.Repository.FullName, state)
+		if event.PullRequest.Merged {
+			if err := releaseMergedCleanups(ctx, redisClient, config, event.Repository.FullName, event.Number); err != nil {
+				logWarning("Failed to clean up the messages of merged PR %d in %s: %v", event.Number, event.Repository.FullName, err)
+			}
+		}
+
+	case "reopened":
+		if err := redisClient.Del(ctx, key).Err(); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		logInfo("PR %d in %s was reopened on GitHub", event.Number, event.Repository.FullName)
+	}
+	return nil
+}
+
+// checkPRState ignores merges of PRs that GitHub reported as already merged or closed
+func checkPRState(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, bool, error) {
+	key := prStateKey(config, job.Payload.Repo, job.PRNumber)
+	state, err := redisClient.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return Decision{}, false, nil
+	}
+	if err != nil {
+		return Decision{}, false, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	if state == PRStateMerged {
+		return Decision{
+			Outcome: OutcomeIgnored,
+			Reason:  "PR already merged",
+			Note:    fmt.Sprintf(":white_check_mark: PR #%d in %s has already been merged, nothing to do.", job.PRNumber, job.Payload.Repo),
+		}, true, nil
+	}
+	return Decision{
+		Outcome: OutcomeIgnored,
+		Reason:  "PR closed",
+		Note:    fmt.Sprintf(":x: PR #%d in %s has been closed, so it wasn't queued. Reopen it and react again to merge it.", job.PRNumber, job.Payload.Repo),
+	}, true, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestValidWebhookSignature(t *testing.T) {
+	body := []byte(`{"action":"closed","number":42}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		header string
+		want   bool
+	}{
+		{"valid", "secret", body, signature, true},
+		{"wrong secret", "other", body, signature, false},
+		{"tampered body", "secret", []byte(`{"action":"closed","number":43}`), signature, false},
+		{"missing header", "secret", body, "", false},
+		{"sha1 prefix", "secret", body, "sha1=" + signature[len("sha256="):], false},
+		{"not hex", "secret", body, "sha256=not-hex", false},
+		{"truncated", "secret", body, signature[:len(signature)-2], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validWebhookSignature(tt.secret, tt.body, tt.header); got != tt.want {
+				t.Errorf("validWebhookSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
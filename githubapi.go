@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// githubTimeout bounds each GitHub API request, which is made while a reaction is being handled
+const githubTimeout = 10 * time.Second
+
+var githubHTTPClient = &http.Client{Timeout: githubTimeout}
+
+// githubAPIError is a GitHub API response with an error status
+type githubAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *githubAPIError) Error() string {
+	return fmt.Sprintf("GitHub API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// githubPullRequest is the part of a GitHub pull request VibeMerge reads
+type githubPullRequest struct {
+	Number         int           `json:"number"`
+	Title          string        `json:"title"`
+	State          string        `json:"state"`
+	Draft          bool          `json:"draft"`
+	Merged         bool          `json:"merged"`
+	Additions      int           `json:"additions"`
+	Deletions      int           `json:"deletions"`
+	Labels         []githubLabel `json:"labels"`
+	Mergeable      *bool         `json:"mergeable"`
+	MergeableState string        `json:"mergeable_state"`
+	MergeCommitSHA string        `json:"merge_commit_sha"`
+	HTMLURL        string        `json:"html_url"`
+	User           struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+		// Repo is the repository the branch lives in, a fork for cross-repository PRs, and null once it's deleted
+		Repo *struct {
+			FullName string `json:"full_name"`
+		} `json:"repo"`
+	} `json:"head"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+// getPullRequest reads a PR from the GitHub API
+func getPullRequest(ctx context.Context, config *Config, repo string, number int) (githubPullRequest, error) {
+	var pr githubPullRequest
+	err := githubRequest(ctx, config, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr)
+	return pr, err
+}
+
+// mergedPullRequest reads from GitHub the PR merged by a Poppit result. It reports false for results of requests
+// that don't merge anything, such as the ready, approve, close, revert and action emoji.
+func mergedPullRequest(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) (githubPullRequest, bool, error) {
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		return githubPullRequest{}, false, err
+	}
+	if !found || !mergeRequest(requested) || requested.PRNumber == 0 {
+		return githubPullRequest{}, false, nil
+	}
+	pr, err := getPullRequest(ctx, config, result.Repo, requested.PRNumber)
+	if err != nil {
+		return pr, false, fmt.Errorf("failed to read PR %d in %s: %w", requested.PRNumber, result.Repo, err)
+	}
+	return pr, true, nil
+}
+
+// githubRequest calls a GitHub REST API path with GITHUB_TOKEN or the GitHub App's token, decoding the JSON response
+// into out
+func githubRequest(ctx context.Context, config *Config, method, path string, body, out any) error {
+	return githubDo(ctx, config, method, strings.TrimSuffix(config.GitHubAPIURL, "/")+path, body, out)
+}
+
+// githubGraphQL runs a GraphQL query, decoding its data into out. GitHub Enterprise Server serves GraphQL at
+// /api/graphql rather than under the /api/v3 REST prefix.
+func githubGraphQL(ctx context.Context, config *Config, query string, variables map[string]any, out any) error {
+	base := strings.TrimSuffix(config.GitHubAPIURL, "/")
+	url := base + "/graphql"
+	if prefix, ok := strings.CutSuffix(base, "/api/v3"); ok {
+		url = prefix + "/api/graphql"
+	}
+
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	request := map[string]any{"query": query, "variables": variables}
+	if err := githubDo(ctx, config, http.MethodPost, url, request, &response); err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("GitHub GraphQL query failed: %s", response.Errors[0].Message)
+	}
+	return json.Unmarshal(response.Data, out)
+}
+
+func githubDo(ctx context.Context, config *Config, method, url string, body, out any) error {
+	token, err := githubToken(ctx, config)
+	if err != nil {
+		return err
+	}
+	return githubSend(ctx, config, method, url, token, body, out)
+}
+
+// githubSend makes a GitHub API request authenticated with token
+func githubSend(ctx context.Context, config *Config, method, url, token string, body, out any) error {
+	// A simulation reads from GitHub but changes nothing there; GraphQL is only used for queries
+	if dryRun != nil && method != http.MethodGet && !strings.HasSuffix(url, "/graphql") {
+		dryRun.add("GitHub %s %s", method, url)
+		return nil
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal GitHub request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := githubHTTPClient
+	if config.GitHubHTTPClient != nil {
+		client = config.GitHubHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var message struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&message)
+		return &githubAPIError{StatusCode: resp.StatusCode, Message: message.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+	return nil
+}
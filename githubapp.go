@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// githubAppTokenMargin is how long an installation token must still be valid to be used. Tokens last an hour, and
+// one handed to Poppit may wait in its queue before its commands run.
+const githubAppTokenMargin = 20 * time.Minute
+
+// githubAppTokens caches the installation token of the GitHub App VibeMerge authenticates as
+var githubAppTokens = &installationTokens{}
+
+// installationTokens fetches GitHub App installation tokens and reuses each until it is about to expire
+type installationTokens struct {
+	mu             sync.Mutex
+	appID          int
+	installationID int
+	token          string
+	expiresAt      time.Time
+}
+
+// get returns an installation token valid for at least githubAppTokenMargin, fetching a new one when needed
+func (t *installationTokens) get(ctx context.Context, config *Config) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// A reload may point at another app or installation, whose token this isn't
+	if t.token != "" && t.appID == config.GitHubAppID && t.installationID == config.GitHubAppInstallationID &&
+		time.Until(t.expiresAt) > githubAppTokenMargin {
+		return t.token, nil
+	}
+
+	jwt, err := githubAppJWT(config, time.Now())
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(config.GitHubAPIURL, "/"), config.GitHubAppInstallationID)
+	if err := githubSend(ctx, config, http.MethodPost, url, jwt, nil, &response); err != nil {
+		return "", fmt.Errorf("failed to create a GitHub App installation token: %w", err)
+	}
+
+	t.appID, t.installationID = config.GitHubAppID, config.GitHubAppInstallationID
+	t.token, t.expiresAt = response.Token, response.ExpiresAt
+	metrics.Add("github_app_token_refreshes", 1)
+	logDebug("Created a GitHub App installation token expiring at %s", response.ExpiresAt.Format(time.RFC3339))
+	return t.token, nil
+}
+
+// githubAppJWT signs the JSON Web Token a GitHub App exchanges for installation tokens. It is backdated a minute
+// against clock drift and lasts under GitHub's ten minute limit.
+func githubAppJWT(config *Config, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": fmt.Sprint(config.GitHubAppID),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, config.GitHubAppKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the GitHub App JWT: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseGitHubAppKey parses a GitHub App private key, which GitHub issues in PKCS #1 PEM
+func parseGitHubAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return key, nil
+}
+
+// loadGitHubApp parses the GitHub App private key from GITHUB_APP_PRIVATE_KEY, or the file GITHUB_APP_PRIVATE_KEY_FILE
+// names, and checks the app is configured completely
+func loadGitHubApp(config *Config) error {
+	keyPEM := []byte(config.GitHubAppPrivateKey)
+	if config.GitHubAppPrivateKeyFile != "" {
+		data, err := os.ReadFile(config.GitHubAppPrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read GITHUB_APP_PRIVATE_KEY_FILE: %w", err)
+		}
+		keyPEM = data
+	}
+
+	if config.GitHubAppID == 0 && config.GitHubAppInstallationID == 0 && len(keyPEM) == 0 {
+		return nil
+	}
+	if config.GitHubAppID <= 0 || config.GitHubAppInstallationID <= 0 || len(keyPEM) == 0 {
+		return fmt.Errorf("GITHUB_APP_ID, GITHUB_APP_INSTALLATION_ID and GITHUB_APP_PRIVATE_KEY or GITHUB_APP_PRIVATE_KEY_FILE must be set together")
+	}
+	if config.GitHubToken != "" {
+		return fmt.Errorf("GITHUB_TOKEN and GITHUB_APP_ID can't both be set")
+	}
+	key, err := parseGitHubAppKey(keyPEM)
+	if err != nil {
+		return fmt.Errorf("invalid GitHub App private key: %w", err)
+	}
+	config.GitHubAppKey = key
+	return nil
+}
+
+// hasGitHubAPI reports whether VibeMerge can call the GitHub API, with GITHUB_TOKEN or as a GitHub App
+func (c *Config) hasGitHubAPI() bool {
+	return c.GitHubToken != "" || c.GitHubAppID != 0
+}
+
+// githubToken returns the token GitHub API requests are made with: an installation token when VibeMerge is a
+// GitHub App, otherwise GITHUB_TOKEN
+func githubToken(ctx context.Context, config *Config) (string, error) {
+	if config.GitHubAppKey == nil {
+		return config.GitHubToken, nil
+	}
+	return githubAppTokens.get(ctx, config)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// controlServer implements the ControlService gRPC API defined in proto/vibemerge/v1/control.proto
+type controlServer struct {
+	UnimplementedControlServiceServer
+	redisClient *redis.Client
+	clients     *slackClients
+}
+
+// serveGRPC runs the gRPC control-plane listener until ctx is cancelled
+func serveGRPC(ctx context.Context, addr string, redisClient *redis.Client, clients *slackClients) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logError("gRPC server failed to listen on %s: %v", addr, err)
+		return
+	}
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcAuth))
+	RegisterControlServiceServer(server, &controlServer{redisClient: redisClient, clients: clients})
+	context.AfterFunc(ctx, server.GracefulStop)
+
+	logInfo("Serving gRPC on %s", addr)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		logError("gRPC server stopped: %v", err)
+	}
+}
+
+// grpcAuth rejects calls without API_TOKEN as a bearer token in the authorization metadata
+func grpcAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		token, _ = strings.CutPrefix(md.Get("authorization")[0], "Bearer ")
+	}
+	expected := currentConfig().APIToken
+	if token == "" || expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		metrics.Add("grpc_unauthorized", 1)
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid API token")
+	}
+	return handler(ctx, req)
+}
+
+func (s *controlServer) GetStatus(ctx context.Context, _ *GetStatusRequest) (*GetStatusResponse, error) {
+	config := currentConfig()
+	state, err := dumpState(ctx, s.redisClient, config)
+	if err != nil {
+		logError("Error reading state for gRPC: %v", err)
+		return nil, status.Error(codes.Internal, "failed to read state")
+	}
+
+	response := &GetStatusResponse{
+		InstanceId:        config.InstanceID,
+		Leader:            !config.LeaderElection || isLeader.Value() == 1,
+		Paused:            state.Paused,
+		PauseReason:       state.PauseReason,
+		StartedAt:         timestamppb.New(state.StartedAt),
+		PoppitQueueLength: state.PoppitQueueLength,
+		DeferredMerges:    state.DeferredMerges,
+	}
+	if state.BlackoutUntil != nil {
+		response.BlackoutUntil = timestamppb.New(*state.BlackoutUntil)
+	}
+	return response, nil
+}
+
+// GetConfig leaves out the fields tagged json:"-" and redacts deploy webhook URLs, like validate-config -print
+func (s *controlServer) GetConfig(_ context.Context, _ *GetConfigRequest) (*GetConfigResponse, error) {
+	configJSON, err := json.Marshal(currentConfig())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal the configuration: %v", err)
+	}
+	return &GetConfigResponse{ConfigJson: string(configJSON)}, nil
+}
+
+func (s *controlServer) Pause(ctx context.Context, req *PauseRequest) (*PauseResponse, error) {
+	reason := "paused through gRPC"
+	if req.GetReason() != "" {
+		reason = fmt.Sprintf("%s: %s", reason, req.GetReason())
+	}
+	if err := pauseMerging(ctx, s.redisClient, currentConfig(), reason); err != nil {
+		logError("Error pausing merging through gRPC: %v", err)
+		return nil, status.Error(codes.Internal, "failed to pause merging")
+	}
+	return &PauseResponse{Reason: reason}, nil
+}
+
+func (s *controlServer) Resume(ctx context.Context, _ *ResumeRequest) (*ResumeResponse, error) {
+	if err := resumeMerging(ctx, s.redisClient, currentConfig()); err != nil {
+		logError("Error resuming merging through gRPC: %v", err)
+		return nil, status.Error(codes.Internal, "failed to resume merging")
+	}
+	return &ResumeResponse{}, nil
+}
+
+func (s *controlServer) RequestMerge(ctx context.Context, req *RequestMergeRequest) (*RequestMergeResponse, error) {
+	if req.GetUser() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	config := currentConfig()
+	prMetadata, err := parsePRURL(req.GetPrUrl(), config)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := eventContext(ctx, config)
+	defer cancel()
+	decision, job, err := requestManualMerge(ctx, s.redisClient, s.clients, config, prMetadata, "grpc", req.GetTeamId(), req.GetUser(), "")
+	if err != nil {
+		logError("Error requesting merge of %s through gRPC: %v", req.GetPrUrl(), err)
+		return nil, status.Error(codes.Internal, "failed to request the merge")
+	}
+	logInfo("Merge of %s#%d requested through gRPC for %s: %s", prMetadata.Repository, prMetadata.PRNumber, req.GetUser(), decision.Outcome)
+	return &RequestMergeResponse{
+		Decision:      decision.Outcome,
+		Reason:        decision.Reason,
+		CorrelationId: job.Payload.CorrelationID,
+		Repository:    prMetadata.Repository,
+		PrNumber:      int32(prMetadata.PRNumber),
+	}, nil
+}
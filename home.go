@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// appHomeRows bounds how many recent decisions and pending merges the App Home tab lists
+const appHomeRows = 10
+
+// AppHomeEvent is the app_home_opened event Slack sends when a user opens the app's Home tab
+type AppHomeEvent struct {
+	TeamID string `json:"team_id"`
+	Event  struct {
+		Type string `json:"type"`
+		User string `json:"user"`
+		Tab  string `json:"tab"`
+	} `json:"event"`
+}
+
+func processAppHomeEvents(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	receiveMessages(ctx, redisClient, clients, currentConfig().AppHomeChannel, func(payload string) {
+		if err := handleAppHomeOpened(context.WithoutCancel(ctx), payload, redisClient, clients, currentConfig()); err != nil {
+			logError("Error publishing App Home: %v", err)
+		}
+	})
+}
+
+// handleAppHomeOpened publishes the Home tab of the user who opened it
+func handleAppHomeOpened(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, config *Config) error {
+	var event AppHomeEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return fmt.Errorf("failed to unmarshal app_home_opened event: %w", err)
+	}
+	// The Messages and About tabs are Slack's own
+	if event.Event.Type != "app_home_opened" || event.Event.Tab != "home" || event.Event.User == "" {
+		return nil
+	}
+
+	ctx, cancel := eventContext(ctx, config)
+	defer cancel()
+
+	workspace := config.workspace(event.TeamID)
+	slackClient := clients.forWorkspace(workspace)
+	if slackClient == nil {
+		logWarning("No Slack bot token configured for workspace %s, not publishing App Home", event.TeamID)
+		return nil
+	}
+
+	blocks, err := appHomeBlocks(ctx, redisClient, config, workspace, event.Event.User)
+	if err != nil {
+		return err
+	}
+	view := slack.HomeTabViewRequest{Type: slack.VTHomeTab, Blocks: slack.Blocks{BlockSet: blocks}}
+	return callSlack(ctx, "views.publish", func() error {
+		_, err := slackClient.PublishViewContext(ctx, slack.PublishViewContextRequest{UserID: event.Event.User, View: view})
+		return err
+	})
+}
+
+// appHomeBlocks lays out a user's Home tab: whether merging is paused or frozen, their pending merges, their recent
+// requests and what each emoji does in the workspace
+func appHomeBlocks(ctx context.Context, redisClient *redis.Client, config *Config, workspace WorkspaceSettings, user string) ([]slack.Block, error) {
+	section := func(text string) slack.Block {
+		return slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)
+	}
+	blocks := []slack.Block{slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "VibeMerge", false, false))}
+
+	var status strings.Builder
+	paused, reason, err := pauseState(ctx, redisClient, config)
+	if err != nil {
+		return nil, err
+	}
+	if paused {
+		fmt.Fprintf(&status, ":double_vertical_bar: Merging is paused (%s)\n", reason)
+	} else {
+		status.WriteString(":white_check_mark: Merging is active\n")
+	}
+	if until, blocked := config.frozenUntil(time.Now().In(config.Timezone)); blocked {
+		fmt.Fprintf(&status, ":snowflake: Merges are frozen until %s", until.Format("Mon 2 Jan 15:04 MST"))
+	} else {
+		status.WriteString(":sunny: No blackout window or freeze in effect")
+	}
+	blocks = append(blocks, section(status.String()), slack.NewDividerBlock())
+
+	pending, err := userPendingMerges(ctx, redisClient, config, user)
+	if err != nil {
+		return nil, err
+	}
+	blocks = append(blocks, section("*Your pending merges*\n"+bulletList(pending, "Nothing pending")))
+
+	recent, err := userRecentActivity(ctx, redisClient, config, user)
+	if err != nil {
+		return nil, err
+	}
+	blocks = append(blocks, section("*Your recent activity*\n"+bulletList(recent, "Nothing yet")), slack.NewDividerBlock())
+
+	channels := "every channel"
+	if len(workspace.Channels) > 0 {
+		mentions := make([]string, len(workspace.Channels))
+		for i, channel := range workspace.Channels {
+			mentions[i] = "<#" + channel + ">"
+		}
+		channels = strings.Join(mentions, ", ")
+	}
+	blocks = append(blocks, section(fmt.Sprintf("*Emoji* (in %s)\n%s", channels, bulletList(config.emojiGuide(workspace), "No emoji configured"))))
+	return blocks, nil
+}
+
+func bulletList(lines []string, empty string) string {
+	if len(lines) == 0 {
+		return "• " + empty
+	}
+	return "• " + strings.Join(lines, "\n• ")
+}
+
+// userPendingMerges describes the merges a user requested that are still queued, deferred, waiting, updating or
+// scheduled
+func userPendingMerges(ctx context.Context, redisClient *redis.Client, config *Config, user string) ([]string, error) {
+	var lines []string
+	iter := redisClient.Scan(ctx, 0, config.PendingKeyPrefix+":*", 100).Iterator()
+	for iter.Next(ctx) && len(lines) < appHomeRows {
+		pendingJSON, err := redisClient.Get(ctx, iter.Val()).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", iter.Val(), err)
+		}
+		var pending PendingMerge
+		if err := json.Unmarshal([]byte(pendingJSON), &pending); err != nil || pending.RequestedBy != user {
+			continue
+		}
+
+		state, err := pendingState(ctx, redisClient, config, pending)
+		if err != nil {
+			return nil, err
+		}
+		if state != "" {
+			lines = append(lines, fmt.Sprintf("%s#%d: %s", pending.Repository, pending.PRNumber, state))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan pending merges: %w", err)
+	}
+	return lines, nil
+}
+
+// pendingState describes where a tracked merge is waiting, or returns "" once it has left VibeMerge's queues
+func pendingState(ctx context.Context, redisClient *redis.Client, config *Config, pending PendingMerge) (string, error) {
+	switch {
+	case pending.QueuedPayload != "":
+		queue := pending.queue(config)
+		_, err := redisClient.LPos(ctx, queue, pending.QueuedPayload, redis.LPosArgs{}).Result()
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to search %s: %w", queue, err)
+		}
+		return "queued for Poppit", nil
+	case pending.DeferredMember != "":
+		score, err := redisClient.ZScore(ctx, config.DeferredQueue, pending.DeferredMember).Result()
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to search %s: %w", config.DeferredQueue, err)
+		}
+		return "deferred until " + time.Unix(int64(score), 0).In(config.Timezone).Format("Mon 2 Jan 15:04 MST"), nil
+	case pending.WaitingMember != "":
+		queueKey := repoQueueKey(config, pending.Repository)
+		_, err := redisClient.LPos(ctx, queueKey, pending.WaitingMember, redis.LPosArgs{}).Result()
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to search %s: %w", queueKey, err)
+		}
+		return "waiting for an earlier merge in the repository", nil
+	case pending.UpdatingMember != "":
+		_, err := redisClient.ZScore(ctx, config.UpdateBranchQueue, pending.UpdatingMember).Result()
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to search %s: %w", config.UpdateBranchQueue, err)
+		}
+		return "waiting for CI on its updated branch", nil
+	case pending.ScheduledMember != "":
+		score, err := redisClient.ZScore(ctx, config.ScheduledQueue, pending.ScheduledMember).Result()
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to search %s: %w", config.ScheduledQueue, err)
+		}
+		return "scheduled for " + time.Unix(int64(score), 0).In(config.Timezone).Format("Mon 2 Jan 15:04 MST"), nil
+	}
+	return "", nil
+}
+
+// userRecentActivity describes a user's latest audit log entries, searching the latest auditLookback entries
+func userRecentActivity(ctx context.Context, redisClient *redis.Client, config *Config, user string) ([]string, error) {
+	messages, err := redisClient.XRevRangeN(ctx, config.AuditStream, "+", "-", auditLookback).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", config.AuditStream, err)
+	}
+
+	var lines []string
+	for _, msg := range messages {
+		entry := parseAuditEntry(msg)
+		if entry.User != user || entry.Repository == "" {
+			continue
+		}
+		line := fmt.Sprintf("%s %s#%d: %s", entry.Time.In(config.Timezone).Format("Mon 2 Jan 15:04"), entry.Repository, entry.PRNumber, entry.Decision)
+		if entry.Reason != "" {
+			line += " (" + entry.Reason + ")"
+		}
+		lines = append(lines, line)
+		if len(lines) == appHomeRows {
+			break
+		}
+	}
+	return lines, nil
+}
+
+// emojiGuide describes what each emoji VibeMerge acts on does in a workspace
+func (c *Config) emojiGuide(workspace WorkspaceSettings) []string {
+	lines := []string{fmt.Sprintf(":%s: merges the PR", workspace.TargetEmoji)}
+	if c.SizeOverrideEmoji != "" {
+		lines = append(lines, fmt.Sprintf(":%s: merges the PR, even over the size limit", c.SizeOverrideEmoji))
+	}
+	if c.UrgentEmoji != "" {
+		lines = append(lines, fmt.Sprintf(":%s: merges the PR ahead of the merges already queued", c.UrgentEmoji))
+	}
+	if c.StackEmoji != "" {
+		lines = append(lines, fmt.Sprintf(":%s: merges the PR's stack from the bottom up", c.StackEmoji))
+	}
+
+	commands := make(map[string]bool)
+	for emoji := range c.Commands {
+		commands[emoji] = true
+	}
+	for _, repo := range c.Repos {
+		for emoji := range repo.commands {
+			commands[emoji] = true
+		}
+	}
+	delete(commands, workspace.TargetEmoji)
+	for _, emoji := range sortedKeys(commands) {
+		lines = append(lines, fmt.Sprintf(":%s: runs its merge commands", emoji))
+	}
+
+	for _, emoji := range []struct{ name, does string }{
+		{workspace.ReadyEmoji, "marks a draft PR ready for review"},
+		{workspace.ApproveEmoji, "approves the PR"},
+		{workspace.CloseEmoji, "closes the PR"},
+		{workspace.RevertEmoji, "opens a PR reverting the merged PR"},
+		{workspace.CancelEmoji, "cancels a pending merge"},
+	} {
+		if emoji.name != "" {
+			lines = append(lines, fmt.Sprintf(":%s: %s", emoji.name, emoji.does))
+		}
+	}
+
+	actions := make(map[string]bool)
+	for emoji := range c.Reactions {
+		actions[emoji] = true
+	}
+	for _, emoji := range sortedKeys(actions) {
+		var does []string
+		for _, action := range c.Reactions[emoji] {
+			does = append(does, strings.ReplaceAll(action.Action, "_", " "))
+		}
+		lines = append(lines, fmt.Sprintf(":%s: %s", emoji, strings.Join(does, ", ")))
+	}
+
+	workflows := make(map[string]bool)
+	for emoji := range c.Workflows {
+		workflows[emoji] = true
+	}
+	for _, emoji := range sortedKeys(workflows) {
+		var does []string
+		for i, step := range c.Workflows[emoji] {
+			name := step.Name
+			if name == "" {
+				name = fmt.Sprintf("%d (%s)", i+1, step.Type)
+			}
+			does = append(does, name)
+		}
+		lines = append(lines, fmt.Sprintf(":%s: runs %s", emoji, strings.Join(does, " → ")))
+	}
+	return lines
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
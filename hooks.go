@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Points in a merge's life where a hook executable can run
+const (
+	HookPreDecision = "pre-decision"
+	HookPreQueue    = "pre-queue"
+	HookPostQueue   = "post-queue"
+	HookPostConfirm = "post-confirm"
+)
+
+// hookOutputLimit bounds how much of a vetoing hook's output is quoted in its thread
+const hookOutputLimit = 300
+
+// HookEvent is the JSON a hook executable reads on stdin
+type HookEvent struct {
+	Hook          string   `json:"hook"`
+	Repository    string   `json:"repository"`
+	PRNumber      int      `json:"pr_number"`
+	Branch        string   `json:"branch,omitempty"`
+	Author        string   `json:"author,omitempty"`
+	SlackUser     string   `json:"slack_user,omitempty"`
+	GitHubUser    string   `json:"github_user,omitempty"`
+	TeamID        string   `json:"team_id,omitempty"`
+	Channel       string   `json:"channel,omitempty"`
+	Ts            string   `json:"ts,omitempty"`
+	CorrelationID string   `json:"correlation_id,omitempty"`
+	Commands      []string `json:"commands,omitempty"`
+	// SHA is the merge commit, for post-confirm hooks
+	SHA string `json:"sha,omitempty"`
+	// Title, URL, BaseBranch and HeadBranch are the PR's, as its message gives them, while Branch is the branch
+	// Poppit checks out
+	Title      string `json:"title,omitempty"`
+	URL        string `json:"url,omitempty"`
+	BaseBranch string `json:"base_branch,omitempty"`
+	HeadBranch string `json:"head_branch,omitempty"`
+}
+
+// hookPath returns the executable configured for a hook point, or "" when there is none
+func (c *Config) hookPath(hook string) string {
+	switch hook {
+	case HookPreDecision:
+		return c.PreDecisionHook
+	case HookPreQueue:
+		return c.PreQueueHook
+	case HookPostQueue:
+		return c.PostQueueHook
+	case HookPostConfirm:
+		return c.PostConfirmHook
+	}
+	return ""
+}
+
+// checkHookPaths makes sure every configured hook is an executable file, so a typo doesn't veto every merge
+func (c *Config) checkHookPaths() error {
+	for _, hook := range []string{HookPreDecision, HookPreQueue, HookPostQueue, HookPostConfirm} {
+		path := c.hookPath(hook)
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("%s hook %q: %w", hook, path, err)
+		}
+		if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+			return fmt.Errorf("%s hook %q is not an executable file", hook, path)
+		}
+	}
+	return nil
+}
+
+func jobHookEvent(hook string, job MergeJob) HookEvent {
+	return HookEvent{
+		Hook:          hook,
+		Repository:    job.Payload.Repo,
+		PRNumber:      job.PRNumber,
+		Title:         job.Title,
+		URL:           job.URL,
+		Branch:        job.Payload.Branch,
+		BaseBranch:    job.BaseBranch,
+		HeadBranch:    job.HeadBranch,
+		Author:        job.Author,
+		SlackUser:     job.RequestedBy,
+		GitHubUser:    job.Payload.GitHubUser,
+		TeamID:        job.TeamID,
+		Channel:       job.Channel,
+		Ts:            job.Ts,
+		CorrelationID: job.Payload.CorrelationID,
+		Commands:      job.Payload.Commands,
+	}
+}
+
+// runHook runs a hook point's executable, if any, with the event as JSON on stdin and the hook point in
+// VIBEMERGE_HOOK. It returns the hook's combined output and an error when it couldn't be run, timed out or exited
+// non-zero.
+func runHook(ctx context.Context, config *Config, event HookEvent) (string, error) {
+	path := config.hookPath(event.Hook)
+	if path == "" {
+		return "", nil
+	}
+	if dryRun != nil {
+		dryRun.skip(event.Hook + " hook")
+		return "", nil
+	}
+	input, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s hook event: %w", event.Hook, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.HookTimeout)*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "VIBEMERGE_HOOK="+event.Hook)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Don't wait on children the hook left holding its output once it is killed
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	logDebug("%s hook for PR %d in %s finished in %s", event.Hook, event.PRNumber, event.Repository, time.Since(start))
+	text := strings.TrimSpace(output.String())
+	if ctx.Err() == context.DeadlineExceeded {
+		return text, fmt.Errorf("timed out after %ds", config.HookTimeout)
+	}
+	return text, err
+}
+
+// checkHook runs a pre hook for a merge job, which vetoes the merge by exiting non-zero. A hook that can't be run
+// vetoes it too, so a broken hook never lets a merge through.
+func checkHook(ctx context.Context, config *Config, hook string, job MergeJob) (Decision, bool) {
+	output, err := runHook(ctx, config, jobHookEvent(hook, job))
+	if err == nil {
+		return Decision{}, false
+	}
+
+	logInfo("The %s hook vetoed the merge of PR %d in %s: %v", hook, job.PRNumber, job.Payload.Repo, err)
+	note := fmt.Sprintf(":no_entry: The %s hook refused to merge PR #%d.", hook, job.PRNumber)
+	if output != "" {
+		if len(output) > hookOutputLimit {
+			output = truncateOutput(output, hookOutputLimit)
+		}
+		note += fmt.Sprintf("\n```%s```", output)
+	}
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  fmt.Sprintf("vetoed by %s hook: %v", hook, err),
+		Note:    note,
+	}, true
+}
+
+// truncateOutput cuts output to at most limit bytes and marks the cut, backing up to the start of a UTF-8 character
+// so none is split
+func truncateOutput(output string, limit int) string {
+	end := limit
+	for end > 0 && !utf8.RuneStart(output[end]) {
+		end--
+	}
+	return output[:end] + "…"
+}
+
+// notifyHook runs a post hook, which can't change anything that already happened, so failures are only logged
+func notifyHook(ctx context.Context, config *Config, event HookEvent) {
+	output, err := runHook(ctx, config, event)
+	if err != nil {
+		logWarning("The %s hook for PR %d in %s failed: %v: %s", event.Hook, event.PRNumber, event.Repository, err, output)
+	}
+}
+
+// notifyConfirmHook runs the post-confirm hook once Poppit reports merging a PR
+func notifyConfirmHook(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) {
+	if config.PostConfirmHook == "" {
+		return
+	}
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		logWarning("Failed to find the request for merge %s, not running the post-confirm hook: %v", result.CorrelationID, err)
+		return
+	}
+	// Ready, approve, close, revert and action results don't merge anything
+	if !found || !mergeRequest(requested) {
+		return
+	}
+	event := HookEvent{
+		Hook:          HookPostConfirm,
+		Repository:    result.Repo,
+		PRNumber:      requested.PRNumber,
+		SlackUser:     requested.User,
+		GitHubUser:    requested.GitHubUser,
+		TeamID:        requested.TeamID,
+		Channel:       requested.Channel,
+		Ts:            requested.Ts,
+		CorrelationID: result.CorrelationID,
+		SHA:           result.SHA,
+	}
+	// The audit log doesn't keep the PR's metadata, so read it from GitHub when possible
+	if config.hasGitHubAPI() && requested.PRNumber != 0 {
+		pr, err := getPullRequest(ctx, config, result.Repo, requested.PRNumber)
+		if err != nil {
+			logWarning("Failed to read PR %d in %s for the post-confirm hook: %v", requested.PRNumber, result.Repo, err)
+		} else {
+			event.Title, event.URL, event.Author = pr.Title, pr.HTMLURL, pr.User.Login
+			event.BaseBranch, event.HeadBranch = pr.Base.Ref, pr.Head.Ref
+		}
+	}
+	notifyHook(ctx, config, event)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		limit  int
+		want   string
+	}{
+		{"ASCII", "abcdef", 3, "abc…"},
+		{"cut inside a character backs up", "ab€cd", 3, "ab…"},
+		{"cut after a character keeps it", "ab€cd", 5, "ab€…"},
+		{"long hook output", strings.Repeat("é", 200), hookOutputLimit, strings.Repeat("é", 150) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateOutput(tt.output, tt.limit)
+			if got != tt.want {
+				t.Errorf("truncateOutput() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateOutput() = %q, not valid UTF-8", got)
+			}
+		})
+	}
+}
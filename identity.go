@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// IdentityMap maps Slack users to GitHub logins
+type IdentityMap struct {
+	// Users maps Slack user IDs to GitHub logins
+	Users map[string]string `json:"users"`
+	// Emails maps Slack profile emails to GitHub logins, used when IDENTITY_EMAIL_MATCH is enabled
+	Emails map[string]string `json:"emails"`
+}
+
+func loadIdentityMap(path string) (*IdentityMap, error) {
+	identities := &IdentityMap{}
+	if path == "" {
+		return identities, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IDENTITY_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, identities); err != nil {
+		return nil, fmt.Errorf("failed to parse IDENTITY_FILE: %w", err)
+	}
+
+	// Emails are matched case-insensitively
+	emails := make(map[string]string, len(identities.Emails))
+	for email, login := range identities.Emails {
+		emails[strings.ToLower(email)] = login
+	}
+	identities.Emails = emails
+
+	return identities, nil
+}
+
+// resolveGitHubLogin finds the GitHub login for a Slack user, returning "" when there is no mapping.
+// Redis overrides win over the identity file, which wins over matching by Slack profile email.
+func resolveGitHubLogin(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, slackUser string) (string, error) {
+	if slackUser == "" {
+		return "", nil
+	}
+
+	login, err := redisClient.HGet(ctx, config.IdentityKey, slackUser).Result()
+	if err == nil && login != "" {
+		return login, nil
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("failed to read %s: %w", config.IdentityKey, err)
+	}
+
+	if login := config.Identities.Users[slackUser]; login != "" {
+		return login, nil
+	}
+
+	if !flagEnabled(FlagIdentityEmailMatch, config.IdentityEmailMatch) || slackClient == nil {
+		return "", nil
+	}
+
+	var user *slack.User
+	err = callSlack(ctx, "users.info", func() error {
+		var err error
+		user, err = slackClient.GetUserInfoContext(ctx, slackUser)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up Slack user %s: %w", slackUser, err)
+	}
+	return config.Identities.Emails[strings.ToLower(user.Profile.Email)], nil
+}
+
+// withGitHubLogin returns a copy of a PR's metadata with the requester's GitHub login, so commands can be rendered
+// with it and newMergeJob records it on the job. It logs rather than fails when the login can't be resolved.
+func withGitHubLogin(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, metadata *PRMetadata, slackUser string) *PRMetadata {
+	requested := *metadata
+	login, err := resolveGitHubLogin(ctx, redisClient, slackClient, config, slackUser)
+	if err != nil {
+		logWarning("Failed to resolve GitHub login for Slack user %s: %v", slackUser, err)
+		return &requested
+	}
+	if login == "" {
+		logDebug("No GitHub login mapped for Slack user %s", slackUser)
+	}
+	requested.GitHubUser = login
+	return &requested
+}
+
+// slackMention mentions the Slack user mapped to a GitHub login, or names the login when nobody is mapped to it.
+// Redis overrides win over the identity file, as in resolveGitHubLogin; redisClient is nil in offline simulations.
+func slackMention(ctx context.Context, redisClient *redis.Client, config *Config, login string) string {
+	var overrides map[string]string
+	if redisClient != nil {
+		var err error
+		overrides, err = redisClient.HGetAll(ctx, config.IdentityKey).Result()
+		if err != nil {
+			logWarning("Failed to read %s: %v", config.IdentityKey, err)
+		}
+	}
+	for _, users := range []map[string]string{overrides, config.Identities.Users} {
+		for slackUser, mapped := range users {
+			if strings.EqualFold(mapped, login) {
+				return "<@" + slackUser + ">"
+			}
+		}
+	}
+	return "@" + login
+}
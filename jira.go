@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// jiraTimeout bounds each Jira API request
+const jiraTimeout = 10 * time.Second
+
+var jiraHTTPClient = &http.Client{Timeout: jiraTimeout}
+
+// issueKeyPattern finds Jira issue keys and Linear issue identifiers, such as VM-123, in a PR's title or branch
+var issueKeyPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`)
+
+// parseJiraTransitions parses JIRA_TRANSITIONS, a comma-separated list of project=transition pairs
+func parseJiraTransitions(value string) (map[string]string, error) {
+	transitions := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		project, transition, ok := strings.Cut(part, "=")
+		project, transition = strings.ToUpper(strings.TrimSpace(project)), strings.TrimSpace(transition)
+		if !ok || project == "" || transition == "" {
+			return nil, fmt.Errorf("invalid JIRA_TRANSITIONS entry %q, expected project=transition", part)
+		}
+		transitions[project] = transition
+	}
+	return transitions, nil
+}
+
+// checkJira rejects Jira transitions that are missing what they need
+func (c *Config) checkJira() error {
+	if c.JiraURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.JiraURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("JIRA_URL %q must be an http or https URL", c.JiraURL)
+	}
+	if c.JiraAPIToken == "" {
+		return fmt.Errorf("JIRA_URL requires JIRA_API_TOKEN")
+	}
+	if len(c.JiraTransitions) == 0 && c.JiraTransition == "" {
+		return fmt.Errorf("JIRA_URL requires JIRA_TRANSITIONS or JIRA_TRANSITION")
+	}
+	if !c.hasGitHubAPI() {
+		return fmt.Errorf("JIRA_URL requires GITHUB_TOKEN or a GitHub App")
+	}
+	return nil
+}
+
+// jiraTransition returns the transition a project's issues go through once merged, or "" to leave them alone
+func (c *Config) jiraTransition(key string) string {
+	project, _, _ := strings.Cut(key, "-")
+	if transition, ok := c.JiraTransitions[project]; ok {
+		return transition
+	}
+	return c.JiraTransition
+}
+
+// issueKeys returns the distinct issue keys in the given texts, in order
+func issueKeys(texts ...string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, key := range issueKeyPattern.FindAllString(text, -1) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// transitionJiraIssues moves the Jira issues named in the title or branch of a PR Poppit merged through their
+// project's transition. Issues already past it, which don't offer the transition, are left alone.
+func transitionJiraIssues(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) error {
+	if config.JiraURL == "" {
+		return nil
+	}
+	pr, merged, err := mergedPullRequest(ctx, redisClient, config, result)
+	if err != nil || !merged {
+		return err
+	}
+	for _, key := range issueKeys(pr.Title, pr.Head.Ref) {
+		transition := config.jiraTransition(key)
+		if transition == "" {
+			continue
+		}
+		if err := transitionJiraIssue(ctx, config, key, transition); err != nil {
+			logWarning("Failed to transition Jira issue %s of PR %d in %s: %v", key, pr.Number, result.Repo, err)
+		}
+	}
+	return nil
+}
+
+// transitionJiraIssue applies the transition with the given name, or leading to the status with that name
+func transitionJiraIssue(ctx context.Context, config *Config, key, name string) error {
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	path := fmt.Sprintf("/rest/api/2/issue/%s/transitions", url.PathEscape(key))
+	if err := jiraRequest(ctx, config, http.MethodGet, path, nil, &available); err != nil {
+		return err
+	}
+
+	for _, transition := range available.Transitions {
+		if !strings.EqualFold(transition.Name, name) && !strings.EqualFold(transition.To.Name, name) {
+			continue
+		}
+		body := map[string]any{"transition": map[string]string{"id": transition.ID}}
+		if err := jiraRequest(ctx, config, http.MethodPost, path, body, nil); err != nil {
+			return err
+		}
+		logInfo("Transitioned Jira issue %s to %s", key, transition.To.Name)
+		return nil
+	}
+	logDebug("Jira issue %s has no %q transition, leaving it as it is", key, name)
+	return nil
+}
+
+// jiraRequest calls the Jira REST API, with JIRA_USER and JIRA_API_TOKEN for Jira Cloud, or JIRA_API_TOKEN alone as
+// a personal access token for Jira Server and Data Center
+func jiraRequest(ctx context.Context, config *Config, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal Jira request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(config.JiraURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if config.JiraUser != "" {
+		req.SetBasicAuth(config.JiraUser, config.JiraAPIToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+config.JiraAPIToken)
+	}
+
+	resp, err := jiraHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Jira API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Jira API returned %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Jira response: %w", err)
+	}
+	return nil
+}
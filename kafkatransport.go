@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// SASL mechanisms selectable with KAFKA_SASL_MECHANISM
+const (
+	KafkaSASLPlain       = "plain"
+	KafkaSASLScramSHA256 = "scram-sha-256"
+	KafkaSASLScramSHA512 = "scram-sha-512"
+)
+
+// kafkaMaxInFlight bounds the messages fetched from a topic whose events are still being handled
+const kafkaMaxInFlight = 256
+
+// kafkaTransport carries events and Poppit commands over Kafka. Each channel is a topic read by the KAFKA_GROUP_ID
+// consumer group, committing its offset once an event is handled, so events published while VibeMerge is down or
+// failing over are handled once it is back. Poppit commands are produced to the queue's topic.
+type kafkaTransport struct {
+	brokers []string
+	groupID string
+	dialer  *kafka.Dialer
+	writer  *kafka.Writer
+}
+
+func newKafkaTransport(config *Config) (*kafkaTransport, error) {
+	mechanism, err := kafkaSASLMechanism(config)
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	if config.KafkaTLSEnabled {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	return &kafkaTransport{
+		brokers: config.KafkaBrokers,
+		groupID: config.KafkaGroupID,
+		dialer:  &kafka.Dialer{ClientID: "vibemerge", Timeout: 10 * time.Second, DualStack: true, TLS: tlsConfig, SASLMechanism: mechanism},
+		writer: &kafka.Writer{
+			Addr: kafka.TCP(config.KafkaBrokers...),
+			// A command is only queued once every in-sync replica has it
+			RequiredAcks: kafka.RequireAll,
+			// Keyed messages, the reactions routed by repository, go to the partition their key hashes to; the rest
+			// are spread round-robin
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 10 * time.Millisecond,
+			Transport:    &kafka.Transport{ClientID: "vibemerge", TLS: tlsConfig, SASL: mechanism},
+		},
+	}, nil
+}
+
+func kafkaSASLMechanism(config *Config) (sasl.Mechanism, error) {
+	switch config.KafkaSASLMechanism {
+	case "":
+		return nil, nil
+	case KafkaSASLPlain:
+		return plain.Mechanism{Username: config.KafkaUsername, Password: config.KafkaPassword}, nil
+	case KafkaSASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, config.KafkaUsername, config.KafkaPassword)
+	case KafkaSASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, config.KafkaUsername, config.KafkaPassword)
+	}
+	return nil, fmt.Errorf("unknown KAFKA_SASL_MECHANISM %q", config.KafkaSASLMechanism)
+}
+
+// Close flushes commands produced but not yet sent
+func (t *kafkaTransport) Close() {
+	if err := t.writer.Close(); err != nil {
+		logWarning("Failed to close the Kafka writer: %v", err)
+	}
+}
+
+// Receive reads a channel's topic as part of the consumer group, handing each message on without waiting for the
+// last, so the workers handle events of different repositories at the same time. Offsets are committed in the order
+// the messages were fetched, once every event up to them has been handled. The reader is recreated with backoff
+// when it fails.
+func (t *kafkaTransport) Receive(ctx context.Context, channel string, handle func(payload string, done func())) {
+	backoff := resubscribeMinBackoff
+	for {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers: t.brokers,
+			GroupID: t.groupID,
+			Topic:   channel,
+			Dialer:  t.dialer,
+			MaxWait: time.Second,
+			// Offsets are committed explicitly, after each event is handled
+			CommitInterval: 0,
+			StartOffset:    kafka.LastOffset,
+		})
+		logInfo("Consuming %s from Kafka as group %s", channel, t.groupID)
+		err := t.consume(ctx, reader, handle)
+		reader.Close()
+		if ctx.Err() != nil {
+			return
+		}
+
+		logError("Failed to consume %s from Kafka: %v (retrying in %s)", channel, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, resubscribeMaxBackoff)
+	}
+}
+
+// kafkaInFlight is a fetched message whose event is being handled
+type kafkaInFlight struct {
+	msg     kafka.Message
+	handled chan struct{}
+}
+
+func (t *kafkaTransport) consume(ctx context.Context, reader *kafka.Reader, handle func(payload string, done func())) error {
+	fetchCtx, stopFetching := context.WithCancel(ctx)
+	defer stopFetching()
+
+	// An offset commits every message before it too, so messages are committed in order as their events finish
+	inFlight := make(chan kafkaInFlight, kafkaMaxInFlight)
+	committed := make(chan error, 1)
+	go func() {
+		var commitErr error
+		for next := range inFlight {
+			<-next.handled
+			if commitErr != nil {
+				continue
+			}
+			// Commit even during shutdown, so the events just handled aren't handled again
+			if err := reader.CommitMessages(context.WithoutCancel(ctx), next.msg); err != nil {
+				commitErr = fmt.Errorf("failed to commit offset %d of %s: %w", next.msg.Offset, next.msg.Topic, err)
+				stopFetching()
+			}
+		}
+		committed <- commitErr
+	}()
+
+	var err error
+	for {
+		var msg kafka.Message
+		msg, err = reader.FetchMessage(fetchCtx)
+		if err != nil {
+			break
+		}
+		handled := make(chan struct{})
+		inFlight <- kafkaInFlight{msg: msg, handled: handled}
+		handle(string(msg.Value), sync.OnceFunc(func() { close(handled) }))
+	}
+
+	// Wait for the events already handed on, so none is still being handled when the reader is recreated
+	close(inFlight)
+	if commitErr := <-committed; commitErr != nil {
+		return commitErr
+	}
+	return err
+}
+
+// PublishPartitioned produces a message keyed by key, so it goes to the partition of the topic key hashes to. The
+// topic's own partitions are used, whatever partitions says.
+func (t *kafkaTransport) PublishPartitioned(ctx context.Context, channel, key, payload string, partitions int) error {
+	return t.writer.WriteMessages(ctx, kafka.Message{Topic: channel, Key: []byte(key), Value: []byte(payload)})
+}
+
+// ReceivePartitioned reads the topic as part of the consumer group, which assigns each of its partitions to one
+// instance at a time
+func (t *kafkaTransport) ReceivePartitioned(ctx context.Context, channel string, partitions int, handle func(payload string, done func())) {
+	t.Receive(ctx, channel, handle)
+}
+
+func (t *kafkaTransport) Publish(ctx context.Context, channel, payload string) error {
+	return t.writer.WriteMessages(ctx, kafka.Message{Topic: channel, Value: []byte(payload)})
+}
+
+func (t *kafkaTransport) Push(ctx context.Context, queue, payload string) error {
+	err := t.writer.WriteMessages(ctx, kafka.Message{Topic: queue, Value: []byte(payload)})
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == 1 {
+		return writeErrs[0]
+	}
+	return err
+}
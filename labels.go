@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// checkLabelGates rejects label gates without GITHUB_TOKEN or a GitHub App to read labels with
+func (c *Config) checkLabelGates() error {
+	gated := len(c.RequiredLabels) > 0 || len(c.BlockedLabels) > 0
+	for _, repo := range c.Repos {
+		gated = gated || len(repo.RequiredLabels) > 0 || len(repo.BlockedLabels) > 0
+	}
+	if gated && !c.hasGitHubAPI() {
+		return fmt.Errorf("REQUIRED_LABELS, BLOCKED_LABELS, required_labels and blocked_labels require GITHUB_TOKEN or a GitHub App")
+	}
+	return nil
+}
+
+// checkLabels denies a merge of a PR missing one of the repository's required labels or carrying a blocked one.
+// Labels are compared case-insensitively, as GitHub does. GitHub errors are logged and the merge let through.
+func checkLabels(ctx context.Context, config *Config, job MergeJob, settings RepoSettings) (Decision, bool) {
+	if len(settings.RequiredLabels) == 0 && len(settings.BlockedLabels) == 0 {
+		return Decision{}, false
+	}
+
+	pr, err := getPullRequest(ctx, config, job.Payload.Repo, job.PRNumber)
+	if err != nil {
+		logWarning("Failed to read the labels of PR %d in %s, not enforcing label gates: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{}, false
+	}
+	hasLabel := func(name string) bool {
+		return slices.ContainsFunc(pr.Labels, func(label githubLabel) bool { return strings.EqualFold(label.Name, name) })
+	}
+
+	var unmet []string
+	for _, name := range settings.BlockedLabels {
+		if hasLabel(name) {
+			unmet = append(unmet, fmt.Sprintf("labeled %s", name))
+		}
+	}
+	for _, name := range settings.RequiredLabels {
+		if !hasLabel(name) {
+			unmet = append(unmet, fmt.Sprintf("not labeled %s", name))
+		}
+	}
+	if len(unmet) == 0 {
+		return Decision{}, false
+	}
+
+	logInfo("PR %d in %s doesn't pass label gates: %s", job.PRNumber, job.Payload.Repo, strings.Join(unmet, ", "))
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  "labels: " + strings.Join(unmet, ", "),
+		Note: fmt.Sprintf(":label: PR #%d is %s, so it was not queued. Update its labels and react again.",
+			job.PRNumber, strings.Join(unmet, " and ")),
+	}, true
+}
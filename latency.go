@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms' buckets
+var latencyBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200}
+
+// histogram is an expvar.Var counting observations into cumulative buckets, like a Prometheus histogram
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds))}
+}
+
+// Observe records a duration in seconds
+func (h *histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// String renders the histogram as JSON, with each bucket counting the observations up to its bound
+func (h *histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var b strings.Builder
+	b.WriteString(`{"buckets":{`)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(&b, `"%s":%d,`, strconv.FormatFloat(bound, 'f', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(&b, `"+Inf":%d},"count":%d,"sum":%s}`, h.count, h.count, strconv.FormatFloat(h.sum, 'f', -1, 64))
+	return b.String()
+}
+
+var (
+	// enqueueLatency is the time from a merge's reaction to its command being pushed to Poppit
+	enqueueLatency = newHistogram(latencyBuckets)
+	// mergeLatency is the time from a merge's reaction to Poppit confirming the merge
+	mergeLatency = newHistogram(latencyBuckets)
+)
+
+func init() {
+	metrics.Set("reaction_to_enqueue_seconds", enqueueLatency)
+	metrics.Set("reaction_to_merge_seconds", mergeLatency)
+}
+
+// recordMergeLatency records how long a merge Poppit completed took from its reaction, in the
+// reaction_to_merge_seconds histogram and as a merged entry in the audit log
+func recordMergeLatency(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) {
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		logWarning("Failed to find the request for merge %s, not recording its latency: %v", result.CorrelationID, err)
+		return
+	}
+	if !found || !mergeRequest(requested) || requested.EventTime.IsZero() {
+		return
+	}
+
+	now := time.Now().UTC()
+	latency := now.Sub(requested.EventTime)
+	mergeLatency.Observe(latency)
+
+	entry := requested
+	entry.Time = now
+	entry.Source = "poppit"
+	entry.Decision = OutcomeMerged
+	entry.Reason = fmt.Sprintf("merged %s after the reaction", latency.Round(time.Second))
+	entry.LatencySeconds = latency.Seconds()
+	if err := recordAudit(ctx, redisClient, config, entry); err != nil {
+		logError("Failed to write audit entry for merge %s: %v", result.CorrelationID, err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// renewLeaseScript extends the leader lease only while this instance still holds it
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript gives up the leader lease only if this instance holds it
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// isLeader is 1 while this instance holds the leader lease, published under "leader" in the metrics
+var isLeader = new(expvar.Int)
+
+func init() {
+	metrics.Set("leader", isLeader)
+}
+
+// defaultInstanceID identifies this instance in the leader lease when INSTANCE_ID isn't set
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "vibemerge"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// runAsLeader keeps this instance on standby until it acquires the leader lease, then runs the event loops
+// for as long as it holds the lease. If the lease is lost the loops are stopped and the instance goes back
+// to standby, so at most one instance consumes events at a time.
+func runAsLeader(ctx context.Context, redisClient *redis.Client, config *Config, loops []func(context.Context)) {
+	ttl := time.Duration(config.LeaderLeaseTTL) * time.Second
+	logInfo("Leader election enabled, instance %s is on standby", config.InstanceID)
+
+	for {
+		if !acquireLease(ctx, redisClient, config, ttl) {
+			return
+		}
+		logInfo("Instance %s is now the leader", config.InstanceID)
+		isLeader.Set(1)
+
+		leaderCtx, stepDown := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			runLoops(leaderCtx, loops)
+			close(done)
+		}()
+
+		holdLease(leaderCtx, redisClient, config, ttl)
+		stepDown()
+		<-done
+		isLeader.Set(0)
+
+		if ctx.Err() != nil {
+			// Hand over straight away instead of making the standby wait for the lease to expire
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := releaseLeaseScript.Run(releaseCtx, redisClient, []string{config.LeaderKey}, config.InstanceID).Err(); err != nil {
+				logWarning("Failed to release the leader lease: %v", err)
+			}
+			cancel()
+			return
+		}
+		logWarning("Instance %s lost the leader lease, returning to standby", config.InstanceID)
+	}
+}
+
+// acquireLease polls for the leader lease until it is acquired, reporting false if ctx ends first
+func acquireLease(ctx context.Context, redisClient *redis.Client, config *Config, ttl time.Duration) bool {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := redisClient.SetNX(ctx, config.LeaderKey, config.InstanceID, ttl).Result()
+		if err != nil && ctx.Err() == nil {
+			logError("Error acquiring the leader lease: %v", err)
+		}
+		if acquired {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// holdLease renews the leader lease until ctx ends or the lease is lost. When Redis can't be reached the lease is
+// treated as lost a third of its TTL before it would expire, so the loops are stopped while this instance still holds
+// it rather than after another instance may have taken over.
+func holdLease(ctx context.Context, redisClient *redis.Client, config *Config, ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	held := ttl - ttl/3
+	until := time.Now().Add(held)
+	deadline := time.NewTimer(held)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			logWarning("Couldn't renew the leader lease before it runs out, stepping down")
+			return
+		case <-ticker.C:
+		}
+
+		// The lease is measured from before the call, and a renewal that hangs gives up when it's time to step down
+		sent := time.Now()
+		renewCtx, cancel := context.WithDeadline(ctx, until)
+		renewed, err := renewLeaseScript.Run(renewCtx, redisClient, []string{config.LeaderKey}, config.InstanceID, ttl.Milliseconds()).Int()
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil && !time.Now().Before(until):
+			logWarning("Couldn't renew the leader lease before it runs out, stepping down")
+			return
+		case err != nil:
+			logError("Error renewing the leader lease: %v", err)
+		case renewed == 0:
+			return
+		default:
+			until = sent.Add(held)
+			deadline.Reset(time.Until(until))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newLeaderTest(t *testing.T) (*miniredis.Miniredis, *redis.Client, *Config) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+	t.Cleanup(func() { redisClient.Close() })
+	return mr, redisClient, &Config{LeaderKey: "vibemerge:leader", InstanceID: "instance-a"}
+}
+
+// holdLeaseReturns runs holdLease in the background and reports whether it stepped down within wait
+func holdLeaseReturns(ctx context.Context, redisClient *redis.Client, config *Config, ttl, wait time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		holdLease(ctx, redisClient, config, ttl)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
+
+func TestHoldLeaseRenews(t *testing.T) {
+	mr, redisClient, config := newLeaderTest(t)
+	ttl := 300 * time.Millisecond
+	mr.Set(config.LeaderKey, config.InstanceID)
+	mr.SetTTL(config.LeaderKey, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if holdLeaseReturns(ctx, redisClient, config, ttl, 3*ttl) {
+		t.Fatal("holdLease stepped down while the lease could be renewed")
+	}
+	if got := mr.TTL(config.LeaderKey); got != ttl {
+		t.Errorf("lease TTL = %v, want it renewed to %v", got, ttl)
+	}
+}
+
+func TestHoldLeaseStepsDownWhenLeaseIsTaken(t *testing.T) {
+	mr, redisClient, config := newLeaderTest(t)
+	ttl := 300 * time.Millisecond
+	mr.Set(config.LeaderKey, "instance-b")
+
+	if !holdLeaseReturns(context.Background(), redisClient, config, ttl, ttl) {
+		t.Fatal("holdLease kept running after another instance took the lease")
+	}
+	if got, _ := mr.Get(config.LeaderKey); got != "instance-b" {
+		t.Errorf("lease holder = %q, want instance-b", got)
+	}
+}
+
+func TestHoldLeaseStepsDownWithoutRedis(t *testing.T) {
+	mr, redisClient, config := newLeaderTest(t)
+	ttl := 300 * time.Millisecond
+	mr.Set(config.LeaderKey, config.InstanceID)
+	mr.Close()
+
+	// The lease is given up a third of its TTL before it would run out
+	start := time.Now()
+	if !holdLeaseReturns(context.Background(), redisClient, config, ttl, ttl) {
+		t.Fatal("holdLease kept running without being able to renew the lease")
+	}
+	if elapsed := time.Since(start); elapsed >= ttl {
+		t.Errorf("stepped down after %v, want before the %v lease ran out", elapsed, ttl)
+	}
+}
+
+func TestRunAsLeaderReleasesLeaseOnShutdown(t *testing.T) {
+	mr, redisClient, config := newLeaderTest(t)
+	config.LeaderLeaseTTL = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runAsLeader(ctx, redisClient, config, []func(context.Context){func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+		}})
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("loops didn't start after acquiring a free lease")
+	}
+	if got, _ := mr.Get(config.LeaderKey); got != config.InstanceID {
+		t.Errorf("lease holder = %q, want %q", got, config.InstanceID)
+	}
+	if isLeader.Value() != 1 {
+		t.Error("leader metric not set while holding the lease")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runAsLeader didn't return after shutdown")
+	}
+	if mr.Exists(config.LeaderKey) {
+		t.Error("lease wasn't released on shutdown")
+	}
+	if isLeader.Value() != 0 {
+		t.Error("leader metric still set after stepping down")
+	}
+}
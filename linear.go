@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// linearTimeout bounds each Linear API request
+const linearTimeout = 10 * time.Second
+
+var linearHTTPClient = &http.Client{Timeout: linearTimeout}
+
+// parseLinearTeams parses LINEAR_TEAMS, a comma-separated list of team keys, each optionally followed by =state for
+// the workflow state merged issues move to instead of the team's first completed state
+func parseLinearTeams(value string) (map[string]string, error) {
+	teams := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		team, state, _ := strings.Cut(part, "=")
+		team = strings.ToUpper(strings.TrimSpace(team))
+		if team == "" {
+			return nil, fmt.Errorf("invalid LINEAR_TEAMS entry %q, expected team or team=state", part)
+		}
+		teams[team] = strings.TrimSpace(state)
+	}
+	return teams, nil
+}
+
+// checkLinear rejects Linear issue closing that is missing what it needs
+func (c *Config) checkLinear() error {
+	if c.LinearAPIKey == "" {
+		return nil
+	}
+	if len(c.LinearTeams) == 0 {
+		return fmt.Errorf("LINEAR_API_KEY requires LINEAR_TEAMS")
+	}
+	if !c.hasGitHubAPI() {
+		return fmt.Errorf("LINEAR_API_KEY requires GITHUB_TOKEN or a GitHub App")
+	}
+	return nil
+}
+
+// closeLinearIssues marks the Linear issues named in the title or branch of a PR Poppit merged as done, for the
+// teams in LINEAR_TEAMS
+func closeLinearIssues(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) error {
+	if config.LinearAPIKey == "" {
+		return nil
+	}
+	pr, merged, err := mergedPullRequest(ctx, redisClient, config, result)
+	if err != nil || !merged {
+		return err
+	}
+	// Linear names branches with lowercase identifiers, and only LINEAR_TEAMS are acted on, so case doesn't matter
+	for _, identifier := range issueKeys(strings.ToUpper(pr.Title), strings.ToUpper(pr.Head.Ref)) {
+		team, _, _ := strings.Cut(identifier, "-")
+		state, ok := config.LinearTeams[team]
+		if !ok {
+			continue
+		}
+		if err := closeLinearIssue(ctx, config, identifier, state); err != nil {
+			logWarning("Failed to close Linear issue %s of PR %d in %s: %v", identifier, pr.Number, result.Repo, err)
+		}
+	}
+	return nil
+}
+
+// closeLinearIssue moves an issue to the named workflow state of its team, or to the team's first completed state
+// when none is named. Issues already completed or cancelled are left alone.
+func closeLinearIssue(ctx context.Context, config *Config, identifier, stateName string) error {
+	var lookup struct {
+		Issue struct {
+			ID    string `json:"id"`
+			State struct {
+				Type string `json:"type"`
+			} `json:"state"`
+			Team struct {
+				States struct {
+					Nodes []struct {
+						ID       string  `json:"id"`
+						Name     string  `json:"name"`
+						Type     string  `json:"type"`
+						Position float64 `json:"position"`
+					} `json:"nodes"`
+				} `json:"states"`
+			} `json:"team"`
+		} `json:"issue"`
+	}
+	query := `query($id: String!) { issue(id: $id) { id state { type } team { states { nodes { id name type position } } } } }`
+	if err := linearQuery(ctx, config, query, map[string]any{"id": identifier}, &lookup); err != nil {
+		return err
+	}
+	issue := lookup.Issue
+	if issue.State.Type == "completed" || issue.State.Type == "canceled" {
+		logDebug("Linear issue %s is already %s, leaving it as it is", identifier, issue.State.Type)
+		return nil
+	}
+
+	var stateID, name string
+	var position float64
+	for _, state := range issue.Team.States.Nodes {
+		if stateName != "" && strings.EqualFold(state.Name, stateName) {
+			stateID, name = state.ID, state.Name
+			break
+		}
+		if stateName == "" && state.Type == "completed" && (stateID == "" || state.Position < position) {
+			stateID, name, position = state.ID, state.Name, state.Position
+		}
+	}
+	if stateID == "" {
+		return fmt.Errorf("the team of %s has no %q state", identifier, stateName)
+	}
+
+	var update struct {
+		IssueUpdate struct {
+			Success bool `json:"success"`
+		} `json:"issueUpdate"`
+	}
+	mutation := `mutation($id: String!, $stateId: String!) { issueUpdate(id: $id, input: {stateId: $stateId}) { success } }`
+	if err := linearQuery(ctx, config, mutation, map[string]any{"id": issue.ID, "stateId": stateID}, &update); err != nil {
+		return err
+	}
+	if !update.IssueUpdate.Success {
+		return fmt.Errorf("Linear didn't update %s", identifier)
+	}
+	logInfo("Moved Linear issue %s to %s", identifier, name)
+	return nil
+}
+
+// linearQuery runs a Linear GraphQL query with LINEAR_API_KEY, decoding its data into out
+func linearQuery(ctx context.Context, config *Config, query string, variables map[string]any, out any) error {
+	data, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Linear request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.LinearAPIURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Personal API keys are sent as they are, without a Bearer prefix
+	req.Header.Set("Authorization", config.LinearAPIKey)
+
+	resp, err := linearHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Linear API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&response); err != nil {
+		return fmt.Errorf("Linear API returned %s: %w", resp.Status, err)
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("Linear query failed: %s", response.Errors[0].Message)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Linear API returned %s", resp.Status)
+	}
+	return json.Unmarshal(response.Data, out)
+}
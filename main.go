@@ -2,36 +2,383 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"text/template"
+	"time"
 
+	"github.com/getsentry/sentry-go"
 	"github.com/redis/go-redis/v9"
 	"github.com/slack-go/slack"
 )
 
 // Config holds the application configuration
 type Config struct {
-	SlackBotToken   string
-	RedisAddr       string
-	RedisPassword   string
-	RedisDB         int
-	WorkDir         string
-	TargetEmoji     string
-	TargetBranch    string
-	PoppitQueue     string
-	TimeBombChannel string
-	TimeBombTTL     int
-	LogLevel        string
+	SlackBotToken string `json:"-"`
+	SlackAppToken string `json:"-"`
+	InputMode     string
+	RedisAddr     string
+	RedisPassword string `json:"-"`
+	RedisDB       int
+	WorkDir       string
+	TargetEmoji   string
+	ReadyEmoji    string
+	ApproveEmoji  string
+	CloseEmoji    string
+	RevertEmoji   string
+	ParsePRLinks  bool
+	PRLinkHosts   []string
+	// MetadataEventTypes are the message metadata event types read as PR metadata, empty for any
+	MetadataEventTypes []string
+	PRLinkOrgs         []string
+	TargetBranch       string
+	PoppitQueue        string
+	SlashCommand       string
+	SlashChannel       string
+	AdminChannel       string
+	AdminReplyChannel  string
+	PauseKey           string
+	AuditStream        string
+	AuditLogFile       string
+	AuditMaxLength     int
+	TimeBombChannel    string
+	TimeBombTTL        int
+	LogLevel           string
+	WorkerCount        int
+	EventTimeout       int
+	SlackRateLimit     int
+	SlackRateBurst     int
+	SlackMaxRetries    int
+	HTTPAddr           string
+
+	// Slack circuit breaker, the queue reactions are parked in while it is open, and the queue of events that
+	// failed validation
+	SlackBreakerThreshold int
+	SlackBreakerCooldown  int
+	DeadLetterQueue       string
+	RejectedEventQueue    string
+
+	// Slack App Home tab, published when a user opens it
+	AppHome        bool
+	AppHomeChannel string
+
+	// Merges held until they are approved with Block Kit buttons
+	ConfirmMerges      bool
+	ConfirmTTL         int
+	ConfirmKeyPrefix   string
+	InteractionChannel string
+	SlackSigningSecret string `json:"-"`
+
+	// Merges scheduled by mentioning the app in a PR message's thread
+	ScheduledMerges bool
+	MentionChannel  string
+	ScheduledQueue  string
+
+	// Bearer token for the REST API served on HTTPAddr and the gRPC API served on GRPCAddr
+	APIToken string `json:"-"`
+	GRPCAddr string
+
+	// GitHub pull_request webhooks, served on HTTPAddr
+	GitHubWebhookSecret string `json:"-"`
+	PRStateKeyPrefix    string
+	PRStateTTL          int
+
+	// Merge commits Poppit reported, for the revert emoji
+	MergeCommitKeyPrefix string
+	MergeCommitTTL       int
+
+	// GitHub API, for checks made before a merge is queued
+	GitHubToken           string `json:"-"`
+	GitHubAPIURL          string
+	GitHubHost            string
+	GitHubCAFile          string
+	GitHubHTTPClient      *http.Client `json:"-"`
+	BranchProtectionCheck bool
+	MergeabilityCheck     bool
+
+	// GitHub App authentication, in place of GITHUB_TOKEN
+	GitHubAppID             int
+	GitHubAppInstallationID int
+	GitHubAppPrivateKey     string `json:"-"`
+	GitHubAppPrivateKeyFile string
+	GitHubAppKey            *rsa.PrivateKey `json:"-"`
+
+	// Branch updates before merging, for PRs behind their base
+	UpdateBranch        bool
+	UpdateBranchQueue   string
+	UpdateBranchTimeout int
+
+	// Merge polling, confirming on GitHub that queued merges landed
+	MergePoll         bool
+	MergePollQueue    string
+	MergePollTimeout  int
+	MergePollInterval int
+
+	// Release-notes entries of merged PRs, overridable per repository
+	ReleaseNotes ReleaseNotesConfig
+
+	// Jira issue transitions after merging, off unless JIRA_URL is set
+	JiraURL         string
+	JiraUser        string
+	JiraAPIToken    string `json:"-"`
+	JiraTransitions map[string]string
+	JiraTransition  string
+
+	// Linear issues closed after merging, off unless LINEAR_API_KEY is set
+	LinearAPIKey string `json:"-"`
+	LinearAPIURL string
+	LinearTeams  map[string]string
+
+	// Branch deletion after merging, overridable per repository
+	DeleteBranch bool
+
+	// PR size gate, overridable per repository
+	MaxPRSize         int
+	SizeOverrideEmoji string
+
+	// Merge priorities: an emoji for urgent merges and a Poppit queue per priority
+	UrgentEmoji    string
+	PriorityQueues map[string]string
+
+	// Stacked PRs: an emoji merging a PR's stack bottom up, and the parents recorded from depends_on
+	StackEmoji     string
+	StackKeyPrefix string
+	StackTTL       int
+
+	// Label gates, overridable per repository
+	RequiredLabels []string
+	BlockedLabels  []string
+
+	// Operational alerts posted to Slack
+	OpsAlertChannel        string
+	OpsErrorAlerts         bool
+	SubscriptionAlertAfter int
+	MonitorInterval        int
+	PoppitQueueAlertLength int
+	DeadLetterAlertLength  int
+	QueueAlertAfter        int
+
+	// Sentry error reporting
+	SentryDSN         string `json:"-"`
+	SentryEnvironment string
+
+	// Paging on critical failures through PagerDuty or Opsgenie
+	PagerProvider       string
+	PagerDutyRoutingKey string `json:"-"`
+	OpsgenieAPIKey      string `json:"-"`
+	OpsgenieAPIURL      string
+
+	// Feature flags flipped at runtime in a Redis hash
+	FlagsKey             string
+	FlagsRefreshInterval int
+
+	// Slack bot token rotation
+	SlackTokenRotation string
+	SlackClientID      string
+	SlackClientSecret  string `json:"-"`
+	SlackRefreshToken  string `json:"-"`
+	SlackTokenKey      string
+
+	// Durable store (sqlite or postgres) of decisions and merge outcomes
+	StoreDriver string
+	StoreDSN    string `json:"-"`
+
+	// Active/standby leader election
+	LeaderElection bool
+	LeaderKey      string
+	LeaderLeaseTTL int
+	InstanceID     string
+	// ReactionPartitions spreads reactions over every instance by repository, with the Kafka or NATS transport
+	ReactionPartitions int
+
+	// Secrets provider (env, vault, aws or gcp) for tokens, passwords and other secret settings
+	SecretsProvider        string
+	SecretsRefreshInterval int
+	VaultAddr              string
+	VaultToken             string `json:"-"`
+	VaultTokenFile         string
+	VaultNamespace         string
+	VaultKVMount           string
+	VaultSecretPath        string
+	AWSRegion              string
+	AWSSecretID            string
+	GCPSecret              string
+
+	// Merge counters per day, repository and requester
+	StatsKeyPrefix     string
+	StatsRetentionDays int
+
+	// Daily merge summary, posted at SUMMARY_TIME in MERGE_TIMEZONE
+	SummaryChannel   string
+	SummaryTime      string
+	SummaryMinute    int `json:"-"`
+	SummaryKeyPrefix string
+
+	// Redis TLS, needed by managed Redis services such as ElastiCache and Azure Cache
+	RedisTLSEnabled            bool
+	RedisTLSCAFile             string
+	RedisTLSCertFile           string
+	RedisTLSKeyFile            string
+	RedisTLSInsecureSkipVerify bool
+	RedisTLS                   *tls.Config `json:"-"`
+
+	// Slack to GitHub identity mapping
+	Identities         *IdentityMap `json:"-"`
+	IdentityKey        string
+	IdentityEmailMatch bool
+
+	// Slack workspaces keyed by team ID, including their bot tokens
+	Workspaces map[string]WorkspaceConfig `json:"-"`
+
+	// Merge policy, overridable per repository via REPO_CONFIG_FILE
+	AllowSelfMerge     bool
+	AuthorizedUsers    []string
+	MergeRateLimit     int
+	MergeRateWindow    int
+	MergeRateMode      string
+	MergeRateKeyPrefix string
+	UserMergeQuota     int
+	UserQuotaKeyPrefix string
+	Repos              map[string]RepoConfig
+
+	// TimeBomb TTLs per Slack channel ID, overriding TIMEBOMB_TTL and the repository's timebomb_ttl
+	TimeBombChannelTTLs map[string]int
+
+	// Message cleanup, held back until the merge is confirmed
+	TimeBombMaxDelay   int
+	TimeBombPendingKey string
+	// CleanupMode is timebomb, update or delete, overridable per Slack channel ID by CleanupChannelModes
+	CleanupMode         string
+	CleanupChannelModes map[string]string
+	CleanupQueue        string
+	// MergeStatusUpdates shows each merge's progress on its Slack message
+	MergeStatusUpdates bool
+
+	// Backpressure on the Poppit queue
+	PoppitMaxQueueLength    int
+	PoppitBackpressureMode  string
+	PoppitBackpressureDelay int
+
+	// Poppit command templates per emoji, from COMMANDS_FILE
+	Commands        CommandTemplates     `json:"-"`
+	DefaultCommands []*template.Template `json:"-"`
+	ReadyCommands   []*template.Template `json:"-"`
+	ApproveCommands []*template.Template `json:"-"`
+	CloseCommands   []*template.Template `json:"-"`
+	RevertCommands  []*template.Template `json:"-"`
+	// CloseComment is posted on a PR before the close emoji closes it
+	CloseComment *template.Template `json:"-"`
+	// SquashSubject and SquashBody render the squash commit message of merges
+	SquashSubject *template.Template `json:"-"`
+	SquashBody    *template.Template `json:"-"`
+
+	// PR actions per emoji, from REACTIONS_FILE and COMMENTS_FILE
+	Reactions ReactionActions `json:"-"`
+
+	// Custom gates applied to merges, from GATES, and external plugins, from PLUGINS_FILE
+	Gates   []Gate                    `json:"-"`
+	Plugins map[string]*ProcessPlugin `json:"-"`
+
+	// Multi-step workflows per emoji, from WORKFLOWS_FILE
+	Workflows         Workflows `json:"-"`
+	WorkflowKeyPrefix string
+	WorkflowTTL       int
+
+	// All-or-nothing digests waiting on a Poppit result before requesting their next PR
+	FanOutKeyPrefix string
+	FanOutTTL       int
+
+	// Transport of inbound events and Poppit commands: Redis, NATS JetStream, Kafka or SQS and SNS
+	Transport          string
+	NATSURL            string
+	NATSStream         string
+	NATSDurablePrefix  string
+	NATSCredsFile      string
+	NATSToken          string `json:"-"`
+	KafkaBrokers       []string
+	KafkaGroupID       string
+	KafkaTLSEnabled    bool
+	KafkaSASLMechanism string
+	KafkaUsername      string
+	KafkaPassword      string `json:"-"`
+	SQSQueuePrefix     string
+	SQSWaitTime        int
+	SNSTopicARN        string
+	AWSEndpointURL     string
+
+	// Outbound webhooks notified of merges and dead-lettered events, from WEBHOOKS_FILE
+	Webhooks []WebhookConfig `json:"-"`
+
+	// Hook executables run with a merge's details on stdin; a failing pre hook vetoes the merge
+	PreDecisionHook string
+	PreQueueHook    string
+	PostQueueHook   string
+	PostConfirmHook string
+	HookTimeout     int
+
+	// EmojiAliases maps the names reactions arrive with to configured emoji, from EMOJI_ALIASES
+	EmojiAliases map[string]string
+
+	// Poppit payload settings per PR event action, from ACTIONS_FILE
+	Actions map[string]ActionConfig
+	// Event actions reactions are acted on, any when empty, from EVENT_ACTIONS
+	EventActions []string
+
+	// Debouncing merge reactions stacked on one message
+	TriggerDebounce   int
+	TriggerLockPrefix string
+
+	// Ignoring reactions on old messages, overridable per repository
+	MaxMessageAge   int
+	MessageAgeReply bool
+
+	// Cancelling pending merges
+	CancelEmoji           string
+	PendingKeyPrefix      string
+	PendingTTL            int
+	TimeBombCancelChannel string
+	MergeDelay            int
+
+	// Per-repository merge serialization
+	SerializeMerges      bool
+	MergeLockTimeout     int
+	RepoLockPrefix       string
+	RepoQueuePrefix      string
+	MergeTrain           bool
+	MergeTrainKey        string
+	PoppitResultsChannel string
+
+	// Merge blackout schedule
+	BlackoutWindows      []BlackoutWindow
+	BlackoutMode         string
+	Timezone             *time.Location `json:"-"`
+	DeferredQueue        string
+	DeferredPollInterval int
+
+	// Merge freezes
+	FreezeDates           []Freeze
+	FreezeCalendarURL     string `json:"-"`
+	FreezeCalendarRefresh int
 }
 
 // ReactionEvent represents the message from slack-relay-reaction-added channel
 type ReactionEvent struct {
+	// SchemaVersion is the version of the envelope, see schemas/reaction-event.schema.json; 0 means version 1
+	SchemaVersion       int         `json:"schema_version,omitempty"`
 	Token               string      `json:"token"`
 	TeamID              string      `json:"team_id"`
 	ContextTeamID       string      `json:"context_team_id"`
@@ -65,27 +412,116 @@ type ReactionEvent struct {
 
 // PRMetadata represents the metadata embedded in Slack messages
 type PRMetadata struct {
-	PRNumber   int    `json:"pr_number"`
-	Repository string `json:"repository"`
-	PRURL      string `json:"pr_url"`
-	Author     string `json:"author"`
-	Branch     string `json:"branch"`
+	// MetadataVersion is the version of the metadata, see metadata.go; 0 means version 1
+	MetadataVersion int    `json:"metadata_version,omitempty"`
+	PRNumber        int    `json:"pr_number"`
+	Repository      string `json:"repository"`
+	PRURL           string `json:"pr_url"`
+	Author          string `json:"author"`
+	Branch          string `json:"branch"`
+	Title           string `json:"title,omitempty"`
+	// BaseBranch is the branch the PR merges into, e.g. main or develop
+	BaseBranch string `json:"base_branch,omitempty"`
+	// EventAction is the action the message announces, e.g. opened or ready_for_review
+	EventAction string `json:"event_action,omitempty"`
+	// PRs lists the PRs of a digest message, merged in order by a single reaction
+	PRs []PRMetadata `json:"prs,omitempty"`
+	// AllOrNothing merges a digest's PRs one at a time, abandoning the rest when one isn't merged
+	AllOrNothing bool `json:"all_or_nothing,omitempty"`
+	// DependsOn is the PR in the same repository this PR is stacked on, which must be merged first
+	DependsOn int `json:"depends_on,omitempty"`
+	// GitHubUser is the requester's GitHub login, set by withGitHubLogin rather than read from the message
+	GitHubUser string `json:"-"`
+	// SquashFlags are the --subject and --body flags of the squash commit, set by withSquashFlags
+	SquashFlags string `json:"-"`
+	// MergeSHA is the commit the PR was merged with, set for the revert emoji
+	MergeSHA string `json:"-"`
+	// Priority is the priority to merge the PR at, e.g. urgent for a hotfix
+	Priority string `json:"priority,omitempty"`
+	// HeadSHA is the PR's head commit, Draft whether it is a draft and Provider where it is hosted, from version 2
+	HeadSHA  string `json:"head_sha,omitempty"`
+	Draft    bool   `json:"draft,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	// ChangedFiles are the paths the PR changes, matched against the repository's paths in place of asking GitHub
+	ChangedFiles []string `json:"changed_files,omitempty"`
 }
 
 // PoppitPayload represents the command payload to send to Poppit
 type PoppitPayload struct {
-	Repo     string   `json:"repo"`
-	Branch   string   `json:"branch"`
-	Type     string   `json:"type"`
-	Dir      string   `json:"dir"`
-	Commands []string `json:"commands"`
+	// SchemaVersion is the version of the payload, see schemas/poppit-payload.schema.json
+	SchemaVersion int      `json:"schema_version"`
+	Repo          string   `json:"repo"`
+	Branch        string   `json:"branch"`
+	Type          string   `json:"type"`
+	Dir           string   `json:"dir"`
+	Commands      []string `json:"commands"`
+	// GitHubUser is the GitHub login of the person who requested the merge, when known
+	GitHubUser string `json:"github_user,omitempty"`
+	// CorrelationID identifies this merge across VibeMerge, Poppit and the audit log
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
 
 // TimeBombMessage represents the TTL message to send to TimeBomb
 type TimeBombMessage struct {
-	Channel string `json:"channel"`
-	Ts      string `json:"ts"`
-	TTL     int    `json:"ttl"`
+	// SchemaVersion is the version of the message, see schemas/timebomb-message.schema.json
+	SchemaVersion int    `json:"schema_version"`
+	Channel       string `json:"channel"`
+	Ts            string `json:"ts"`
+	TTL           int    `json:"ttl"`
+}
+
+// MergeJob is a merge ready to be handed to Poppit, along with the Slack message that triggered it
+type MergeJob struct {
+	Payload     PoppitPayload `json:"payload"`
+	PRNumber    int           `json:"pr_number"`
+	Author      string        `json:"author,omitempty"`
+	RequestedBy string        `json:"requested_by"`
+	TeamID      string        `json:"team_id,omitempty"`
+	Channel     string        `json:"channel"`
+	Ts          string        `json:"ts"`
+	// Title, URL, BaseBranch and HeadBranch are the PR's, as its message gives them, for hooks
+	Title      string `json:"title,omitempty"`
+	URL        string `json:"url,omitempty"`
+	BaseBranch string `json:"base_branch,omitempty"`
+	HeadBranch string `json:"head_branch,omitempty"`
+	// Batch is set for merges requested from a digest, which share its Slack message
+	Batch bool `json:"batch,omitempty"`
+	// SizeOverride is set for merges requested with SIZE_OVERRIDE_EMOJI, which skip the PR size gate
+	SizeOverride bool `json:"size_override,omitempty"`
+	// Priority is urgent for merges requested with URGENT_EMOJI, a priority from PRIORITY_QUEUES, or empty
+	Priority string `json:"priority,omitempty"`
+	// EventTime is when the merge was requested, for the reaction_to_enqueue_seconds histogram
+	EventTime time.Time `json:"event_time,omitempty"`
+	// ChangedFiles are the PR's changed files from its message, and Queue the poppit_queue of the repository's
+	// paths they match, set by checkPaths
+	ChangedFiles []string `json:"changed_files,omitempty"`
+	Queue        string   `json:"queue,omitempty"`
+	// DependsOn is the PR this PR is stacked on, from its message's depends_on
+	DependsOn int `json:"depends_on,omitempty"`
+	// ApprovedBy is the Slack user who approved the merge with CONFIRM_MERGES
+	ApprovedBy string `json:"approved_by,omitempty"`
+}
+
+// Possible outcomes of a merge request
+const (
+	OutcomeQueued    = "queued"
+	OutcomeDeferred  = "deferred"
+	OutcomeDenied    = "denied"
+	OutcomeIgnored   = "ignored"
+	OutcomeCancelled = "cancelled"
+	OutcomeFailed    = "failed"
+	// OutcomeMerged is recorded when Poppit confirms a merge, not decided on a request
+	OutcomeMerged = "merged"
+)
+
+// Decision is what VibeMerge did with a merge request
+type Decision struct {
+	Outcome string
+	Reason  string
+	// Note is shown to the requester when set
+	Note string
+	// CancelButton offers a Cancel button with the note
+	CancelButton bool
 }
 
 // LogLevel represents the logging level
@@ -98,7 +534,14 @@ const (
 	LogLevelError
 )
 
-var currentLogLevel LogLevel
+// currentLogLevel is stored atomically so it can change on config reload
+var currentLogLevel atomic.Int32
+
+// activeConfig holds the running configuration, replaced atomically on reload
+var activeConfig atomic.Pointer[Config]
+
+// configFileValues holds settings read from CONFIG_FILE, consulted by getEnv
+var configFileValues map[string]string
 
 func parseLogLevel(level string) LogLevel {
 	switch strings.ToUpper(level) {
@@ -116,34 +559,109 @@ func parseLogLevel(level string) LogLevel {
 }
 
 func logDebug(format string, v ...interface{}) {
-	if LogLevelDebug >= currentLogLevel {
+	if LogLevelDebug >= LogLevel(currentLogLevel.Load()) {
 		log.Printf("[DEBUG] "+format, v...)
 	}
 }
 
 func logInfo(format string, v ...interface{}) {
-	if LogLevelInfo >= currentLogLevel {
+	if LogLevelInfo >= LogLevel(currentLogLevel.Load()) {
 		log.Printf("[INFO] "+format, v...)
 	}
 }
 
 func logWarning(format string, v ...interface{}) {
-	if LogLevelWarning >= currentLogLevel {
+	if LogLevelWarning >= LogLevel(currentLogLevel.Load()) {
 		log.Printf("[WARNING] "+format, v...)
 	}
 }
 
+// logError logs an error, reports it to Sentry and, with OPS_ERROR_ALERTS, posts it to OPS_ALERT_CHANNEL
 func logError(format string, v ...interface{}) {
-	if LogLevelError >= currentLogLevel {
+	logEventError(format, v...)
+	message := fmt.Sprintf(format, v...)
+	reportError(message)
+	alertOpsError(":x: VibeMerge error: " + message)
+}
+
+// logEventError logs an error without reporting or posting it, for event failures that are reported with their
+// context from the audit log instead
+func logEventError(format string, v ...interface{}) {
+	if LogLevelError >= LogLevel(currentLogLevel.Load()) {
 		log.Printf("[ERROR] "+format, v...)
 	}
 }
 
+// subcommands are the vibemerge subcommands, each given the arguments after its name
+var subcommands = map[string]func(args []string) error{
+	"serve":           runServeCommand,
+	"validate-config": runValidateConfigCommand,
+	"simulate":        runSimulateCommand,
+	"replay":          runReplayCommand,
+	"stats":           runStatsCommand,
+	"audit":           runAuditCommand,
+}
+
+const usage = `Usage: vibemerge [command] [flags]
+
+Commands:
+  serve            process reactions and slash commands (the default)
+  validate-config  check the configuration and exit
+  simulate         show what VibeMerge would do with a reaction event, without doing it
+  replay           handle failed or lost reactions again
+  stats            print merge counts per day, repository and requester
+  audit            print audit log entries
+
+Run vibemerge <command> -h for a command's flags.
+`
+
 func main() {
-	config := loadConfig()
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Print(usage)
+		return
+	}
+
+	run, ok := subcommands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+	if err := run(args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runServeCommand implements `vibemerge serve`, running VibeMerge until it is signalled or drained
+func runServeCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Parse(args)
+
+	config, err := loadServeConfig()
+	if err != nil {
+		return err
+	}
+	activeConfig.Store(config)
 
 	// Set the log level
-	currentLogLevel = parseLogLevel(config.LogLevel)
+	currentLogLevel.Store(int32(parseLogLevel(config.LogLevel)))
+	configureSlackLimiter(config)
+
+	if err := initSentry(config); err != nil {
+		return err
+	}
+	defer sentry.Flush(sentryFlushTimeout)
+
+	pager, err = newPager(config)
+	if err != nil {
+		return err
+	}
+	if pager != nil {
+		logInfo("Paging critical failures through %s", config.PagerProvider)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -153,208 +671,1595 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Initialize Redis client
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     config.RedisAddr,
-		Password: config.RedisPassword,
-		DB:       config.RedisDB,
-	})
+	redisClient := newRedisClient(config)
 	defer redisClient.Close()
 
 	// Test Redis connection
 	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		return fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 	logInfo("Connected to Redis successfully")
 
-	// Initialize Slack client
-	slackClient := slack.New(config.SlackBotToken)
-
-	// Start processing
-	go processReactions(ctx, redisClient, slackClient, config)
-
-	// Wait for shutdown signal
-	<-sigChan
-	logInfo("Shutdown signal received, exiting...")
-	cancel()
-}
+	closeTransport, err := openTransport(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer closeTransport()
 
-func loadConfig() *Config {
-	config := &Config{
-		SlackBotToken:   getEnv("SLACK_BOT_TOKEN", ""),
-		RedisAddr:       getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:   getEnv("REDIS_PASSWORD", ""),
-		RedisDB:         0,
-		WorkDir:         getEnv("WORK_DIR", "/tmp/vibemerge"),
-		TargetEmoji:     getEnv("TARGET_EMOJI", "heart_eyes_cat"),
-		TargetBranch:    getEnv("TARGET_BRANCH", "refs/heads/main"),
-		PoppitQueue:     getEnv("POPPIT_QUEUE", "poppit-commands"),
-		TimeBombChannel: getEnv("TIMEBOMB_CHANNEL", "timebomb-messages"),
-		TimeBombTTL:     getEnvInt("TIMEBOMB_TTL", 86400), // 24 hours in seconds
-		LogLevel:        getEnv("LOG_LEVEL", "INFO"),
+	if err := slackTokens.start(ctx, redisClient, config); err != nil {
+		return fmt.Errorf("failed to load the rotated Slack token: %w", err)
 	}
 
-	if config.SlackBotToken == "" {
-		log.Fatal("SLACK_BOT_TOKEN environment variable is required")
+	if err := refreshFeatureFlags(ctx, redisClient, config); err != nil {
+		return err
 	}
 
-	return config
-}
+	if config.StoreDriver != "" {
+		sqlStore, err := openStore(ctx, config)
+		if err != nil {
+			return fmt.Errorf("failed to open the durable store: %w", err)
+		}
+		defer sqlStore.Close()
+		store = sqlStore
+		logInfo("Recording decisions and merge outcomes in the %s store", config.StoreDriver)
+	}
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	// Slack clients are created per workspace bot token as events arrive
+	slackClients := newSlackClients()
+	slackBreaker.alert = func(text string) {
+		alertCtx, cancel := context.WithTimeout(context.WithValue(ctx, bypassBreakerKey{}, true), 30*time.Second)
+		defer cancel()
+		alertOps(alertCtx, slackClients, currentConfig(), text)
 	}
-	return defaultValue
-}
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+	// Event loops consume events and queues, so with leader election only the leader runs them.
+	// Each loop finishes the event it is handling before returning.
+	eventLoops := []func(context.Context){
+		func(ctx context.Context) { processDeferredMerges(ctx, redisClient) },
+		func(ctx context.Context) { processScheduledMerges(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processBranchUpdates(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processMergePolls(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processAdminCommands(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processPoppitResults(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processRepoQueues(ctx, redisClient) },
+		func(ctx context.Context) { processMergeTrains(ctx, redisClient) },
+		func(ctx context.Context) { processDailySummary(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processParkedReactions(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processQueueMonitor(ctx, redisClient, slackClients) },
+		func(ctx context.Context) { processCleanupDeadlines(ctx, redisClient) },
+		func(ctx context.Context) { processMessageCleanups(ctx, redisClient, slackClients) },
+	}
+	if config.InputMode == InputModeSocket {
+		eventLoops = append(eventLoops, func(ctx context.Context) { processSocketMode(ctx, redisClient, slackClients) })
+	} else {
+		// With REACTION_PARTITIONS every replica consumes reactions, each taking its share of the partitions
+		if config.ReactionPartitions == 0 {
+			eventLoops = append(eventLoops, func(ctx context.Context) { processReactions(ctx, redisClient, slackClients) })
+		}
+		eventLoops = append(eventLoops, func(ctx context.Context) { processSlashCommands(ctx, redisClient, slackClients) })
+		if config.AppHome {
+			eventLoops = append(eventLoops, func(ctx context.Context) { processAppHomeEvents(ctx, redisClient, slackClients) })
+		}
+		if config.interactive() {
+			eventLoops = append(eventLoops, func(ctx context.Context) { processInteractions(ctx, redisClient, slackClients) })
+		}
+		if config.ScheduledMerges {
+			eventLoops = append(eventLoops, func(ctx context.Context) { processMentions(ctx, redisClient, slackClients) })
 		}
-		// Use standard log here since logging system may not be initialized yet
-		log.Printf("[WARNING] invalid integer value for %s: %s, using default: %d", key, value, defaultValue)
 	}
-	return defaultValue
-}
 
-func processReactions(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config) {
-	pubsub := redisClient.Subscribe(ctx, "slack-relay-reaction-added")
-	defer pubsub.Close()
+	loops := []func(context.Context){
+		func(ctx context.Context) { processSecretsRefresh(ctx) },
+		func(ctx context.Context) { processOpsErrors(ctx, slackClients) },
+		func(ctx context.Context) { processFeatureFlags(ctx, redisClient) },
+		func(ctx context.Context) { processFreezeCalendar(ctx) },
+	}
+	if config.ReactionPartitions > 0 {
+		loops = append(loops, func(ctx context.Context) { processReactions(ctx, redisClient, slackClients) })
+	}
+	if config.LeaderElection {
+		loops = append(loops, func(ctx context.Context) { runAsLeader(ctx, redisClient, config, eventLoops) })
+	} else {
+		loops = append(loops, eventLoops...)
+	}
+	if config.HTTPAddr != "" {
+		if config.APIToken != "" {
+			// Stats and the dashboard show the audit log and the configuration, so they need the API token too
+			http.Handle("/stats", apiAuth(statsHandler(redisClient)))
+			http.Handle("/dashboard", apiAuth(dashboardHandler(redisClient)))
+			registerAPI(redisClient, slackClients)
+		} else {
+			logInfo("API_TOKEN is not set, the REST API, stats and dashboard are disabled")
+		}
+		if config.GitHubWebhookSecret != "" {
+			http.Handle("/github/webhook", githubWebhookHandler(redisClient))
+		} else {
+			logInfo("GITHUB_WEBHOOK_SECRET is not set, the GitHub webhook endpoint is disabled")
+		}
+		if config.SlackSigningSecret != "" {
+			http.Handle("/slack/interactions", slackInteractionsHandler(redisClient, slackClients))
+		}
+		loops = append(loops, func(ctx context.Context) { serveHTTP(ctx, config.HTTPAddr) })
+	}
+	if config.GRPCAddr != "" {
+		loops = append(loops, func(ctx context.Context) { serveGRPC(ctx, config.GRPCAddr, redisClient, slackClients) })
+	}
 
-	logInfo("Subscribed to slack-relay-reaction-added channel")
+	done := make(chan struct{})
+	go func() {
+		runLoops(ctx, loops)
+		close(done)
+	}()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			msg, err := pubsub.ReceiveMessage(ctx)
-			if err != nil {
-				logError("Error receiving message: %v", err)
-				continue
-			}
+	// Wait for shutdown signal or an admin drain request
+	select {
+	case <-sigChan:
+		logInfo("Shutdown signal received, exiting...")
+	case <-drainRequested:
+		logInfo("Drain requested, finishing in-flight work and exiting...")
+	}
+	cancel()
+	<-done
+	return nil
+}
 
-			if err := handleReactionMessage(ctx, msg.Payload, redisClient, slackClient, config); err != nil {
-				logError("Error handling reaction message: %v", err)
-			}
-		}
+// loadServeConfig loads the configuration and checks the settings needed to handle events
+func loadServeConfig() (*Config, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
 	}
+	if config.SlackBotToken == "" && len(config.Workspaces) == 0 {
+		return nil, fmt.Errorf("SLACK_BOT_TOKEN environment variable is required")
+	}
+	return config, nil
 }
 
-func handleReactionMessage(ctx context.Context, payload string, redisClient *redis.Client, slackClient *slack.Client, config *Config) error {
-	var reactionEvent ReactionEvent
-	if err := json.Unmarshal([]byte(payload), &reactionEvent); err != nil {
-		return fmt.Errorf("failed to unmarshal reaction event: %w", err)
+// runValidateConfigCommand implements `vibemerge validate-config`, loading the configuration as serve would
+func runValidateConfigCommand(args []string) error {
+	flags := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	printConfig := flags.Bool("print", false, "print the configuration as JSON, without secrets")
+	flags.Parse(args)
+
+	config, err := loadServeConfig()
+	if err != nil {
+		return err
 	}
+	if *printConfig {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(config)
+	}
+	fmt.Println("Configuration is valid")
+	return nil
+}
 
-	// Only process configured target emoji reactions
-	if reactionEvent.Event.Reaction != config.TargetEmoji {
-		logDebug("Ignoring reaction: %s", reactionEvent.Event.Reaction)
-		return nil
+// runLoops runs each loop in its own goroutine until all of them have returned
+func runLoops(ctx context.Context, loops []func(context.Context)) {
+	var wg sync.WaitGroup
+	for _, loop := range loops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reportPanic()
+			loop(ctx)
+		}()
 	}
+	wg.Wait()
+}
 
-	logInfo("Processing %s reaction on message %s in channel %s",
-		config.TargetEmoji, reactionEvent.Event.Item.Ts, reactionEvent.Event.Item.Channel)
+func currentConfig() *Config {
+	return activeConfig.Load()
+}
 
-	// Retrieve the message from Slack
-	metadata, err := getMessageMetadata(slackClient, reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts)
+func loadConfig() (*Config, error) {
+	values, err := readConfigFile(os.Getenv("CONFIG_FILE"))
 	if err != nil {
-		return fmt.Errorf("failed to get message metadata: %w", err)
+		return nil, err
 	}
+	configFileValues = values
 
-	if metadata == nil {
-		logDebug("No PR metadata found in message, ignoring")
-		return nil
-	}
+	config := &Config{
+		SlackBotToken:      getEnv("SLACK_BOT_TOKEN", ""),
+		SlackAppToken:      getEnv("SLACK_APP_TOKEN", ""),
+		InputMode:          strings.ToLower(getEnv("INPUT_MODE", InputModeRelay)),
+		RedisAddr:          getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:      getEnv("REDIS_PASSWORD", ""),
+		RedisDB:            0,
+		WorkDir:            getEnv("WORK_DIR", "/tmp/vibemerge"),
+		TargetEmoji:        getEnv("TARGET_EMOJI", "heart_eyes_cat"),
+		ReadyEmoji:         getEnv("READY_EMOJI", ""),
+		ApproveEmoji:       getEnv("APPROVE_EMOJI", ""),
+		CloseEmoji:         getEnv("CLOSE_EMOJI", ""),
+		RevertEmoji:        getEnv("REVERT_EMOJI", ""),
+		ParsePRLinks:       getEnvBool("PARSE_PR_LINKS", false),
+		PRLinkHosts:        getEnvList("PR_LINK_HOSTS"),
+		MetadataEventTypes: getEnvList("METADATA_EVENT_TYPES"),
+		PRLinkOrgs:         getEnvList("PR_LINK_ORGS"),
+		TargetBranch:       getEnv("TARGET_BRANCH", "refs/heads/main"),
+		PoppitQueue:        getEnv("POPPIT_QUEUE", "poppit-commands"),
+		SlashCommand:       getEnv("SLASH_COMMAND", "/vibemerge"),
+		SlashChannel:       getEnv("SLASH_COMMAND_CHANNEL", "slack-relay-slash-commands"),
+		AdminChannel:       getEnv("ADMIN_CHANNEL", "vibemerge-admin"),
+		AdminReplyChannel:  getEnv("ADMIN_REPLY_CHANNEL", "vibemerge-admin-replies"),
+		PauseKey:           getEnv("PAUSE_KEY", "vibemerge:paused"),
+		AuditStream:        getEnv("AUDIT_STREAM", "vibemerge:audit"),
+		AuditLogFile:       getEnv("AUDIT_LOG_FILE", ""),
+		AuditMaxLength:     getEnvInt("AUDIT_MAX_LENGTH", 0),
+		TimeBombChannel:    getEnv("TIMEBOMB_CHANNEL", "timebomb-messages"),
+		TimeBombTTL:        getEnvInt("TIMEBOMB_TTL", 86400), // 24 hours in seconds
+		LogLevel:           getEnv("LOG_LEVEL", "INFO"),
+		WorkerCount:        getEnvInt("WORKER_COUNT", 4),
+		EventTimeout:       getEnvInt("EVENT_TIMEOUT", 30),
+		SlackRateLimit:     getEnvInt("SLACK_RATE_LIMIT", 50),
+		SlackRateBurst:     getEnvInt("SLACK_RATE_BURST", 5),
+		SlackMaxRetries:    getEnvInt("SLACK_MAX_RETRIES", 3),
+		HTTPAddr:           getEnv("HTTP_ADDR", ""),
 
-	logInfo("Found PR metadata: repo=%s, pr=%d", metadata.Repository, metadata.PRNumber)
+		SlackBreakerThreshold: getEnvInt("SLACK_BREAKER_THRESHOLD", 5),
+		SlackBreakerCooldown:  getEnvInt("SLACK_BREAKER_COOLDOWN", 60),
+		DeadLetterQueue:       getEnv("DEAD_LETTER_QUEUE", "vibemerge:dead-letter"),
+		RejectedEventQueue:    getEnv("REJECTED_EVENT_QUEUE", "vibemerge:rejected"),
 
-	// Create Poppit payload
-	poppitPayload := PoppitPayload{
-		Repo:   metadata.Repository,
-		Branch: config.TargetBranch,
-		Type:   "vibe-merge",
-		Dir:    config.WorkDir,
-		Commands: []string{
-			fmt.Sprintf("gh pr --repo %s ready %d", metadata.Repository, metadata.PRNumber),
-			fmt.Sprintf("gh pr --repo %s merge %d --squash", metadata.Repository, metadata.PRNumber),
+		AppHome:        getEnvBool("APP_HOME", false),
+		AppHomeChannel: getEnv("APP_HOME_CHANNEL", "slack-relay-app-home-opened"),
+
+		ConfirmMerges:      getEnvBool("CONFIRM_MERGES", false),
+		ConfirmTTL:         getEnvInt("CONFIRM_TTL", 3600),
+		ConfirmKeyPrefix:   getEnv("CONFIRM_KEY_PREFIX", "vibemerge:confirm"),
+		InteractionChannel: getEnv("INTERACTION_CHANNEL", "slack-relay-interactions"),
+		SlackSigningSecret: getEnv("SLACK_SIGNING_SECRET", ""),
+
+		ScheduledMerges: getEnvBool("SCHEDULED_MERGES", false),
+		MentionChannel:  getEnv("MENTION_CHANNEL", "slack-relay-app-mention"),
+		ScheduledQueue:  getEnv("SCHEDULED_QUEUE", "vibemerge:scheduled"),
+
+		APIToken: getEnv("API_TOKEN", ""),
+		GRPCAddr: getEnv("GRPC_ADDR", ""),
+
+		GitHubWebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
+		PRStateKeyPrefix:    getEnv("PR_STATE_KEY_PREFIX", "vibemerge:pr-state"),
+		PRStateTTL:          getEnvInt("PR_STATE_TTL", 30*86400),
+
+		MergeCommitKeyPrefix: getEnv("MERGE_COMMIT_KEY_PREFIX", "vibemerge:merge-commit"),
+		MergeCommitTTL:       getEnvInt("MERGE_COMMIT_TTL", 30*86400),
+
+		WorkflowKeyPrefix: getEnv("WORKFLOW_KEY_PREFIX", "vibemerge:workflow"),
+		WorkflowTTL:       getEnvInt("WORKFLOW_TTL", 86400),
+		FanOutKeyPrefix:   getEnv("FANOUT_KEY_PREFIX", "vibemerge:fanout"),
+		FanOutTTL:         getEnvInt("FANOUT_TTL", 86400),
+
+		Transport:          strings.ToLower(getEnv("TRANSPORT", TransportRedis)),
+		NATSURL:            getEnv("NATS_URL", "nats://127.0.0.1:4222"),
+		NATSStream:         getEnv("NATS_STREAM", "VIBEMERGE"),
+		NATSDurablePrefix:  getEnv("NATS_DURABLE_PREFIX", "vibemerge"),
+		NATSCredsFile:      getEnv("NATS_CREDS_FILE", ""),
+		NATSToken:          getEnv("NATS_TOKEN", ""),
+		KafkaBrokers:       getEnvList("KAFKA_BROKERS"),
+		KafkaGroupID:       getEnv("KAFKA_GROUP_ID", "vibemerge"),
+		KafkaTLSEnabled:    getEnvBool("KAFKA_TLS_ENABLED", false),
+		KafkaSASLMechanism: strings.ToLower(getEnv("KAFKA_SASL_MECHANISM", "")),
+		KafkaUsername:      getEnv("KAFKA_USERNAME", ""),
+		KafkaPassword:      getEnv("KAFKA_PASSWORD", ""),
+		SQSQueuePrefix:     getEnv("SQS_QUEUE_PREFIX", ""),
+		SQSWaitTime:        getEnvInt("SQS_WAIT_TIME", 20),
+		SNSTopicARN:        getEnv("SNS_TOPIC_ARN", ""),
+		AWSEndpointURL:     getEnv("AWS_ENDPOINT_URL", ""),
+
+		PreDecisionHook: getEnv("PRE_DECISION_HOOK", ""),
+		PreQueueHook:    getEnv("PRE_QUEUE_HOOK", ""),
+		PostQueueHook:   getEnv("POST_QUEUE_HOOK", ""),
+		PostConfirmHook: getEnv("POST_CONFIRM_HOOK", ""),
+		HookTimeout:     getEnvInt("HOOK_TIMEOUT", 30),
+
+		GitHubToken:           getEnv("GITHUB_TOKEN", ""),
+		GitHubHost:            getEnv("GH_HOST", defaultGitHubHost),
+		GitHubCAFile:          getEnv("GITHUB_CA_FILE", ""),
+		BranchProtectionCheck: getEnvBool("BRANCH_PROTECTION_CHECK", false),
+		MergeabilityCheck:     getEnvBool("MERGEABILITY_CHECK", false),
+
+		GitHubAppID:             getEnvInt("GITHUB_APP_ID", 0),
+		GitHubAppInstallationID: getEnvInt("GITHUB_APP_INSTALLATION_ID", 0),
+		GitHubAppPrivateKey:     getEnv("GITHUB_APP_PRIVATE_KEY", ""),
+		GitHubAppPrivateKeyFile: getEnv("GITHUB_APP_PRIVATE_KEY_FILE", ""),
+
+		UpdateBranch:        getEnvBool("UPDATE_BRANCH", false),
+		UpdateBranchQueue:   getEnv("UPDATE_BRANCH_QUEUE", "vibemerge:updating"),
+		UpdateBranchTimeout: getEnvInt("UPDATE_BRANCH_TIMEOUT", 1800),
+
+		MergePoll:         getEnvBool("MERGE_POLL", false),
+		MergePollQueue:    getEnv("MERGE_POLL_QUEUE", "vibemerge:merge-poll"),
+		MergePollTimeout:  getEnvInt("MERGE_POLL_TIMEOUT", 900),
+		MergePollInterval: getEnvInt("MERGE_POLL_INTERVAL", 30),
+
+		ReleaseNotes: ReleaseNotesConfig{
+			SlackChannel: getEnv("RELEASE_NOTES_SLACK_CHANNEL", ""),
+			Channel:      getEnv("RELEASE_NOTES_CHANNEL", ""),
+			Template:     getEnv("RELEASE_NOTES_TEMPLATE", defaultReleaseNoteTemplate),
 		},
+
+		JiraURL:        getEnv("JIRA_URL", ""),
+		JiraUser:       getEnv("JIRA_USER", ""),
+		JiraAPIToken:   getEnv("JIRA_API_TOKEN", ""),
+		JiraTransition: getEnv("JIRA_TRANSITION", ""),
+
+		LinearAPIKey: getEnv("LINEAR_API_KEY", ""),
+		LinearAPIURL: getEnv("LINEAR_API_URL", "https://api.linear.app/graphql"),
+
+		DeleteBranch: getEnvBool("DELETE_BRANCH", false),
+
+		MaxPRSize:         getEnvInt("MAX_PR_SIZE", 0),
+		SizeOverrideEmoji: getEnv("SIZE_OVERRIDE_EMOJI", ""),
+
+		UrgentEmoji: getEnv("URGENT_EMOJI", ""),
+
+		StackEmoji:     getEnv("STACK_EMOJI", ""),
+		StackKeyPrefix: getEnv("STACK_KEY_PREFIX", "vibemerge:stack"),
+		StackTTL:       getEnvInt("STACK_TTL", 30*86400),
+
+		RequiredLabels: getEnvList("REQUIRED_LABELS"),
+		BlockedLabels:  getEnvList("BLOCKED_LABELS"),
+
+		OpsAlertChannel:        getEnv("OPS_ALERT_CHANNEL", ""),
+		OpsErrorAlerts:         getEnvBool("OPS_ERROR_ALERTS", false),
+		SubscriptionAlertAfter: getEnvInt("SUBSCRIPTION_ALERT_AFTER", 120),
+		MonitorInterval:        getEnvInt("MONITOR_INTERVAL", 60),
+		PoppitQueueAlertLength: getEnvInt("POPPIT_QUEUE_ALERT_LENGTH", 0),
+		DeadLetterAlertLength:  getEnvInt("DEAD_LETTER_ALERT_LENGTH", 0),
+		QueueAlertAfter:        getEnvInt("QUEUE_ALERT_AFTER", 300),
+
+		SentryDSN:         getEnv("SENTRY_DSN", ""),
+		SentryEnvironment: getEnv("SENTRY_ENVIRONMENT", ""),
+
+		PagerProvider:       strings.ToLower(getEnv("PAGER_PROVIDER", "")),
+		PagerDutyRoutingKey: getEnv("PAGERDUTY_ROUTING_KEY", ""),
+		OpsgenieAPIKey:      getEnv("OPSGENIE_API_KEY", ""),
+		OpsgenieAPIURL:      getEnv("OPSGENIE_API_URL", "https://api.opsgenie.com"),
+
+		FlagsKey:             getEnv("FLAGS_KEY", "vibemerge:flags"),
+		FlagsRefreshInterval: getEnvInt("FLAGS_REFRESH_INTERVAL", 30),
+
+		StoreDriver: strings.ToLower(getEnv("STORE_DRIVER", "")),
+		StoreDSN:    getEnv("STORE_DSN", ""),
+
+		LeaderElection: getEnvBool("LEADER_ELECTION", false),
+		LeaderKey:      getEnv("LEADER_KEY", "vibemerge:leader"),
+		LeaderLeaseTTL: getEnvInt("LEADER_LEASE_TTL", 15),
+		InstanceID:     getEnv("INSTANCE_ID", defaultInstanceID()),
+
+		ReactionPartitions: getEnvInt("REACTION_PARTITIONS", 0),
+
+		SlackTokenRotation: strings.ToLower(getEnv("SLACK_TOKEN_ROTATION", "")),
+		SlackClientID:      getEnv("SLACK_CLIENT_ID", ""),
+		SlackClientSecret:  getEnv("SLACK_CLIENT_SECRET", ""),
+		SlackRefreshToken:  getEnv("SLACK_REFRESH_TOKEN", ""),
+		SlackTokenKey:      getEnv("SLACK_TOKEN_KEY", "vibemerge:slack-token"),
+
+		SecretsProvider:        strings.ToLower(getEnv("SECRETS_PROVIDER", SecretsProviderEnv)),
+		SecretsRefreshInterval: getEnvInt("SECRETS_REFRESH_INTERVAL", 300),
+		VaultAddr:              getEnv("VAULT_ADDR", ""),
+		VaultToken:             getEnv("VAULT_TOKEN", ""),
+		VaultTokenFile:         getEnv("VAULT_TOKEN_FILE", ""),
+		VaultNamespace:         getEnv("VAULT_NAMESPACE", ""),
+		VaultKVMount:           getEnv("VAULT_KV_MOUNT", "secret"),
+		VaultSecretPath:        getEnv("VAULT_SECRET_PATH", ""),
+		AWSRegion:              getEnv("AWS_REGION", getEnv("AWS_DEFAULT_REGION", "")),
+		AWSSecretID:            getEnv("AWS_SECRET_ID", ""),
+		GCPSecret:              getEnv("GCP_SECRET", ""),
+
+		StatsKeyPrefix:     getEnv("STATS_KEY_PREFIX", "vibemerge:stats"),
+		StatsRetentionDays: getEnvInt("STATS_RETENTION_DAYS", 90),
+
+		SummaryChannel:   getEnv("SUMMARY_CHANNEL", ""),
+		SummaryTime:      getEnv("SUMMARY_TIME", "09:00"),
+		SummaryKeyPrefix: getEnv("SUMMARY_KEY_PREFIX", "vibemerge:summary"),
+
+		RedisTLSEnabled:            getEnvBool("REDIS_TLS_ENABLED", false),
+		RedisTLSCAFile:             getEnv("REDIS_TLS_CA_FILE", ""),
+		RedisTLSCertFile:           getEnv("REDIS_TLS_CERT_FILE", ""),
+		RedisTLSKeyFile:            getEnv("REDIS_TLS_KEY_FILE", ""),
+		RedisTLSInsecureSkipVerify: getEnvBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+
+		BlackoutMode:         strings.ToLower(getEnv("BLACKOUT_MODE", BlackoutModeReject)),
+		DeferredQueue:        getEnv("DEFERRED_QUEUE", "vibemerge:deferred"),
+		DeferredPollInterval: getEnvInt("DEFERRED_POLL_INTERVAL", 30),
+
+		FreezeCalendarURL:     getEnv("FREEZE_CALENDAR_URL", ""),
+		FreezeCalendarRefresh: getEnvInt("FREEZE_CALENDAR_REFRESH", 3600),
+
+		IdentityKey:        getEnv("IDENTITY_KEY", "vibemerge:identities"),
+		IdentityEmailMatch: getEnvBool("IDENTITY_EMAIL_MATCH", false),
+
+		AllowSelfMerge:     getEnvBool("ALLOW_SELF_MERGE", true),
+		AuthorizedUsers:    getEnvList("AUTHORIZED_USERS"),
+		MergeRateLimit:     getEnvInt("MERGE_RATE_LIMIT", 0),
+		MergeRateWindow:    getEnvInt("MERGE_RATE_WINDOW", 3600),
+		MergeRateMode:      strings.ToLower(getEnv("MERGE_RATE_MODE", MergeRateModeReject)),
+		MergeRateKeyPrefix: getEnv("MERGE_RATE_KEY_PREFIX", "vibemerge:merge-rate"),
+		UserQuotaKeyPrefix: getEnv("USER_QUOTA_KEY_PREFIX", "vibemerge:user-quota"),
+
+		PoppitMaxQueueLength:    getEnvInt("POPPIT_MAX_QUEUE_LENGTH", 0),
+		PoppitBackpressureMode:  strings.ToLower(getEnv("POPPIT_BACKPRESSURE_MODE", BackpressureModeReject)),
+		PoppitBackpressureDelay: getEnvInt("POPPIT_BACKPRESSURE_DELAY", 300),
+
+		TriggerDebounce:   getEnvInt("TRIGGER_DEBOUNCE", 30),
+		TriggerLockPrefix: getEnv("TRIGGER_LOCK_PREFIX", "vibemerge:trigger"),
+
+		MaxMessageAge:   getEnvInt("MAX_MESSAGE_AGE", 0),
+		MessageAgeReply: getEnvBool("MESSAGE_AGE_REPLY", false),
+
+		CancelEmoji:           getEnvAllowEmpty("CANCEL_EMOJI", "no_entry"),
+		PendingKeyPrefix:      getEnv("PENDING_KEY_PREFIX", "vibemerge:pending"),
+		PendingTTL:            getEnvInt("PENDING_TTL", 86400),
+		TimeBombCancelChannel: getEnv("TIMEBOMB_CANCEL_CHANNEL", "timebomb-cancel"),
+		MergeDelay:            getEnvInt("MERGE_DELAY_SECONDS", 0),
+		TimeBombMaxDelay:      getEnvInt("TIMEBOMB_MAX_DELAY", 86400),
+		TimeBombPendingKey:    getEnv("TIMEBOMB_PENDING_KEY", "vibemerge:timebomb-pending"),
+		CleanupMode:           strings.ToLower(getEnv("CLEANUP_MODE", CleanupModeTimeBomb)),
+		CleanupQueue:          getEnv("CLEANUP_QUEUE", "vibemerge:cleanup"),
+		MergeStatusUpdates:    getEnvBool("MERGE_STATUS_UPDATES", false),
+
+		SerializeMerges:      getEnvBool("SERIALIZE_MERGES", false),
+		MergeLockTimeout:     getEnvInt("MERGE_LOCK_TIMEOUT", 900),
+		RepoLockPrefix:       getEnv("REPO_LOCK_PREFIX", "vibemerge:repo-lock"),
+		RepoQueuePrefix:      getEnv("REPO_QUEUE_PREFIX", "vibemerge:repo-queue"),
+		MergeTrain:           getEnvBool("MERGE_TRAIN", false),
+		MergeTrainKey:        getEnv("MERGE_TRAIN_KEY", "vibemerge:merge-train"),
+		PoppitResultsChannel: getEnv("POPPIT_RESULTS_CHANNEL", "poppit-results"),
 	}
 
-	// Publish to Poppit queue
-	payloadJSON, err := json.Marshal(poppitPayload)
+	identities, err := loadIdentityMap(getEnv("IDENTITY_FILE", ""))
 	if err != nil {
-		return fmt.Errorf("failed to marshal poppit payload: %w", err)
+		return nil, err
 	}
+	config.Identities = identities
 
-	if err := redisClient.RPush(ctx, config.PoppitQueue, string(payloadJSON)).Err(); err != nil {
-		return fmt.Errorf("failed to push to %s: %w", config.PoppitQueue, err)
+	config.UserMergeQuota, err = parseUserMergeQuota(getEnv("USER_MERGE_QUOTA", ""))
+	if err != nil {
+		return nil, err
 	}
 
-	logInfo("Successfully queued merge command for PR %d in %s", metadata.PRNumber, metadata.Repository)
+	redisTLS, err := loadRedisTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	config.RedisTLS = redisTLS
 
-	// Set TTL on the processed message by publishing to TimeBomb
-	channel := reactionEvent.Event.Item.Channel
-	timestamp := reactionEvent.Event.Item.Ts
-	if err := publishTimeBombMessage(ctx, redisClient, config, channel, timestamp); err != nil {
-		// Log the error but don't fail the entire operation
-		logWarning("Failed to set TTL on message: %v", err)
+	if err := checkGitHubHost(config.GitHubHost); err != nil {
+		return nil, err
+	}
+	config.GitHubAPIURL = getEnv("GITHUB_API_URL", gitHubAPIURL(config.GitHubHost))
+	githubClient, err := loadGitHubHTTPClient(config)
+	if err != nil {
+		return nil, err
 	}
+	config.GitHubHTTPClient = githubClient
 
-	return nil
-}
+	workspaces, err := loadWorkspaceConfigs(getEnv("WORKSPACES_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.Workspaces = workspaces
 
-func getMessageMetadata(slackClient *slack.Client, channel, timestamp string) (*PRMetadata, error) {
-	// Retrieve the message using conversations.history
-	params := &slack.GetConversationHistoryParameters{
-		ChannelID:          channel,
-		Latest:             timestamp,
-		Limit:              1,
-		Inclusive:          true,
-		IncludeAllMetadata: true,
+	repos, err := loadRepoConfigs(getEnv("REPO_CONFIG_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.Repos = repos
+	if err := config.checkDeleteBranch(); err != nil {
+		return nil, err
+	}
+	if config.MaxMessageAge < 0 {
+		return nil, fmt.Errorf("MAX_MESSAGE_AGE must not be negative, got %d", config.MaxMessageAge)
+	}
+	if config.TriggerDebounce < 0 {
+		return nil, fmt.Errorf("TRIGGER_DEBOUNCE must not be negative, got %d", config.TriggerDebounce)
+	}
+	if config.MaxPRSize < 0 {
+		return nil, fmt.Errorf("MAX_PR_SIZE must not be negative, got %d", config.MaxPRSize)
+	}
+	if err := config.checkSizeGate(); err != nil {
+		return nil, err
+	}
+	if err := config.checkLabelGates(); err != nil {
+		return nil, err
+	}
+	if err := config.checkMergePoll(); err != nil {
+		return nil, err
+	}
+	if err := config.ReleaseNotes.parse(); err != nil {
+		return nil, fmt.Errorf("invalid RELEASE_NOTES_TEMPLATE: %w", err)
+	}
+	if err := config.checkReleaseNotes(); err != nil {
+		return nil, err
+	}
+	if err := config.checkRepoPaths(); err != nil {
+		return nil, err
+	}
+	jiraTransitions, err := parseJiraTransitions(getEnv("JIRA_TRANSITIONS", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.JiraTransitions = jiraTransitions
+	linearTeams, err := parseLinearTeams(getEnv("LINEAR_TEAMS", ""))
+	if err != nil {
+		return nil, err
 	}
+	config.LinearTeams = linearTeams
 
-	history, err := slackClient.GetConversationHistory(params)
+	channelTTLs, err := parseChannelTTLs(getEnv("TIMEBOMB_CHANNEL_TTLS", ""))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+		return nil, err
 	}
+	config.TimeBombChannelTTLs = channelTTLs
 
-	if len(history.Messages) == 0 {
-		return nil, fmt.Errorf("no message found at timestamp %s", timestamp)
+	cleanupModes, err := parseChannelModes(getEnv("CLEANUP_CHANNEL_MODES", ""))
+	if err != nil {
+		return nil, err
 	}
+	config.CleanupChannelModes = cleanupModes
 
-	message := history.Messages[0]
+	aliases, err := parseEmojiAliases(getEnv("EMOJI_ALIASES", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.EmojiAliases = aliases
+	if config.PriorityQueues, err = parsePriorityQueues(getEnv("PRIORITY_QUEUES", "")); err != nil {
+		return nil, err
+	}
 
-	// Check if message has metadata
-	if message.Metadata.EventType == "" {
-		return nil, nil
+	target, targets := splitTargetEmoji(config.TargetEmoji)
+	if target == "" {
+		return nil, fmt.Errorf("TARGET_EMOJI must name at least one emoji")
+	}
+	config.TargetEmoji = target
+	for emoji, target := range targets {
+		if _, ok := config.EmojiAliases[emoji]; ok {
+			return nil, fmt.Errorf("TARGET_EMOJI %s is also an alias in EMOJI_ALIASES", emoji)
+		}
+		config.EmojiAliases[emoji] = target
 	}
 
-	// Parse metadata as PRMetadata
-	var metadata PRMetadata
-	metadataJSON, err := json.Marshal(message.Metadata.EventPayload)
+	commands, err := loadCommandTemplates(getEnv("COMMANDS_FILE", ""))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, err
 	}
+	config.Commands = commands
 
-	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal PR metadata: %w", err)
+	defaults, err := parseCommandTemplates(map[string][]string{
+		"merge":   defaultMergeCommands,
+		"ready":   defaultReadyCommands,
+		"approve": defaultApproveCommands,
+		"close":   defaultCloseCommands,
+		"revert":  defaultRevertCommands,
+	})
+	if err != nil {
+		return nil, err
 	}
+	config.DefaultCommands = defaults["merge"]
+	config.ReadyCommands = defaults["ready"]
+	config.ApproveCommands = defaults["approve"]
+	config.CloseCommands = defaults["close"]
+	config.RevertCommands = defaults["revert"]
 
-	// Validate that required fields are present
-	if metadata.PRNumber == 0 || metadata.Repository == "" {
-		return nil, nil
+	closeComment, err := parseCloseComment(getEnv("CLOSE_COMMENT", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.CloseComment = closeComment
+
+	if config.SquashSubject, err = parseSquashTemplate("SQUASH_SUBJECT", getEnv("SQUASH_SUBJECT", "")); err != nil {
+		return nil, err
+	}
+	if config.SquashBody, err = parseSquashTemplate("SQUASH_BODY", getEnv("SQUASH_BODY", "")); err != nil {
+		return nil, err
 	}
 
-	return &metadata, nil
+	comments, err := loadCommentTemplates(getEnv("COMMENTS_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	plugins, err := loadPlugins(getEnv("PLUGINS_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.Plugins = plugins
+	if config.Gates, err = parseGates(getEnvList("GATES"), plugins); err != nil {
+		return nil, err
+	}
+	reactions, err := loadReactionActions(getEnv("REACTIONS_FILE", ""), comments, pluginActions(plugins))
+	if err != nil {
+		return nil, err
+	}
+	config.Reactions = reactions
+	if err := config.checkReactionEmoji(); err != nil {
+		return nil, err
+	}
+	if config.Webhooks, err = loadWebhooks(getEnv("WEBHOOKS_FILE", "")); err != nil {
+		return nil, err
+	}
+	workflows, err := loadWorkflows(getEnv("WORKFLOWS_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.Workflows = workflows
+	if err := config.checkWorkflowEmoji(); err != nil {
+		return nil, err
+	}
+	if err := config.checkReleaseSteps(); err != nil {
+		return nil, err
+	}
+	if err := config.checkUrgentEmoji(); err != nil {
+		return nil, err
+	}
+	if err := config.checkStackEmoji(); err != nil {
+		return nil, err
+	}
+
+	actions, err := loadActionConfigs(getEnv("ACTIONS_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	config.Actions = actions
+	config.EventActions = getEnvList("EVENT_ACTIONS")
+
+	if len(config.PRLinkHosts) == 0 {
+		config.PRLinkHosts = []string{config.GitHubHost}
+	}
+
+	if err := loadSecrets(context.Background(), config); err != nil {
+		return nil, err
+	}
+	if err := loadGitHubApp(config); err != nil {
+		return nil, err
+	}
+	if err := config.checkJira(); err != nil {
+		return nil, err
+	}
+	if err := config.checkLinear(); err != nil {
+		return nil, err
+	}
+
+	windows, err := parseBlackoutWindows(getEnv("MERGE_BLACKOUT", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MERGE_BLACKOUT: %w", err)
+	}
+	config.BlackoutWindows = windows
+
+	location, err := time.LoadLocation(getEnv("MERGE_TIMEZONE", "UTC"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MERGE_TIMEZONE: %w", err)
+	}
+	config.Timezone = location
+
+	freezes, err := parseFreezeDates(getEnv("FREEZE_DATES", ""), config.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FREEZE_DATES: %w", err)
+	}
+	config.FreezeDates = freezes
+
+	summaryMinute, err := parseClock(config.SummaryTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SUMMARY_TIME: %w", err)
+	}
+	config.SummaryMinute = summaryMinute
+
+	switch config.SlackTokenRotation {
+	case "", TokenRotationSecrets:
+	case TokenRotationOAuth:
+		if config.SlackClientID == "" || config.SlackClientSecret == "" {
+			return nil, fmt.Errorf("SLACK_TOKEN_ROTATION=%s requires SLACK_CLIENT_ID and SLACK_CLIENT_SECRET", TokenRotationOAuth)
+		}
+	default:
+		return nil, fmt.Errorf("SLACK_TOKEN_ROTATION must be empty, %q or %q, got %q", TokenRotationOAuth, TokenRotationSecrets, config.SlackTokenRotation)
+	}
+	switch config.StoreDriver {
+	case "":
+	case StoreDriverSQLite, StoreDriverPostgres:
+		if config.StoreDSN == "" {
+			return nil, fmt.Errorf("STORE_DRIVER=%s requires STORE_DSN", config.StoreDriver)
+		}
+	default:
+		return nil, fmt.Errorf("STORE_DRIVER must be empty, %q or %q, got %q", StoreDriverSQLite, StoreDriverPostgres, config.StoreDriver)
+	}
+	if config.GRPCAddr != "" && config.APIToken == "" {
+		return nil, fmt.Errorf("GRPC_ADDR requires API_TOKEN")
+	}
+	if config.LeaderLeaseTTL < 3 {
+		return nil, fmt.Errorf("LEADER_LEASE_TTL must be at least 3 seconds, got %d", config.LeaderLeaseTTL)
+	}
+	if config.StatsRetentionDays < 1 {
+		return nil, fmt.Errorf("STATS_RETENTION_DAYS must be positive, got %d", config.StatsRetentionDays)
+	}
+	if config.BlackoutMode != BlackoutModeReject && config.BlackoutMode != BlackoutModeDefer {
+		return nil, fmt.Errorf("BLACKOUT_MODE must be %q or %q, got %q", BlackoutModeReject, BlackoutModeDefer, config.BlackoutMode)
+	}
+	if config.FreezeCalendarRefresh < 1 {
+		return nil, fmt.Errorf("FREEZE_CALENDAR_REFRESH must be positive, got %d", config.FreezeCalendarRefresh)
+	}
+	if !validCleanupMode(config.CleanupMode) {
+		return nil, fmt.Errorf("CLEANUP_MODE must be timebomb, update or delete, got %s", config.CleanupMode)
+	}
+	if config.TimeBombMaxDelay < 0 {
+		return nil, fmt.Errorf("TIMEBOMB_MAX_DELAY must not be negative, got %d", config.TimeBombMaxDelay)
+	}
+	if config.DeferredPollInterval <= 0 {
+		return nil, fmt.Errorf("DEFERRED_POLL_INTERVAL must be positive, got %d", config.DeferredPollInterval)
+	}
+	switch config.Transport {
+	case TransportRedis, TransportNATS, TransportKafka, TransportSQS:
+	default:
+		return nil, fmt.Errorf("TRANSPORT must be %q, %q, %q or %q, got %q", TransportRedis, TransportNATS, TransportKafka, TransportSQS, config.Transport)
+	}
+	if err := config.checkTransport(); err != nil {
+		return nil, err
+	}
+	if err := config.checkReactionPartitions(); err != nil {
+		return nil, err
+	}
+	if config.SQSWaitTime < 0 || config.SQSWaitTime > 20 {
+		return nil, fmt.Errorf("SQS_WAIT_TIME must be between 0 and 20, got %d", config.SQSWaitTime)
+	}
+	if config.Transport == TransportKafka && len(config.KafkaBrokers) == 0 {
+		return nil, fmt.Errorf("TRANSPORT=%s requires KAFKA_BROKERS", TransportKafka)
+	}
+	switch config.KafkaSASLMechanism {
+	case "", KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512:
+	default:
+		return nil, fmt.Errorf("KAFKA_SASL_MECHANISM must be %q, %q or %q, got %q", KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512, config.KafkaSASLMechanism)
+	}
+	if config.InputMode != InputModeRelay && config.InputMode != InputModeSocket {
+		return nil, fmt.Errorf("INPUT_MODE must be %q or %q, got %q", InputModeRelay, InputModeSocket, config.InputMode)
+	}
+	if config.InputMode == InputModeSocket && (config.SlackAppToken == "" || config.SlackBotToken == "") {
+		return nil, fmt.Errorf("INPUT_MODE=%s requires SLACK_APP_TOKEN and SLACK_BOT_TOKEN", InputModeSocket)
+	}
+	if err := config.checkMergeRate(); err != nil {
+		return nil, err
+	}
+	if config.MergeDelay < 0 {
+		return nil, fmt.Errorf("MERGE_DELAY_SECONDS must not be negative, got %d", config.MergeDelay)
+	}
+	if config.MergeDelay > 0 && config.MergeDelay >= config.PendingTTL {
+		return nil, fmt.Errorf("MERGE_DELAY_SECONDS must be less than PENDING_TTL (%d), got %d", config.PendingTTL, config.MergeDelay)
+	}
+	if config.ConfirmTTL <= 0 {
+		return nil, fmt.Errorf("CONFIRM_TTL must be positive, got %d", config.ConfirmTTL)
+	}
+	if err := config.checkBackpressure(); err != nil {
+		return nil, err
+	}
+	if config.WorkerCount <= 0 {
+		return nil, fmt.Errorf("WORKER_COUNT must be positive, got %d", config.WorkerCount)
+	}
+	if config.EventTimeout <= 0 {
+		return nil, fmt.Errorf("EVENT_TIMEOUT must be positive, got %d", config.EventTimeout)
+	}
+	if config.SlackRateLimit < 0 {
+		return nil, fmt.Errorf("SLACK_RATE_LIMIT must not be negative, got %d", config.SlackRateLimit)
+	}
+	if config.SlackRateLimit > 0 && config.SlackRateBurst <= 0 {
+		return nil, fmt.Errorf("SLACK_RATE_BURST must be positive, got %d", config.SlackRateBurst)
+	}
+	if _, err := newPager(config); err != nil {
+		return nil, err
+	}
+	if config.MonitorInterval <= 0 {
+		return nil, fmt.Errorf("MONITOR_INTERVAL must be positive, got %d", config.MonitorInterval)
+	}
+	if config.SlackBreakerThreshold < 0 {
+		return nil, fmt.Errorf("SLACK_BREAKER_THRESHOLD must not be negative, got %d", config.SlackBreakerThreshold)
+	}
+	if config.SlackBreakerCooldown <= 0 {
+		return nil, fmt.Errorf("SLACK_BREAKER_COOLDOWN must be positive, got %d", config.SlackBreakerCooldown)
+	}
+	if config.MergeCommitTTL <= 0 {
+		return nil, fmt.Errorf("MERGE_COMMIT_TTL must be positive, got %d", config.MergeCommitTTL)
+	}
+	if config.WorkflowTTL <= 0 {
+		return nil, fmt.Errorf("WORKFLOW_TTL must be positive, got %d", config.WorkflowTTL)
+	}
+	if config.FanOutTTL <= 0 {
+		return nil, fmt.Errorf("FANOUT_TTL must be positive, got %d", config.FanOutTTL)
+	}
+	if config.StackTTL <= 0 {
+		return nil, fmt.Errorf("STACK_TTL must be positive, got %d", config.StackTTL)
+	}
+	if config.HookTimeout <= 0 {
+		return nil, fmt.Errorf("HOOK_TIMEOUT must be positive, got %d", config.HookTimeout)
+	}
+	if err := config.checkHookPaths(); err != nil {
+		return nil, err
+	}
+	if config.PRStateTTL <= 0 {
+		return nil, fmt.Errorf("PR_STATE_TTL must be positive, got %d", config.PRStateTTL)
+	}
+	if config.BranchProtectionCheck && !config.hasGitHubAPI() {
+		return nil, fmt.Errorf("BRANCH_PROTECTION_CHECK requires GITHUB_TOKEN or a GitHub App")
+	}
+	if config.MergeabilityCheck && !config.hasGitHubAPI() {
+		return nil, fmt.Errorf("MERGEABILITY_CHECK requires GITHUB_TOKEN or a GitHub App")
+	}
+	if config.MergeTrain && !config.hasGitHubAPI() {
+		return nil, fmt.Errorf("MERGE_TRAIN requires GITHUB_TOKEN or a GitHub App")
+	}
+	if config.UpdateBranch && !config.hasGitHubAPI() {
+		return nil, fmt.Errorf("UPDATE_BRANCH requires GITHUB_TOKEN or a GitHub App")
+	}
+	if config.UpdateBranchTimeout <= 0 {
+		return nil, fmt.Errorf("UPDATE_BRANCH_TIMEOUT must be positive, got %d", config.UpdateBranchTimeout)
+	}
+	if config.MergeLockTimeout <= 0 {
+		return nil, fmt.Errorf("MERGE_LOCK_TIMEOUT must be positive, got %d", config.MergeLockTimeout)
+	}
+	if config.FlagsRefreshInterval <= 0 {
+		return nil, fmt.Errorf("FLAGS_REFRESH_INTERVAL must be positive, got %d", config.FlagsRefreshInterval)
+	}
+
+	return config, nil
+}
+
+func newRedisClient(config *Config) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     config.RedisAddr,
+		Password: config.RedisPassword,
+		DB:       config.RedisDB,
+		// nil unless REDIS_TLS_ENABLED is set
+		TLSConfig: config.RedisTLS,
+	})
+}
+
+// readConfigFile reads KEY=VALUE settings from a dotenv-style file. Environment variables take precedence.
+func readConfigFile(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	if path == "" {
+		return values, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, i+1)
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+
+	return values, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	if value := configFileValues[key]; value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAllowEmpty is getEnv for settings an empty value turns off, such as CANCEL_EMOJI, so only a variable that
+// isn't set at all falls back to the default
+func getEnvAllowEmpty(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	if value, ok := configFileValues[key]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := getEnv(key, ""); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+		// Use standard log here since logging system may not be initialized yet
+		log.Printf("[WARNING] invalid integer value for %s: %s, using default: %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := getEnv(key, ""); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+		log.Printf("[WARNING] invalid boolean value for %s: %s, using default: %t", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma-separated list, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func processReactions(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	dispatcher := newReactionDispatcher(currentConfig().WorkerCount)
+	// Let queued and in-flight events finish even if shutdown begins while they are pending
+	defer dispatcher.close()
+
+	if currentConfig().ReactionPartitions > 0 {
+		processPartitionedReactions(ctx, redisClient, clients, dispatcher)
+		return
+	}
+
+	// A reaction is only acknowledged to a durable transport once its worker has handled it, not once it's queued
+	eventSource(redisClient, clients).Receive(ctx, "slack-relay-reaction-added", func(payload string, done func()) {
+		dispatcher.submit(ctx, payload, redisClient, clients, done)
+	})
+}
+
+// handleReaction handles a reaction event within EVENT_TIMEOUT, parking it in DEAD_LETTER_QUEUE if the Slack
+// circuit breaker is open
+func handleReaction(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients) {
+	config := currentConfig()
+	eventCtx, cancel := eventContext(ctx, config)
+	defer cancel()
+
+	err := handleReactionMessage(eventCtx, payload, redisClient, clients, config)
+	if errors.Is(err, errSlackCircuitOpen) {
+		parkReaction(eventCtx, redisClient, config, payload)
+		return
+	}
+	if errors.Is(err, errInvalidEvent) {
+		rejectEvent(eventCtx, redisClient, config, payload, err)
+		return
+	}
+	if err != nil {
+		logEventError("Error handling reaction message: %v", err)
+	}
+}
+
+func handleReactionMessage(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, config *Config) (err error) {
+	// Reject malformed events outright rather than acting on whatever parsed
+	if err := validateReactionEvent(payload); err != nil {
+		return err
+	}
+	var reactionEvent ReactionEvent
+	if err := json.Unmarshal([]byte(payload), &reactionEvent); err != nil {
+		return fmt.Errorf("failed to unmarshal reaction event: %w", err)
+	}
+
+	// Only process merge, action and workflow emoji and the workspace's cancel, ready, approve, close and revert emoji
+	workspace := config.workspace(reactionEvent.TeamID)
+	reactionEvent.Event.Reaction = workspace.normalizeReaction(reactionEvent.Event.Reaction)
+	reaction := reactionEvent.Event.Reaction
+	isCancel := workspace.CancelEmoji != "" && reaction == workspace.CancelEmoji
+	isReady := workspace.ReadyEmoji != "" && reaction == workspace.ReadyEmoji
+	isApprove := workspace.ApproveEmoji != "" && reaction == workspace.ApproveEmoji
+	isClose := workspace.CloseEmoji != "" && reaction == workspace.CloseEmoji
+	isRevert := workspace.RevertEmoji != "" && reaction == workspace.RevertEmoji
+	_, isAction := config.Reactions[reaction]
+	_, isWorkflow := config.Workflows[reaction]
+	if !config.isMergeEmoji(workspace, reaction) && !isCancel && !isReady && !isApprove && !isClose && !isRevert && !isAction && !isWorkflow {
+		logDebug("Ignoring reaction: %s", reaction)
+		return nil
+	}
+	if !workspace.allowsChannel(reactionEvent.Event.Item.Channel) {
+		logDebug("Ignoring reaction in channel %s, not configured for workspace %s", reactionEvent.Event.Item.Channel, reactionEvent.TeamID)
+		return nil
+	}
+
+	slackClient := clients.forWorkspace(workspace)
+	if slackClient == nil {
+		logWarning("No Slack bot token configured for workspace %s, ignoring reaction", reactionEvent.TeamID)
+		return nil
+	}
+
+	logInfo("Processing %s reaction on message %s in channel %s",
+		reaction, reactionEvent.Event.Item.Ts, reactionEvent.Event.Item.Channel)
+
+	audit := AuditEntry{
+		EventTime: time.Unix(reactionEvent.EventTime, 0).UTC(),
+		Source:    "reaction",
+		User:      reactionEvent.Event.User,
+		TeamID:    reactionEvent.TeamID,
+		Reaction:  reaction,
+		Channel:   reactionEvent.Event.Item.Channel,
+		Ts:        reactionEvent.Event.Item.Ts,
+	}
+	var decision Decision
+	// Digests and stacks record an entry per PR instead
+	var digest bool
+	// Record the decision even when the event ran out of time
+	defer func() {
+		if !digest {
+			audit.finish(context.WithoutCancel(ctx), redisClient, config, decision, err)
+		}
+	}()
+
+	if isCancel {
+		audit.Source = "cancel"
+		decision, err = handleCancelReaction(ctx, redisClient, config, reactionEvent, &audit)
+		if err != nil {
+			return err
+		}
+		if decision.Note != "" {
+			notifyThread(ctx, slackClient, audit.Channel, audit.Ts, decision.Note)
+		}
+		return nil
+	}
+
+	// Only the first of several merge reactions stacked on a message acts
+	if config.isMergeEmoji(workspace, reaction) && config.TriggerDebounce > 0 {
+		var claimed bool
+		claimed, err = claimTrigger(ctx, redisClient, config, audit.Channel, audit.Ts, audit.User)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			logInfo("Merge of message %s in channel %s already triggered, ignoring", audit.Ts, audit.Channel)
+			decision = Decision{
+				Outcome: OutcomeIgnored,
+				Reason:  "merge already in progress",
+				Note:    fmt.Sprintf(":repeat: Thanks <@%s>, a merge of this is already in progress.", audit.User),
+			}
+			notifyThread(ctx, slackClient, audit.Channel, audit.Ts, decision.Note)
+			return nil
+		}
+		defer func() {
+			if err != nil || (!digest && decision.Outcome != OutcomeQueued && decision.Outcome != OutcomeDeferred) {
+				releaseTrigger(context.WithoutCancel(ctx), redisClient, config, audit.Channel, audit.Ts)
+			}
+		}()
+	}
+
+	// Retrieve the message from Slack, unless it was fetched to find the worker for the event
+	metadata, fetched := prefetchedMetadata(ctx, reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts)
+	if !fetched {
+		metadata, err = getMessageMetadata(ctx, slackClient, config, reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts)
+		if err != nil {
+			return fmt.Errorf("failed to get message metadata: %w", err)
+		}
+	}
+
+	if metadata == nil {
+		logDebug("No PR metadata found in message, ignoring")
+		decision = Decision{Outcome: OutcomeIgnored, Reason: "no PR metadata on message"}
+		return nil
+	}
+
+	if len(metadata.PRs) > 0 {
+		digest = true
+		handleDigestReaction(ctx, redisClient, slackClient, config, reactionEvent, metadata, audit)
+		return nil
+	}
+	if config.StackEmoji != "" && reaction == config.StackEmoji {
+		digest = true
+		return handleStackReaction(ctx, redisClient, slackClient, config, reactionEvent, metadata, audit)
+	}
+
+	logInfo("Found PR metadata: repo=%s, pr=%d, action=%s", metadata.Repository, metadata.PRNumber, metadata.EventAction)
+	decision, err = requestPR(ctx, redisClient, slackClient, config, reactionEvent, metadata, false, &audit)
+	if err != nil {
+		return err
+	}
+	if decision.Note != "" {
+		postDecisionNote(ctx, slackClient, audit.Channel, audit.Ts, decision)
+	}
+
+	return nil
+}
+
+// requestPR builds the Poppit job the reaction asks for on a PR and submits it
+func requestPR(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, reactionEvent ReactionEvent, metadata *PRMetadata, batch bool, audit *AuditEntry) (Decision, error) {
+	workspace := config.workspace(reactionEvent.TeamID)
+	reaction := reactionEvent.Event.Reaction
+	audit.Repository = metadata.Repository
+	audit.PRNumber = metadata.PRNumber
+	trackStack(ctx, redisClient, config, metadata)
+
+	if config.skipsAction(metadata.EventAction) {
+		logDebug("Reactions on %s messages are skipped, ignoring", metadata.EventAction)
+		return Decision{Outcome: OutcomeIgnored, Reason: fmt.Sprintf("event action %s is skipped", metadata.EventAction)}, nil
+	}
+	if decision, stale := checkMessageAge(config, metadata, reactionEvent.Event.Item.Ts, time.Now()); stale {
+		return decision, nil
+	}
+	if actions, ok := config.Reactions[reaction]; ok {
+		return requestReactionActions(ctx, redisClient, slackClient, config, reactionEvent, metadata, actions, audit)
+	}
+	if steps, ok := config.Workflows[reaction]; ok {
+		return requestWorkflow(ctx, redisClient, slackClient, config, reactionEvent, metadata, steps, batch, audit)
+	}
+	// The size override emoji merges like the target emoji, past the PR size gate
+	sizeOverride := config.SizeOverrideEmoji != "" && reaction == config.SizeOverrideEmoji
+	if sizeOverride {
+		reaction = workspace.TargetEmoji
+	}
+	// The urgent emoji merges like the target emoji, ahead of the merges already queued
+	urgent := config.UrgentEmoji != "" && reaction == config.UrgentEmoji
+	if urgent {
+		reaction = workspace.TargetEmoji
+	}
+
+	var fallback []*template.Template
+	switch reaction {
+	case workspace.TargetEmoji:
+		fallback = config.DefaultCommands
+	case workspace.ReadyEmoji:
+		fallback = config.ReadyCommands
+	case workspace.ApproveEmoji:
+		fallback = config.ApproveCommands
+	case workspace.CloseEmoji:
+		fallback = config.CloseCommands
+	case workspace.RevertEmoji:
+		fallback = config.RevertCommands
+	}
+	templates, ok := config.commandTemplates(metadata.Repository, reaction, fallback)
+	if !ok {
+		logDebug("No commands for %s reactions in %s, ignoring", reaction, metadata.Repository)
+		return Decision{Outcome: OutcomeIgnored, Reason: fmt.Sprintf("no commands for %s in repository", reaction)}, nil
+	}
+
+	requested := withGitHubLogin(ctx, redisClient, slackClient, config, metadata, reactionEvent.Event.User)
+	if config.isMergeEmoji(workspace, reaction) {
+		var err error
+		if requested, err = withSquashFlags(ctx, slackClient, config, requested, reactionEvent.Event.User); err != nil {
+			return Decision{}, err
+		}
+	}
+	isRevert := workspace.RevertEmoji != "" && reaction == workspace.RevertEmoji
+	if isRevert {
+		audit.Source = "revert"
+		sha, err := mergeCommit(ctx, redisClient, config, metadata.Repository, metadata.PRNumber)
+		if err != nil {
+			return Decision{}, err
+		}
+		if sha == "" {
+			return unknownMergeCommit(metadata), nil
+		}
+		requested.MergeSHA = sha
+	}
+	job, err := newMergeJob(config, requested, templates, reactionEvent.TeamID, reactionEvent.Event.User, reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts)
+	if err != nil {
+		return Decision{}, err
+	}
+	job.Batch = batch
+	job.SizeOverride = sizeOverride
+	if urgent {
+		job.Priority = PriorityUrgent
+	}
+	job.EventTime = audit.EventTime
+	audit.GitHubUser = job.Payload.GitHubUser
+	audit.CorrelationID = job.Payload.CorrelationID
+
+	if workspace.ReadyEmoji != "" && reaction == workspace.ReadyEmoji {
+		audit.Source = "ready"
+		return submitReady(ctx, redisClient, config, job)
+	}
+	if workspace.ApproveEmoji != "" && reaction == workspace.ApproveEmoji {
+		audit.Source = "approve"
+		return submitApprove(ctx, redisClient, config, job)
+	}
+	if workspace.CloseEmoji != "" && reaction == workspace.CloseEmoji {
+		audit.Source = "close"
+		if config.CloseComment != nil {
+			comment, err := commentCommand(ctx, slackClient, config.CloseComment, requested, reactionEvent.Event.User)
+			if err != nil {
+				return Decision{}, err
+			}
+			job.Payload.Commands = append([]string{comment}, job.Payload.Commands...)
+		}
+		return submitClose(ctx, redisClient, config, job)
+	}
+	if isRevert {
+		return submitRevert(ctx, redisClient, config, job)
+	}
+	// Digests merge several PRs at once, which a confirmation per PR would defeat
+	if config.ConfirmMerges && !batch {
+		// Asking for approval of a merge the requester may not make would only get it denied once approved
+		if decision, denied := checkAuthorized(job, config); denied {
+			logInfo("User %s is not authorized to merge PR %d in %s", job.RequestedBy, job.PRNumber, job.Payload.Repo)
+			return decision, nil
+		}
+		return requestConfirmation(ctx, redisClient, slackClient, config, job)
+	}
+	return submitMerge(ctx, redisClient, config, job)
+}
+
+// newMergeJob builds the Poppit merge payload for a PR announced in the given Slack message
+func newMergeJob(config *Config, metadata *PRMetadata, templates []*template.Template, teamID, user, channel, timestamp string) (MergeJob, error) {
+	commands, err := renderCommands(templates, metadata)
+	if err != nil {
+		return MergeJob{}, err
+	}
+
+	poppitPayload := PoppitPayload{
+		SchemaVersion: PoppitPayloadSchemaVersion,
+		Repo:          metadata.Repository,
+		Branch:        config.targetBranch(metadata),
+		Type:          "vibe-merge",
+		Dir:           config.repoSettings(metadata.Repository).WorkDir,
+		Commands:      commands,
+		GitHubUser:    metadata.GitHubUser,
+		CorrelationID: newCorrelationID(),
+	}
+	if err := config.applyAction(&poppitPayload, metadata); err != nil {
+		return MergeJob{}, err
+	}
+
+	return MergeJob{
+		Payload:      poppitPayload,
+		PRNumber:     metadata.PRNumber,
+		Author:       metadata.Author,
+		Title:        metadata.Title,
+		URL:          metadata.PRURL,
+		BaseBranch:   metadata.BaseBranch,
+		HeadBranch:   metadata.Branch,
+		RequestedBy:  user,
+		TeamID:       teamID,
+		Channel:      channel,
+		Ts:           timestamp,
+		Priority:     config.mergePriority(metadata),
+		ChangedFiles: metadata.ChangedFiles,
+		DependsOn:    metadata.DependsOn,
+	}, nil
+}
+
+// submitMerge applies the merge gates and queues the job, reporting what was decided
+func submitMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (decision Decision, err error) {
+	if decision, denied := checkAuthorized(job, config); denied {
+		logInfo("User %s is not authorized to merge PR %d in %s", job.RequestedBy, job.PRNumber, job.Payload.Repo)
+		return decision, nil
+	}
+
+	// Skip PRs that GitHub reported as merged or closed outside VibeMerge
+	decision, closed, err := checkPRState(ctx, redisClient, config, job)
+	if err != nil {
+		return Decision{}, err
+	}
+	if closed {
+		logInfo("PR %d in %s is no longer open (%s), not queueing", job.PRNumber, job.Payload.Repo, decision.Reason)
+		return decision, nil
+	}
+
+	paused, reason, err := pauseState(ctx, redisClient, config)
+	if err != nil {
+		return Decision{}, err
+	}
+	if paused {
+		logInfo("Merging is paused (%s), not queueing PR %d in %s", reason, job.PRNumber, job.Payload.Repo)
+		return Decision{
+			Outcome: OutcomeDenied,
+			Reason:  fmt.Sprintf("merging paused: %s", reason),
+			Note:    fmt.Sprintf(":double_vertical_bar: Merging is currently paused (%s), so PR #%d was not queued. Please try again once it is resumed.", reason, job.PRNumber),
+		}, nil
+	}
+
+	if decision, vetoed := checkHook(ctx, config, HookPreDecision, job); vetoed {
+		return decision, nil
+	}
+
+	settings := config.repoSettings(job.Payload.Repo)
+	if decision, denied := checkSelfMerge(job, settings); denied {
+		logInfo("Denied self-merge of PR %d in %s requested by %s", job.PRNumber, job.Payload.Repo, job.RequestedBy)
+		return decision, nil
+	}
+	if decision, denied := checkPaths(ctx, config, &job); denied {
+		return decision, nil
+	}
+	if decision, denied, err := checkStack(ctx, redisClient, config, job); err != nil || denied {
+		return decision, err
+	}
+	if decision, denied := checkBranchProtection(ctx, config, job); denied {
+		return decision, nil
+	}
+	if decision, denied := checkMergeable(ctx, redisClient, config, job); denied {
+		return decision, nil
+	}
+	if decision, denied := checkPRSize(ctx, config, job, settings); denied {
+		return decision, nil
+	}
+	if decision, denied := checkLabels(ctx, config, job, settings); denied {
+		return decision, nil
+	}
+	decision, denied, err := checkGates(ctx, config, job)
+	if err != nil || denied {
+		return decision, err
+	}
+
+	// Count the merge against its requester's daily quota, giving it back unless it's queued or deferred
+	decision, overQuota, err := takeUserQuota(ctx, redisClient, config, job)
+	if err != nil {
+		return Decision{}, err
+	}
+	if overQuota {
+		return decision, nil
+	}
+	defer func() {
+		if err != nil || (decision.Outcome != OutcomeQueued && decision.Outcome != OutcomeDeferred) {
+			returnUserQuota(ctx, redisClient, config, job)
+		}
+	}()
+
+	// Hold back merges during blackout windows
+	if until, blocked := config.frozenUntil(time.Now().In(config.Timezone)); blocked {
+		return holdForBlackout(ctx, redisClient, config, job, until)
+	}
+
+	// Hold back merges while Poppit is backed up, before a rate limit slot is taken
+	queue, length, backedUp, err := poppitBackedUp(ctx, redisClient, config, job)
+	if err != nil {
+		return Decision{}, err
+	}
+	if backedUp {
+		return holdForBackpressure(ctx, redisClient, config, job, queue, length)
+	}
+
+	// Hold back merges over the repository's rate limit, giving the slot back unless the merge goes ahead or is deferred
+	until, limited, err := reserveMergeSlot(ctx, redisClient, config, job, settings.MergeRateLimit)
+	if err != nil {
+		return Decision{}, err
+	}
+	if settings.MergeRateLimit > 0 {
+		defer func() {
+			if err != nil || (decision.Outcome != OutcomeQueued && decision.Outcome != OutcomeDeferred) {
+				refundMergeSlot(ctx, redisClient, config, job)
+			}
+		}()
+	}
+	if limited {
+		return holdForMergeRate(ctx, redisClient, config, job, settings.MergeRateLimit, until)
+	}
+
+	// Bring a PR behind its base up to date first, merging once CI passes on the update
+	decision, updating, err := updateBranch(ctx, redisClient, config, job)
+	if err != nil {
+		return Decision{}, err
+	}
+	if updating {
+		return decision, nil
+	}
+
+	// Leave a window to cancel the merge in
+	decision, delayed, err := delayMerge(ctx, redisClient, config, job)
+	if err != nil || delayed {
+		return decision, err
+	}
+
+	return queueMerge(ctx, redisClient, config, job)
+}
+
+// queueMerge hands a merge job to Poppit, or parks it behind an in-flight merge in the same repository
+func queueMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, error) {
+	if decision, vetoed := checkHook(ctx, config, HookPreQueue, job); vetoed {
+		return decision, nil
+	}
+	if config.serializeMerges() {
+		position, err := serializeMerge(ctx, redisClient, config, job)
+		if err != nil {
+			return Decision{}, err
+		}
+		if position > 0 && config.mergeTrain() {
+			logInfo("PR %d in %s joined the merge train at position %d", job.PRNumber, job.Payload.Repo, position)
+			return Decision{
+				Outcome: OutcomeQueued,
+				Reason:  fmt.Sprintf("merge train position %d", position),
+				Note: fmt.Sprintf(":steam_locomotive: PR #%d is number %d in the merge train for %s. It will be merged once the merges ahead of it are in and CI on their base branch is green.",
+					job.PRNumber, position, job.Payload.Repo),
+			}, nil
+		}
+		if position > 0 {
+			logInfo("PR %d in %s is waiting for an earlier merge in the same repository", job.PRNumber, job.Payload.Repo)
+			return Decision{
+				Outcome: OutcomeQueued,
+				Reason:  "waiting for an earlier merge in the repository",
+				Note:    fmt.Sprintf(":hourglass_flowing_sand: Another merge in %s is in progress. PR #%d will be merged as soon as it finishes.", job.Payload.Repo, job.PRNumber),
+			}, nil
+		}
+	}
+
+	if err := pushToPoppit(ctx, redisClient, config, job); err != nil {
+		return Decision{}, err
+	}
+	return Decision{Outcome: OutcomeQueued}, nil
+}
+
+// pushToPoppit pushes a merge job to the Poppit queue and schedules cleanup of its Slack message
+func pushToPoppit(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) error {
+	payloadJSON, err := marshalPoppitPayload(ctx, config, job.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal poppit payload: %w", err)
+	}
+
+	queue, err := pushMergeCommand(ctx, redisClient, config, job, string(payloadJSON))
+	if err != nil {
+		return fmt.Errorf("failed to push to %s: %w", queue, err)
+	}
+
+	logInfo("Successfully queued merge command for PR %d in %s", job.PRNumber, job.Payload.Repo)
+	notifyHook(ctx, config, jobHookEvent(HookPostQueue, job))
+	sendWebhooks(config, jobWebhookEvent(WebhookMergeQueued, job))
+	if !job.EventTime.IsZero() {
+		enqueueLatency.Observe(time.Since(job.EventTime))
+	}
+
+	if err := recordMergeStats(ctx, redisClient, config, job); err != nil {
+		logWarning("Failed to update merge stats: %v", err)
+	}
+
+	if store != nil {
+		if err := store.RecordMerge(ctx, job); err != nil {
+			logError("Error writing to the durable store: %v", err)
+		}
+	}
+
+	pollMember, err := watchMerge(ctx, redisClient, config, job)
+	if err != nil {
+		logWarning("Failed to watch merge of PR %d in %s for it landing: %v", job.PRNumber, job.Payload.Repo, err)
+	}
+	if err := trackPendingMerge(ctx, redisClient, config, job, PendingMerge{QueuedPayload: string(payloadJSON), Queue: queue, PollMember: pollMember}); err != nil {
+		logWarning("Failed to track pending merge, it can't be cancelled: %v", err)
+	}
+
+	// Clean up the processed message once the merge is confirmed, unless TIMEBOMB_MAX_DELAY is 0
+	if job.Ts == "" {
+		return nil
+	}
+	queueMergeStatus(ctx, redisClient, config, newMessageCleanup(job), MergeStatusQueued, "")
+	if config.TimeBombMaxDelay > 0 {
+		if err := deferCleanup(ctx, redisClient, config, job); err != nil {
+			logWarning("Failed to hold back cleanup of message: %v", err)
+		}
+		return nil
+	}
+	if err := cleanUpMessage(ctx, redisClient, config, newMessageCleanup(job)); err != nil {
+		// Log the error but don't fail the entire operation
+		logWarning("Failed to clean up message: %v", err)
+	}
+
+	return nil
+}
+
+// notifyThread posts a reply in the thread of the given message, logging rather than failing on error
+func notifyThread(ctx context.Context, slackClient *slack.Client, channel, timestamp, text string) {
+	err := callSlack(ctx, "chat.postMessage", func() error {
+		_, _, err := slackClient.PostMessageContext(ctx, channel,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionTS(timestamp),
+		)
+		return err
+	})
+	if err != nil {
+		logWarning("Failed to post thread reply on message %s in channel %s: %v", timestamp, channel, err)
+	}
+}
+
+// getMessageMetadata reads the PR metadata of a message. Replies without metadata of their own use the
+// metadata of their thread's parent, so reacting anywhere in a PR thread works.
+func getMessageMetadata(ctx context.Context, slackClient *slack.Client, config *Config, channel, timestamp string) (*PRMetadata, error) {
+	message, err := getMessage(ctx, slackClient, channel, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := messagePRMetadata(message, config)
+	if err != nil || metadata != nil {
+		return metadata, err
+	}
+
+	if message.ThreadTimestamp == "" || message.ThreadTimestamp == message.Timestamp {
+		return nil, nil
+	}
+	logDebug("No PR metadata on thread reply %s, checking parent message %s", timestamp, message.ThreadTimestamp)
+	parent, err := getMessage(ctx, slackClient, channel, message.ThreadTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread parent: %w", err)
+	}
+	return messagePRMetadata(parent, config)
+}
+
+// messagePRMetadata reads a message's PR metadata, falling back to the PR it links to when PARSE_PR_LINKS is set
+func messagePRMetadata(message *slack.Message, config *Config) (*PRMetadata, error) {
+	metadata, err := parsePRMetadata(message, config)
+	if err != nil || metadata != nil || !flagEnabled(FlagParsePRLinks, config.ParsePRLinks) {
+		return metadata, err
+	}
+	return parsePRLinks(message, config)
+}
+
+// getMessage retrieves a single message, whether it is in the channel itself or a reply in a thread
+func getMessage(ctx context.Context, slackClient *slack.Client, channel, timestamp string) (*slack.Message, error) {
+	// Retrieve the message using conversations.history
+	params := &slack.GetConversationHistoryParameters{
+		ChannelID:          channel,
+		Latest:             timestamp,
+		Limit:              1,
+		Inclusive:          true,
+		IncludeAllMetadata: true,
+	}
+
+	var history *slack.GetConversationHistoryResponse
+	err := callSlack(ctx, "conversations.history", func() error {
+		var err error
+		history, err = slackClient.GetConversationHistoryContext(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+	}
+
+	// conversations.history only returns channel messages, so a thread reply comes back as an earlier message
+	if len(history.Messages) > 0 && history.Messages[0].Timestamp == timestamp {
+		return &history.Messages[0], nil
+	}
+
+	return getThreadReply(ctx, slackClient, channel, timestamp)
+}
+
+// getThreadReply retrieves a thread reply using conversations.replies
+func getThreadReply(ctx context.Context, slackClient *slack.Client, channel, timestamp string) (*slack.Message, error) {
+	params := &slack.GetConversationRepliesParameters{
+		ChannelID:          channel,
+		Timestamp:          timestamp,
+		Oldest:             timestamp,
+		Latest:             timestamp,
+		Inclusive:          true,
+		IncludeAllMetadata: true,
+	}
+
+	var replies []slack.Message
+	err := callSlack(ctx, "conversations.replies", func() error {
+		var err error
+		replies, _, _, err = slackClient.GetConversationRepliesContext(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation replies: %w", err)
+	}
+
+	for i := range replies {
+		if replies[i].Timestamp == timestamp {
+			return &replies[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no message found at timestamp %s", timestamp)
+}
+
+// parsePRMetadata reads the PR metadata embedded in a message, returning nil when it has none. With
+// METADATA_EVENT_TYPES set, metadata of other event types isn't PR metadata.
+func parsePRMetadata(message *slack.Message, config *Config) (*PRMetadata, error) {
+	// Check if message has metadata
+	if message.Metadata.EventType == "" {
+		return nil, nil
+	}
+	if len(config.MetadataEventTypes) > 0 && !slices.Contains(config.MetadataEventTypes, message.Metadata.EventType) {
+		logDebug("Ignoring metadata of event type %s, not in METADATA_EVENT_TYPES", message.Metadata.EventType)
+		return nil, nil
+	}
+
+	metadataJSON, err := json.Marshal(message.Metadata.EventPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return decodePRMetadata(metadataJSON, message.Metadata.EventType)
 }
 
-func publishTimeBombMessage(ctx context.Context, redisClient *redis.Client, config *Config, channel, timestamp string) error {
+func publishTimeBombMessage(ctx context.Context, redisClient *redis.Client, config *Config, teamID, repo, channel, timestamp string) error {
+	ttl := config.timeBombTTL(repo, channel)
 	timeBombMsg := TimeBombMessage{
-		Channel: channel,
-		Ts:      timestamp,
-		TTL:     config.TimeBombTTL,
+		SchemaVersion: TimeBombMessageSchemaVersion,
+		Channel:       channel,
+		Ts:            timestamp,
+		TTL:           ttl,
 	}
 
 	msgJSON, err := json.Marshal(timeBombMsg)
@@ -362,10 +2267,11 @@ func publishTimeBombMessage(ctx context.Context, redisClient *redis.Client, conf
 		return fmt.Errorf("failed to marshal timebomb message: %w", err)
 	}
 
-	if err := redisClient.Publish(ctx, config.TimeBombChannel, string(msgJSON)).Err(); err != nil {
-		return fmt.Errorf("failed to publish to %s: %w", config.TimeBombChannel, err)
+	timeBombChannel := config.workspace(teamID).TimeBombChannel
+	if err := serviceNotifier(redisClient).Publish(ctx, timeBombChannel, string(msgJSON)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", timeBombChannel, err)
 	}
 
-	logInfo("Successfully set TTL of %d seconds on message %s in channel %s", config.TimeBombTTL, timestamp, channel)
+	logInfo("Successfully set TTL of %d seconds on message %s in channel %s", ttl, timestamp, channel)
 	return nil
 }
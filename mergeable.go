@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// mergeableAttempts and mergeableRetryDelay bound the wait for GitHub to compute a PR's mergeability, which it does
+// in the background after the PR changes
+const (
+	mergeableAttempts   = 3
+	mergeableRetryDelay = time.Second
+)
+
+// checkMergeable denies a merge of a PR with conflicts, tagging its author in the thread, rather than letting
+// Poppit's merge command fail. When GitHub can't say, the merge is let through.
+func checkMergeable(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, bool) {
+	if !config.MergeabilityCheck {
+		return Decision{}, false
+	}
+
+	var pr githubPullRequest
+	var err error
+	for attempt := 1; attempt <= mergeableAttempts; attempt++ {
+		pr, err = getPullRequest(ctx, config, job.Payload.Repo, job.PRNumber)
+		if err != nil || pr.Mergeable != nil || attempt == mergeableAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return Decision{}, false
+		case <-time.After(mergeableRetryDelay):
+		}
+	}
+	if err != nil {
+		logWarning("Failed to check whether PR %d in %s is mergeable, not enforcing it: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{}, false
+	}
+	if pr.Mergeable == nil {
+		logDebug("GitHub hasn't computed whether PR %d in %s is mergeable yet", job.PRNumber, job.Payload.Repo)
+		return Decision{}, false
+	}
+	if *pr.Mergeable || pr.MergeableState != "dirty" {
+		return Decision{}, false
+	}
+
+	author := job.Author
+	if author == "" {
+		author = "the author"
+	} else {
+		author = slackMention(ctx, redisClient, config, author)
+	}
+	logInfo("PR %d in %s has conflicts, not queueing", job.PRNumber, job.Payload.Repo)
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  "PR has conflicts",
+		Note: fmt.Sprintf(":warning: PR #%d in %s has conflicts with %s, so it was not queued. %s, please resolve them and react again once it's ready.",
+			job.PRNumber, job.Payload.Repo, pr.Base.Ref, author),
+	}, true
+}
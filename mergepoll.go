@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// checkMergePoll rejects MERGE_POLL without GITHUB_TOKEN or a GitHub App to read PRs with
+func (c *Config) checkMergePoll() error {
+	if c.MergePoll && !c.hasGitHubAPI() {
+		return fmt.Errorf("MERGE_POLL requires GITHUB_TOKEN or a GitHub App")
+	}
+	if c.MergePollTimeout <= 0 {
+		return fmt.Errorf("MERGE_POLL_TIMEOUT must be positive, got %d", c.MergePollTimeout)
+	}
+	if c.MergePollInterval <= 0 {
+		return fmt.Errorf("MERGE_POLL_INTERVAL must be positive, got %d", c.MergePollInterval)
+	}
+	return nil
+}
+
+// watchMerge adds a merge pushed to Poppit to MERGE_POLL_QUEUE, scored by when VibeMerge stops waiting for it to
+// land. It returns the member added, or "" when MERGE_POLL is off.
+func watchMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (string, error) {
+	if !config.MergePoll {
+		return "", nil
+	}
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal polled merge: %w", err)
+	}
+
+	deadline := time.Now().Add(time.Duration(config.MergePollTimeout) * time.Second)
+	member := redis.Z{Score: float64(deadline.Unix()), Member: string(jobJSON)}
+	if err := redisClient.ZAdd(ctx, config.MergePollQueue, member).Err(); err != nil {
+		return "", fmt.Errorf("failed to add to %s: %w", config.MergePollQueue, err)
+	}
+	return string(jobJSON), nil
+}
+
+func processMergePolls(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	ticker := time.NewTicker(time.Duration(currentConfig().MergePollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := checkMergePolls(context.WithoutCancel(ctx), redisClient, clients, currentConfig(), time.Now()); err != nil {
+				logError("Error polling merges: %v", err)
+			}
+		}
+	}
+}
+
+// checkMergePolls reads the PRs of merges pushed to Poppit from GitHub, posting the merge commit in the thread of
+// those that merged and a failure notice for those closed unmerged or still open after MERGE_POLL_TIMEOUT
+func checkMergePolls(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, now time.Time) error {
+	entries, err := redisClient.ZRangeWithScores(ctx, config.MergePollQueue, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.MergePollQueue, err)
+	}
+
+	for _, entry := range entries {
+		member := entry.Member.(string)
+		var job MergeJob
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			logError("Dropping malformed polled merge: %v", err)
+			redisClient.ZRem(ctx, config.MergePollQueue, member)
+			continue
+		}
+
+		var note string
+		pr, err := getPullRequest(ctx, config, job.Payload.Repo, job.PRNumber)
+		switch {
+		case err == nil && pr.Merged:
+			note = fmt.Sprintf(":white_check_mark: PR #%d was merged as <%s|`%s`>.",
+				job.PRNumber, commitURL(config, job.Payload.Repo, pr.MergeCommitSHA), shortSHA(pr.MergeCommitSHA))
+		case err == nil && pr.State == "closed":
+			note = fmt.Sprintf(":x: PR #%d was closed without being merged.", job.PRNumber)
+		case now.Unix() >= int64(entry.Score):
+			note = fmt.Sprintf(":warning: PR #%d still hadn't merged %s after it was queued. Check Poppit's logs, then react again to retry.",
+				job.PRNumber, time.Duration(config.MergePollTimeout)*time.Second)
+		default:
+			if err != nil {
+				logWarning("Failed to read PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+			}
+			continue
+		}
+
+		// Only the caller that removes the entry gets to act on it
+		removed, err := redisClient.ZRem(ctx, config.MergePollQueue, member).Result()
+		if err != nil {
+			return fmt.Errorf("failed to remove from %s: %w", config.MergePollQueue, err)
+		}
+		if removed == 0 {
+			continue
+		}
+
+		if pr.Merged {
+			logInfo("PR %d in %s merged as %s", job.PRNumber, job.Payload.Repo, pr.MergeCommitSHA)
+			key := mergeCommitKey(config, job.Payload.Repo, job.PRNumber)
+			if err := redisClient.Set(ctx, key, pr.MergeCommitSHA, time.Duration(config.MergeCommitTTL)*time.Second).Err(); err != nil {
+				logWarning("Failed to set %s: %v", key, err)
+			}
+			queueMergeStatus(ctx, redisClient, config, newMessageCleanup(job), MergeStatusMerged, pr.MergeCommitSHA)
+		} else {
+			logWarning("Merge %s of PR %d in %s didn't land (state %q)", job.Payload.CorrelationID, job.PRNumber, job.Payload.Repo, pr.State)
+			queueMergeStatus(ctx, redisClient, config, newMessageCleanup(job), MergeStatusFailed, "not merged on GitHub")
+		}
+		if slackClient := clients.forWorkspace(config.workspace(job.TeamID)); slackClient != nil && job.Ts != "" {
+			notifyThread(ctx, slackClient, job.Channel, job.Ts, note)
+		}
+	}
+	return nil
+}
+
+// commitURL links to a commit of a repository on GH_HOST
+func commitURL(config *Config, repo, sha string) string {
+	return fmt.Sprintf("https://%s/%s/commit/%s", config.GitHubHost, repo, sha)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// PR metadata versions. Version 1 is the original shape, where messages without a repository and PR number aren't
+// PR messages. Version 2 adds head_sha, draft and provider, and requires the fields a notifier always knows.
+const (
+	MetadataVersion1 = 1
+	MetadataVersion2 = 2
+)
+
+// MetadataProviderGitHub is the only provider of version 2 metadata VibeMerge can merge for
+const MetadataProviderGitHub = "github"
+
+// metadataV2Fields are the template fields only version 2 metadata carries, by their JSON name
+var metadataV2Fields = map[string]string{
+	"HeadSHA":  "head_sha",
+	"Draft":    "draft",
+	"Provider": "provider",
+}
+
+// decodePRMetadata decodes the event payload of a message's metadata as version 1 or 2 PR metadata, returning nil
+// for version 1 metadata that doesn't describe a PR
+func decodePRMetadata(data []byte, eventType string) (*PRMetadata, error) {
+	var metadata PRMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal PR metadata: %w", err)
+	}
+	if metadata.EventAction == "" {
+		metadata.EventAction = eventType
+	}
+
+	switch metadata.MetadataVersion {
+	case 0, MetadataVersion1:
+		metadata.MetadataVersion = MetadataVersion1
+		if len(metadata.PRs) > 0 {
+			return digestMetadata(&metadata), nil
+		}
+		if metadata.PRNumber == 0 || metadata.Repository == "" {
+			return nil, nil
+		}
+		return &metadata, nil
+	case MetadataVersion2:
+		if len(metadata.PRs) == 0 {
+			if err := metadata.checkV2(); err != nil {
+				return nil, err
+			}
+			return &metadata, nil
+		}
+		for i := range metadata.PRs {
+			entry := &metadata.PRs[i]
+			entry.MetadataVersion = MetadataVersion2
+			if err := entry.checkV2(); err != nil {
+				return nil, fmt.Errorf("digest entry %d: %w", i+1, err)
+			}
+		}
+		return digestMetadata(&metadata), nil
+	default:
+		return nil, fmt.Errorf("unsupported metadata_version %d, expected %d or %d", metadata.MetadataVersion, MetadataVersion1, MetadataVersion2)
+	}
+}
+
+// checkV2 reports the fields version 2 metadata is missing, and a provider VibeMerge can't merge for
+func (m *PRMetadata) checkV2() error {
+	var missing []string
+	for _, field := range []struct {
+		name    string
+		missing bool
+	}{
+		{"pr_number", m.PRNumber == 0},
+		{"repository", m.Repository == ""},
+		{"author", m.Author == ""},
+		{"branch", m.Branch == ""},
+		{"base_branch", m.BaseBranch == ""},
+		{"head_sha", m.HeadSHA == ""},
+		{"provider", m.Provider == ""},
+	} {
+		if field.missing {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("version 2 PR metadata is missing %s", strings.Join(missing, ", "))
+	}
+	if m.Provider != MetadataProviderGitHub {
+		return fmt.Errorf("PR metadata of PR %d in %s is from provider %q, only %q is supported", m.PRNumber, m.Repository, m.Provider, MetadataProviderGitHub)
+	}
+	return nil
+}
+
+// checkTemplateFields rejects command templates using fields that version 1 metadata doesn't have, which would
+// otherwise render as empty values
+func checkTemplateFields(templates []*template.Template, metadata *PRMetadata) error {
+	if metadata.MetadataVersion >= MetadataVersion2 {
+		return nil
+	}
+	for _, tmpl := range templates {
+		if tmpl.Tree == nil {
+			continue
+		}
+		for _, field := range templateFields(tmpl.Tree.Root) {
+			if name, ok := metadataV2Fields[field]; ok {
+				return fmt.Errorf("command %s needs %s, which PR %d's metadata doesn't have: its notifier must send metadata_version 2",
+					tmpl.Name(), name, metadata.PRNumber)
+			}
+		}
+	}
+	return nil
+}
+
+// templateFields lists the fields of the template's data a template node refers to, e.g. HeadSHA for {{.HeadSHA}}
+func templateFields(node parse.Node) []string {
+	var fields []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			fields = append(fields, templateFields(child)...)
+		}
+	case *parse.ActionNode:
+		fields = append(fields, templateFields(n.Pipe)...)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				fields = append(fields, templateFields(arg)...)
+			}
+		}
+	case *parse.FieldNode:
+		fields = append(fields, n.Ident[0])
+	case *parse.IfNode:
+		fields = append(fields, branchFields(&n.BranchNode)...)
+	case *parse.RangeNode:
+		fields = append(fields, branchFields(&n.BranchNode)...)
+	case *parse.WithNode:
+		fields = append(fields, branchFields(&n.BranchNode)...)
+	case *parse.TemplateNode:
+		fields = append(fields, templateFields(n.Pipe)...)
+	}
+	return fields
+}
+
+func branchFields(n *parse.BranchNode) []string {
+	fields := templateFields(n.Pipe)
+	fields = append(fields, templateFields(n.List)...)
+	return append(fields, templateFields(n.ElseList)...)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"time"
+)
+
+// metrics holds VibeMerge's counters, published by expvar at /debug/vars on HTTP_ADDR
+var metrics = expvar.NewMap("vibemerge")
+
+// serveHTTP runs the HTTP listener until ctx is cancelled
+func serveHTTP(ctx context.Context, addr string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           http.DefaultServeMux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	})
+
+	logInfo("Serving HTTP on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logError("HTTP server stopped: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Queue samples taken every MONITOR_INTERVAL, published in the metrics
+var (
+	poppitQueueLength  = new(expvar.Int)
+	deadLetterLength   = new(expvar.Int)
+	deferredLagSeconds = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("poppit_queue_length", poppitQueueLength)
+	metrics.Set("dead_letter_length", deadLetterLength)
+	metrics.Set("deferred_lag_seconds", deferredLagSeconds)
+}
+
+// queueThreshold tracks how long a sampled value has been at or over its alert threshold
+type queueThreshold struct {
+	since   time.Time
+	alerted bool
+}
+
+// processQueueMonitor samples the Poppit queue, the dead letter queue and the deferred queue every MONITOR_INTERVAL.
+// A queue that stays at or over its alert length for QUEUE_ALERT_AFTER seconds is reported to OPS_ALERT_CHANNEL,
+// with a follow-up once it is back under.
+func processQueueMonitor(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	ticker := time.NewTicker(time.Duration(currentConfig().MonitorInterval) * time.Second)
+	defer ticker.Stop()
+
+	poppit, deadLetter := &queueThreshold{}, &queueThreshold{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		config := currentConfig()
+		if err := sampleQueues(ctx, redisClient, config); err != nil {
+			logError("Error sampling queues: %v", err)
+			continue
+		}
+		checkQueueThreshold(ctx, clients, config, poppit, config.PoppitQueue, poppitQueueLength.Value(), config.PoppitQueueAlertLength, "")
+		checkQueueThreshold(ctx, clients, config, deadLetter, config.DeadLetterQueue, deadLetterLength.Value(), config.DeadLetterAlertLength, "dead-letter")
+	}
+}
+
+// readPoppitQueueLength counts the commands waiting in every Poppit queue, or returns 0 when TRANSPORT sends them
+// elsewhere
+func readPoppitQueueLength(ctx context.Context, redisClient *redis.Client, config *Config) (int64, error) {
+	if !config.commandsInRedis() {
+		return 0, nil
+	}
+	var total int64
+	for _, queue := range config.poppitQueues() {
+		length, err := redisClient.LLen(ctx, queue).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the length of %s: %w", queue, err)
+		}
+		total += length
+	}
+	return total, nil
+}
+
+// readPoppitQueues reads the commands waiting in every Poppit queue, or none when TRANSPORT sends them elsewhere
+func readPoppitQueues(ctx context.Context, redisClient *redis.Client, config *Config) ([]string, error) {
+	if !config.commandsInRedis() {
+		return nil, nil
+	}
+	var commands []string
+	for _, queue := range config.poppitQueues() {
+		queued, err := redisClient.LRange(ctx, queue, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", queue, err)
+		}
+		commands = append(commands, queued...)
+	}
+	return commands, nil
+}
+
+// sampleQueues reads the queue lengths and how long the oldest due deferred merge has been waiting to be released
+func sampleQueues(ctx context.Context, redisClient *redis.Client, config *Config) error {
+	length, err := readPoppitQueueLength(ctx, redisClient, config)
+	if err != nil {
+		return err
+	}
+	poppitQueueLength.Set(length)
+
+	length, err = redisClient.LLen(ctx, config.DeadLetterQueue).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read the length of %s: %w", config.DeadLetterQueue, err)
+	}
+	deadLetterLength.Set(length)
+
+	oldest, err := redisClient.ZRangeWithScores(ctx, config.DeferredQueue, 0, 0).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.DeferredQueue, err)
+	}
+	var lag int64
+	if len(oldest) > 0 {
+		lag = max(0, time.Now().Unix()-int64(oldest[0].Score))
+	}
+	deferredLagSeconds.Set(lag)
+	return nil
+}
+
+// checkQueueThreshold alerts once a queue has been at or over limit for QUEUE_ALERT_AFTER, and again when it recovers.
+// A limit of 0 disables the alert. With a pageKey, the queue is also paged about.
+func checkQueueThreshold(ctx context.Context, clients *slackClients, config *Config, threshold *queueThreshold, queue string, length int64, limit int, pageKey string) {
+	if limit <= 0 || length < int64(limit) {
+		if threshold.alerted {
+			alertOps(ctx, clients, config, fmt.Sprintf(":white_check_mark: `%s` is back under %d entries (%d).", queue, limit, length))
+			if pageKey != "" {
+				resolvePage(pageKey)
+			}
+		}
+		*threshold = queueThreshold{}
+		return
+	}
+
+	if threshold.since.IsZero() {
+		threshold.since = time.Now()
+	}
+	over := time.Since(threshold.since)
+	if !threshold.alerted && over >= time.Duration(config.QueueAlertAfter)*time.Second {
+		logWarning("%s has held at least %d entries for %s", queue, limit, over.Round(time.Second))
+		alertOps(ctx, clients, config, fmt.Sprintf(":rotating_light: `%s` has held at least %d entries for %s, now %d.",
+			queue, limit, over.Round(time.Second), length))
+		if pageKey != "" {
+			triggerPage(pageKey, fmt.Sprintf("VibeMerge's %s has held at least %d entries for %s, now %d", queue, limit, over.Round(time.Second), length))
+		}
+		threshold.alerted = true
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsConnectTimeout bounds connecting to NATS and looking up the stream at startup
+const natsConnectTimeout = 10 * time.Second
+
+// invalidDurableChars matches the characters not allowed in JetStream consumer names
+var invalidDurableChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// natsTransport carries events and Poppit commands over NATS JetStream. Each channel is a subject of NATS_STREAM,
+// read through a durable consumer so events published while VibeMerge is down or failing over are delivered
+// once it is back, and each Poppit command is published to the queue's subject.
+type natsTransport struct {
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	stream        string
+	durablePrefix string
+}
+
+func newNATSTransport(ctx context.Context, config *Config) (*natsTransport, error) {
+	opts := []nats.Option{
+		nats.Name("vibemerge"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logWarning("Disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logInfo("Reconnected to NATS at %s", conn.ConnectedUrl())
+		}),
+	}
+	if config.NATSCredsFile != "" {
+		opts = append(opts, nats.UserCredentials(config.NATSCredsFile))
+	}
+	if config.NATSToken != "" {
+		opts = append(opts, nats.Token(config.NATSToken))
+	}
+
+	conn, err := nats.Connect(config.NATSURL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", config.NATSURL, err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	// The stream is managed outside VibeMerge, but must exist and capture its subjects
+	lookupCtx, cancel := context.WithTimeout(ctx, natsConnectTimeout)
+	defer cancel()
+	if _, err := js.Stream(lookupCtx, config.NATSStream); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to find NATS stream %s: %w", config.NATSStream, err)
+	}
+	logInfo("Connected to NATS at %s", conn.ConnectedUrl())
+
+	return &natsTransport{conn: conn, js: js, stream: config.NATSStream, durablePrefix: config.NATSDurablePrefix}, nil
+}
+
+// Close drains the connection, flushing commands published but not yet sent
+func (t *natsTransport) Close() {
+	if err := t.conn.Drain(); err != nil {
+		logWarning("Failed to drain the NATS connection: %v", err)
+	}
+}
+
+// durable names the consumer of a channel, shared by every instance so a new leader carries on where the last
+// one stopped
+func (t *natsTransport) durable(channel string) string {
+	return invalidDurableChars.ReplaceAllString(t.durablePrefix+"-"+channel, "_")
+}
+
+// Receive consumes a channel's subject, acknowledging each message once its event has been handled, even when that
+// happens on a worker. Creating the consumer is retried with backoff; once consuming, the client reconnects by
+// itself.
+func (t *natsTransport) Receive(ctx context.Context, channel string, handle func(payload string, done func())) {
+	backoff := resubscribeMinBackoff
+	for {
+		consumer, err := t.js.CreateOrUpdateConsumer(ctx, t.stream, jetstream.ConsumerConfig{
+			Durable:       t.durable(channel),
+			FilterSubject: channel,
+			DeliverPolicy: jetstream.DeliverNewPolicy,
+			AckPolicy:     jetstream.AckExplicitPolicy,
+			// Events are handled one at a time: the next is only delivered once the last is handled and acknowledged
+			MaxAckPending: 1,
+			AckWait:       time.Duration(currentConfig().EventTimeout)*time.Second + time.Minute,
+		})
+		if err == nil {
+			var consume jetstream.ConsumeContext
+			consume, err = consumer.Consume(func(msg jetstream.Msg) {
+				handleAndWait(string(msg.Data()), handle)
+				if err := msg.Ack(); err != nil {
+					logWarning("Failed to acknowledge NATS message on %s: %v", channel, err)
+				}
+			}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+				logWarning("Error consuming %s from NATS: %v", channel, err)
+			}))
+			if err == nil {
+				logInfo("Consuming %s from NATS stream %s", channel, t.stream)
+				<-ctx.Done()
+				// Let the event being handled finish before returning
+				consume.Drain()
+				<-consume.Closed()
+				return
+			}
+		}
+
+		logError("Failed to consume %s from NATS: %v (retrying in %s)", channel, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, resubscribeMaxBackoff)
+	}
+}
+
+// partitionSubject is the subject of one of a channel's partitions
+func partitionSubject(channel string, partition int) string {
+	return fmt.Sprintf("%s.%d", channel, partition)
+}
+
+// PublishPartitioned publishes a message to the subject of the partition key hashes to, e.g.
+// slack-relay-reaction-added.by-repo.3, and waits for the stream to store it
+func (t *natsTransport) PublishPartitioned(ctx context.Context, channel, key, payload string, partitions int) error {
+	_, err := t.js.Publish(ctx, partitionSubject(channel, reactionPartition(key, partitions)), []byte(payload))
+	return err
+}
+
+// ReceivePartitioned consumes every partition's subject through its own durable consumer. The consumers are shared
+// by the instances and deliver one message at a time, so each partition is handled in order by whichever instance
+// takes its next message, while the partitions are handled side by side.
+func (t *natsTransport) ReceivePartitioned(ctx context.Context, channel string, partitions int, handle func(payload string, done func())) {
+	var wg sync.WaitGroup
+	for partition := range partitions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.Receive(ctx, partitionSubject(channel, partition), handle)
+		}()
+	}
+	wg.Wait()
+}
+
+func (t *natsTransport) Publish(ctx context.Context, channel, payload string) error {
+	return t.conn.Publish(channel, []byte(payload))
+}
+
+// Push publishes a Poppit command to JetStream, which acknowledges it once the stream has stored it
+func (t *natsTransport) Push(ctx context.Context, queue, payload string) error {
+	_, err := t.js.Publish(ctx, queue, []byte(payload))
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// opsgenie raises alerts through the Opsgenie Alert API with an API integration's key
+type opsgenie struct {
+	apiURL string
+	apiKey string
+	source string
+	client *http.Client
+}
+
+func newOpsgenie(config *Config) (*opsgenie, error) {
+	if config.OpsgenieAPIKey == "" {
+		return nil, fmt.Errorf("PAGER_PROVIDER=%s requires OPSGENIE_API_KEY", PagerProviderOpsgenie)
+	}
+	return &opsgenie{
+		apiURL: strings.TrimSuffix(config.OpsgenieAPIURL, "/"),
+		apiKey: config.OpsgenieAPIKey,
+		source: config.InstanceID,
+		client: &http.Client{Timeout: pagerTimeout},
+	}, nil
+}
+
+// Trigger creates an alert whose alias is the key, which Opsgenie deduplicates while the alert is open
+func (o *opsgenie) Trigger(ctx context.Context, key, summary string) error {
+	body, err := json.Marshal(map[string]string{
+		"message":  summary,
+		"alias":    key,
+		"source":   o.source,
+		"priority": "P1",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Opsgenie alert: %w", err)
+	}
+	return postPager(ctx, o.client, o.apiURL+"/v2/alerts", o.header(), string(body))
+}
+
+// Resolve closes the alert with the key as its alias
+func (o *opsgenie) Resolve(ctx context.Context, key string) error {
+	body, err := json.Marshal(map[string]string{"source": o.source})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Opsgenie close request: %w", err)
+	}
+	closeURL := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", o.apiURL, url.PathEscape(key))
+	return postPager(ctx, o.client, closeURL, o.header(), string(body))
+}
+
+func (o *opsgenie) header() http.Header {
+	return http.Header{"Authorization": {"GenieKey " + o.apiKey}}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Pager providers select where critical failures are paged
+const (
+	PagerProviderPagerDuty = "pagerduty"
+	PagerProviderOpsgenie  = "opsgenie"
+)
+
+// pagerTimeout bounds a single call to a pager provider
+const pagerTimeout = 10 * time.Second
+
+// Pager raises and resolves incidents in an on-call system. key identifies the incident across calls, so
+// triggering it again while it is open doesn't page twice and resolving it closes it.
+type Pager interface {
+	Trigger(ctx context.Context, key, summary string) error
+	Resolve(ctx context.Context, key string) error
+}
+
+// pager is the PAGER_PROVIDER backend, or nil when paging is disabled
+var pager Pager
+
+// newPager returns the pager selected by PAGER_PROVIDER
+func newPager(config *Config) (Pager, error) {
+	switch config.PagerProvider {
+	case "":
+		return nil, nil
+	case PagerProviderPagerDuty:
+		return newPagerDuty(config)
+	case PagerProviderOpsgenie:
+		return newOpsgenie(config)
+	default:
+		return nil, fmt.Errorf("unknown PAGER_PROVIDER %q", config.PagerProvider)
+	}
+}
+
+// triggerPage raises an incident in the background, so the failure being paged about isn't held up by it
+func triggerPage(key, summary string) {
+	if pager == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), pagerTimeout)
+		defer cancel()
+		if err := pager.Trigger(ctx, pagerKey(key), summary); err != nil {
+			logError("Failed to page %s: %v", key, err)
+			return
+		}
+		metrics.Add("pages_triggered", 1)
+		logInfo("Paged %s: %s", key, summary)
+	}()
+}
+
+// resolvePage resolves an incident raised by triggerPage in the background
+func resolvePage(key string) {
+	if pager == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), pagerTimeout)
+		defer cancel()
+		if err := pager.Resolve(ctx, pagerKey(key)); err != nil {
+			logError("Failed to resolve page %s: %v", key, err)
+			return
+		}
+		logInfo("Resolved page %s", key)
+	}()
+}
+
+// pagerKey namespaces incident keys, which are shared by every instance so a fleet raises a single incident
+func pagerKey(key string) string {
+	return "vibemerge:" + key
+}
+
+// postPager sends a JSON request to a pager provider, failing on any response but 2xx
+func postPager(ctx context.Context, client *http.Client, url string, header http.Header, body string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(response)))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDuty raises incidents through the PagerDuty Events API v2 with an integration's routing key
+type pagerDuty struct {
+	routingKey string
+	source     string
+	client     *http.Client
+}
+
+func newPagerDuty(config *Config) (*pagerDuty, error) {
+	if config.PagerDutyRoutingKey == "" {
+		return nil, fmt.Errorf("PAGER_PROVIDER=%s requires PAGERDUTY_ROUTING_KEY", PagerProviderPagerDuty)
+	}
+	return &pagerDuty{
+		routingKey: config.PagerDutyRoutingKey,
+		source:     config.InstanceID,
+		client:     &http.Client{Timeout: pagerTimeout},
+	}, nil
+}
+
+// pagerDutyEvent is an Events API v2 event. Payload is only sent with triggers.
+type pagerDutyEvent struct {
+	RoutingKey  string                 `json:"routing_key"`
+	EventAction string                 `json:"event_action"`
+	DedupKey    string                 `json:"dedup_key"`
+	Payload     *pagerDutyEventPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyEventPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+func (p *pagerDuty) Trigger(ctx context.Context, key, summary string) error {
+	return p.send(ctx, pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    key,
+		Payload:     &pagerDutyEventPayload{Summary: summary, Source: p.source, Severity: "critical"},
+	})
+}
+
+func (p *pagerDuty) Resolve(ctx context.Context, key string) error {
+	return p.send(ctx, pagerDutyEvent{RoutingKey: p.routingKey, EventAction: "resolve", DedupKey: key})
+}
+
+func (p *pagerDuty) send(ctx context.Context, event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty event: %w", err)
+	}
+	return postPager(ctx, p.client, pagerDutyEventsURL, http.Header{}, string(body))
+}
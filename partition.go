@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// partitionedReactionsChannel carries reaction events routed by repository, when REACTION_PARTITIONS is set
+const partitionedReactionsChannel = "slack-relay-reaction-added.by-repo"
+
+// partitionedTransport is a transport that spreads messages over partitions by key. Each partition is consumed by
+// one instance at a time, in order, so messages with the same key are handled in order whichever instance takes them.
+type partitionedTransport interface {
+	// PublishPartitioned publishes a message to the partition of channel that key hashes to
+	PublishPartitioned(ctx context.Context, channel, key, payload string, partitions int) error
+	// ReceivePartitioned consumes this instance's share of a channel's partitions, as EventSource.Receive does
+	ReceivePartitioned(ctx context.Context, channel string, partitions int, handle func(payload string, done func()))
+}
+
+// checkReactionPartitions makes sure REACTION_PARTITIONS is used with a transport that can partition reactions
+func (c *Config) checkReactionPartitions() error {
+	if c.ReactionPartitions < 0 {
+		return fmt.Errorf("REACTION_PARTITIONS must not be negative, got %d", c.ReactionPartitions)
+	}
+	if c.ReactionPartitions == 0 {
+		return nil
+	}
+	if c.Transport != TransportKafka && c.Transport != TransportNATS {
+		return fmt.Errorf("REACTION_PARTITIONS requires TRANSPORT=%s or TRANSPORT=%s, got %q", TransportKafka, TransportNATS, c.Transport)
+	}
+	if c.InputMode != InputModeRelay {
+		return fmt.Errorf("REACTION_PARTITIONS can't be used with INPUT_MODE=%s, whose events aren't shared between instances", c.InputMode)
+	}
+	return nil
+}
+
+// reactionPartition returns the partition of n a key hashes to
+func reactionPartition(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// partitionedReaction is a reaction event routed to the partition of its repository, along with the message's PR
+// metadata when it was fetched to find the repository, so the instance handling it doesn't fetch it again
+type partitionedReaction struct {
+	Repo     string      `json:"repo"`
+	Channel  string      `json:"channel,omitempty"`
+	Ts       string      `json:"ts,omitempty"`
+	Event    string      `json:"event"`
+	Fetched  bool        `json:"fetched,omitempty"`
+	Metadata *PRMetadata `json:"metadata,omitempty"`
+}
+
+// processPartitionedReactions consumes reactions on every instance. Each instance takes a share of the events
+// published by the relay, finds their repository and routes them to its partition; the partitions are shared
+// between the instances, so every repository's events are handled in order by one instance at a time.
+func processPartitionedReactions(ctx context.Context, redisClient *redis.Client, clients *slackClients, dispatcher *reactionDispatcher) {
+	config := currentConfig()
+	partitioned := transport.(partitionedTransport)
+	logInfo("Routing reactions to %d partitions of %s by repository", config.ReactionPartitions, partitionedReactionsChannel)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		eventSource(redisClient, clients).Receive(ctx, "slack-relay-reaction-added", func(payload string, done func()) {
+			dispatcher.resolvers.submit(reactionChannel(payload), func() {
+				routeReaction(ctx, partitioned, payload, redisClient, clients, dispatcher, done)
+			})
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		partitioned.ReceivePartitioned(ctx, partitionedReactionsChannel, config.ReactionPartitions, func(payload string, done func()) {
+			var routed partitionedReaction
+			if err := json.Unmarshal([]byte(payload), &routed); err != nil {
+				logWarning("Dropping malformed message on %s: %v", partitionedReactionsChannel, err)
+				done()
+				return
+			}
+			eventCtx := ctx
+			if routed.Fetched {
+				eventCtx = withPrefetchedMetadata(ctx, routed.Channel, routed.Ts, routed.Metadata)
+			}
+			dispatcher.handlers.submit(routed.Repo, func() {
+				defer done()
+				handleReaction(eventCtx, routed.Event, redisClient, clients)
+			})
+		})
+	}()
+	wg.Wait()
+}
+
+// routeReaction publishes a reaction event to the partition of its repository. An event that can't be published
+// is handled here instead, so it isn't lost, though no longer in order with its repository's other events.
+func routeReaction(ctx context.Context, partitioned partitionedTransport, payload string, redisClient *redis.Client, clients *slackClients, dispatcher *reactionDispatcher, done func()) {
+	config := currentConfig()
+	repo, resolvedCtx := resolveReactionRepo(ctx, payload, redisClient, clients, config)
+
+	routed := partitionedReaction{Repo: repo, Event: payload}
+	var reactionEvent ReactionEvent
+	if json.Unmarshal([]byte(payload), &reactionEvent) == nil {
+		routed.Channel, routed.Ts = reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts
+		routed.Metadata, routed.Fetched = prefetchedMetadata(resolvedCtx, routed.Channel, routed.Ts)
+	}
+
+	data, err := json.Marshal(routed)
+	if err == nil {
+		publishCtx, cancel := eventContext(ctx, config)
+		err = partitioned.PublishPartitioned(publishCtx, partitionedReactionsChannel, repo, string(data), config.ReactionPartitions)
+		cancel()
+	}
+	if err == nil {
+		done()
+		return
+	}
+
+	logWarning("Failed to route reaction to the partition of %s, handling it here: %v", repo, err)
+	dispatcher.handlers.submit(repo, func() {
+		defer done()
+		handleReaction(resolvedCtx, payload, redisClient, clients)
+	})
+}
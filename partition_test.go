@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// recordingPartitions is a partitionedTransport that records what is published to it
+type recordingPartitions struct {
+	mu        sync.Mutex
+	err       error
+	published []partitionedReaction
+	keys      []string
+}
+
+func (r *recordingPartitions) PublishPartitioned(ctx context.Context, channel, key, payload string, partitions int) error {
+	if r.err != nil {
+		return r.err
+	}
+	var routed partitionedReaction
+	if err := json.Unmarshal([]byte(payload), &routed); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.published = append(r.published, routed)
+	r.keys = append(r.keys, key)
+	return nil
+}
+
+func (r *recordingPartitions) ReceivePartitioned(ctx context.Context, channel string, partitions int, handle func(payload string, done func())) {
+}
+
+func TestReactionPartition(t *testing.T) {
+	for _, repo := range []string{"org/api", "org/web", "org/worker", ""} {
+		partition := reactionPartition(repo, 8)
+		if partition < 0 || partition >= 8 {
+			t.Errorf("reactionPartition(%q, 8) = %d, out of range", repo, partition)
+		}
+		if again := reactionPartition(repo, 8); again != partition {
+			t.Errorf("reactionPartition(%q, 8) = %d then %d, want the same partition", repo, partition, again)
+		}
+	}
+}
+
+func TestCheckReactionPartitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		partitions int
+		transport  string
+		inputMode  string
+		wantErr    bool
+	}{
+		{"off", 0, TransportRedis, InputModeRelay, false},
+		{"kafka", 16, TransportKafka, InputModeRelay, false},
+		{"nats", 16, TransportNATS, InputModeRelay, false},
+		{"redis pub/sub can't partition", 16, TransportRedis, InputModeRelay, true},
+		{"sqs can't partition", 16, TransportSQS, InputModeRelay, true},
+		{"socket mode isn't shared", 16, TransportKafka, InputModeSocket, true},
+		{"negative", -1, TransportKafka, InputModeRelay, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{ReactionPartitions: tt.partitions, Transport: tt.transport, InputMode: tt.inputMode}
+			if err := config.checkReactionPartitions(); (err != nil) != tt.wantErr {
+				t.Errorf("checkReactionPartitions() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouteReaction(t *testing.T) {
+	tests := []struct {
+		name          string
+		publishErr    error
+		wantPublished bool
+	}{
+		{"routed to the repository's partition", nil, true},
+		{"handled here when it can't be routed", errors.New("broker unavailable"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer redisClient.Close()
+
+			config := &Config{
+				CancelEmoji:        "no_entry",
+				PendingKeyPrefix:   "vibemerge:pending",
+				EventTimeout:       5,
+				ReactionPartitions: 8,
+			}
+			previous := activeConfig.Load()
+			defer activeConfig.Store(previous)
+			activeConfig.Store(config)
+
+			// The repository of a cancelled merge comes from the pending merge, without asking Slack
+			pendingJSON, err := json.Marshal(PendingMerge{CorrelationID: "corr-1", Repository: "org/repo", PRNumber: 42})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mr.Set(pendingKey(config, "C123", "1700000000.000100"), string(pendingJSON))
+			payload := `{"team_id":"T1","event_time":1700000000,"event":{"type":"reaction_added","user":"U1","reaction":"no_entry",` +
+				`"item":{"type":"message","channel":"C123","ts":"1700000000.000100"}}}`
+
+			partitions := &recordingPartitions{err: tt.publishErr}
+			dispatcher := newReactionDispatcher(2)
+			var done sync.WaitGroup
+			done.Add(1)
+			routeReaction(context.Background(), partitions, payload, redisClient, newSlackClients(), dispatcher, done.Done)
+			dispatcher.close()
+			done.Wait()
+
+			if published := len(partitions.published) == 1; published != tt.wantPublished {
+				t.Fatalf("published %d events, want published: %v", len(partitions.published), tt.wantPublished)
+			}
+			if !tt.wantPublished {
+				return
+			}
+			routed := partitions.published[0]
+			if partitions.keys[0] != "org/repo" || routed.Repo != "org/repo" {
+				t.Errorf("routed by %q as %q, want org/repo", partitions.keys[0], routed.Repo)
+			}
+			if routed.Event != payload || routed.Channel != "C123" || routed.Ts != "1700000000.000100" {
+				t.Errorf("routed %+v, want the original event of C123 at 1700000000.000100", routed)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// maxChangedFilePages caps the pages of changed files read from GitHub, which lists at most 3000 files per PR
+const maxChangedFilePages = 30
+
+// PathConfig holds the rules of a sub-team of a monorepo, for PRs changing files that match its globs. Unset fields
+// keep the repository's settings.
+type PathConfig struct {
+	// Name identifies the sub-team in logs and notes
+	Name string `json:"name"`
+	// Globs are matched against the paths of the PR's changed files; * and ? stay within a directory, and a ** segment
+	// matches any number of directories, e.g. services/payments/** or **/*.proto
+	Globs []string `json:"globs"`
+	// AuthorizedUsers are the Slack users who may merge PRs touching the paths, on top of AUTHORIZED_USERS
+	AuthorizedUsers []string `json:"authorized_users,omitempty"`
+	// RequiredChecks are check runs or commit statuses that must have succeeded on the PR's head commit
+	RequiredChecks []string `json:"required_checks,omitempty"`
+	// PoppitQueue and WorkDir route merges of PRs touching the paths to the sub-team's Poppit workers
+	PoppitQueue *string `json:"poppit_queue,omitempty"`
+	WorkDir     *string `json:"work_dir,omitempty"`
+}
+
+// passedConclusions are the check run conclusions and commit status states that satisfy a required check
+var passedConclusions = []string{"success", "neutral", "skipped"}
+
+// check rejects a path entry without a name or globs, or with a glob that can't be matched
+func (p PathConfig) check() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("paths entry needs a name")
+	}
+	if len(p.Globs) == 0 {
+		return fmt.Errorf("paths entry %s needs globs", p.Name)
+	}
+	for _, glob := range p.Globs {
+		if _, err := path.Match(strings.ReplaceAll(glob, "**", "*"), ""); err != nil || glob == "" {
+			return fmt.Errorf("paths entry %s: invalid glob %q", p.Name, glob)
+		}
+	}
+	if p.PoppitQueue != nil && strings.TrimSpace(*p.PoppitQueue) == "" {
+		return fmt.Errorf("paths entry %s: poppit_queue must not be empty", p.Name)
+	}
+	if p.WorkDir != nil && strings.TrimSpace(*p.WorkDir) == "" {
+		return fmt.Errorf("paths entry %s: work_dir must not be empty", p.Name)
+	}
+	return nil
+}
+
+// checkRepoPaths rejects required_checks in paths without GITHUB_TOKEN or a GitHub App to read checks with
+func (c *Config) checkRepoPaths() error {
+	for name, repo := range c.Repos {
+		for _, entry := range repo.Paths {
+			if len(entry.RequiredChecks) > 0 && !c.hasGitHubAPI() {
+				return fmt.Errorf("REPO_CONFIG_FILE %s: required_checks of paths entry %s require GITHUB_TOKEN or a GitHub App", name, entry.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether any of the files matches one of the entry's globs
+func (p PathConfig) matches(files []string) bool {
+	for _, file := range files {
+		for _, glob := range p.Globs {
+			if matchPathGlob(strings.Split(glob, "/"), strings.Split(strings.TrimPrefix(file, "/"), "/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPathGlob matches a path against a glob, both split into segments, where a ** segment matches any number of
+// segments, none included
+func matchPathGlob(glob, segments []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchPathGlob(glob[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], segments[0]); !ok {
+			return false
+		}
+		glob, segments = glob[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// changedFiles returns the paths a PR changes: those in its message's changed_files, or else the PR's files on GitHub
+func changedFiles(ctx context.Context, config *Config, job MergeJob) ([]string, error) {
+	if len(job.ChangedFiles) > 0 {
+		return job.ChangedFiles, nil
+	}
+	if !config.hasGitHubAPI() {
+		return nil, fmt.Errorf("the PR's message has no changed_files and neither GITHUB_TOKEN nor a GitHub App is set")
+	}
+
+	var files []string
+	for page := 1; page <= maxChangedFilePages; page++ {
+		var entries []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename"`
+		}
+		url := fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", job.Payload.Repo, job.PRNumber, page)
+		if err := githubRequest(ctx, config, http.MethodGet, url, nil, &entries); err != nil {
+			return nil, fmt.Errorf("failed to list the files of PR %d in %s: %w", job.PRNumber, job.Payload.Repo, err)
+		}
+		for _, entry := range entries {
+			// A file moved out of a sub-team's paths still touches them
+			files = append(files, entry.Filename)
+			if entry.PreviousFilename != "" {
+				files = append(files, entry.PreviousFilename)
+			}
+		}
+		if len(entries) < 100 {
+			break
+		}
+	}
+	return files, nil
+}
+
+// checkPaths applies the rules of every entry in the repository's paths whose globs match one of the PR's changed
+// files: the requester must be in each entry's authorized_users and each entry's required_checks must have
+// succeeded. The first matching entry that sets poppit_queue or work_dir routes the merge. A PR whose changed files
+// can't be read isn't queued, since the rules protecting its paths can't be known.
+func checkPaths(ctx context.Context, config *Config, job *MergeJob) (Decision, bool) {
+	entries := config.Repos[job.Payload.Repo].Paths
+	if len(entries) == 0 {
+		return Decision{}, false
+	}
+	files, err := changedFiles(ctx, config, *job)
+	if err != nil {
+		logWarning("Failed to read the changed files of PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{
+			Outcome: OutcomeDenied,
+			Reason:  "changed files unknown",
+			Note:    fmt.Sprintf(":file_folder: I couldn't work out which files PR #%d changes, so I don't know whose rules apply and it was not queued. Please try again shortly.", job.PRNumber),
+		}, true
+	}
+
+	var matched, required []string
+	var queue, dir string
+	for _, entry := range entries {
+		if !entry.matches(files) {
+			continue
+		}
+		matched = append(matched, entry.Name)
+		if len(entry.AuthorizedUsers) > 0 && !slices.Contains(entry.AuthorizedUsers, job.RequestedBy) {
+			logInfo("User %s is not authorized to merge PR %d in %s, which touches the paths of %s", job.RequestedBy, job.PRNumber, job.Payload.Repo, entry.Name)
+			return Decision{
+				Outcome: OutcomeDenied,
+				Reason:  fmt.Sprintf("requester not authorized for the paths of %s", entry.Name),
+				Note: fmt.Sprintf(":lock: Sorry <@%s>, PR #%d changes files owned by %s and you're not on its list of people who can merge them, so it was not queued.",
+					job.RequestedBy, job.PRNumber, entry.Name),
+			}, true
+		}
+		for _, check := range entry.RequiredChecks {
+			if !slices.Contains(required, check) {
+				required = append(required, check)
+			}
+		}
+		if queue == "" && entry.PoppitQueue != nil {
+			queue = *entry.PoppitQueue
+		}
+		if dir == "" && entry.WorkDir != nil {
+			dir = *entry.WorkDir
+		}
+	}
+	if len(matched) == 0 {
+		return Decision{}, false
+	}
+	logDebug("PR %d in %s touches the paths of %s", job.PRNumber, job.Payload.Repo, strings.Join(matched, ", "))
+
+	if len(required) > 0 {
+		pr, err := getPullRequest(ctx, config, job.Payload.Repo, job.PRNumber)
+		var states map[string]string
+		if err == nil {
+			states, err = checkStates(ctx, config, job.Payload.Repo, pr.Head.SHA)
+		}
+		if err != nil {
+			logWarning("Failed to read the checks of PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+			return Decision{
+				Outcome: OutcomeDenied,
+				Reason:  "required checks unknown",
+				Note:    fmt.Sprintf(":hourglass: I couldn't read the checks of PR #%d, so it was not queued. Please try again shortly.", job.PRNumber),
+			}, true
+		}
+		var unmet []string
+		for _, check := range required {
+			if !slices.Contains(passedConclusions, states[check]) {
+				unmet = append(unmet, check)
+			}
+		}
+		if len(unmet) > 0 {
+			logInfo("PR %d in %s hasn't passed the required checks of %s: %s", job.PRNumber, job.Payload.Repo, strings.Join(matched, ", "), strings.Join(unmet, ", "))
+			return Decision{
+				Outcome: OutcomeDenied,
+				Reason:  "required checks not passed: " + strings.Join(unmet, ", "),
+				Note: fmt.Sprintf(":vertical_traffic_light: PR #%d needs %s to pass before it can be merged, so it was not queued. React again once they're green.",
+					job.PRNumber, strings.Join(unmet, ", ")),
+			}, true
+		}
+	}
+
+	if queue != "" {
+		job.Queue = queue
+	}
+	// A dir in ACTIONS_FILE takes precedence, as it does over the repository's work_dir
+	if dir != "" && job.Payload.Dir == config.repoSettings(job.Payload.Repo).WorkDir {
+		job.Payload.Dir = dir
+	}
+	return Decision{}, false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pauseState reports whether merging is paused, along with the reason stored in the pause key.
+// The key can also be set by hand, e.g. `redis-cli SET vibemerge:paused "incident 123"`.
+func pauseState(ctx context.Context, redisClient *redis.Client, config *Config) (bool, string, error) {
+	reason, err := redisClient.Get(ctx, config.PauseKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("failed to read %s: %w", config.PauseKey, err)
+	}
+	return true, reason, nil
+}
+
+func pauseMerging(ctx context.Context, redisClient *redis.Client, config *Config, reason string) error {
+	if err := redisClient.Set(ctx, config.PauseKey, reason, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set %s: %w", config.PauseKey, err)
+	}
+	logWarning("Merging paused: %s", reason)
+	return nil
+}
+
+func resumeMerging(ctx context.Context, redisClient *redis.Client, config *Config) error {
+	if err := redisClient.Del(ctx, config.PauseKey).Err(); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", config.PauseKey, err)
+	}
+	logInfo("Merging resumed")
+	// Merges left waiting on a repository while paused go ahead now rather than on the next sweep
+	if config.serializeMerges() {
+		if err := sweepRepoQueues(ctx, redisClient, config); err != nil {
+			logWarning("Failed to release the merges waiting on repositories: %v", err)
+		}
+	}
+	return nil
+}
+
+// closedSinceQueued re-checks the PR of a merge leaving a queue, which is dropped if GitHub reported the PR merged or
+// closed while it waited. A PR whose state can't be read is assumed to still be open.
+func closedSinceQueued(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, bool) {
+	decision, closed, err := checkPRState(ctx, redisClient, config, job)
+	if err != nil {
+		logWarning("Failed to re-check the state of PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{}, false
+	}
+	if closed {
+		logInfo("PR %d in %s is no longer open (%s), dropping its waiting merge", job.PRNumber, job.Payload.Repo, decision.Reason)
+	}
+	return decision, closed
+}
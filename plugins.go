@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultPluginTimeout bounds each call to an external plugin that doesn't set its own timeout
+const defaultPluginTimeout = 10
+
+// Gate is a custom policy check on merges, applied after the built-in gates. Check reports true with the decision
+// to deny a merge; an error fails the request, so a broken gate never lets a merge through.
+type Gate interface {
+	Name() string
+	Check(ctx context.Context, job MergeJob) (Decision, bool, error)
+}
+
+// Action is a custom action an emoji in REACTIONS_FILE can take on a PR, e.g. {"action": "my_action", "params": {...}}.
+// Commands renders the Poppit commands that take it.
+type Action interface {
+	Name() string
+	Commands(ctx context.Context, metadata *PRMetadata, slackUser string, params map[string]any) ([]string, error)
+}
+
+// Gates and actions compiled into VibeMerge, registered from init functions. They are available to GATES and
+// REACTIONS_FILE by name, alongside the external plugins of PLUGINS_FILE.
+var (
+	registeredGates   = make(map[string]Gate)
+	registeredActions = make(map[string]Action)
+)
+
+// registerGate adds a compiled-in gate to the registry, panicking on a duplicate name since that is a build mistake
+func registerGate(gate Gate) {
+	if _, ok := registeredGates[gate.Name()]; ok {
+		panic(fmt.Sprintf("gate %q registered twice", gate.Name()))
+	}
+	registeredGates[gate.Name()] = gate
+}
+
+// registerAction adds a compiled-in action to the registry, panicking on a duplicate name or one that shadows a
+// built-in action
+func registerAction(action Action) {
+	switch action.Name() {
+	case ReactionAddLabel, ReactionRemoveLabel, ReactionComment, ReactionRequestReview:
+		panic(fmt.Sprintf("action %q is built in", action.Name()))
+	}
+	if _, ok := registeredActions[action.Name()]; ok {
+		panic(fmt.Sprintf("action %q registered twice", action.Name()))
+	}
+	registeredActions[action.Name()] = action
+}
+
+// ProcessPlugin is an external plugin from PLUGINS_FILE, run once per call with a PluginRequest as JSON on stdin and
+// answering with a PluginResponse as JSON on stdout. The same executable can act as a gate, an action or both.
+type ProcessPlugin struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Timeout is the seconds a call may take, 10 by default
+	Timeout int `json:"timeout,omitempty"`
+
+	name string
+}
+
+// PluginRequest is what an external plugin reads on stdin. Kind is "gate" or "action".
+type PluginRequest struct {
+	Kind       string         `json:"kind"`
+	Job        *MergeJob      `json:"job,omitempty"`
+	Metadata   *PRMetadata    `json:"metadata,omitempty"`
+	SlackUser  string         `json:"slack_user,omitempty"`
+	GitHubUser string         `json:"github_user,omitempty"`
+	Params     map[string]any `json:"params,omitempty"`
+}
+
+// PluginResponse is what an external plugin writes on stdout. A gate sets Deny, with a Reason and optionally a
+// Note for the PR's thread; an action lists its Commands. Error fails the request.
+type PluginResponse struct {
+	Deny     bool     `json:"deny,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+	Note     string   `json:"note,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// loadPlugins reads the external plugins file, which maps plugin names to the executables that implement them
+func loadPlugins(path string) (map[string]*ProcessPlugin, error) {
+	plugins := make(map[string]*ProcessPlugin)
+	if path == "" {
+		return plugins, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PLUGINS_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, &plugins); err != nil {
+		return nil, fmt.Errorf("failed to parse PLUGINS_FILE: %w", err)
+	}
+
+	for name, plugin := range plugins {
+		if plugin == nil || plugin.Command == "" {
+			return nil, fmt.Errorf("PLUGINS_FILE: plugin %q needs a command", name)
+		}
+		if _, ok := registeredGates[name]; ok {
+			return nil, fmt.Errorf("PLUGINS_FILE: plugin %q has the name of a compiled-in gate", name)
+		}
+		if _, ok := registeredActions[name]; ok {
+			return nil, fmt.Errorf("PLUGINS_FILE: plugin %q has the name of a compiled-in action", name)
+		}
+		switch name {
+		case ReactionAddLabel, ReactionRemoveLabel, ReactionComment, ReactionRequestReview:
+			return nil, fmt.Errorf("PLUGINS_FILE: plugin %q has the name of a built-in action", name)
+		}
+		if plugin.Timeout < 0 {
+			return nil, fmt.Errorf("PLUGINS_FILE: timeout of plugin %q must not be negative, got %d", name, plugin.Timeout)
+		}
+		if plugin.Timeout == 0 {
+			plugin.Timeout = defaultPluginTimeout
+		}
+		plugin.name = name
+	}
+	return plugins, nil
+}
+
+// parseGates resolves the comma-separated gate names of GATES, in the order they are applied
+func parseGates(names []string, plugins map[string]*ProcessPlugin) ([]Gate, error) {
+	var gates []Gate
+	for _, name := range names {
+		if gate, ok := registeredGates[name]; ok {
+			gates = append(gates, gate)
+			continue
+		}
+		if plugin, ok := plugins[name]; ok {
+			gates = append(gates, plugin)
+			continue
+		}
+		return nil, fmt.Errorf("GATES: unknown gate %q, expected one of %s", name, strings.Join(availablePlugins(registeredGates, plugins), ", "))
+	}
+	return gates, nil
+}
+
+// pluginActions returns every action available to REACTIONS_FILE besides the built-in ones
+func pluginActions(plugins map[string]*ProcessPlugin) map[string]Action {
+	actions := make(map[string]Action, len(registeredActions)+len(plugins))
+	for name, action := range registeredActions {
+		actions[name] = action
+	}
+	for name, plugin := range plugins {
+		actions[name] = plugin
+	}
+	return actions
+}
+
+func availablePlugins[T any](registered map[string]T, plugins map[string]*ProcessPlugin) []string {
+	var names []string
+	for name := range registered {
+		names = append(names, name)
+	}
+	for name := range plugins {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return []string{"none configured"}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkGates applies the custom gates of GATES in order, stopping at the first that denies the merge
+func checkGates(ctx context.Context, config *Config, job MergeJob) (Decision, bool, error) {
+	for _, gate := range config.Gates {
+		decision, denied, err := gate.Check(ctx, job)
+		if err != nil {
+			return Decision{}, false, fmt.Errorf("gate %s failed: %w", gate.Name(), err)
+		}
+		if denied {
+			logInfo("Gate %s denied the merge of PR %d in %s: %s", gate.Name(), job.PRNumber, job.Payload.Repo, decision.Reason)
+			if decision.Outcome == "" {
+				decision.Outcome = OutcomeDenied
+			}
+			return decision, true, nil
+		}
+	}
+	return Decision{}, false, nil
+}
+
+func (p *ProcessPlugin) Name() string {
+	return p.name
+}
+
+// Check asks an external plugin whether a merge may go ahead
+func (p *ProcessPlugin) Check(ctx context.Context, job MergeJob) (Decision, bool, error) {
+	var response PluginResponse
+	if err := p.call(ctx, PluginRequest{Kind: "gate", Job: &job, SlackUser: job.RequestedBy, GitHubUser: job.Payload.GitHubUser}, &response); err != nil {
+		return Decision{}, false, err
+	}
+	if !response.Deny {
+		return Decision{}, false, nil
+	}
+
+	reason := response.Reason
+	if reason == "" {
+		reason = "denied"
+	}
+	note := response.Note
+	if note == "" {
+		note = fmt.Sprintf(":no_entry: The %s check refused to merge PR #%d: %s", p.name, job.PRNumber, reason)
+	}
+	return Decision{Outcome: OutcomeDenied, Reason: fmt.Sprintf("%s gate: %s", p.name, reason), Note: note}, true, nil
+}
+
+// Commands asks an external plugin for the Poppit commands that take its action on a PR
+func (p *ProcessPlugin) Commands(ctx context.Context, metadata *PRMetadata, slackUser string, params map[string]any) ([]string, error) {
+	var response PluginResponse
+	request := PluginRequest{Kind: "action", Metadata: metadata, SlackUser: slackUser, GitHubUser: metadata.GitHubUser, Params: params}
+	if err := p.call(ctx, request, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Commands) == 0 {
+		return nil, fmt.Errorf("plugin %s returned no commands", p.name)
+	}
+	return response.Commands, nil
+}
+
+// call runs the plugin's executable with a request, decoding its response
+func (p *ProcessPlugin) call(ctx context.Context, request PluginRequest, response *PluginResponse) error {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.Timeout)*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("plugin %s timed out after %ds", p.name, p.Timeout)
+		}
+		return fmt.Errorf("plugin %s failed: %w: %s", p.name, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return fmt.Errorf("plugin %s returned invalid JSON: %w", p.name, err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("plugin %s: %s", p.name, response.Error)
+	}
+	return nil
+}
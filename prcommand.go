@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// submitPRCommand hands Poppit the commands of a job that acts on a PR without merging it. Since nothing is merged,
+// only authorization, the checks given and, when open is set, that the PR is still open are checked. kind names the
+// command in the logs and is the reason recorded once it's queued.
+func submitPRCommand(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, kind string, open bool, checks ...func(MergeJob) (Decision, bool)) (Decision, error) {
+	if decision, denied := checkAuthorized(job, config); denied {
+		logInfo("User %s is not authorized to request %s of PR %d in %s", job.RequestedBy, kind, job.PRNumber, job.Payload.Repo)
+		return decision, nil
+	}
+	for _, check := range checks {
+		if decision, denied := check(job); denied {
+			logInfo("User %s can't request %s of PR %d in %s: %s", job.RequestedBy, kind, job.PRNumber, job.Payload.Repo, decision.Reason)
+			return decision, nil
+		}
+	}
+
+	if open {
+		decision, closed, err := checkPRState(ctx, redisClient, config, job)
+		if err != nil {
+			return Decision{}, err
+		}
+		if closed {
+			return decision, nil
+		}
+	}
+
+	if err := pushPoppitPayload(ctx, redisClient, config, job.Payload); err != nil {
+		return Decision{}, err
+	}
+
+	logInfo("Successfully queued %s of PR %d in %s", kind, job.PRNumber, job.Payload.Repo)
+	return Decision{Outcome: OutcomeQueued, Reason: kind}, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Merge priorities. Others can be named in PRIORITY_QUEUES, each with a queue of its own.
+const (
+	PriorityUrgent = "urgent"
+	PriorityNormal = "normal"
+)
+
+// frontPusher is implemented by transports that can put a command at the front of a queue, ahead of those already
+// waiting. Only Redis can, so other transports need a queue per priority in PRIORITY_QUEUES.
+type frontPusher interface {
+	PushFront(ctx context.Context, queue, payload string) error
+}
+
+func (t redisTransport) PushFront(ctx context.Context, queue, payload string) error {
+	return t.redisClient.LPush(ctx, queue, payload).Err()
+}
+
+// parsePriorityQueues parses PRIORITY_QUEUES, a comma-separated list of priority=queue pairs
+func parsePriorityQueues(value string) (map[string]string, error) {
+	queues := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		priority, queue, ok := strings.Cut(part, "=")
+		priority, queue = strings.ToLower(strings.TrimSpace(priority)), strings.TrimSpace(queue)
+		if !ok || priority == "" || queue == "" {
+			return nil, fmt.Errorf("invalid PRIORITY_QUEUES entry %q, expected priority=queue", part)
+		}
+		queues[priority] = queue
+	}
+	return queues, nil
+}
+
+// checkUrgentEmoji rejects an urgent emoji that already does something else
+func (c *Config) checkUrgentEmoji() error {
+	switch c.UrgentEmoji {
+	case "":
+	case c.TargetEmoji, c.ReadyEmoji, c.ApproveEmoji, c.CloseEmoji, c.RevertEmoji, c.CancelEmoji, c.SizeOverrideEmoji:
+		return fmt.Errorf("URGENT_EMOJI %q is already a merge, ready, approve, close, revert, cancel or size override emoji", c.UrgentEmoji)
+	}
+	if _, ok := c.Reactions[c.UrgentEmoji]; ok {
+		return fmt.Errorf("URGENT_EMOJI %q has actions in REACTIONS_FILE or COMMENTS_FILE", c.UrgentEmoji)
+	}
+	if _, ok := c.Workflows[c.UrgentEmoji]; ok {
+		return fmt.Errorf("URGENT_EMOJI %q has a workflow in WORKFLOWS_FILE", c.UrgentEmoji)
+	}
+	return nil
+}
+
+// mergePriority resolves the priority a PR's message asks for, falling back to normal for priorities that aren't
+// urgent or named in PRIORITY_QUEUES
+func (c *Config) mergePriority(metadata *PRMetadata) string {
+	priority := strings.ToLower(strings.TrimSpace(metadata.Priority))
+	if priority == "" || priority == PriorityNormal {
+		return ""
+	}
+	if _, ok := c.PriorityQueues[priority]; ok || priority == PriorityUrgent {
+		return priority
+	}
+	logWarning("Unknown priority %q for PR %d in %s, merging at normal priority", metadata.Priority, metadata.PRNumber, metadata.Repository)
+	return ""
+}
+
+// priorityQueue returns the queue a merge of the given priority in a repository is pushed to, and whether it goes
+// to the front of the queue because its priority has no queue of its own. A repository's poppit_queue takes
+// precedence over PRIORITY_QUEUES, so its merges stay with its worker fleet.
+func (c *Config) priorityQueue(repo, priority string) (string, bool) {
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	if queue := c.repoSettings(repo).PoppitQueue; queue != c.PoppitQueue {
+		return queue, priority == PriorityUrgent
+	}
+	if queue, ok := c.PriorityQueues[priority]; ok {
+		return queue, false
+	}
+	return c.PoppitQueue, priority == PriorityUrgent
+}
+
+// mergeQueue returns the queue a merge is pushed to and whether it goes to the front of it: the queue of its
+// priority, unless the paths the PR touches have one, which takes precedence like a repository's
+func (c *Config) mergeQueue(job MergeJob) (string, bool) {
+	if job.Queue != "" {
+		return job.Queue, job.Priority == PriorityUrgent
+	}
+	return c.priorityQueue(job.Payload.Repo, job.Priority)
+}
+
+// pushMergeCommand pushes a merge's Poppit payload to its queue, returning the queue
+func pushMergeCommand(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, payload string) (string, error) {
+	queue, front := config.mergeQueue(job)
+	sink := commandSink(redisClient)
+	if pusher, ok := sink.(frontPusher); ok && front {
+		logInfo("Queueing urgent merge of PR %d in %s ahead of the others in %s", job.PRNumber, job.Payload.Repo, queue)
+		return queue, pusher.PushFront(ctx, queue, payload)
+	}
+	if front {
+		logWarning("TRANSPORT=%s can't queue ahead of other commands, set an urgent queue in PRIORITY_QUEUES", config.Transport)
+	}
+	return queue, sink.Push(ctx, queue, payload)
+}
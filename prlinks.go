@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// prLinkPattern finds pull request URLs, on github.com or a GitHub Enterprise host, anywhere in a message's text,
+// blocks or attachments, including the attachments Slack adds when it unfurls a link
+var prLinkPattern = regexp.MustCompile(`https?://([^/\s|<>"]+)/([^/\s|<>"]+/[^/\s|<>"]+)/pull/(\d+)`)
+
+// prLink is a pull request URL found in a message
+type prLink struct {
+	Host     string
+	Repo     string
+	PRNumber int
+	URL      string
+}
+
+// findPRLinks returns the pull request URLs in text, in order
+func findPRLinks(text string) []prLink {
+	var links []prLink
+	for _, match := range prLinkPattern.FindAllStringSubmatch(text, -1) {
+		prNumber, err := strconv.Atoi(match[3])
+		if err != nil {
+			continue
+		}
+		links = append(links, prLink{Host: match[1], Repo: match[2], PRNumber: prNumber, URL: match[0]})
+	}
+	return links
+}
+
+// checkPRLink ensures a link points at an allowed host and, when PR_LINK_ORGS is set, an allowed organisation
+func (c *Config) checkPRLink(link prLink) error {
+	if !slices.ContainsFunc(c.PRLinkHosts, func(host string) bool { return strings.EqualFold(host, link.Host) }) {
+		return fmt.Errorf("%s is not an allowed GitHub host", link.Host)
+	}
+	if len(c.PRLinkOrgs) == 0 {
+		return nil
+	}
+	org, _, _ := strings.Cut(link.Repo, "/")
+	if !slices.ContainsFunc(c.PRLinkOrgs, func(allowed string) bool { return strings.EqualFold(allowed, org) }) {
+		return fmt.Errorf("%s is not an allowed organisation", org)
+	}
+	return nil
+}
+
+// parsePRURL extracts the repository and PR number from a pull request URL on an allowed host and organisation
+func parsePRURL(value string, config *Config) (*PRMetadata, error) {
+	// Slack wraps links as <url> or <url|label>
+	value = strings.Trim(value, "<>")
+	if url, _, ok := strings.Cut(value, "|"); ok {
+		value = url
+	}
+
+	links := findPRLinks(value)
+	if len(links) == 0 || !strings.HasPrefix(value, links[0].URL) {
+		return nil, fmt.Errorf("%q is not a GitHub pull request URL", value)
+	}
+	if err := config.checkPRLink(links[0]); err != nil {
+		return nil, err
+	}
+
+	return &PRMetadata{
+		PRNumber:   links[0].PRNumber,
+		Repository: links[0].Repo,
+		PRURL:      value,
+	}, nil
+}
+
+// parsePRLinks builds PR metadata from the pull request URL in a message without metadata, such as those posted
+// by the GitHub Slack app or a PR link pasted by hand. Links to hosts or organisations that aren't allowed are
+// skipped. It returns nil when the message links to no PR, or to more than one.
+func parsePRLinks(message *slack.Message, config *Config) (*PRMetadata, error) {
+	sources := []string{message.Text}
+	for _, part := range []any{message.Attachments, message.Blocks} {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message content: %w", err)
+		}
+		sources = append(sources, string(data))
+	}
+
+	var found *PRMetadata
+	for _, source := range sources {
+		for _, link := range findPRLinks(source) {
+			if err := config.checkPRLink(link); err != nil {
+				logDebug("Ignoring PR link %s in message %s: %v", link.URL, message.Timestamp, err)
+				continue
+			}
+			if found == nil {
+				found = &PRMetadata{PRNumber: link.PRNumber, Repository: link.Repo, PRURL: link.URL}
+				continue
+			}
+			if found.PRNumber != link.PRNumber || !strings.EqualFold(found.Repository, link.Repo) {
+				logDebug("Message %s links to more than one PR, ignoring", message.Timestamp)
+				return nil, nil
+			}
+		}
+	}
+	return found, nil
+}
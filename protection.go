@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// branchProtection is the part of a branch's protection rules that can be checked before a merge is queued
+type branchProtection struct {
+	RequiredStatusChecks *struct {
+		Contexts []string `json:"contexts"`
+		Checks   []struct {
+			Context string `json:"context"`
+		} `json:"checks"`
+	} `json:"required_status_checks"`
+	RequiredPullRequestReviews *struct {
+		RequiredApprovingReviewCount int `json:"required_approving_review_count"`
+	} `json:"required_pull_request_reviews"`
+	RequiredConversationResolution *struct {
+		Enabled bool `json:"enabled"`
+	} `json:"required_conversation_resolution"`
+}
+
+// failedConclusions are the check run conclusions and commit status states that fail a required check
+var failedConclusions = []string{"failure", "error", "cancelled", "timed_out", "action_required", "startup_failure"}
+
+// checkBranchProtection denies a merge that the base branch's protection rules would obviously refuse, listing every
+// unmet requirement in the thread. GitHub errors are logged and the merge is let through, since Poppit's merge
+// command is still checked by GitHub.
+func checkBranchProtection(ctx context.Context, config *Config, job MergeJob) (Decision, bool) {
+	if !config.BranchProtectionCheck {
+		return Decision{}, false
+	}
+	unmet, err := unmetProtection(ctx, config, job.Payload.Repo, job.PRNumber)
+	if err != nil {
+		logWarning("Failed to check branch protection for PR %d in %s, not enforcing it: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{}, false
+	}
+	if len(unmet) == 0 {
+		return Decision{}, false
+	}
+
+	logInfo("PR %d in %s doesn't meet branch protection: %s", job.PRNumber, job.Payload.Repo, strings.Join(unmet, "; "))
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  "branch protection: " + strings.Join(unmet, "; "),
+		Note: fmt.Sprintf(":shield: PR #%d in %s can't be merged yet, so it was not queued:\n• %s",
+			job.PRNumber, job.Payload.Repo, strings.Join(unmet, "\n• ")),
+	}, true
+}
+
+// unmetProtection lists the requirements of the PR's base branch protection the PR doesn't meet. Required checks
+// that are still running aren't listed, only those that failed.
+func unmetProtection(ctx context.Context, config *Config, repo string, number int) ([]string, error) {
+	pr, err := getPullRequest(ctx, config, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	var protection branchProtection
+	err = githubRequest(ctx, config, http.MethodGet, fmt.Sprintf("/repos/%s/branches/%s/protection", repo, url.PathEscape(pr.Base.Ref)), nil, &protection)
+	var apiErr *githubAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		// The branch isn't protected
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protection of %s: %w", pr.Base.Ref, err)
+	}
+
+	var unmet []string
+	if reviews := protection.RequiredPullRequestReviews; reviews != nil {
+		approvals, changesRequested, err := reviewState(ctx, config, repo, number)
+		if err != nil {
+			return nil, err
+		}
+		if approvals < reviews.RequiredApprovingReviewCount {
+			unmet = append(unmet, fmt.Sprintf("approving reviews: %d of %d required", approvals, reviews.RequiredApprovingReviewCount))
+		}
+		for _, reviewer := range changesRequested {
+			unmet = append(unmet, fmt.Sprintf("changes requested by %s", reviewer))
+		}
+	}
+	if resolution := protection.RequiredConversationResolution; resolution != nil && resolution.Enabled {
+		unresolved, err := unresolvedThreads(ctx, config, repo, number)
+		if err != nil {
+			return nil, err
+		}
+		if unresolved > 0 {
+			unmet = append(unmet, fmt.Sprintf("unresolved conversations: %d", unresolved))
+		}
+	}
+	if checks := protection.RequiredStatusChecks; checks != nil {
+		required := slices.Clone(checks.Contexts)
+		for _, check := range checks.Checks {
+			if !slices.Contains(required, check.Context) {
+				required = append(required, check.Context)
+			}
+		}
+		if len(required) > 0 {
+			failed, err := failedChecks(ctx, config, repo, pr.Head.SHA)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range required {
+				if slices.Contains(failed, name) {
+					unmet = append(unmet, fmt.Sprintf("required check %s failed", name))
+				}
+			}
+		}
+	}
+	return unmet, nil
+}
+
+// reviewState counts a PR's approvals and lists who requested changes, from each reviewer's latest review
+func reviewState(ctx context.Context, config *Config, repo string, number int) (int, []string, error) {
+	var reviews []struct {
+		State string `json:"state"`
+		User  struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := githubRequest(ctx, config, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d/reviews?per_page=100", repo, number), nil, &reviews); err != nil {
+		return 0, nil, fmt.Errorf("failed to read reviews: %w", err)
+	}
+
+	latest := make(map[string]string)
+	var reviewers []string
+	for _, review := range reviews {
+		// Comments don't change a reviewer's verdict
+		if review.State == "COMMENTED" || review.State == "PENDING" {
+			continue
+		}
+		if _, seen := latest[review.User.Login]; !seen {
+			reviewers = append(reviewers, review.User.Login)
+		}
+		latest[review.User.Login] = review.State
+	}
+
+	approvals := 0
+	var changesRequested []string
+	for _, reviewer := range reviewers {
+		switch latest[reviewer] {
+		case "APPROVED":
+			approvals++
+		case "CHANGES_REQUESTED":
+			changesRequested = append(changesRequested, reviewer)
+		}
+	}
+	return approvals, changesRequested, nil
+}
+
+// unresolvedThreads counts a PR's unresolved review threads, which only the GraphQL API reports
+func unresolvedThreads(ctx context.Context, config *Config, repo string, number int) (int, error) {
+	owner, name, _ := strings.Cut(repo, "/")
+	var data struct {
+		Repository struct {
+			PullRequest struct {
+				ReviewThreads struct {
+					Nodes []struct {
+						IsResolved bool `json:"isResolved"`
+					} `json:"nodes"`
+				} `json:"reviewThreads"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	}
+	const query = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) { reviewThreads(first: 100) { nodes { isResolved } } }
+  }
+}`
+	if err := githubGraphQL(ctx, config, query, map[string]any{"owner": owner, "name": name, "number": number}, &data); err != nil {
+		return 0, fmt.Errorf("failed to read review threads: %w", err)
+	}
+
+	unresolved := 0
+	for _, thread := range data.Repository.PullRequest.ReviewThreads.Nodes {
+		if !thread.IsResolved {
+			unresolved++
+		}
+	}
+	return unresolved, nil
+}
+
+// failedChecks lists the check runs and commit statuses that failed on a commit
+func failedChecks(ctx context.Context, config *Config, repo, sha string) ([]string, error) {
+	states, err := checkStates(ctx, config, repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	var failed []string
+	for name, state := range states {
+		if slices.Contains(failedConclusions, state) {
+			failed = append(failed, name)
+		}
+	}
+	return failed, nil
+}
+
+// checkStates maps the names of a commit's commit statuses and check runs to their state or conclusion, which is
+// empty for a check run still in progress
+func checkStates(ctx context.Context, config *Config, repo, sha string) (map[string]string, error) {
+	var status struct {
+		Statuses []struct {
+			Context string `json:"context"`
+			State   string `json:"state"`
+		} `json:"statuses"`
+	}
+	if err := githubRequest(ctx, config, http.MethodGet, fmt.Sprintf("/repos/%s/commits/%s/status?per_page=100", repo, sha), nil, &status); err != nil {
+		return nil, fmt.Errorf("failed to read commit statuses: %w", err)
+	}
+	var runs struct {
+		CheckRuns []struct {
+			Name       string `json:"name"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	if err := githubRequest(ctx, config, http.MethodGet, fmt.Sprintf("/repos/%s/commits/%s/check-runs?per_page=100", repo, sha), nil, &runs); err != nil {
+		return nil, fmt.Errorf("failed to read check runs: %w", err)
+	}
+
+	states := make(map[string]string)
+	for _, s := range status.Statuses {
+		states[s.Context] = s.State
+	}
+	for _, run := range runs.CheckRuns {
+		states[run.Name] = run.Conclusion
+	}
+	return states, nil
+}
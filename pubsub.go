@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	resubscribeMinBackoff = time.Second
+	resubscribeMaxBackoff = 30 * time.Second
+	// subscriptionPingInterval is how long a subscription may be silent before it is pinged to check it is still alive
+	subscriptionPingInterval = 30 * time.Second
+)
+
+// subscriptionGaps records, per channel, the total seconds its subscription has been down.
+// Messages published while a subscription is down are lost.
+var subscriptionGaps = new(expvar.Map)
+
+// subscriptionsDown counts the subscriptions currently lost
+var subscriptionsDown = new(expvar.Int)
+
+func init() {
+	metrics.Set("pubsub_gap_seconds", subscriptionGaps)
+	metrics.Set("pubsub_subscriptions_down", subscriptionsDown)
+}
+
+// subscribe subscribes to a pub/sub channel and closes the subscription when ctx is cancelled,
+// which unblocks a pending receive during shutdown
+func subscribe(ctx context.Context, redisClient *redis.Client, channel string) *redis.PubSub {
+	pubsub := redisClient.Subscribe(ctx, channel)
+	context.AfterFunc(ctx, func() { pubsub.Close() })
+	return pubsub
+}
+
+// receiveMessages passes each message published on a channel to handle until ctx is cancelled, over the configured
+// transport
+func receiveMessages(ctx context.Context, redisClient *redis.Client, clients *slackClients, channel string, handle func(payload string)) {
+	eventSource(redisClient, clients).Receive(ctx, channel, func(payload string, done func()) {
+		defer done()
+		handle(payload)
+	})
+}
+
+// receiveRedisMessages passes each message published on a Redis channel to handle until ctx is cancelled.
+// When the subscription is lost it resubscribes with backoff, records the gap, and alerts
+// OPS_ALERT_CHANNEL if it stays down for longer than SUBSCRIPTION_ALERT_AFTER.
+func receiveRedisMessages(ctx context.Context, redisClient *redis.Client, clients *slackClients, channel string, handle func(payload string)) {
+	var downSince time.Time
+	alerted := false
+	backoff := resubscribeMinBackoff
+	defer func() {
+		if !downSince.IsZero() {
+			subscriptionsDown.Add(-1)
+		}
+	}()
+
+	for {
+		pubsub := subscribe(ctx, redisClient, channel)
+		// Receive waits for Redis to confirm the subscription, so a dead connection is noticed straight away
+		_, err := pubsub.Receive(ctx)
+		if err == nil {
+			if !downSince.IsZero() {
+				gap := time.Since(downSince)
+				subscriptionGaps.AddFloat(channel, gap.Seconds())
+				logWarning("Resubscribed to %s channel after %s; messages published in that time were lost", channel, gap.Round(time.Second))
+				if alerted {
+					alertOps(ctx, clients, currentConfig(), fmt.Sprintf(":white_check_mark: VibeMerge resubscribed to Redis channel `%s` after %s down.", channel, gap.Round(time.Second)))
+					resolvePage("subscription:" + channel)
+				}
+				downSince = time.Time{}
+				alerted = false
+				subscriptionsDown.Add(-1)
+			} else {
+				logInfo("Subscribed to %s channel", channel)
+			}
+			backoff = resubscribeMinBackoff
+			err = receiveUntilLost(ctx, pubsub, handle)
+		}
+		pubsub.Close()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if downSince.IsZero() {
+			downSince = time.Now()
+			metrics.Add("pubsub_disconnects", 1)
+			subscriptionsDown.Add(1)
+		}
+		logError("Subscription to %s channel lost: %v (resubscribing in %s)", channel, err, backoff)
+
+		config := currentConfig()
+		alertAfter := time.Duration(config.SubscriptionAlertAfter) * time.Second
+		if !alerted && alertAfter > 0 && time.Since(downSince) >= alertAfter {
+			alertOps(ctx, clients, config, fmt.Sprintf(":rotating_light: VibeMerge has been unable to subscribe to Redis channel `%s` for %s: %v",
+				channel, time.Since(downSince).Round(time.Second), err))
+			triggerPage("subscription:"+channel, fmt.Sprintf("VibeMerge has been unable to subscribe to Redis channel %s for %s: %v",
+				channel, time.Since(downSince).Round(time.Second), err))
+			alerted = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, resubscribeMaxBackoff)
+	}
+}
+
+// receiveUntilLost hands messages to handle until the subscription fails. A subscription that has
+// been quiet for subscriptionPingInterval is pinged, so a silently dropped connection is detected.
+func receiveUntilLost(ctx context.Context, pubsub *redis.PubSub, handle func(payload string)) error {
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, subscriptionPingInterval)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if err := pubsub.Ping(ctx); err != nil {
+					return fmt.Errorf("ping failed: %w", err)
+				}
+				continue
+			}
+			return err
+		}
+
+		if message, ok := msg.(*redis.Message); ok {
+			handle(message.Payload)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeUserQuotaScript counts a merge against a user's daily counter unless it already reached the quota, returning
+// the merges counted so far today or -1 when the quota is used up. ARGV[2] is the Unix time the day ends at.
+var takeUserQuotaScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used >= tonumber(ARGV[1]) then
+	return -1
+end
+used = redis.call('INCR', KEYS[1])
+redis.call('EXPIREAT', KEYS[1], ARGV[2])
+return used
+`)
+
+// returnUserQuotaScript gives a merge back, leaving alone a counter that expired in the meantime
+var returnUserQuotaScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+// parseUserMergeQuota parses USER_MERGE_QUOTA, the merges each user may request a day, where empty or 0 means no
+// quota. Unlike most numeric settings a value that isn't a number is an error rather than falling back to the
+// default, since that would silently lift the quota.
+func parseUserMergeQuota(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	quota, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("USER_MERGE_QUOTA must be a number, got %q", value)
+	}
+	if quota < 0 {
+		return 0, fmt.Errorf("USER_MERGE_QUOTA must not be negative, got %d", quota)
+	}
+	return quota, nil
+}
+
+// userQuotaKey is a user's merge counter for the day of now in MERGE_TIMEZONE
+func userQuotaKey(config *Config, user string, now time.Time) string {
+	return fmt.Sprintf("%s:%s:%s", config.UserQuotaKeyPrefix, now.In(config.Timezone).Format(time.DateOnly), user)
+}
+
+// userQuotaApplies reports whether a merge counts against USER_MERGE_QUOTA
+func userQuotaApplies(config *Config, job MergeJob) bool {
+	return config.UserMergeQuota > 0 && job.RequestedBy != ""
+}
+
+// takeUserQuota counts a merge against its requester's USER_MERGE_QUOTA for the day, denying it once the quota is
+// used up
+func takeUserQuota(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, bool, error) {
+	if !userQuotaApplies(config, job) {
+		return Decision{}, false, nil
+	}
+
+	now := time.Now()
+	day := now.In(config.Timezone)
+	endOfDay := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, config.Timezone)
+	used, err := takeUserQuotaScript.Run(ctx, redisClient, []string{userQuotaKey(config, job.RequestedBy, now)},
+		config.UserMergeQuota, endOfDay.Unix()).Int64()
+	if err != nil {
+		return Decision{}, false, fmt.Errorf("failed to check merge quota for %s: %w", job.RequestedBy, err)
+	}
+	if used >= 0 {
+		return Decision{}, false, nil
+	}
+
+	logInfo("User %s reached the merge quota of %d a day, not queueing PR %d in %s", job.RequestedBy, config.UserMergeQuota, job.PRNumber, job.Payload.Repo)
+	resume := endOfDay.Format("Mon 15:04 MST")
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  fmt.Sprintf("merge quota of %d a day reached", config.UserMergeQuota),
+		Note: fmt.Sprintf(":no_entry_sign: <@%s> has already requested %d merges today, the most allowed, so PR #%d was not queued. The quota resets at %s.",
+			job.RequestedBy, config.UserMergeQuota, job.PRNumber, resume),
+	}, true, nil
+}
+
+// returnUserQuota gives back a merge counted by takeUserQuota that was then not queued or deferred
+func returnUserQuota(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) {
+	if !userQuotaApplies(config, job) {
+		return
+	}
+	key := userQuotaKey(config, job.RequestedBy, time.Now())
+	if err := returnUserQuotaScript.Run(ctx, redisClient, []string{key}).Err(); err != nil {
+		logWarning("Failed to give back the merge quota of %s: %v", job.RequestedBy, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUserMergeQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"unset", "", 0, false},
+		{"quota", "10", 10, false},
+		{"disabled", "0", 0, false},
+		{"negative", "-1", 0, true},
+		{"not a number", "ten", 0, true},
+		{"fraction", "2.5", 0, true},
+		{"spaces", " 10", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUserMergeQuota(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUserMergeQuota(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseUserMergeQuota(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserQuotaKey(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{UserQuotaKeyPrefix: "vibemerge:user-quota", Timezone: berlin}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"same day", time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC), "vibemerge:user-quota:2026-10-16:U123"},
+		{"already tomorrow in the timezone", time.Date(2026, time.October, 16, 22, 30, 0, 0, time.UTC), "vibemerge:user-quota:2026-10-17:U123"},
+		{"late evening in the timezone", time.Date(2026, time.October, 16, 23, 30, 0, 0, berlin), "vibemerge:user-quota:2026-10-16:U123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userQuotaKey(config, "U123", tt.now); got != tt.want {
+				t.Errorf("userQuotaKey(%s) = %q, want %q", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserQuotaApplies(t *testing.T) {
+	tests := []struct {
+		name        string
+		quota       int
+		requestedBy string
+		want        bool
+	}{
+		{"quota and requester", 5, "U123", true},
+		{"no quota", 0, "U123", false},
+		{"no requester", 5, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := MergeJob{RequestedBy: tt.requestedBy}
+			if got := userQuotaApplies(&Config{UserMergeQuota: tt.quota}, job); got != tt.want {
+				t.Errorf("userQuotaApplies = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
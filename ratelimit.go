@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// reserveMergeSlotScript counts the merges in a repository's sliding window and, when there is room,
+// records this one. Over the limit it returns the time the next slot opens, reserving that slot when
+// ARGV[5] is "1" so merges deferred one after another are released one window apart.
+// A slot the merge reserved before, when it was deferred, is given up first so it isn't counted twice.
+// Scores and times are in milliseconds.
+var reserveMergeSlotScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREM', KEYS[1], ARGV[4])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local slot = now
+if count >= limit then
+	local oldest = redis.call('ZRANGE', KEYS[1], count - limit, count - limit, 'WITHSCORES')
+	slot = tonumber(oldest[2]) + window
+	if ARGV[5] ~= '1' then
+		return slot
+	end
+end
+redis.call('ZADD', KEYS[1], slot, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], slot - now + window)
+return slot
+`)
+
+// What MERGE_RATE_MODE does with a merge over its repository's rate limit
+const (
+	MergeRateModeReject = "reject"
+	MergeRateModeDefer  = "defer"
+)
+
+// checkMergeRate rejects an unknown MERGE_RATE_MODE and a MERGE_RATE_WINDOW no merge could be counted in
+func (c *Config) checkMergeRate() error {
+	if c.MergeRateMode != MergeRateModeReject && c.MergeRateMode != MergeRateModeDefer {
+		return fmt.Errorf("MERGE_RATE_MODE must be %q or %q, got %q", MergeRateModeReject, MergeRateModeDefer, c.MergeRateMode)
+	}
+	if c.MergeRateWindow <= 0 {
+		return fmt.Errorf("MERGE_RATE_WINDOW must be positive, got %d", c.MergeRateWindow)
+	}
+	return nil
+}
+
+func mergeRateKey(config *Config, repo string) string {
+	return fmt.Sprintf("%s:%s", config.MergeRateKeyPrefix, repo)
+}
+
+// reserveMergeSlot counts a merge against its repository's rate limit. It reports whether the limit
+// is reached and, if so, when the next merge may run.
+func reserveMergeSlot(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, limit int) (time.Time, bool, error) {
+	if limit <= 0 {
+		return time.Time{}, false, nil
+	}
+
+	now := time.Now()
+	reserve := "0"
+	if config.MergeRateMode == MergeRateModeDefer {
+		reserve = "1"
+	}
+
+	key := mergeRateKey(config, job.Payload.Repo)
+	window := time.Duration(config.MergeRateWindow) * time.Second
+	slot, err := reserveMergeSlotScript.Run(ctx, redisClient, []string{key},
+		now.UnixMilli(), window.Milliseconds(), limit, job.Payload.CorrelationID, reserve).Int64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to check merge rate for %s: %w", job.Payload.Repo, err)
+	}
+
+	if slot <= now.UnixMilli() {
+		return time.Time{}, false, nil
+	}
+	return time.UnixMilli(slot), true, nil
+}
+
+// refundMergeSlot gives back the slot reserveMergeSlot took for a merge that a later gate stopped
+func refundMergeSlot(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) {
+	key := mergeRateKey(config, job.Payload.Repo)
+	if err := redisClient.ZRem(ctx, key, job.Payload.CorrelationID).Err(); err != nil {
+		logWarning("Failed to refund the merge rate slot of PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+	}
+}
+
+// holdForMergeRate defers or rejects a merge that would exceed its repository's rate limit
+func holdForMergeRate(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, limit int, until time.Time) (Decision, error) {
+	resume := until.In(config.Timezone).Format("15:04 MST")
+	window := formatWindow(time.Duration(config.MergeRateWindow) * time.Second)
+
+	if config.MergeRateMode == MergeRateModeDefer {
+		if err := deferMerge(ctx, redisClient, config, job, until); err != nil {
+			return Decision{}, err
+		}
+		logInfo("Deferred merge of PR %d in %s until %s (merge rate limit)", job.PRNumber, job.Payload.Repo, resume)
+		return Decision{
+			Outcome: OutcomeDeferred,
+			Reason:  fmt.Sprintf("merge rate limit of %d per %s until %s", limit, window, resume),
+			Note:    fmt.Sprintf(":traffic_light: %s has had %d merges in the last %s. PR #%d will be merged automatically at %s.", job.Payload.Repo, limit, window, job.PRNumber, resume),
+		}, nil
+	}
+
+	logInfo("Rejected merge of PR %d in %s over the merge rate limit (next slot %s)", job.PRNumber, job.Payload.Repo, resume)
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  fmt.Sprintf("merge rate limit of %d per %s until %s", limit, window, resume),
+		Note:    fmt.Sprintf(":traffic_light: %s has had %d merges in the last %s, so PR #%d was not queued. Please react again after %s.", job.Payload.Repo, limit, window, job.PRNumber, resume),
+	}, nil
+}
+
+// formatWindow renders a rate window without trailing zero units, e.g. "1h" rather than "1h0m0s"
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRateLimitTestConfig() *Config {
+	return &Config{
+		Timezone:           time.UTC,
+		PauseKey:           "vibemerge:paused",
+		DeferredQueue:      "vibemerge:deferred",
+		MergeRateKeyPrefix: "vibemerge:merge-rate",
+		MergeRateLimit:     1,
+		MergeRateWindow:    3600,
+		MergeRateMode:      MergeRateModeDefer,
+	}
+}
+
+func TestFlushDeferredMergesRespectsMergeRate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	config := newRateLimitTestConfig()
+	ctx := context.Background()
+	now := time.Now()
+
+	// Another merge already used the only slot in the window
+	rateKey := mergeRateKey(config, "org/repo")
+	redisClient.ZAdd(ctx, rateKey, redis.Z{Score: float64(now.Add(-time.Minute).UnixMilli()), Member: "corr-earlier"})
+
+	job := MergeJob{PRNumber: 42}
+	job.Payload.Repo = "org/repo"
+	job.Payload.CorrelationID = "corr-deferred"
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisClient.ZAdd(ctx, config.DeferredQueue, redis.Z{Score: float64(now.Add(-time.Second).Unix()), Member: string(jobJSON)})
+
+	if err := flushDeferredMerges(ctx, redisClient, config, now); err != nil {
+		t.Fatalf("flushDeferredMerges() error = %v", err)
+	}
+
+	score, err := redisClient.ZScore(ctx, config.DeferredQueue, string(jobJSON)).Result()
+	if err != nil {
+		t.Fatalf("deferred merge was released over the merge rate limit: %v", err)
+	}
+	if want := now.Add(-time.Minute).Add(time.Hour).Unix(); int64(score) != want {
+		t.Errorf("deferred until %d, want the next slot at %d", int64(score), want)
+	}
+}
+
+func TestReserveMergeSlotRefund(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	config := newRateLimitTestConfig()
+	ctx := context.Background()
+
+	job := MergeJob{PRNumber: 42}
+	job.Payload.Repo = "org/repo"
+	job.Payload.CorrelationID = "corr-1"
+
+	if _, limited, err := reserveMergeSlot(ctx, redisClient, config, job, 1); err != nil || limited {
+		t.Fatalf("reserveMergeSlot() = %v, %v, want a free slot", limited, err)
+	}
+	// Reserving again for the same merge, as a flushed deferred merge does, doesn't count it twice
+	if _, limited, err := reserveMergeSlot(ctx, redisClient, config, job, 1); err != nil || limited {
+		t.Fatalf("reserveMergeSlot() again = %v, %v, want the merge's own slot", limited, err)
+	}
+
+	other := job
+	other.Payload.CorrelationID = "corr-2"
+	if _, limited, _ := reserveMergeSlot(ctx, redisClient, config, other, 1); !limited {
+		t.Fatal("second merge in the window was not limited")
+	}
+	// The limited merge reserved the next slot in defer mode, so give both back
+	refundMergeSlot(ctx, redisClient, config, job)
+	refundMergeSlot(ctx, redisClient, config, other)
+	if count := redisClient.ZCard(ctx, mergeRateKey(config, "org/repo")).Val(); count != 0 {
+		t.Errorf("%d slots left after refunds, want 0", count)
+	}
+}
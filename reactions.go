@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Actions an emoji in REACTIONS_FILE can take on a PR
+const (
+	ReactionAddLabel      = "add_label"
+	ReactionRemoveLabel   = "remove_label"
+	ReactionComment       = "comment"
+	ReactionRequestReview = "request_review"
+)
+
+// ReactionAction is one parameterized action an emoji takes on a PR, e.g.
+// {"action": "add_label", "labels": ["hotfix"]} or {"action": "comment", "comment": "Taking a look"}
+type ReactionAction struct {
+	Action  string   `json:"action"`
+	Labels  []string `json:"labels,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+	// Reviewers are GitHub logins or org/team slugs, with or without a leading @
+	Reviewers []string `json:"reviewers,omitempty"`
+	// Params are passed to a plugin action as they are
+	Params map[string]any `json:"params,omitempty"`
+
+	comment *template.Template
+	plugin  Action
+}
+
+// ReactionActions maps an emoji to the actions it takes, in order
+type ReactionActions map[string][]ReactionAction
+
+// loadReactionActions reads the per-emoji actions file and merges in COMMENTS_FILE, whose entries are comment actions.
+// Besides the built-in actions, an emoji can take any of plugins.
+func loadReactionActions(path string, comments CommentTemplates, plugins map[string]Action) (ReactionActions, error) {
+	actions := make(ReactionActions)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read REACTIONS_FILE: %w", err)
+		}
+		if err := json.Unmarshal(data, &actions); err != nil {
+			return nil, fmt.Errorf("failed to parse REACTIONS_FILE: %w", err)
+		}
+	}
+
+	for emoji, list := range actions {
+		if len(list) == 0 {
+			return nil, fmt.Errorf("REACTIONS_FILE: no actions for emoji %q", emoji)
+		}
+		for i := range list {
+			if err := list[i].parse(emoji, plugins); err != nil {
+				return nil, fmt.Errorf("REACTIONS_FILE: %w", err)
+			}
+		}
+	}
+	for emoji, comment := range comments {
+		if _, ok := actions[emoji]; ok {
+			return nil, fmt.Errorf("emoji %q is in both COMMENTS_FILE and REACTIONS_FILE", emoji)
+		}
+		actions[emoji] = []ReactionAction{{Action: ReactionComment, comment: comment}}
+	}
+	return actions, nil
+}
+
+// parse checks an action's parameters and parses its comment template
+func (a *ReactionAction) parse(emoji string, plugins map[string]Action) error {
+	switch a.Action {
+	case ReactionAddLabel, ReactionRemoveLabel:
+		if len(a.Labels) == 0 {
+			return fmt.Errorf("%s for emoji %q needs labels", a.Action, emoji)
+		}
+		for _, label := range a.Labels {
+			if strings.TrimSpace(label) == "" || strings.Contains(label, ",") {
+				return fmt.Errorf("invalid label %q for emoji %q", label, emoji)
+			}
+		}
+	case ReactionComment:
+		if strings.TrimSpace(a.Comment) == "" {
+			return fmt.Errorf("comment for emoji %q needs a comment", emoji)
+		}
+		tmpl, err := template.New(emoji).Option("missingkey=error").Parse(a.Comment)
+		if err != nil {
+			return fmt.Errorf("invalid comment template for emoji %q: %w", emoji, err)
+		}
+		a.comment = tmpl
+	case ReactionRequestReview:
+		if len(a.Reviewers) == 0 {
+			return fmt.Errorf("%s for emoji %q needs reviewers", a.Action, emoji)
+		}
+		for i, reviewer := range a.Reviewers {
+			reviewer = strings.TrimPrefix(strings.TrimSpace(reviewer), "@")
+			if reviewer == "" || strings.ContainsAny(reviewer, ", ") {
+				return fmt.Errorf("invalid reviewer %q for emoji %q", a.Reviewers[i], emoji)
+			}
+			a.Reviewers[i] = reviewer
+		}
+	default:
+		plugin, ok := plugins[a.Action]
+		if !ok {
+			return fmt.Errorf("unknown action %q for emoji %q, expected add_label, remove_label, comment, request_review or a plugin action", a.Action, emoji)
+		}
+		a.plugin = plugin
+	}
+	return nil
+}
+
+// command renders the Poppit command that takes an action on a PR
+func (a ReactionAction) command(ctx context.Context, slackClient *slack.Client, metadata *PRMetadata, slackUser string) (string, error) {
+	switch a.Action {
+	case ReactionAddLabel:
+		return fmt.Sprintf("gh pr --repo %s edit %d --add-label %s", metadata.Repository, metadata.PRNumber, shellQuote(strings.Join(a.Labels, ","))), nil
+	case ReactionRemoveLabel:
+		return fmt.Sprintf("gh pr --repo %s edit %d --remove-label %s", metadata.Repository, metadata.PRNumber, shellQuote(strings.Join(a.Labels, ","))), nil
+	case ReactionRequestReview:
+		return fmt.Sprintf("gh pr --repo %s edit %d --add-reviewer %s", metadata.Repository, metadata.PRNumber, shellQuote(strings.Join(a.Reviewers, ","))), nil
+	default:
+		return commentCommand(ctx, slackClient, a.comment, metadata, slackUser)
+	}
+}
+
+// checkReactionEmoji rejects action emoji that already do something else, since a reaction runs one pipeline only
+func (c *Config) checkReactionEmoji() error {
+	for emoji := range c.Reactions {
+		switch emoji {
+		case c.TargetEmoji, c.ReadyEmoji, c.ApproveEmoji, c.CloseEmoji, c.RevertEmoji, c.CancelEmoji, c.SizeOverrideEmoji:
+			return fmt.Errorf("emoji %q is already a merge, ready, approve, close, revert, cancel or size override emoji", emoji)
+		}
+		if _, ok := c.Commands[emoji]; ok {
+			return fmt.Errorf("emoji %q has actions and commands in COMMANDS_FILE", emoji)
+		}
+		if target, ok := c.EmojiAliases[emoji]; ok {
+			return fmt.Errorf("emoji %q has actions but is an alias of %q", emoji, target)
+		}
+	}
+	return nil
+}
+
+// reactionCommands renders the Poppit commands for an emoji's actions on a PR
+func reactionCommands(ctx context.Context, slackClient *slack.Client, actions []ReactionAction, metadata *PRMetadata, slackUser string) ([]string, error) {
+	commands := make([]string, 0, len(actions))
+	for _, action := range actions {
+		if action.plugin != nil {
+			pluginCommands, err := action.plugin.Commands(ctx, metadata, slackUser, action.Params)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, pluginCommands...)
+			continue
+		}
+		command, err := action.command(ctx, slackClient, metadata, slackUser)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+// requestReactionActions follows requestPR for an emoji from REACTIONS_FILE or COMMENTS_FILE, queuing the commands
+// that take its actions on the PR
+func requestReactionActions(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, reactionEvent ReactionEvent, metadata *PRMetadata, actions []ReactionAction, audit *AuditEntry) (Decision, error) {
+	audit.Source = "action"
+	requested := withGitHubLogin(ctx, redisClient, slackClient, config, metadata, reactionEvent.Event.User)
+	job, err := newMergeJob(config, requested, nil, reactionEvent.TeamID, reactionEvent.Event.User, reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts)
+	if err != nil {
+		return Decision{}, err
+	}
+	job.Payload.Commands, err = reactionCommands(ctx, slackClient, actions, requested, reactionEvent.Event.User)
+	if err != nil {
+		return Decision{}, err
+	}
+	audit.GitHubUser = job.Payload.GitHubUser
+	audit.CorrelationID = job.Payload.CorrelationID
+	return submitReactionActions(ctx, redisClient, config, job)
+}
+
+// submitReactionActions hands Poppit the commands of an emoji's actions
+func submitReactionActions(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, error) {
+	return submitPRCommand(ctx, redisClient, config, job, "action", true)
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// submitReady hands Poppit the commands that mark a draft PR ready for review, leaving the Slack message in place
+func submitReady(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, error) {
+	return submitPRCommand(ctx, redisClient, config, job, "ready for review", true)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// loadRedisTLSConfig builds the TLS settings for the Redis connection, or returns nil when TLS is disabled
+func loadRedisTLSConfig(config *Config) (*tls.Config, error) {
+	if !config.RedisTLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.RedisTLSInsecureSkipVerify,
+	}
+
+	if config.RedisTLSCAFile != "" {
+		caPEM, err := os.ReadFile(config.RedisTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read REDIS_TLS_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in REDIS_TLS_CA_FILE %s", config.RedisTLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// A client certificate is only needed for Redis servers that require mutual TLS
+	if config.RedisTLSCertFile != "" || config.RedisTLSKeyFile != "" {
+		if config.RedisTLSCertFile == "" || config.RedisTLSKeyFile == "" {
+			return nil, fmt.Errorf("REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(config.RedisTLSCertFile, config.RedisTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Version schemes of a release step
+const (
+	ReleaseSchemeDate   = "date"
+	ReleaseSchemeSemver = "semver"
+)
+
+// defaultReleaseCommands tag the tip of the PR's target branch, which includes the PR once a merge step has run, and
+// publish a GitHub release for the tag
+var defaultReleaseCommands = []string{
+	"git fetch origin {{.Branch}}",
+	`git tag -a {{.Tag}} -m "Release {{.Tag}}" FETCH_HEAD`,
+	"git push origin {{.Tag}}",
+	"gh release create {{.Tag}} --repo {{.Repository}} --verify-tag --generate-notes --title {{.Tag}}",
+}
+
+// ReleaseStep is what a release step needs once its turn comes, when the version is picked and its commands are
+// rendered
+type ReleaseStep struct {
+	Scheme      string   `json:"scheme"`
+	TagPrefix   string   `json:"tag_prefix"`
+	DateFormat  string   `json:"date_format,omitempty"`
+	MajorLabels []string `json:"major_labels,omitempty"`
+	MinorLabels []string `json:"minor_labels,omitempty"`
+	// Commands are templates executed with a ReleaseTag
+	Commands []string `json:"commands"`
+}
+
+// ReleaseTag is the data a release step's command templates are executed with
+type ReleaseTag struct {
+	Repository string
+	PRNumber   int
+	// Branch is the branch the PR merges into, which is tagged
+	Branch string
+	// Version is the new version, and Tag the version with the step's tag_prefix
+	Version string
+	Tag     string
+	// PreviousTag is the tag the version was worked out from, or "" for a first release
+	PreviousTag string
+}
+
+// parseRelease checks a release step's parameters and that its command templates only use ReleaseTag's fields
+func (s *WorkflowStep) parseRelease(name string) error {
+	switch s.Scheme {
+	case ReleaseSchemeSemver:
+		if s.DateFormat != "" {
+			return fmt.Errorf("step %s: date_format only applies to the date scheme", name)
+		}
+		if len(s.MajorLabels) == 0 {
+			s.MajorLabels = []string{"major", "breaking"}
+		}
+		if len(s.MinorLabels) == 0 {
+			s.MinorLabels = []string{"minor", "feature"}
+		}
+	case ReleaseSchemeDate:
+		if len(s.MajorLabels) > 0 || len(s.MinorLabels) > 0 {
+			return fmt.Errorf("step %s: major_labels and minor_labels only apply to the semver scheme", name)
+		}
+		if s.DateFormat == "" {
+			s.DateFormat = "2006.01.02"
+		}
+		// A layout without any date elements would give every release the same version
+		reference := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+		if reference.Format(s.DateFormat) == s.DateFormat || strings.ContainsAny(reference.Format(s.DateFormat), " /") {
+			return fmt.Errorf("step %s: invalid date_format %q", name, s.DateFormat)
+		}
+	default:
+		return fmt.Errorf("step %s: unknown scheme %q, expected date or semver", name, s.Scheme)
+	}
+	if s.TagPrefix == nil {
+		prefix := "v"
+		s.TagPrefix = &prefix
+	}
+
+	for _, command := range s.Commands {
+		tmpl, err := template.New(name).Option("missingkey=error").Funcs(deployFuncs).Parse(command)
+		if err != nil {
+			return fmt.Errorf("step %s: invalid command template: %w", name, err)
+		}
+		if err := tmpl.Execute(io.Discard, ReleaseTag{}); err != nil {
+			return fmt.Errorf("step %s: invalid command template: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// releaseStep copies a release step's parameters into the run, to be acted on when its turn comes
+func (s *WorkflowStep) releaseStep() *ReleaseStep {
+	commands := s.Commands
+	if len(commands) == 0 {
+		commands = defaultReleaseCommands
+	}
+	return &ReleaseStep{
+		Scheme:      s.Scheme,
+		TagPrefix:   *s.TagPrefix,
+		DateFormat:  s.DateFormat,
+		MajorLabels: s.MajorLabels,
+		MinorLabels: s.MinorLabels,
+		Commands:    commands,
+	}
+}
+
+// checkReleaseSteps rejects release steps without GITHUB_TOKEN or a GitHub App to read tags and labels with
+func (c *Config) checkReleaseSteps() error {
+	for emoji, steps := range c.Workflows {
+		for _, step := range steps {
+			if step.Type == WorkflowStepRelease && !c.hasGitHubAPI() {
+				return fmt.Errorf("the release step of the %q workflow requires GITHUB_TOKEN or a GitHub App", emoji)
+			}
+		}
+	}
+	return nil
+}
+
+// releaseCommands picks the version a release step tags the PR's target branch with, from the repository's tags
+// and, for the semver scheme, the PR's labels, and renders the step's commands with it
+func releaseCommands(ctx context.Context, config *Config, job MergeJob, release ReleaseStep) ([]string, error) {
+	repo := job.Payload.Repo
+	var refs []struct {
+		Ref string `json:"ref"`
+	}
+	path := fmt.Sprintf("/repos/%s/git/matching-refs/tags/%s", repo, release.TagPrefix)
+	if err := githubRequest(ctx, config, http.MethodGet, strings.TrimSuffix(path, "/"), nil, &refs); err != nil {
+		return nil, fmt.Errorf("failed to list the tags of %s: %w", repo, err)
+	}
+	var tags []string
+	for _, ref := range refs {
+		tags = append(tags, strings.TrimPrefix(ref.Ref, "refs/tags/"))
+	}
+
+	var labels []string
+	if release.Scheme == ReleaseSchemeSemver {
+		pr, err := getPullRequest(ctx, config, repo, job.PRNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PR %d in %s: %w", job.PRNumber, repo, err)
+		}
+		for _, label := range pr.Labels {
+			labels = append(labels, label.Name)
+		}
+	}
+
+	version, previous := nextReleaseVersion(release, tags, labels, time.Now())
+	tag := ReleaseTag{
+		Repository:  repo,
+		PRNumber:    job.PRNumber,
+		Branch:      job.Payload.Branch,
+		Version:     version,
+		Tag:         release.TagPrefix + version,
+		PreviousTag: previous,
+	}
+	var commands []string
+	for _, command := range release.Commands {
+		tmpl, err := template.New("release").Option("missingkey=error").Funcs(deployFuncs).Parse(command)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release command template: %w", err)
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, tag); err != nil {
+			return nil, fmt.Errorf("failed to render release command: %w", err)
+		}
+		commands = append(commands, b.String())
+	}
+	logInfo("Releasing %s in %s as %s (previous tag %q)", job.Payload.Branch, repo, tag.Tag, previous)
+	return commands, nil
+}
+
+// nextReleaseVersion returns the version after the existing tags, and the tag it follows.
+//
+// The semver scheme bumps the highest X.Y.Z tag's major version when the PR has one of major_labels, its minor
+// version for one of minor_labels and its patch version otherwise, starting from 0.0.0. The date scheme uses today's
+// date, adding .1, .2 and so on for further releases the same day.
+func nextReleaseVersion(release ReleaseStep, tags, labels []string, now time.Time) (string, string) {
+	var previous string
+	if release.Scheme == ReleaseSchemeDate {
+		date := now.UTC().Format(release.DateFormat)
+		count := -1
+		for _, tag := range tags {
+			rest, ok := strings.CutPrefix(tag, release.TagPrefix+date)
+			if !ok {
+				continue
+			}
+			n := 0
+			if rest != "" {
+				suffix, ok := strings.CutPrefix(rest, ".")
+				var err error
+				if n, err = strconv.Atoi(suffix); !ok || err != nil || n < 1 {
+					continue
+				}
+			}
+			if n > count {
+				count, previous = n, tag
+			}
+		}
+		if count < 0 {
+			return date, previous
+		}
+		return fmt.Sprintf("%s.%d", date, count+1), previous
+	}
+
+	var latest [3]int
+	for _, tag := range tags {
+		version, ok := parseSemver(strings.TrimPrefix(tag, release.TagPrefix))
+		if ok && (previous == "" || slices.Compare(version[:], latest[:]) > 0) {
+			latest, previous = version, tag
+		}
+	}
+	hasLabel := func(names []string) bool {
+		return slices.ContainsFunc(labels, func(label string) bool {
+			return slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(label, name) })
+		})
+	}
+	switch {
+	case hasLabel(release.MajorLabels):
+		latest = [3]int{latest[0] + 1, 0, 0}
+	case hasLabel(release.MinorLabels):
+		latest = [3]int{latest[0], latest[1] + 1, 0}
+	default:
+		latest[2]++
+	}
+	return fmt.Sprintf("%d.%d.%d", latest[0], latest[1], latest[2]), previous
+}
+
+// parseSemver parses a plain X.Y.Z version; pre-releases and build metadata aren't release tags
+func parseSemver(version string) ([3]int, bool) {
+	var parsed [3]int
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
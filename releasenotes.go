@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// defaultReleaseNoteTemplate renders a release-notes entry unless RELEASE_NOTES_TEMPLATE or the repository sets one
+const defaultReleaseNoteTemplate = `{{.Title}} (#{{.PRNumber}}) by @{{.Author}}{{with .Labels}} [{{join . ", "}}]{{end}}`
+
+// releaseNoteFuncs are the functions available to release-notes templates
+var releaseNoteFuncs = template.FuncMap{"join": strings.Join}
+
+// ReleaseNotesConfig says where the release-notes entries of merged PRs go, a Slack channel, a Redis channel or both,
+// and how they are written. A repository's fields override RELEASE_NOTES_SLACK_CHANNEL, RELEASE_NOTES_CHANNEL and
+// RELEASE_NOTES_TEMPLATE one by one.
+type ReleaseNotesConfig struct {
+	SlackChannel string `json:"slack_channel,omitempty"`
+	Channel      string `json:"channel,omitempty"`
+	// Template is executed with a ReleaseNote
+	Template string `json:"template,omitempty"`
+
+	template *template.Template
+}
+
+// ReleaseNote is the release-notes entry of a merged PR, published as JSON to the Redis channel
+type ReleaseNote struct {
+	Repository string    `json:"repository"`
+	PRNumber   int       `json:"pr_number"`
+	Title      string    `json:"title"`
+	Author     string    `json:"author"`
+	Labels     []string  `json:"labels"`
+	URL        string    `json:"url"`
+	SHA        string    `json:"sha,omitempty"`
+	MergedAt   time.Time `json:"merged_at"`
+	// Text is the entry rendered from the template
+	Text string `json:"text"`
+}
+
+// parse parses the release-notes template, if one is set, rendering it once so unknown fields are caught now rather
+// than after a merge
+func (r *ReleaseNotesConfig) parse() error {
+	if r.Template == "" {
+		return nil
+	}
+	tmpl, err := template.New("release_notes").Option("missingkey=error").Funcs(releaseNoteFuncs).Parse(r.Template)
+	if err != nil {
+		return fmt.Errorf("invalid release notes template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, ReleaseNote{}); err != nil {
+		return fmt.Errorf("invalid release notes template: %w", err)
+	}
+	r.template = tmpl
+	return nil
+}
+
+// enabled reports whether release-notes entries are published anywhere
+func (r ReleaseNotesConfig) enabled() bool {
+	return r.SlackChannel != "" || r.Channel != ""
+}
+
+// checkReleaseNotes rejects release notes without GITHUB_TOKEN or a GitHub App to read the PR's details with
+func (c *Config) checkReleaseNotes() error {
+	enabled := c.ReleaseNotes.enabled()
+	for _, repo := range c.Repos {
+		enabled = enabled || (repo.ReleaseNotes != nil && repo.ReleaseNotes.enabled())
+	}
+	if enabled && !c.hasGitHubAPI() {
+		return fmt.Errorf("RELEASE_NOTES_SLACK_CHANNEL, RELEASE_NOTES_CHANNEL and release_notes require GITHUB_TOKEN or a GitHub App")
+	}
+	return nil
+}
+
+// releaseNotes resolves a repository's release-notes settings from its release_notes and the global configuration
+func (c *Config) releaseNotes(repo string) ReleaseNotesConfig {
+	settings := c.ReleaseNotes
+	override := c.Repos[repo].ReleaseNotes
+	if override == nil {
+		return settings
+	}
+	if override.SlackChannel != "" {
+		settings.SlackChannel = override.SlackChannel
+	}
+	if override.Channel != "" {
+		settings.Channel = override.Channel
+	}
+	if override.template != nil {
+		settings.Template, settings.template = override.Template, override.template
+	}
+	return settings
+}
+
+// render writes the entry's text with the template
+func (r ReleaseNotesConfig) render(note ReleaseNote) (string, error) {
+	var b bytes.Buffer
+	if err := r.template.Execute(&b, note); err != nil {
+		return "", fmt.Errorf("failed to render release notes: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// publishReleaseNote publishes the release-notes entry of a merge Poppit completed, when its repository has release
+// notes. The PR's title, author and labels are read from GitHub.
+func publishReleaseNote(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, result PoppitResult) error {
+	notes := config.releaseNotes(result.Repo)
+	if !notes.enabled() {
+		return nil
+	}
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		return err
+	}
+	// Ready, approve, close, revert and action results don't merge anything
+	if !found || !mergeRequest(requested) || requested.PRNumber == 0 {
+		return nil
+	}
+
+	pr, err := getPullRequest(ctx, config, result.Repo, requested.PRNumber)
+	if err != nil {
+		return fmt.Errorf("failed to read PR %d in %s: %w", requested.PRNumber, result.Repo, err)
+	}
+	note := ReleaseNote{
+		Repository: result.Repo,
+		PRNumber:   pr.Number,
+		Title:      pr.Title,
+		Author:     pr.User.Login,
+		Labels:     []string{},
+		URL:        pr.HTMLURL,
+		SHA:        result.SHA,
+		MergedAt:   time.Now().UTC(),
+	}
+	for _, label := range pr.Labels {
+		note.Labels = append(note.Labels, label.Name)
+	}
+	if note.Text, err = notes.render(note); err != nil {
+		return err
+	}
+
+	if notes.Channel != "" {
+		noteJSON, err := json.Marshal(note)
+		if err != nil {
+			return fmt.Errorf("failed to marshal release note: %w", err)
+		}
+		if err := serviceNotifier(redisClient).Publish(ctx, notes.Channel, string(noteJSON)); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", notes.Channel, err)
+		}
+	}
+	if notes.SlackChannel != "" {
+		slackClient := clients.forWorkspace(config.workspace(requested.TeamID))
+		if slackClient == nil {
+			return fmt.Errorf("no Slack client for workspace %s", requested.TeamID)
+		}
+		err := callSlack(ctx, "chat.postMessage", func() error {
+			_, _, err := slackClient.PostMessageContext(ctx, notes.SlackChannel, slack.MsgOptionText(note.Text, false))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to post release notes to %s: %w", notes.SlackChannel, err)
+		}
+	}
+	logInfo("Published release notes for PR %d in %s", note.PRNumber, result.Repo)
+	return nil
+}
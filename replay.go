@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// streamIDPattern matches a Redis stream ID, or the millisecond timestamp it starts with
+var streamIDPattern = regexp.MustCompile(`^\d+(-\d+)?$`)
+
+// replaySources are the audit sources recorded for reactions, which can be replayed
+var replaySources = []string{"reaction", "ready", "approve", "action", "close", "revert", "workflow", "cancel", "digest"}
+
+// runReplayCommand implements `vibemerge replay`, handling reactions again after an outage. Reactions come from
+// the audit stream, starting at a stream ID, or from a file of JSON lines holding either reaction events as relayed
+// on slack-relay-reaction-added or audit entries as printed by `vibemerge audit`.
+func runReplayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	from := flags.String("from", "", "audit stream ID to replay from, or a file of reaction events or audit entries")
+	dryRun := flags.Bool("dry-run", false, "print the reaction events that would be replayed without handling them")
+	flags.Parse(args)
+
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	activeConfig.Store(config)
+	currentLogLevel.Store(int32(parseLogLevel(config.LogLevel)))
+	configureSlackLimiter(config)
+
+	ctx := context.Background()
+	redisClient := newRedisClient(config)
+	defer redisClient.Close()
+
+	var payloads []string
+	if streamIDPattern.MatchString(*from) {
+		payloads, err = replayFromStream(ctx, redisClient, config, *from)
+	} else {
+		payloads, err = replayFromFile(*from)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, payload := range payloads {
+			fmt.Println(payload)
+		}
+		return nil
+	}
+
+	if config.SlackBotToken == "" && len(config.Workspaces) == 0 {
+		return fmt.Errorf("SLACK_BOT_TOKEN environment variable is required")
+	}
+	closeTransport, err := openTransport(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer closeTransport()
+	if err := slackTokens.start(ctx, redisClient, config); err != nil {
+		return fmt.Errorf("failed to load the rotated Slack token: %w", err)
+	}
+	if config.StoreDriver != "" {
+		sqlStore, err := openStore(ctx, config)
+		if err != nil {
+			return fmt.Errorf("failed to open the durable store: %w", err)
+		}
+		defer sqlStore.Close()
+		store = sqlStore
+	}
+	clients := newSlackClients()
+
+	var failed int
+	for _, payload := range payloads {
+		eventCtx, cancel := eventContext(ctx, config)
+		err := handleReactionMessage(eventCtx, payload, redisClient, clients, config)
+		cancel()
+		if err != nil {
+			logError("Error replaying reaction %s: %v", payload, err)
+			failed++
+		}
+	}
+
+	logInfo("Replayed %d reactions, %d failed", len(payloads), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d reactions failed again", failed, len(payloads))
+	}
+	return nil
+}
+
+// replayFromStream returns the failed reactions in the audit stream from the given ID onwards
+func replayFromStream(ctx context.Context, redisClient *redis.Client, config *Config, from string) ([]string, error) {
+	messages, err := redisClient.XRange(ctx, config.AuditStream, from, "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", config.AuditStream, err)
+	}
+
+	entries := make([]AuditEntry, 0, len(messages))
+	for _, msg := range messages {
+		entries = append(entries, parseAuditEntry(msg))
+	}
+	return failedReactions(entries, config)
+}
+
+// replayFromFile reads reaction events and audit entries from a file of JSON lines. Reaction events are replayed
+// as they are, and audit entries when they record a failed reaction.
+func replayFromFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var payloads []string
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if _, ok := fields["event"]; ok {
+			payloads = append(payloads, string(data))
+			continue
+		}
+
+		var entry AuditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	reactions, err := failedReactions(entries, currentConfig())
+	if err != nil {
+		return nil, err
+	}
+	return append(payloads, reactions...), nil
+}
+
+// failedReactions rebuilds the reaction events whose audit entries all failed, in the order they were first
+// recorded. A digest records an entry per PR, so a digest with any PR that went through isn't replayed, as that
+// would merge those PRs again.
+func failedReactions(entries []AuditEntry, config *Config) ([]string, error) {
+	type reactionKey struct {
+		TeamID, User, Channel, Ts, Reaction string
+	}
+
+	var order []reactionKey
+	first := make(map[reactionKey]AuditEntry)
+	failed := make(map[reactionKey]bool)
+	for _, entry := range entries {
+		if !slices.Contains(replaySources, entry.Source) {
+			continue
+		}
+		key := reactionKey{entry.TeamID, entry.User, entry.Channel, entry.Ts, entry.Reaction}
+		if _, seen := first[key]; !seen {
+			order = append(order, key)
+			first[key] = entry
+			failed[key] = true
+		}
+		if entry.Decision != OutcomeFailed {
+			failed[key] = false
+		}
+	}
+
+	var payloads []string
+	for _, key := range order {
+		if !failed[key] {
+			continue
+		}
+		event := auditReactionEvent(first[key], config)
+		if event.Event.Reaction == "" {
+			logWarning("Not replaying %s reaction by %s on %s/%s, its emoji is no longer configured", first[key].Source, key.User, key.Channel, key.Ts)
+			continue
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal reaction event: %w", err)
+		}
+		payloads = append(payloads, string(payload))
+	}
+	return payloads, nil
+}
+
+// auditReactionEvent rebuilds the reaction event an audit entry was recorded for. Entries written before the
+// reaction was recorded get the workspace's emoji for the entry's source.
+func auditReactionEvent(entry AuditEntry, config *Config) ReactionEvent {
+	reaction := entry.Reaction
+	if reaction == "" {
+		workspace := config.workspace(entry.TeamID)
+		switch entry.Source {
+		case "ready":
+			reaction = workspace.ReadyEmoji
+		case "approve":
+			reaction = workspace.ApproveEmoji
+		case "close":
+			reaction = workspace.CloseEmoji
+		case "revert":
+			reaction = workspace.RevertEmoji
+		case "cancel":
+			reaction = workspace.CancelEmoji
+		default:
+			reaction = workspace.TargetEmoji
+		}
+	}
+
+	var event ReactionEvent
+	event.Type = "event_callback"
+	event.TeamID = entry.TeamID
+	if !entry.EventTime.IsZero() {
+		event.EventTime = entry.EventTime.Unix()
+	}
+	event.Event.Type = "reaction_added"
+	event.Event.User = entry.User
+	event.Event.Reaction = reaction
+	event.Event.Item.Type = "message"
+	event.Event.Item.Channel = entry.Channel
+	event.Event.Item.Ts = entry.Ts
+	return event
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RepoConfig holds settings that can be overridden per repository. Unset fields fall back to the global configuration.
+type RepoConfig struct {
+	AllowSelfMerge *bool `json:"allow_self_merge,omitempty"`
+	MergeRateLimit *int  `json:"merge_rate_limit,omitempty"`
+	TimeBombTTL    *int  `json:"timebomb_ttl,omitempty"`
+	// TargetBranch is the repository's default branch, used when a PR's message has no base_branch
+	TargetBranch *string `json:"target_branch,omitempty"`
+	// DeleteBranch deletes a PR's branch once VibeMerge has merged it
+	DeleteBranch *bool `json:"delete_branch,omitempty"`
+	// MaxPRSize is the most lines a PR may change to be merged from Slack, 0 for no limit
+	MaxPRSize *int `json:"max_pr_size,omitempty"`
+	// RequiredLabels must all be on a PR, and none of BlockedLabels, for it to be merged
+	RequiredLabels []string `json:"required_labels,omitempty"`
+	BlockedLabels  []string `json:"blocked_labels,omitempty"`
+	// MaxMessageAge is the age in seconds past which reactions on the repository's messages are ignored, 0 for none
+	MaxMessageAge *int `json:"max_message_age,omitempty"`
+	// Deploy is sent once Poppit reports a PR merged, to kick off a deployment
+	Deploy *DeployConfig `json:"deploy,omitempty"`
+	// ReleaseNotes overrides where and how the release-notes entries of the repository's merged PRs are published
+	ReleaseNotes *ReleaseNotesConfig `json:"release_notes,omitempty"`
+	// Commands overrides the command templates per emoji for the repository
+	Commands map[string][]string `json:"commands,omitempty"`
+	// PoppitQueue and WorkDir route the repository's commands to a Poppit worker fleet of its own
+	PoppitQueue *string `json:"poppit_queue,omitempty"`
+	WorkDir     *string `json:"work_dir,omitempty"`
+	// Paths apply sub-team rules to PRs of a monorepo by the files they change, see paths.go
+	Paths []PathConfig `json:"paths,omitempty"`
+
+	commands CommandTemplates
+}
+
+// RepoSettings is the effective configuration for a single repository
+type RepoSettings struct {
+	AllowSelfMerge bool
+	MergeRateLimit int
+	TimeBombTTL    int
+	TargetBranch   string
+	DeleteBranch   bool
+	MaxPRSize      int
+	RequiredLabels []string
+	BlockedLabels  []string
+	MaxMessageAge  int
+	Deploy         *DeployConfig
+	PoppitQueue    string
+	WorkDir        string
+}
+
+// loadRepoConfigs reads the per-repository overrides file, keyed by owner/name
+func loadRepoConfigs(path string) (map[string]RepoConfig, error) {
+	repos := make(map[string]RepoConfig)
+	if path == "" {
+		return repos, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read REPO_CONFIG_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return nil, fmt.Errorf("failed to parse REPO_CONFIG_FILE: %w", err)
+	}
+
+	for name, repo := range repos {
+		if repo.TimeBombTTL != nil && *repo.TimeBombTTL <= 0 {
+			return nil, fmt.Errorf("REPO_CONFIG_FILE %s: timebomb_ttl must be positive, got %d", name, *repo.TimeBombTTL)
+		}
+		if repo.MaxMessageAge != nil && *repo.MaxMessageAge < 0 {
+			return nil, fmt.Errorf("REPO_CONFIG_FILE %s: max_message_age must not be negative, got %d", name, *repo.MaxMessageAge)
+		}
+		if repo.TargetBranch != nil && strings.TrimSpace(*repo.TargetBranch) == "" {
+			return nil, fmt.Errorf("REPO_CONFIG_FILE %s: target_branch must not be empty", name)
+		}
+		if repo.PoppitQueue != nil && strings.TrimSpace(*repo.PoppitQueue) == "" {
+			return nil, fmt.Errorf("REPO_CONFIG_FILE %s: poppit_queue must not be empty", name)
+		}
+		if repo.WorkDir != nil && strings.TrimSpace(*repo.WorkDir) == "" {
+			return nil, fmt.Errorf("REPO_CONFIG_FILE %s: work_dir must not be empty", name)
+		}
+		for _, entry := range repo.Paths {
+			if err := entry.check(); err != nil {
+				return nil, fmt.Errorf("REPO_CONFIG_FILE %s: %w", name, err)
+			}
+		}
+		if repo.Deploy != nil {
+			if err := repo.Deploy.parse(); err != nil {
+				return nil, fmt.Errorf("REPO_CONFIG_FILE %s: %w", name, err)
+			}
+		}
+		if repo.ReleaseNotes != nil {
+			if err := repo.ReleaseNotes.parse(); err != nil {
+				return nil, fmt.Errorf("REPO_CONFIG_FILE %s: %w", name, err)
+			}
+		}
+		commands, err := parseCommandTemplates(repo.Commands)
+		if err != nil {
+			return nil, fmt.Errorf("REPO_CONFIG_FILE %s: %w", name, err)
+		}
+		repo.commands = commands
+		repos[name] = repo
+	}
+	return repos, nil
+}
+
+// repoSettings resolves the settings for a repository from its overrides and the global configuration
+func (c *Config) repoSettings(repo string) RepoSettings {
+	settings := RepoSettings{
+		AllowSelfMerge: c.AllowSelfMerge,
+		MergeRateLimit: c.MergeRateLimit,
+		TimeBombTTL:    c.TimeBombTTL,
+		TargetBranch:   c.TargetBranch,
+		DeleteBranch:   c.DeleteBranch,
+		MaxPRSize:      c.MaxPRSize,
+		RequiredLabels: c.RequiredLabels,
+		BlockedLabels:  c.BlockedLabels,
+		MaxMessageAge:  c.MaxMessageAge,
+		PoppitQueue:    c.PoppitQueue,
+		WorkDir:        c.WorkDir,
+	}
+
+	override, ok := c.Repos[repo]
+	if !ok {
+		return settings
+	}
+	if override.AllowSelfMerge != nil {
+		settings.AllowSelfMerge = *override.AllowSelfMerge
+	}
+	if override.MergeRateLimit != nil {
+		settings.MergeRateLimit = *override.MergeRateLimit
+	}
+	if override.TimeBombTTL != nil {
+		settings.TimeBombTTL = *override.TimeBombTTL
+	}
+	if override.TargetBranch != nil {
+		settings.TargetBranch = *override.TargetBranch
+	}
+	if override.DeleteBranch != nil {
+		settings.DeleteBranch = *override.DeleteBranch
+	}
+	if override.MaxPRSize != nil {
+		settings.MaxPRSize = *override.MaxPRSize
+	}
+	if override.RequiredLabels != nil {
+		settings.RequiredLabels = override.RequiredLabels
+	}
+	if override.BlockedLabels != nil {
+		settings.BlockedLabels = override.BlockedLabels
+	}
+	if override.MaxMessageAge != nil {
+		settings.MaxMessageAge = *override.MaxMessageAge
+	}
+	if override.PoppitQueue != nil {
+		settings.PoppitQueue = *override.PoppitQueue
+	}
+	if override.WorkDir != nil {
+		settings.WorkDir = *override.WorkDir
+	}
+	settings.Deploy = override.Deploy
+	return settings
+}
+
+// poppitQueue resolves the Poppit queue a repository's commands are pushed to: its poppit_queue, then POPPIT_QUEUE
+func (c *Config) poppitQueue(repo string) string {
+	return c.repoSettings(repo).PoppitQueue
+}
+
+// poppitQueues lists every queue Poppit commands can be pushed to: POPPIT_QUEUE, then the PRIORITY_QUEUES and the
+// poppit_queue of the repositories and their paths, sorted
+func (c *Config) poppitQueues() []string {
+	var queues []string
+	add := func(queue *string) {
+		if queue != nil && *queue != c.PoppitQueue && !slices.Contains(queues, *queue) {
+			queues = append(queues, *queue)
+		}
+	}
+	for _, queue := range c.PriorityQueues {
+		add(&queue)
+	}
+	for _, repo := range c.Repos {
+		add(repo.PoppitQueue)
+		for _, entry := range repo.Paths {
+			add(entry.PoppitQueue)
+		}
+	}
+	slices.Sort(queues)
+	return append([]string{c.PoppitQueue}, queues...)
+}
+
+// targetBranch resolves the branch Poppit checks out for a PR: the base_branch from its message, then the
+// repository's target_branch, then TARGET_BRANCH. Branch names are turned into refs, so `develop` becomes
+// `refs/heads/develop`.
+func (c *Config) targetBranch(metadata *PRMetadata) string {
+	if metadata.BaseBranch != "" {
+		return branchRef(metadata.BaseBranch)
+	}
+	return branchRef(c.repoSettings(metadata.Repository).TargetBranch)
+}
+
+// branchRef turns a branch name into a ref, leaving refs as they are
+func branchRef(branch string) string {
+	if branch == "" || strings.HasPrefix(branch, "refs/") {
+		return branch
+	}
+	return "refs/heads/" + branch
+}
+
+// timeBombTTL resolves the TTL of a merged message: the channel's TIMEBOMB_CHANNEL_TTLS entry, then the
+// repository's timebomb_ttl, then TIMEBOMB_TTL
+func (c *Config) timeBombTTL(repo, channel string) int {
+	if ttl, ok := c.TimeBombChannelTTLs[channel]; ok {
+		return ttl
+	}
+	return c.repoSettings(repo).TimeBombTTL
+}
+
+// parseChannelTTLs parses comma-separated channel=seconds pairs, e.g. "C0123=3600,C0456=604800"
+func parseChannelTTLs(value string) (map[string]int, error) {
+	ttls := make(map[string]int)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		channel, seconds, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid TIMEBOMB_CHANNEL_TTLS entry %q, expected channel=seconds", part)
+		}
+		ttl, err := strconv.Atoi(strings.TrimSpace(seconds))
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid TIMEBOMB_CHANNEL_TTLS entry %q, seconds must be a positive integer", part)
+		}
+		ttls[strings.TrimSpace(channel)] = ttl
+	}
+	return ttls, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// resultHandler is one of the steps taken when Poppit reports that it finished a payload. Each runs on its own, so
+// one that fails or panics, say a Linear outage, doesn't keep the others, such as releasing the repository's merge
+// lock, from running.
+type resultHandler struct {
+	name string
+	// onSuccess and onFailure say which results the handler runs for
+	onSuccess, onFailure bool
+	handle               func(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, result PoppitResult) error
+}
+
+// resultHandlers run in the order they were registered, from the init function below
+var resultHandlers []resultHandler
+
+// registerResultHandler adds a handler of finished Poppit results, panicking on a duplicate name since that is a
+// build mistake
+func registerResultHandler(handler resultHandler) {
+	for _, registered := range resultHandlers {
+		if registered.name == handler.name {
+			panic(fmt.Sprintf("result handler %q registered twice", handler.name))
+		}
+	}
+	resultHandlers = append(resultHandlers, handler)
+}
+
+func init() {
+	registerResultHandler(resultHandler{name: "durable store", onSuccess: true, onFailure: true, handle: recordStoredOutcome})
+
+	registerResultHandler(resultHandler{name: "merge status", onSuccess: true, handle: func(ctx context.Context, redisClient *redis.Client, _ *slackClients, config *Config, result PoppitResult) error {
+		queueResultStatus(ctx, redisClient, config, result, MergeStatusMerged, result.SHA)
+		return nil
+	}})
+	registerResultHandler(resultHandler{name: "message cleanup", onSuccess: true, handle: func(ctx context.Context, redisClient *redis.Client, _ *slackClients, config *Config, result PoppitResult) error {
+		return releaseCleanup(ctx, redisClient, config, result.CorrelationID, true, "merge completed by Poppit")
+	}})
+	registerResultHandler(resultHandler{name: "branch deletion", onSuccess: true, handle: withoutSlack(deleteMergedBranch)})
+	registerResultHandler(resultHandler{name: "merge commit", onSuccess: true, handle: withoutSlack(alwaysSucceeds(recordMergeCommit))})
+	registerResultHandler(resultHandler{name: "deploy", onSuccess: true, handle: withoutSlack(triggerDeploy)})
+	registerResultHandler(resultHandler{name: "release notes", onSuccess: true, handle: publishReleaseNote})
+	registerResultHandler(resultHandler{name: "Jira issues", onSuccess: true, handle: withoutSlack(transitionJiraIssues)})
+	registerResultHandler(resultHandler{name: "Linear issues", onSuccess: true, handle: withoutSlack(closeLinearIssues)})
+	registerResultHandler(resultHandler{name: "confirm hook", onSuccess: true, handle: withoutSlack(alwaysSucceeds(notifyConfirmHook))})
+	registerResultHandler(resultHandler{name: "merge latency", onSuccess: true, handle: withoutSlack(alwaysSucceeds(recordMergeLatency))})
+
+	registerResultHandler(resultHandler{name: "failure audit", onFailure: true, handle: withoutSlack(recordMergeFailure)})
+
+	registerResultHandler(resultHandler{name: "webhooks", onSuccess: true, onFailure: true, handle: withoutSlack(alwaysSucceeds(sendResultWebhooks))})
+	registerResultHandler(resultHandler{name: "workflow", onSuccess: true, onFailure: true, handle: advanceWorkflow})
+	registerResultHandler(resultHandler{name: "all-or-nothing digest", onSuccess: true, onFailure: true, handle: advanceFanOut})
+	// Last, so the next merge is only released once everything else is done with this one
+	registerResultHandler(resultHandler{name: "repository queue", onSuccess: true, onFailure: true, handle: withoutSlack(releaseFinishedMerge)})
+}
+
+// withoutSlack adapts a handler that doesn't post to Slack
+func withoutSlack(handle func(context.Context, *redis.Client, *Config, PoppitResult) error) func(context.Context, *redis.Client, *slackClients, *Config, PoppitResult) error {
+	return func(ctx context.Context, redisClient *redis.Client, _ *slackClients, config *Config, result PoppitResult) error {
+		return handle(ctx, redisClient, config, result)
+	}
+}
+
+// alwaysSucceeds adapts a handler that logs its own failures
+func alwaysSucceeds(handle func(context.Context, *redis.Client, *Config, PoppitResult)) func(context.Context, *redis.Client, *Config, PoppitResult) error {
+	return func(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) error {
+		handle(ctx, redisClient, config, result)
+		return nil
+	}
+}
+
+// runResultHandlers runs every handler registered for a finished result, logging the ones that fail
+func runResultHandlers(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, result PoppitResult) {
+	for _, handler := range resultHandlers {
+		if (result.Success && !handler.onSuccess) || (!result.Success && !handler.onFailure) {
+			continue
+		}
+		if err := runResultHandler(ctx, redisClient, clients, config, result, handler); err != nil {
+			logError("Result handler %s failed for merge %s in %s: %v", handler.name, result.CorrelationID, result.Repo, err)
+		}
+	}
+}
+
+// runResultHandler runs one handler, turning a panic into an error so the handlers after it still run
+func runResultHandler(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, result PoppitResult, handler resultHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sentry.CurrentHub().Recover(r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler.handle(ctx, redisClient, clients, config, result)
+}
+
+// recordStoredOutcome writes a finished merge's outcome to the durable store, when there is one
+func recordStoredOutcome(ctx context.Context, _ *redis.Client, _ *slackClients, _ *Config, result PoppitResult) error {
+	if store == nil {
+		return nil
+	}
+	return store.RecordOutcome(ctx, result)
+}
+
+// recordMergeFailure audits a failed merge, attributed to the request that queued it so it links back to its Slack
+// message, and marks the merge failed on that message
+func recordMergeFailure(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) error {
+	entry := AuditEntry{
+		Time:          time.Now().UTC(),
+		EventTime:     time.Now().UTC(),
+		Source:        "poppit",
+		Repository:    result.Repo,
+		Decision:      OutcomeFailed,
+		Reason:        fmt.Sprintf("Poppit exited with code %d", result.ExitCode),
+		CorrelationID: result.CorrelationID,
+	}
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		logWarning("Failed to find the request for failed merge %s: %v", result.CorrelationID, err)
+	}
+	if found {
+		entry.User = requested.User
+		entry.GitHubUser = requested.GitHubUser
+		entry.TeamID = requested.TeamID
+		entry.Channel = requested.Channel
+		entry.Ts = requested.Ts
+		entry.PRNumber = requested.PRNumber
+		if mergeRequest(requested) {
+			queueMergeStatus(ctx, redisClient, config, auditMessage(requested), MergeStatusFailed, failureReason(result))
+		}
+	}
+	reportFailure(entry, nil)
+	if err := recordAudit(ctx, redisClient, config, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
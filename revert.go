@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func mergeCommitKey(config *Config, repo string, prNumber int) string {
+	return fmt.Sprintf("%s:%s#%d", config.MergeCommitKeyPrefix, repo, prNumber)
+}
+
+// recordMergeCommit remembers the commit Poppit reported merging a PR with, for the revert emoji
+func recordMergeCommit(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) {
+	if result.SHA == "" {
+		return
+	}
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		logWarning("Failed to find the request for merge %s, not recording its merge commit: %v", result.CorrelationID, err)
+		return
+	}
+	if !found || !mergeRequest(requested) || requested.PRNumber == 0 {
+		return
+	}
+
+	key := mergeCommitKey(config, result.Repo, requested.PRNumber)
+	if err := redisClient.Set(ctx, key, result.SHA, time.Duration(config.MergeCommitTTL)*time.Second).Err(); err != nil {
+		logWarning("Failed to set %s: %v", key, err)
+	}
+}
+
+// mergeCommit returns the commit a PR was merged with: the one Poppit reported, or else the one GitHub reports
+// when GITHUB_TOKEN or a GitHub App is set up. It returns "" when the PR isn't known to be merged.
+func mergeCommit(ctx context.Context, redisClient *redis.Client, config *Config, repo string, prNumber int) (string, error) {
+	key := mergeCommitKey(config, repo, prNumber)
+	sha, err := redisClient.Get(ctx, key).Result()
+	if err == nil {
+		return sha, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if !config.hasGitHubAPI() {
+		return "", nil
+	}
+
+	pr, err := getPullRequest(ctx, config, repo, prNumber)
+	if err != nil {
+		return "", err
+	}
+	if !pr.Merged {
+		return "", nil
+	}
+	return pr.MergeCommitSHA, nil
+}
+
+// unknownMergeCommit is the decision for a revert of a PR whose merge commit isn't known
+func unknownMergeCommit(metadata *PRMetadata) Decision {
+	return Decision{
+		Outcome: OutcomeIgnored,
+		Reason:  "merge commit unknown",
+		Note:    fmt.Sprintf(":shrug: I don't know which commit merged PR #%d in %s, so I can't revert it.", metadata.PRNumber, metadata.Repository),
+	}
+}
+
+// submitRevert hands Poppit the commands that open a PR reverting a merged PR, whose state isn't checked since it's
+// no longer open
+func submitRevert(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, error) {
+	decision, err := submitPRCommand(ctx, redisClient, config, job, "revert", false)
+	if err == nil && decision.Outcome == OutcomeQueued {
+		decision.Note = fmt.Sprintf(":rewind: <@%s> asked for PR #%d to be reverted. A PR reverting it will be opened shortly.", job.RequestedBy, job.PRNumber)
+	}
+	return decision, err
+}
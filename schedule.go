@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the timezone database so MERGE_TIMEZONE works in the scratch image
+	_ "time/tzdata"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BlackoutModeReject = "reject"
+	BlackoutModeDefer  = "defer"
+)
+
+// BlackoutWindow is a recurring weekly period during which no merges are queued
+type BlackoutWindow struct {
+	Spec     string
+	StartDay time.Weekday
+	StartMin int
+	EndDay   time.Weekday
+	EndMin   int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// parseBlackoutWindows parses a comma-separated list of windows such as "Fri 16:00-Mon 08:00"
+func parseBlackoutWindows(spec string) ([]BlackoutWindow, error) {
+	var windows []BlackoutWindow
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		start, end, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("blackout window %q must be in the form \"Fri 16:00-Mon 08:00\"", part)
+		}
+
+		startDay, startMin, err := parseWeekTime(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of blackout window %q: %w", part, err)
+		}
+		endDay, endMin, err := parseWeekTime(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of blackout window %q: %w", part, err)
+		}
+		if startDay == endDay && startMin == endMin {
+			return nil, fmt.Errorf("blackout window %q has the same start and end", part)
+		}
+
+		windows = append(windows, BlackoutWindow{
+			Spec:     part,
+			StartDay: startDay,
+			StartMin: startMin,
+			EndDay:   endDay,
+			EndMin:   endMin,
+		})
+	}
+	return windows, nil
+}
+
+// parseWeekTime parses "Fri 16:00" into a weekday and minutes since midnight
+func parseWeekTime(value string) (time.Weekday, int, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("expected \"<day> <HH:MM>\", got %q", strings.TrimSpace(value))
+	}
+
+	day, ok := weekdayNames[strings.ToLower(fields[0])]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown day %q", fields[0])
+	}
+
+	minutes, err := parseClock(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return day, minutes, nil
+}
+
+// parseClock parses "16:00" into minutes since midnight
+func parseClock(value string) (int, error) {
+	hourStr, minStr, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("invalid hour %q", hourStr)
+	}
+	minute, err := strconv.Atoi(minStr)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("invalid minute %q", minStr)
+	}
+	return hour*60 + minute, nil
+}
+
+func weekMinute(day time.Weekday, minute int) int {
+	return int(day)*24*60 + minute
+}
+
+// contains reports whether t falls within the window, handling windows that wrap past Saturday
+func (w BlackoutWindow) contains(t time.Time) bool {
+	now := weekMinute(t.Weekday(), t.Hour()*60+t.Minute())
+	start := weekMinute(w.StartDay, w.StartMin)
+	end := weekMinute(w.EndDay, w.EndMin)
+
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// endAfter returns the first time the window closes after t
+func (w BlackoutWindow) endAfter(t time.Time) time.Time {
+	days := (int(w.EndDay) - int(t.Weekday()) + 7) % 7
+	if days == 0 && w.EndMin <= t.Hour()*60+t.Minute() {
+		days = 7
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+days, w.EndMin/60, w.EndMin%60, 0, 0, t.Location())
+}
+
+// blackoutUntil reports whether t is inside a blackout window and, if so, when merging may resume.
+// Overlapping or back-to-back windows are followed through to the end of the last one.
+func blackoutUntil(windows []BlackoutWindow, t time.Time) (time.Time, bool) {
+	until := t
+	blocked := false
+
+	// Each iteration moves past one window, so this terminates after at most len(windows) steps
+	for i := 0; i <= len(windows); i++ {
+		var active *BlackoutWindow
+		for j := range windows {
+			if windows[j].contains(until) {
+				active = &windows[j]
+				break
+			}
+		}
+		if active == nil {
+			break
+		}
+		blocked = true
+		until = active.endAfter(until)
+	}
+
+	return until, blocked
+}
+
+// holdForBlackout rejects or defers a merge requested during a blackout window or a freeze
+func holdForBlackout(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, until time.Time) (Decision, error) {
+	resume := until.Format("Mon 15:04 MST")
+	// Freezes can last beyond the coming week
+	if time.Until(until) > 6*24*time.Hour {
+		resume = until.Format("Mon 2 Jan 15:04 MST")
+	}
+
+	if config.BlackoutMode == BlackoutModeDefer {
+		if err := deferMerge(ctx, redisClient, config, job, until); err != nil {
+			return Decision{}, err
+		}
+		logInfo("Deferred merge of PR %d in %s until %s (blackout window)", job.PRNumber, job.Payload.Repo, resume)
+		return Decision{
+			Outcome: OutcomeDeferred,
+			Reason:  fmt.Sprintf("blackout window until %s", resume),
+			Note:    fmt.Sprintf(":zzz: Merges are frozen right now. PR #%d will be merged automatically when the window opens at %s.", job.PRNumber, resume),
+		}, nil
+	}
+
+	logInfo("Rejected merge of PR %d in %s during blackout window (resumes %s)", job.PRNumber, job.Payload.Repo, resume)
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  fmt.Sprintf("blackout window until %s", resume),
+		Note:    fmt.Sprintf(":no_entry: Merges are frozen until %s. Please react again once the window opens.", resume),
+	}, nil
+}
+
+// deferMerge parks a merge job in the deferred queue until releaseAt
+func deferMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, releaseAt time.Time) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal deferred merge: %w", err)
+	}
+
+	member := redis.Z{Score: float64(releaseAt.Unix()), Member: string(jobJSON)}
+	if err := redisClient.ZAdd(ctx, config.DeferredQueue, member).Err(); err != nil {
+		return fmt.Errorf("failed to add to %s: %w", config.DeferredQueue, err)
+	}
+
+	if err := trackPendingMerge(ctx, redisClient, config, job, PendingMerge{DeferredMember: string(jobJSON)}); err != nil {
+		logWarning("Failed to track deferred merge, it can't be cancelled: %v", err)
+	}
+	return nil
+}
+
+func processDeferredMerges(ctx context.Context, redisClient *redis.Client) {
+	ticker := time.NewTicker(time.Duration(currentConfig().DeferredPollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := flushDeferredMerges(context.WithoutCancel(ctx), redisClient, currentConfig(), time.Now()); err != nil {
+				logError("Error flushing deferred merges: %v", err)
+			}
+		}
+	}
+}
+
+// flushDeferredMerges queues every deferred merge whose release time has passed
+func flushDeferredMerges(ctx context.Context, redisClient *redis.Client, config *Config, now time.Time) error {
+	if _, blocked := config.frozenUntil(now.In(config.Timezone)); blocked {
+		return nil
+	}
+	// Deferred merges stay put while merging is paused, and are released once it's resumed
+	if paused, _, err := pauseState(ctx, redisClient, config); err != nil || paused {
+		return err
+	}
+	due, err := redisClient.ZRangeByScore(ctx, config.DeferredQueue, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.DeferredQueue, err)
+	}
+
+	for _, member := range due {
+		var job MergeJob
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			logError("Dropping malformed deferred merge: %v", err)
+			redisClient.ZRem(ctx, config.DeferredQueue, member)
+			continue
+		}
+
+		// Deferred merges wait for a backed up Poppit queue too, rather than all landing on it at once
+		queue, length, backedUp, err := poppitBackedUp(ctx, redisClient, config, job)
+		if err != nil {
+			return err
+		}
+		if backedUp {
+			logDebug("Holding deferred merge of PR %d in %s, %d commands in %s", job.PRNumber, job.Payload.Repo, length, queue)
+			continue
+		}
+
+		// Only the caller that removes the entry gets to queue it
+		removed, err := redisClient.ZRem(ctx, config.DeferredQueue, member).Result()
+		if err != nil {
+			return fmt.Errorf("failed to remove from %s: %w", config.DeferredQueue, err)
+		}
+		if removed == 0 {
+			continue
+		}
+		if _, closed := closedSinceQueued(ctx, redisClient, config, job); closed {
+			continue
+		}
+
+		// Deferred merges count against the repository's rate limit too, staying deferred until a slot is free
+		limit := config.repoSettings(job.Payload.Repo).MergeRateLimit
+		until, limited, err := reserveMergeSlot(ctx, redisClient, config, job, limit)
+		if err != nil {
+			logError("Error checking the merge rate of deferred PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+			until, limited = now, true
+		}
+		if limited {
+			logDebug("Holding deferred merge of PR %d in %s until %s (merge rate limit)", job.PRNumber, job.Payload.Repo, until.Format(time.RFC3339))
+			if err := redisClient.ZAdd(ctx, config.DeferredQueue, redis.Z{Score: float64(until.Unix()), Member: member}).Err(); err != nil {
+				logError("Failed to put deferred merge of PR %d in %s back on %s, it is lost: %v", job.PRNumber, job.Payload.Repo, config.DeferredQueue, err)
+			}
+			continue
+		}
+
+		logInfo("Releasing deferred merge of PR %d in %s", job.PRNumber, job.Payload.Repo)
+		decision, err := queueMerge(ctx, redisClient, config, job)
+		if limit > 0 && (err != nil || decision.Outcome != OutcomeQueued) {
+			refundMergeSlot(ctx, redisClient, config, job)
+		}
+		if err != nil {
+			// Put the merge back so the next poll tries it again rather than dropping it
+			logError("Error queueing deferred merge of PR %d in %s, retrying: %v", job.PRNumber, job.Payload.Repo, err)
+			if err := redisClient.ZAdd(ctx, config.DeferredQueue, redis.Z{Score: float64(now.Unix()), Member: member}).Err(); err != nil {
+				logError("Failed to put deferred merge of PR %d in %s back on %s, it is lost: %v", job.PRNumber, job.Payload.Repo, config.DeferredQueue, err)
+			}
+		}
+	}
+
+	return nil
+}
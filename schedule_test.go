@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseBlackoutWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []BlackoutWindow
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{
+			name: "weekend",
+			spec: "Fri 16:00-Mon 08:00",
+			want: []BlackoutWindow{{Spec: "Fri 16:00-Mon 08:00", StartDay: time.Friday, StartMin: 16 * 60, EndDay: time.Monday, EndMin: 8 * 60}},
+		},
+		{
+			name: "several with full day names",
+			spec: " wednesday 12:30-Wednesday 13:15 , SAT 00:00-sun 23:59 ",
+			want: []BlackoutWindow{
+				{Spec: "wednesday 12:30-Wednesday 13:15", StartDay: time.Wednesday, StartMin: 12*60 + 30, EndDay: time.Wednesday, EndMin: 13*60 + 15},
+				{Spec: "SAT 00:00-sun 23:59", StartDay: time.Saturday, StartMin: 0, EndDay: time.Sunday, EndMin: 23*60 + 59},
+			},
+		},
+		{"no separator", "Fri 16:00", nil, true},
+		{"unknown day", "Fry 16:00-Mon 08:00", nil, true},
+		{"missing time", "Fri-Mon 08:00", nil, true},
+		{"hour out of range", "Fri 24:00-Mon 08:00", nil, true},
+		{"minute out of range", "Fri 16:60-Mon 08:00", nil, true},
+		{"no colon", "Fri 1600-Mon 08:00", nil, true},
+		{"same start and end", "Fri 16:00-Fri 16:00", nil, true},
+		{"one bad window", "Fri 16:00-Mon 08:00,Tue", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBlackoutWindows(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBlackoutWindows(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseBlackoutWindows(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseBlackoutWindows(%q)[%d] = %+v, want %+v", tt.spec, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBlackoutUntil(t *testing.T) {
+	windows, err := parseBlackoutWindows("Fri 16:00-Mon 08:00,Mon 08:00-Mon 09:00,Wed 12:00-Wed 13:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-10-16 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantUntil   time.Time
+		wantBlocked bool
+	}{
+		{"before the weekend", at(16, 15, 59), at(16, 15, 59), false},
+		{"start of the weekend", at(16, 16, 0), at(19, 9, 0), true},
+		{"over the week boundary", at(18, 12, 0), at(19, 9, 0), true},
+		{"in the back-to-back window", at(19, 8, 30), at(19, 9, 0), true},
+		{"end of the last window", at(19, 9, 0), at(19, 9, 0), false},
+		{"midweek window", at(21, 12, 15), at(21, 13, 0), true},
+		{"after the midweek window", at(21, 13, 0), at(21, 13, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, blocked := blackoutUntil(windows, tt.now)
+			if blocked != tt.wantBlocked || !until.Equal(tt.wantUntil) {
+				t.Errorf("blackoutUntil(%s) = %s, %t, want %s, %t", tt.now, until, blocked, tt.wantUntil, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestBlackoutWindowWrappingTheWeek(t *testing.T) {
+	windows, err := parseBlackoutWindows("Fri 22:00-Fri 06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A window that ends before it starts wraps around the whole week
+	now := time.Date(2026, time.October, 20, 12, 0, 0, 0, time.UTC)
+	until, blocked := blackoutUntil(windows, now)
+	if want := time.Date(2026, time.October, 23, 6, 0, 0, 0, time.UTC); !blocked || !until.Equal(want) {
+		t.Errorf("blackoutUntil(%s) = %s, %t, want %s, true", now, until, blocked, want)
+	}
+}
+
+func TestFlushDeferredMergesKeepsUnqueuedMerge(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+	config := &Config{
+		Timezone:      time.UTC,
+		PauseKey:      "vibemerge:paused",
+		DeferredQueue: "vibemerge:deferred",
+		PoppitQueue:   "poppit:commands",
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	// Pushing to Poppit fails while its queue holds a value of the wrong type
+	mr.Set(config.PoppitQueue, "not a list")
+
+	job := MergeJob{PRNumber: 42}
+	job.Payload.Repo = "org/repo"
+	job.Payload.CorrelationID = "corr-deferred"
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisClient.ZAdd(ctx, config.DeferredQueue, redis.Z{Score: float64(now.Add(-time.Second).Unix()), Member: string(jobJSON)})
+
+	if err := flushDeferredMerges(ctx, redisClient, config, now); err != nil {
+		t.Fatalf("flushDeferredMerges() error = %v", err)
+	}
+	if err := redisClient.ZScore(ctx, config.DeferredQueue, string(jobJSON)).Err(); err != nil {
+		t.Errorf("deferred merge was dropped after failing to queue: %v", err)
+	}
+}
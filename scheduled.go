@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const scheduleUsage = "Reply in the thread of a PR message with `@VibeMerge merge at 17:00` or `@VibeMerge merge at Fri 17:00` to schedule its merge."
+
+// userMentionPattern matches Slack user mentions such as <@U123ABC> or <@U123ABC|name>
+var userMentionPattern = regexp.MustCompile(`<@[^>]+>`)
+
+// MentionEvent is the app_mention event Slack sends when someone mentions the app in a message
+type MentionEvent struct {
+	TeamID string `json:"team_id"`
+	Event  struct {
+		Type     string `json:"type"`
+		User     string `json:"user"`
+		Text     string `json:"text"`
+		Channel  string `json:"channel"`
+		Ts       string `json:"ts"`
+		ThreadTs string `json:"thread_ts"`
+	} `json:"event"`
+}
+
+// parseScheduleCommand parses "merge at 17:00" or "merge at Fri 17:00", with any mentions removed, into the next
+// such time after now
+func parseScheduleCommand(text string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(userMentionPattern.ReplaceAllString(text, " ")))
+	if len(fields) < 3 || len(fields) > 4 || fields[0] != "merge" || fields[1] != "at" {
+		return time.Time{}, fmt.Errorf("expected \"merge at <HH:MM>\"")
+	}
+
+	if len(fields) == 4 {
+		day, minutes, err := parseWeekTime(fields[2] + " " + fields[3])
+		if err != nil {
+			return time.Time{}, err
+		}
+		days := (int(day) - int(now.Weekday()) + 7) % 7
+		at := time.Date(now.Year(), now.Month(), now.Day()+days, minutes/60, minutes%60, 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 7)
+		}
+		return at, nil
+	}
+
+	minutes, err := parseClock(fields[2])
+	if err != nil {
+		return time.Time{}, err
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), minutes/60, minutes%60, 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at, nil
+}
+
+func processMentions(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	receiveMessages(ctx, redisClient, clients, currentConfig().MentionChannel, func(payload string) {
+		if err := handleMention(context.WithoutCancel(ctx), payload, redisClient, clients, currentConfig()); err != nil {
+			logError("Error handling mention: %v", err)
+		}
+	})
+}
+
+// handleMention schedules the merge of the PR whose thread the app was mentioned in with "merge at <time>"
+func handleMention(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, config *Config) (err error) {
+	var event MentionEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return fmt.Errorf("failed to unmarshal app_mention event: %w", err)
+	}
+	if event.Event.Type != "app_mention" || event.Event.User == "" {
+		return nil
+	}
+
+	workspace := config.workspace(event.TeamID)
+	if !workspace.allowsChannel(event.Event.Channel) {
+		logDebug("Ignoring mention in channel %s, not configured for workspace %s", event.Event.Channel, event.TeamID)
+		return nil
+	}
+	slackClient := clients.forWorkspace(workspace)
+	if slackClient == nil {
+		logWarning("No Slack bot token configured for workspace %s, ignoring mention", event.TeamID)
+		return nil
+	}
+
+	ctx, cancel := eventContext(ctx, config)
+	defer cancel()
+
+	channel, user, thread := event.Event.Channel, event.Event.User, event.Event.ThreadTs
+	audit := AuditEntry{
+		EventTime: time.Now().UTC(),
+		Source:    "schedule",
+		User:      user,
+		TeamID:    event.TeamID,
+		Channel:   channel,
+		Ts:        thread,
+	}
+	var decision Decision
+	defer func() {
+		audit.finish(context.WithoutCancel(ctx), redisClient, config, decision, err)
+		if err == nil && decision.Note != "" {
+			reply := thread
+			if reply == "" {
+				reply = event.Event.Ts
+			}
+			notifyThread(ctx, slackClient, channel, reply, decision.Note)
+		}
+	}()
+
+	at, parseErr := parseScheduleCommand(event.Event.Text, time.Now().In(config.Timezone))
+	if parseErr != nil {
+		decision = Decision{Outcome: OutcomeIgnored, Reason: "unrecognised command", Note: fmt.Sprintf(":thinking_face: Sorry, I didn't understand that (%v). %s", parseErr, scheduleUsage)}
+		return nil
+	}
+	if thread == "" {
+		decision = Decision{Outcome: OutcomeIgnored, Reason: "not in a thread", Note: ":thinking_face: " + scheduleUsage}
+		return nil
+	}
+	if !config.isAuthorized(user) {
+		decision = Decision{Outcome: OutcomeDenied, Reason: "requester not authorized", Note: fmt.Sprintf(":lock: Sorry <@%s>, you're not on the list of people who can merge with VibeMerge.", user)}
+		return nil
+	}
+	// Beyond PENDING_TTL the merge could no longer be cancelled
+	if ahead := time.Until(at); ahead > time.Duration(config.PendingTTL)*time.Second {
+		decision = Decision{
+			Outcome: OutcomeDenied,
+			Reason:  "scheduled too far ahead",
+			Note:    fmt.Sprintf(":calendar: Sorry <@%s>, merges can be scheduled at most %s ahead.", user, formatWindow(time.Duration(config.PendingTTL)*time.Second)),
+		}
+		return nil
+	}
+
+	metadata, err := getMessageMetadata(ctx, slackClient, config, channel, thread)
+	if err != nil {
+		return fmt.Errorf("failed to get message metadata: %w", err)
+	}
+	if metadata == nil || len(metadata.PRs) > 0 {
+		decision = Decision{Outcome: OutcomeIgnored, Reason: "no PR metadata on message", Note: ":thinking_face: This thread isn't about a single PR, so there's nothing to schedule."}
+		return nil
+	}
+	audit.Repository = metadata.Repository
+	audit.PRNumber = metadata.PRNumber
+
+	job, err := manualMergeJob(ctx, redisClient, clients, config, metadata, event.TeamID, user, channel, thread)
+	if err != nil {
+		return err
+	}
+	audit.GitHubUser = job.Payload.GitHubUser
+	audit.CorrelationID = job.Payload.CorrelationID
+
+	decision, err = scheduleMerge(ctx, redisClient, config, job, at)
+	return err
+}
+
+// scheduleMerge parks a merge job in SCHEDULED_QUEUE until at, when it goes through submitMerge's checks
+func scheduleMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob, at time.Time) (Decision, error) {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to marshal scheduled merge: %w", err)
+	}
+
+	member := redis.Z{Score: float64(at.Unix()), Member: string(jobJSON)}
+	if err := redisClient.ZAdd(ctx, config.ScheduledQueue, member).Err(); err != nil {
+		return Decision{}, fmt.Errorf("failed to add to %s: %w", config.ScheduledQueue, err)
+	}
+	if err := trackPendingMerge(ctx, redisClient, config, job, PendingMerge{ScheduledMember: string(jobJSON)}); err != nil {
+		logWarning("Failed to track scheduled merge, it can't be cancelled: %v", err)
+	}
+
+	when := at.Format("Mon 15:04 MST")
+	logInfo("Scheduled merge of PR %d in %s for %s", job.PRNumber, job.Payload.Repo, when)
+	note := fmt.Sprintf(":calendar: <@%s> scheduled the merge of PR #%d for %s. It goes through the usual checks then.", job.RequestedBy, job.PRNumber, when)
+	if emoji := config.workspace(job.TeamID).CancelEmoji; emoji != "" {
+		note += fmt.Sprintf(" React with :%s: to cancel it.", emoji)
+	}
+	return Decision{Outcome: OutcomeDeferred, Reason: "scheduled for " + when, Note: note}, nil
+}
+
+func processScheduledMerges(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	ticker := time.NewTicker(time.Duration(currentConfig().DeferredPollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := flushScheduledMerges(context.WithoutCancel(ctx), redisClient, clients, currentConfig(), time.Now()); err != nil {
+				logError("Error flushing scheduled merges: %v", err)
+			}
+		}
+	}
+}
+
+// flushScheduledMerges submits every scheduled merge whose time has come, telling its thread the outcome
+func flushScheduledMerges(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, now time.Time) error {
+	due, err := redisClient.ZRangeByScore(ctx, config.ScheduledQueue, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.ScheduledQueue, err)
+	}
+
+	for _, member := range due {
+		// Only the caller that removes the entry gets to submit it
+		removed, err := redisClient.ZRem(ctx, config.ScheduledQueue, member).Result()
+		if err != nil {
+			return fmt.Errorf("failed to remove from %s: %w", config.ScheduledQueue, err)
+		}
+		if removed == 0 {
+			continue
+		}
+
+		var job MergeJob
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			logError("Dropping malformed scheduled merge: %v", err)
+			continue
+		}
+
+		logInfo("Submitting scheduled merge of PR %d in %s", job.PRNumber, job.Payload.Repo)
+		audit := AuditEntry{
+			EventTime:     now.UTC(),
+			Source:        "schedule",
+			User:          job.RequestedBy,
+			GitHubUser:    job.Payload.GitHubUser,
+			TeamID:        job.TeamID,
+			Channel:       job.Channel,
+			Ts:            job.Ts,
+			Repository:    job.Payload.Repo,
+			PRNumber:      job.PRNumber,
+			CorrelationID: job.Payload.CorrelationID,
+		}
+		decision, err := submitMerge(ctx, redisClient, config, job)
+		audit.finish(ctx, redisClient, config, decision, err)
+		if err != nil {
+			logError("Error submitting scheduled merge of PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+			continue
+		}
+
+		if decision.Note == "" && decision.Outcome == OutcomeQueued {
+			decision.Note = fmt.Sprintf(":alarm_clock: Queued the scheduled merge of PR #%d.", job.PRNumber)
+		}
+		if slackClient := clients.forWorkspace(config.workspace(job.TeamID)); slackClient != nil && decision.Note != "" {
+			postDecisionNote(ctx, slackClient, job.Channel, job.Ts, decision)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Schema versions of the reaction events VibeMerge accepts and the payloads it produces. The schemas are in the
+// schemas directory; a change consumers have to adapt to gets a new version.
+const (
+	ReactionEventSchemaVersion   = 1
+	PoppitPayloadSchemaVersion   = 1
+	TimeBombMessageSchemaVersion = 1
+)
+
+//go:embed schemas/*.schema.json
+var schemaFiles embed.FS
+
+var reactionEventSchema = mustCompileSchema("reaction-event.schema.json")
+
+// errInvalidEvent is returned for events that don't match their schema. They are rejected rather than retried,
+// since they would only fail again.
+var errInvalidEvent = errors.New("invalid event")
+
+// mustCompileSchema compiles an embedded schema, panicking if it is broken since that is a build mistake
+func mustCompileSchema(name string) *jsonschema.Schema {
+	data, err := schemaFiles.ReadFile("schemas/" + name)
+	if err != nil {
+		panic(fmt.Sprintf("schema %s: %v", name, err))
+	}
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(string(data)))
+	if err != nil {
+		panic(fmt.Sprintf("schema %s: %v", name, err))
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(name, doc); err != nil {
+		panic(fmt.Sprintf("schema %s: %v", name, err))
+	}
+	return compiler.MustCompile(name)
+}
+
+// validateReactionEvent checks a reaction event against its schema before any of it is acted on
+func validateReactionEvent(payload string) error {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: not JSON: %v", errInvalidEvent, err)
+	}
+	if err := reactionEventSchema.Validate(doc); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidEvent, schemaErrors(err))
+	}
+	return nil
+}
+
+// schemaErrors flattens a validation error into the failing locations and reasons, on one line
+func schemaErrors(err error) string {
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err.Error()
+	}
+	var reasons []string
+	var collect func(unit jsonschema.OutputUnit)
+	collect = func(unit jsonschema.OutputUnit) {
+		if unit.Error != nil {
+			location := unit.InstanceLocation
+			if location == "" {
+				location = "/"
+			}
+			reasons = append(reasons, fmt.Sprintf("%s: %s", location, unit.Error))
+		}
+		for _, detail := range unit.Errors {
+			collect(detail)
+		}
+	}
+	for _, detail := range validationErr.BasicOutput().Errors {
+		collect(detail)
+	}
+	if len(reasons) == 0 {
+		return err.Error()
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// RejectedEvent is an event that failed validation, kept in REJECTED_EVENT_QUEUE for inspection
+type RejectedEvent struct {
+	Payload string `json:"payload"`
+	Reason  string `json:"reason"`
+}
+
+// rejectEvent moves an event that failed validation to REJECTED_EVENT_QUEUE. Unlike DEAD_LETTER_QUEUE, rejected
+// events aren't handled again.
+func rejectEvent(ctx context.Context, redisClient *redis.Client, config *Config, payload string, reason error) {
+	metrics.Add("events_rejected", 1)
+	logWarning("Rejected reaction event: %v", reason)
+	sendDeadLetterWebhooks(config, payload, reason.Error())
+
+	rejectedJSON, err := json.Marshal(RejectedEvent{Payload: payload, Reason: reason.Error()})
+	if err != nil {
+		logError("Failed to marshal rejected event: %v", err)
+		return
+	}
+	if err := redisClient.RPush(ctx, config.RejectedEventQueue, string(rejectedJSON)).Err(); err != nil {
+		logError("Error adding rejected event to %s, it is lost: %v", config.RejectedEventQueue, err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Secrets providers select where SLACK_BOT_TOKEN and the other secret settings come from
+const (
+	SecretsProviderEnv   = "env"
+	SecretsProviderVault = "vault"
+	SecretsProviderAWS   = "aws"
+	SecretsProviderGCP   = "gcp"
+)
+
+// secretsTimeout bounds a single fetch from a secrets provider
+const secretsTimeout = 10 * time.Second
+
+// SecretsProvider supplies secret settings, keyed by the environment variable they replace
+// (SLACK_BOT_TOKEN, REDIS_PASSWORD and the other settings applySecrets lists). Keys a provider
+// doesn't return keep the value from the environment.
+type SecretsProvider interface {
+	Secrets(ctx context.Context) (map[string]string, error)
+}
+
+// secretsRenewer is implemented by providers whose credentials expire unless renewed
+type secretsRenewer interface {
+	Renew(ctx context.Context) error
+}
+
+// envSecrets leaves every secret to the environment and CONFIG_FILE
+type envSecrets struct{}
+
+func (envSecrets) Secrets(context.Context) (map[string]string, error) {
+	return nil, nil
+}
+
+// newSecretsProvider returns the provider selected by SECRETS_PROVIDER
+func newSecretsProvider(config *Config) (SecretsProvider, error) {
+	switch config.SecretsProvider {
+	case SecretsProviderEnv:
+		return envSecrets{}, nil
+	case SecretsProviderVault:
+		return newVaultSecrets(config)
+	case SecretsProviderAWS:
+		return newAWSSecrets(config)
+	case SecretsProviderGCP:
+		return newGCPSecrets(config)
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_PROVIDER %q", config.SecretsProvider)
+	}
+}
+
+// loadSecrets fetches the secret settings from the configured provider into config
+func loadSecrets(ctx context.Context, config *Config) error {
+	provider, err := newSecretsProvider(config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretsTimeout)
+	defer cancel()
+	secrets, err := provider.Secrets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch secrets from %s: %w", config.SecretsProvider, err)
+	}
+	config.applySecrets(secrets)
+	return nil
+}
+
+// applySecrets overrides the secret settings the provider returned
+func (c *Config) applySecrets(secrets map[string]string) {
+	for key, field := range map[string]*string{
+		"SLACK_BOT_TOKEN":        &c.SlackBotToken,
+		"SLACK_APP_TOKEN":        &c.SlackAppToken,
+		"REDIS_PASSWORD":         &c.RedisPassword,
+		"GITHUB_WEBHOOK_SECRET":  &c.GitHubWebhookSecret,
+		"SLACK_CLIENT_SECRET":    &c.SlackClientSecret,
+		"SLACK_REFRESH_TOKEN":    &c.SlackRefreshToken,
+		"STORE_DSN":              &c.StoreDSN,
+		"API_TOKEN":              &c.APIToken,
+		"SENTRY_DSN":             &c.SentryDSN,
+		"PAGERDUTY_ROUTING_KEY":  &c.PagerDutyRoutingKey,
+		"OPSGENIE_API_KEY":       &c.OpsgenieAPIKey,
+		"GITHUB_TOKEN":           &c.GitHubToken,
+		"GITHUB_APP_PRIVATE_KEY": &c.GitHubAppPrivateKey,
+		"FREEZE_CALENDAR_URL":    &c.FreezeCalendarURL,
+		"SLACK_SIGNING_SECRET":   &c.SlackSigningSecret,
+		"NATS_TOKEN":             &c.NATSToken,
+		"KAFKA_PASSWORD":         &c.KafkaPassword,
+		"JIRA_API_TOKEN":         &c.JiraAPIToken,
+		"LINEAR_API_KEY":         &c.LinearAPIKey,
+	} {
+		if value := secrets[key]; value != "" {
+			*field = value
+		}
+	}
+}
+
+// processSecretsRefresh periodically renews the provider's credentials and picks up rotated secrets
+func processSecretsRefresh(ctx context.Context) {
+	interval := currentConfig().SecretsRefreshInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := refreshSecrets(ctx, currentConfig()); err != nil {
+				logError("Error refreshing secrets: %v", err)
+			}
+		}
+	}
+}
+
+func refreshSecrets(ctx context.Context, config *Config) error {
+	if config.SecretsProvider == SecretsProviderEnv {
+		return nil
+	}
+
+	provider, err := newSecretsProvider(config)
+	if err != nil {
+		return err
+	}
+	if renewer, ok := provider.(secretsRenewer); ok {
+		renewCtx, cancel := context.WithTimeout(ctx, secretsTimeout)
+		err := renewer.Renew(renewCtx)
+		cancel()
+		if err != nil {
+			logWarning("Failed to renew %s credentials: %v", config.SecretsProvider, err)
+		}
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, secretsTimeout)
+	defer cancel()
+	secrets, err := provider.Secrets(fetchCtx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch secrets from %s: %w", config.SecretsProvider, err)
+	}
+
+	updated := *config
+	updated.applySecrets(secrets)
+	if updated.SlackBotToken == config.SlackBotToken && updated.SlackAppToken == config.SlackAppToken &&
+		updated.RedisPassword == config.RedisPassword && updated.GitHubWebhookSecret == config.GitHubWebhookSecret {
+		return nil
+	}
+
+	// Swap only if no reload-config happened while the secrets were fetched
+	if activeConfig.CompareAndSwap(config, &updated) {
+		logInfo("Secrets rotated in %s have been applied", config.SecretsProvider)
+	}
+	return nil
+}
+
+// parseSecretJSON reads a secret stored as a JSON object of environment variable names and values
+func parseSecretJSON(data []byte, name string) (map[string]string, error) {
+	var secrets map[string]string
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("secret %s must be a JSON object of string values: %w", name, err)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// sentryFlushTimeout bounds how long reports are sent for before exiting or re-panicking
+const sentryFlushTimeout = 2 * time.Second
+
+// initSentry starts reporting to SENTRY_DSN. Without it, the sentry package drops everything it is given.
+func initSentry(config *Config) error {
+	if config.SentryDSN == "" {
+		return nil
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         config.SentryDSN,
+		Environment: config.SentryEnvironment,
+		ServerName:  config.InstanceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialise Sentry: %w", err)
+	}
+	logInfo("Reporting errors to Sentry")
+	return nil
+}
+
+// reportFailure sends a failed event or merge to Sentry, tagged with its context from the audit entry. err is nil
+// for failures reported as outcomes, such as a Poppit merge that exited non-zero.
+func reportFailure(entry AuditEntry, err error) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(map[string]string{
+			"source":         entry.Source,
+			"repository":     entry.Repository,
+			"channel":        entry.Channel,
+			"ts":             entry.Ts,
+			"correlation_id": entry.CorrelationID,
+			"team_id":        entry.TeamID,
+		})
+		if entry.PRNumber != 0 {
+			scope.SetTag("pr_number", strconv.Itoa(entry.PRNumber))
+		}
+		scope.SetUser(sentry.User{ID: entry.User, Username: entry.GitHubUser})
+		if err == nil {
+			err = errors.New(entry.Reason)
+		}
+		sentry.CaptureException(err)
+	})
+}
+
+// reportError sends a logged error to Sentry
+func reportError(message string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetLevel(sentry.LevelError)
+		sentry.CaptureMessage(message)
+	})
+}
+
+// reportPanic, deferred at the top of a goroutine, sends a panic to Sentry before letting it crash the process
+func reportPanic() {
+	if r := recover(); r != nil {
+		sentry.CurrentHub().Recover(r)
+		sentry.Flush(sentryFlushTimeout)
+		panic(r)
+	}
+}
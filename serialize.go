@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PoppitResult is published by Poppit when it finishes executing a payload
+type PoppitResult struct {
+	CorrelationID string `json:"correlation_id"`
+	Type          string `json:"type"`
+	Repo          string `json:"repo"`
+	Success       bool   `json:"success"`
+	ExitCode      int    `json:"exit_code"`
+	Output        string `json:"output"`
+	// State is "started" when Poppit reports that it began running the payload, and empty once it finished
+	State string `json:"state,omitempty"`
+	// SHA is the merge commit, when Poppit reports it
+	SHA string `json:"sha,omitempty"`
+}
+
+// releaseRepoScript hands the repository lock from the merge that finished to the next waiting merge.
+// ARGV[1] is the correlation ID that finished, or "" when sweeping a lock that has expired.
+var releaseRepoScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder and holder ~= ARGV[1] then
+	return false
+end
+local nextJob = redis.call('LPOP', KEYS[2])
+if not nextJob then
+	redis.call('DEL', KEYS[1])
+	return false
+end
+local job = cjson.decode(nextJob)
+redis.call('SET', KEYS[1], job.payload.correlation_id, 'EX', ARGV[2])
+return nextJob
+`)
+
+// unlockRepoScript frees the repository lock if finishedID still holds it, leaving the waiting merges queued
+var unlockRepoScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// requeueRepoScript puts a released merge Poppit didn't take back at the head of the repository queue and frees the
+// lock it was handed, so the next release or sweep tries it again
+var requeueRepoScript = redis.NewScript(`
+redis.call('LPUSH', KEYS[2], ARGV[2])
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+end
+return 1
+`)
+
+func repoLockKey(config *Config, repo string) string {
+	return fmt.Sprintf("%s:%s", config.RepoLockPrefix, repo)
+}
+
+func repoQueueKey(config *Config, repo string) string {
+	return fmt.Sprintf("%s:%s", config.RepoQueuePrefix, repo)
+}
+
+// serializeMerge takes the repository's merge lock for the job, or parks the job behind the merge holding it.
+// It reports the job's place among the merges waiting on the repository, or 0 when it may be pushed to Poppit now.
+func serializeMerge(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (int, error) {
+	lockKey := repoLockKey(config, job.Payload.Repo)
+	timeout := time.Duration(config.MergeLockTimeout) * time.Second
+
+	acquired, err := redisClient.SetNX(ctx, lockKey, job.Payload.CorrelationID, timeout).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire %s: %w", lockKey, err)
+	}
+	if acquired {
+		return 0, nil
+	}
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal waiting merge: %w", err)
+	}
+
+	// Urgent merges go next, ahead of those already waiting on the repository
+	queueKey := repoQueueKey(config, job.Payload.Repo)
+	var position int64 = 1
+	if job.Priority == PriorityUrgent {
+		err = redisClient.LPush(ctx, queueKey, string(jobJSON)).Err()
+	} else {
+		position, err = redisClient.RPush(ctx, queueKey, string(jobJSON)).Result()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to push to %s: %w", queueKey, err)
+	}
+
+	if err := trackPendingMerge(ctx, redisClient, config, job, PendingMerge{WaitingMember: string(jobJSON)}); err != nil {
+		logWarning("Failed to track waiting merge, it can't be cancelled: %v", err)
+	}
+	return int(position), nil
+}
+
+// releaseRepo pushes the next merge waiting on a repository to Poppit once finishedID no longer holds its lock
+func releaseRepo(ctx context.Context, redisClient *redis.Client, config *Config, repo, finishedID string) error {
+	keys := []string{repoLockKey(config, repo), repoQueueKey(config, repo)}
+	// While merging is paused no merge is handed on; sweepRepoQueues releases the next one once it's resumed
+	paused, _, err := pauseState(ctx, redisClient, config)
+	if err != nil {
+		return err
+	}
+	if paused {
+		if finishedID == "" {
+			return nil
+		}
+		if err := unlockRepoScript.Run(ctx, redisClient, keys[:1], finishedID).Err(); err != nil {
+			return fmt.Errorf("failed to release merge lock for %s: %w", repo, err)
+		}
+		return nil
+	}
+
+	nextJSON, err := releaseRepoScript.Run(ctx, redisClient, keys, finishedID, config.MergeLockTimeout).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release merge lock for %s: %w", repo, err)
+	}
+
+	var job MergeJob
+	if err := json.Unmarshal([]byte(nextJSON), &job); err != nil {
+		return fmt.Errorf("failed to unmarshal waiting merge: %w", err)
+	}
+
+	if _, closed := closedSinceQueued(ctx, redisClient, config, job); closed {
+		// The dropped merge took the lock, so hand it straight on to the merge after it
+		return releaseRepo(ctx, redisClient, config, repo, job.Payload.CorrelationID)
+	}
+	logInfo("Releasing next merge for %s: PR %d", repo, job.PRNumber)
+	if err := pushToPoppit(ctx, redisClient, config, job); err != nil {
+		if requeueErr := requeueRepoScript.Run(ctx, redisClient, keys, job.Payload.CorrelationID, nextJSON).Err(); requeueErr != nil {
+			logError("Failed to put merge %s back on %s, it is lost: %v", job.Payload.CorrelationID, keys[1], requeueErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func processPoppitResults(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	receiveMessages(ctx, redisClient, clients, currentConfig().PoppitResultsChannel, func(payload string) {
+		if err := handlePoppitResult(context.WithoutCancel(ctx), payload, redisClient, clients, currentConfig()); err != nil {
+			logError("Error handling Poppit result: %v", err)
+		}
+	})
+}
+
+func handlePoppitResult(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, config *Config) error {
+	var result PoppitResult
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		return fmt.Errorf("failed to unmarshal Poppit result: %w", err)
+	}
+
+	// The payload type can be customised per event action, so results are recognised by correlation ID alone
+	if result.CorrelationID == "" {
+		return nil
+	}
+
+	if result.State == PoppitStateStarted {
+		logDebug("Poppit started merge %s in %s", result.CorrelationID, result.Repo)
+		queueResultStatus(ctx, redisClient, config, result, MergeStatusMerging, "")
+		return nil
+	}
+
+	if result.Success {
+		logInfo("Poppit completed merge %s in %s", result.CorrelationID, result.Repo)
+	} else {
+		logWarning("Poppit merge %s in %s failed with exit code %d", result.CorrelationID, result.Repo, result.ExitCode)
+	}
+	runResultHandlers(ctx, redisClient, clients, config, result)
+	return nil
+}
+
+// releaseFinishedMerge hands the repository's merge lock from a finished merge to the next one waiting, unless a
+// merge train keeps it until CI passes on the base branch
+func releaseFinishedMerge(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) error {
+	if !config.serializeMerges() {
+		return nil
+	}
+	if result.Success && config.mergeTrain() {
+		stopped, err := stopTrain(ctx, redisClient, config, result)
+		if err != nil {
+			return err
+		}
+		if stopped {
+			return nil
+		}
+	}
+	return releaseRepo(ctx, redisClient, config, result.Repo, result.CorrelationID)
+}
+
+// processRepoQueues periodically releases merges stuck behind a lock that expired without a Poppit result
+func processRepoQueues(ctx context.Context, redisClient *redis.Client) {
+	ticker := time.NewTicker(time.Duration(currentConfig().DeferredPollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			config := currentConfig()
+			if !config.serializeMerges() {
+				continue
+			}
+			if err := sweepRepoQueues(context.WithoutCancel(ctx), redisClient, config); err != nil {
+				logError("Error sweeping repository merge queues: %v", err)
+			}
+		}
+	}
+}
+
+func sweepRepoQueues(ctx context.Context, redisClient *redis.Client, config *Config) error {
+	prefix := config.RepoQueuePrefix + ":"
+	iter := redisClient.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		repo := strings.TrimPrefix(iter.Val(), prefix)
+		if err := releaseRepo(ctx, redisClient, config, repo, ""); err != nil {
+			logError("Error releasing merge queue for %s: %v", repo, err)
+		}
+	}
+	return iter.Err()
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Simulation is what VibeMerge would do with a reaction event, as reported by `vibemerge simulate`
+type Simulation struct {
+	Decision string         `json:"decision"`
+	Reason   string         `json:"reason,omitempty"`
+	Metadata *PRMetadata    `json:"metadata,omitempty"`
+	Payload  *PoppitPayload `json:"payload,omitempty"`
+	// Unchecked lists what was assumed rather than checked: Redis state in an offline simulation, and hooks, which
+	// are never run
+	Unchecked []string `json:"unchecked,omitempty"`
+	// Effects lists what serve would have done outside its Redis state, none of which was done: Poppit commands,
+	// notifications, Slack and GitHub calls, webhooks and workflow requests
+	Effects []string `json:"effects,omitempty"`
+	// Digest holds a simulation per PR of a digest message or stack
+	Digest []Simulation `json:"digest,omitempty"`
+}
+
+// dryRun records what a simulation would have done, and is nil while serving. The GitHub client, hooks, webhooks
+// and workflow HTTP steps check it before changing anything outside VibeMerge.
+var dryRun *simulatedEffects
+
+// runSimulateCommand implements `vibemerge simulate`, running a reaction event through the same handler as serve.
+// Redis writes go to a scratch Redis, which reads the keys they touch from the live one first unless the simulation
+// is offline, and Slack, Poppit, GitHub changes, hooks and webhooks are stood in for. The message's PR metadata is
+// fetched from Slack unless -message gives the message as JSON, in which case neither Slack nor Redis is needed
+// unless -redis is set.
+func runSimulateCommand(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	eventFile := flags.String("event", "", "reaction event JSON, as relayed on slack-relay-reaction-added")
+	messageFile := flags.String("message", "", "Slack message JSON to read PR metadata from instead of fetching it from Slack")
+	useRedis := flags.Bool("redis", false, "with -message, still read the pause switch, PR state, rate limits and merge locks from Redis")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: vibemerge simulate [-message message.json [-redis]] -event event.json")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	// The event may also be given as the only argument
+	if *eventFile == "" && flags.NArg() == 1 {
+		*eventFile = flags.Arg(0)
+	} else if *eventFile == "" || flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	payload, err := os.ReadFile(*eventFile)
+	if err != nil {
+		return fmt.Errorf("failed to read reaction event: %w", err)
+	}
+
+	var message *slack.Message
+	if *messageFile != "" {
+		data, err := os.ReadFile(*messageFile)
+		if err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+		message = &slack.Message{}
+		if err := json.Unmarshal(data, message); err != nil {
+			return fmt.Errorf("failed to unmarshal message: %w", err)
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	activeConfig.Store(config)
+	currentLogLevel.Store(int32(parseLogLevel(config.LogLevel)))
+	configureSlackLimiter(config)
+
+	// Offline, liveClient stays nil and the simulation starts from an empty Redis
+	ctx := context.Background()
+	var liveClient *redis.Client
+	if message == nil || *useRedis {
+		liveClient = newRedisClient(config)
+		defer liveClient.Close()
+		if err := refreshFeatureFlags(ctx, liveClient, config); err != nil {
+			return err
+		}
+	}
+	if message == nil {
+		if err := slackTokens.start(ctx, liveClient, config); err != nil {
+			return fmt.Errorf("failed to load the rotated Slack token: %w", err)
+		}
+	}
+
+	simulation, err := simulateReaction(ctx, liveClient, config, string(payload), message)
+	if err != nil {
+		return err
+	}
+	if liveClient == nil {
+		simulation.Unchecked = append(simulation.Unchecked, "feature flags")
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(simulation)
+}
+
+// simulateReaction runs a reaction event through handleReactionMessage in dry-run mode. liveClient is only read
+// from, and is nil in an offline simulation; message stands in for the Slack message when it is set.
+func simulateReaction(ctx context.Context, liveClient *redis.Client, config *Config, payload string, message *slack.Message) (Simulation, error) {
+	var reactionEvent ReactionEvent
+	if err := json.Unmarshal([]byte(payload), &reactionEvent); err != nil {
+		return Simulation{}, fmt.Errorf("failed to unmarshal reaction event: %w", err)
+	}
+
+	scratch, err := miniredis.Run()
+	if err != nil {
+		return Simulation{}, fmt.Errorf("failed to start the scratch Redis: %w", err)
+	}
+	defer scratch.Close()
+	redisClient := redis.NewClient(&redis.Options{Addr: scratch.Addr()})
+	defer redisClient.Close()
+
+	effects := &simulatedEffects{}
+	if liveClient != nil {
+		seedClient := redis.NewClient(&redis.Options{Addr: scratch.Addr()})
+		defer seedClient.Close()
+		redisClient.AddHook(&seedingHook{live: liveClient, scratch: seedClient, seeded: make(map[string]bool)})
+	} else {
+		effects.skip("Redis state: the pause switch, PR state, pending merges, rate limits, quotas and merge locks")
+	}
+
+	slackAPI := newSimulatedSlack(effects, message)
+	defer slackAPI.Close()
+	clients := newSlackClients()
+	clients.options = []slack.Option{slack.OptionAPIURL(slackAPI.URL + "/api/")}
+	// The simulated decisions are only read back from the scratch audit stream
+	simulated := *config
+	simulated.AuditLogFile = ""
+	config = &simulated
+	if config.workspace(reactionEvent.TeamID).BotToken == "" {
+		if message == nil {
+			return Simulation{}, fmt.Errorf("no Slack bot token configured for workspace %s, use -message instead", reactionEvent.TeamID)
+		}
+		// Offline, the stand-in Slack needs no token, but the handler needs a client
+		config.SlackBotToken = "xoxb-simulated"
+	}
+
+	previousTransport, previousServiceTransport := transport, serviceTransport
+	dryRun, transport, serviceTransport = effects, effects, effects
+	defer func() { dryRun, transport, serviceTransport = nil, previousTransport, previousServiceTransport }()
+
+	// The message is fetched as the dispatcher does, so the handler reuses it and the simulation can show it
+	_, ctx = resolveReactionRepo(ctx, payload, redisClient, clients, config)
+	metadata, _ := prefetchedMetadata(ctx, reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts)
+	handleErr := handleReactionMessage(ctx, payload, redisClient, clients, config)
+
+	messages, err := redisClient.XRange(ctx, config.AuditStream, "-", "+").Result()
+	if err != nil {
+		return Simulation{}, fmt.Errorf("failed to read the simulated decisions: %w", err)
+	}
+	// Errors once the handler has the PR are recorded as failed decisions, so only earlier ones fail the simulation
+	if handleErr != nil && len(messages) == 0 {
+		return Simulation{}, handleErr
+	}
+	simulation := Simulation{Decision: OutcomeIgnored, Reason: "not one of the workspace's emoji, or in a channel it doesn't watch", Metadata: metadata}
+	if len(messages) > 1 || (metadata != nil && len(metadata.PRs) > 0) {
+		simulation.Decision, simulation.Reason = "digest", ""
+		for _, msg := range messages {
+			entry := parseAuditEntry(msg)
+			simulation.Digest = append(simulation.Digest, Simulation{
+				Decision: entry.Decision,
+				Reason:   entry.Reason,
+				Metadata: &PRMetadata{Repository: entry.Repository, PRNumber: entry.PRNumber},
+				Payload:  effects.payload(entry.CorrelationID),
+			})
+		}
+	} else if len(messages) == 1 {
+		entry := parseAuditEntry(messages[0])
+		simulation.Decision, simulation.Reason = entry.Decision, entry.Reason
+		simulation.Payload = effects.payload(entry.CorrelationID)
+	}
+	simulation.Unchecked = append(simulation.Unchecked, effects.unchecked...)
+	simulation.Effects = effects.effects
+	return simulation, nil
+}
+
+// simulatedEffects stands in for Poppit and the services VibeMerge notifies, recording what it is sent
+type simulatedEffects struct {
+	mu        sync.Mutex
+	effects   []string
+	unchecked []string
+	payloads  []string
+}
+
+func (e *simulatedEffects) add(format string, args ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.effects = append(e.effects, fmt.Sprintf(format, args...))
+}
+
+func (e *simulatedEffects) skip(check string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.unchecked = append(e.unchecked, check)
+}
+
+// payload returns the Poppit payload pushed with a correlation ID, if any
+func (e *simulatedEffects) payload(correlationID string) *PoppitPayload {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, payloadJSON := range e.payloads {
+		var payload PoppitPayload
+		if json.Unmarshal([]byte(payloadJSON), &payload) == nil && correlationID != "" && payload.CorrelationID == correlationID {
+			return &payload
+		}
+	}
+	return nil
+}
+
+// Receive never delivers anything, as a simulation handles a single event
+func (e *simulatedEffects) Receive(ctx context.Context, channel string, handle func(payload string, done func())) {
+}
+
+func (e *simulatedEffects) Push(ctx context.Context, queue, payload string) error {
+	e.add("push to %s", queue)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.payloads = append(e.payloads, payload)
+	return nil
+}
+
+func (e *simulatedEffects) PushFront(ctx context.Context, queue, payload string) error {
+	return e.Push(ctx, queue, payload)
+}
+
+func (e *simulatedEffects) Publish(ctx context.Context, channel, payload string) error {
+	e.add("publish on %s: %s", channel, payload)
+	return nil
+}
+
+// slackReadMethods are the Slack Web API methods a simulation passes on to Slack, as they change nothing
+var slackReadMethods = map[string]bool{
+	"auth.test":             true,
+	"bots.info":             true,
+	"chat.getPermalink":     true,
+	"conversations.history": true,
+	"conversations.info":    true,
+	"conversations.replies": true,
+	"team.info":             true,
+	"users.info":            true,
+	"users.lookupByEmail":   true,
+	"users.profile.get":     true,
+}
+
+// newSimulatedSlack stands in for the Slack Web API. Reads are answered with message when it is set, and otherwise
+// passed on to Slack; every other call is recorded and answered as if it succeeded.
+func newSimulatedSlack(effects *simulatedEffects, message *slack.Message) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := strings.TrimPrefix(r.URL.Path, "/api/")
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case message != nil && (method == "conversations.history" || method == "conversations.replies"):
+			json.NewEncoder(w).Encode(map[string]any{"ok": true, "messages": []slack.Message{*message}})
+		case message != nil && slackReadMethods[method]:
+			// Offline, Slack isn't asked, so e.g. {{.SlackName}} renders as the reacting user's ID
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": "simulated_offline"})
+		case slackReadMethods[method]:
+			req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, slack.APIURL+method, bytes.NewReader(body))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			req.Header = r.Header.Clone()
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+		default:
+			form, _ := url.ParseQuery(string(body))
+			effect := "Slack " + method
+			if channel := form.Get("channel"); channel != "" {
+				effect += " in " + channel
+			}
+			if text := form.Get("text"); text != "" {
+				effect += ": " + text
+			}
+			effects.add("%s", effect)
+			json.NewEncoder(w).Encode(map[string]any{"ok": true, "channel": form.Get("channel"), "ts": form.Get("ts")})
+		}
+	}))
+}
+
+// seedingHook copies each key a command touches from the live Redis into the scratch one the first time it is
+// used, so a simulation sees live state while everything it writes stays in the scratch Redis
+type seedingHook struct {
+	live, scratch *redis.Client
+	mu            sync.Mutex
+	seeded        map[string]bool
+}
+
+func (h *seedingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *seedingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.seed(ctx, cmd)
+		return next(ctx, cmd)
+	}
+}
+
+func (h *seedingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.seed(ctx, cmd)
+		}
+		return next(ctx, cmds)
+	}
+}
+
+// seed asks the live Redis which keys a command touches, with COMMAND GETKEYS, and copies those not seeded yet
+func (h *seedingHook) seed(ctx context.Context, cmd redis.Cmder) {
+	keys, err := h.live.Do(ctx, append([]any{"command", "getkeys"}, cmd.Args()...)...).StringSlice()
+	if err != nil {
+		// The command has no keys, or the Redis server can't say which
+		logDebug("Not seeding %s from Redis: %v", cmd.Name(), err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range keys {
+		if h.seeded[key] {
+			continue
+		}
+		h.seeded[key] = true
+		if err := copyRedisKey(ctx, h.live, h.scratch, key); err != nil {
+			logWarning("Failed to read %s from Redis, simulating without it: %v", key, err)
+		}
+	}
+}
+
+// copyRedisKey copies a string, list, set, sorted set or hash with its TTL. Streams, such as the audit log, are left
+// out, so the scratch Redis only holds the simulation's own entries.
+func copyRedisKey(ctx context.Context, from, to *redis.Client, key string) error {
+	kind, err := from.Type(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "string":
+		value, err := from.Get(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		err = to.Set(ctx, key, value, 0).Err()
+	case "list":
+		values, err := from.LRange(ctx, key, 0, -1).Result()
+		if err != nil || len(values) == 0 {
+			return err
+		}
+		err = to.RPush(ctx, key, values).Err()
+	case "set":
+		members, err := from.SMembers(ctx, key).Result()
+		if err != nil || len(members) == 0 {
+			return err
+		}
+		err = to.SAdd(ctx, key, members).Err()
+	case "zset":
+		members, err := from.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil || len(members) == 0 {
+			return err
+		}
+		err = to.ZAdd(ctx, key, members...).Err()
+	case "hash":
+		fields, err := from.HGetAll(ctx, key).Result()
+		if err != nil || len(fields) == 0 {
+			return err
+		}
+		err = to.HSet(ctx, key, fields).Err()
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ttl, err := from.PTTL(ctx, key).Result()
+	if err != nil || ttl <= 0 {
+		return err
+	}
+	return to.PExpire(ctx, key, ttl).Err()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestSimulateReactionOffline(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         string
+		reaction     string
+		wantDecision string
+		wantPayload  bool
+	}{
+		{"authorized merge is queued", "U_ADMIN", "white_check_mark", OutcomeQueued, true},
+		{"unauthorized merge is refused", "U_OTHER", "white_check_mark", OutcomeDenied, false},
+		{"other emoji are ignored", "U_ADMIN", "tada", OutcomeIgnored, false},
+	}
+
+	t.Setenv("TARGET_EMOJI", "white_check_mark")
+	t.Setenv("AUTHORIZED_USERS", "U_ADMIN")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := activeConfig.Load()
+	defer activeConfig.Store(previous)
+	activeConfig.Store(config)
+
+	var message slack.Message
+	if err := json.Unmarshal([]byte(`{"type":"message","ts":"1700000000.000100","metadata":{"event_type":"pr_opened",
+		"event_payload":{"pr_number":42,"repository":"org/repo","pr_url":"https://github.com/org/repo/pull/42","branch":"feature"}}}`), &message); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := `{"team_id":"T1","event_time":1700000000,"event":{"type":"reaction_added","user":"` + tt.user +
+				`","reaction":"` + tt.reaction + `","item":{"type":"message","channel":"C123","ts":"1700000000.000100"}}}`
+			simulation, err := simulateReaction(context.Background(), nil, config, payload, &message)
+			if err != nil {
+				t.Fatalf("simulateReaction() error = %v", err)
+			}
+			if simulation.Decision != tt.wantDecision {
+				t.Errorf("decision = %q (%s), want %q", simulation.Decision, simulation.Reason, tt.wantDecision)
+			}
+			if (simulation.Payload != nil) != tt.wantPayload {
+				t.Errorf("payload = %+v, want one: %v", simulation.Payload, tt.wantPayload)
+			}
+			if tt.wantPayload && (len(simulation.Effects) == 0 || !strings.HasPrefix(simulation.Effects[0], "push to "+config.PoppitQueue)) {
+				t.Errorf("effects = %q, want the Poppit command", simulation.Effects)
+			}
+			if dryRun != nil || transport != nil {
+				t.Error("the simulation left dry-run mode on")
+			}
+		})
+	}
+}
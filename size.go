@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// checkSizeGate rejects a PR size limit without GITHUB_TOKEN or a GitHub App to read sizes with, and a size override emoji that
+// already does something else
+func (c *Config) checkSizeGate() error {
+	limited := c.MaxPRSize > 0
+	for name, repo := range c.Repos {
+		if repo.MaxPRSize == nil {
+			continue
+		}
+		if *repo.MaxPRSize < 0 {
+			return fmt.Errorf("REPO_CONFIG_FILE %s: max_pr_size must not be negative, got %d", name, *repo.MaxPRSize)
+		}
+		limited = limited || *repo.MaxPRSize > 0
+	}
+	if limited && !c.hasGitHubAPI() {
+		return fmt.Errorf("MAX_PR_SIZE and max_pr_size require GITHUB_TOKEN or a GitHub App")
+	}
+
+	switch c.SizeOverrideEmoji {
+	case "":
+	case c.TargetEmoji, c.ReadyEmoji, c.ApproveEmoji, c.CloseEmoji, c.RevertEmoji, c.CancelEmoji:
+		return fmt.Errorf("SIZE_OVERRIDE_EMOJI %q is already a merge, ready, approve, close, revert or cancel emoji", c.SizeOverrideEmoji)
+	}
+	return nil
+}
+
+// checkPRSize denies a merge of a PR changing more lines than the repository's size limit, pointing at the normal
+// review flow. Merges requested with SIZE_OVERRIDE_EMOJI skip it. GitHub errors are logged and the merge let through.
+func checkPRSize(ctx context.Context, config *Config, job MergeJob, settings RepoSettings) (Decision, bool) {
+	if settings.MaxPRSize <= 0 {
+		return Decision{}, false
+	}
+	if job.SizeOverride {
+		logInfo("Size gate of PR %d in %s overridden by %s", job.PRNumber, job.Payload.Repo, job.RequestedBy)
+		return Decision{}, false
+	}
+
+	pr, err := getPullRequest(ctx, config, job.Payload.Repo, job.PRNumber)
+	if err != nil {
+		logWarning("Failed to read the size of PR %d in %s, not enforcing the size gate: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{}, false
+	}
+	size := pr.Additions + pr.Deletions
+	if size <= settings.MaxPRSize {
+		return Decision{}, false
+	}
+
+	logInfo("PR %d in %s changes %d lines, over the limit of %d", job.PRNumber, job.Payload.Repo, size, settings.MaxPRSize)
+	note := fmt.Sprintf(":straight_ruler: PR #%d changes %d lines, more than the %d %s allows merging from Slack, so it was not queued. Please merge it through the normal review flow.",
+		job.PRNumber, size, settings.MaxPRSize, job.Payload.Repo)
+	if config.SizeOverrideEmoji != "" {
+		note += fmt.Sprintf(" If it really needs to go in now, react with :%s: instead.", config.SizeOverrideEmoji)
+	}
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  fmt.Sprintf("PR size %d over limit of %d", size, settings.MaxPRSize),
+		Note:    note,
+	}, true
+}
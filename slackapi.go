@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"golang.org/x/time/rate"
+)
+
+// slackLimiter spaces out Slack Web API calls so bursts of reactions stay under Slack's rate limits
+var slackLimiter = rate.NewLimiter(rate.Inf, 1)
+
+// configureSlackLimiter applies SLACK_RATE_LIMIT and SLACK_RATE_BURST, where a limit of 0 disables limiting
+func configureSlackLimiter(config *Config) {
+	if config.SlackRateLimit == 0 {
+		slackLimiter.SetLimit(rate.Inf)
+		return
+	}
+	slackLimiter.SetLimit(rate.Every(time.Minute / time.Duration(config.SlackRateLimit)))
+	slackLimiter.SetBurst(config.SlackRateBurst)
+}
+
+// callSlack runs a Slack Web API call through the circuit breaker, failing fast with errSlackCircuitOpen
+// while it is open
+func callSlack(ctx context.Context, method string, call func() error) error {
+	if ctx.Value(bypassBreakerKey{}) != nil {
+		err := retrySlack(ctx, method, call)
+		trackSlackAuth(method, err)
+		return err
+	}
+
+	config := currentConfig()
+	if !slackBreaker.allow(time.Duration(config.SlackBreakerCooldown) * time.Second) {
+		metrics.Add("slack_breaker_rejected", 1)
+		return fmt.Errorf("calling %s: %w", method, errSlackCircuitOpen)
+	}
+	err := retrySlack(ctx, method, call)
+	slackBreaker.record(ctx, err, config.SlackBreakerThreshold)
+	trackSlackAuth(method, err)
+	return err
+}
+
+// slackAuthPaged is set while a page about Slack rejecting the bot token is open
+var slackAuthPaged atomic.Bool
+
+// trackSlackAuth pages when Slack rejects the bot token even after any rotation, and resolves the page once a call
+// succeeds again
+func trackSlackAuth(method string, err error) {
+	if isInvalidToken(err) {
+		if slackAuthPaged.CompareAndSwap(false, true) {
+			triggerPage("slack-auth", fmt.Sprintf("Slack rejected VibeMerge's bot token for %s: %v", method, err))
+		}
+	} else if err == nil && slackAuthPaged.CompareAndSwap(true, false) {
+		resolvePage("slack-auth")
+	}
+}
+
+// retrySlack runs a Slack Web API call once the client-side limiter allows it. When Slack still
+// answers 429, it waits for Retry-After and tries again, up to SLACK_MAX_RETRIES times. A call
+// rejected for its token is retried once after the token is rotated, if rotation is configured.
+func retrySlack(ctx context.Context, method string, call func() error) error {
+	rotated := false
+	for attempt := 0; ; attempt++ {
+		if err := slackLimiter.Wait(ctx); err != nil {
+			return fmt.Errorf("waiting to call %s: %w", method, err)
+		}
+
+		err := call()
+		if isInvalidToken(err) && !rotated {
+			rotated = true
+			ok, rotateErr := slackTokens.rotate(ctx)
+			if rotateErr != nil {
+				logError("Slack rejected the token for %s and rotating it failed: %v", method, rotateErr)
+			}
+			if ok {
+				logWarning("Slack rejected the token for %s, retrying with the rotated token", method)
+				continue
+			}
+		}
+
+		var rateLimited *slack.RateLimitedError
+		if !errors.As(err, &rateLimited) {
+			return err
+		}
+
+		metrics.Add("slack_rate_limited", 1)
+		if attempt >= currentConfig().SlackMaxRetries {
+			return err
+		}
+
+		logWarning("Slack rate limited %s, retrying in %s", method, rateLimited.RetryAfter)
+		timer := time.NewTimer(rateLimited.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (gave up waiting to retry: %v)", err, ctx.Err())
+		case <-timer.C:
+		}
+		metrics.Add("slack_retries", 1)
+	}
+}
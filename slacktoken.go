@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Slack token rotation mechanisms
+const (
+	// TokenRotationOAuth exchanges SLACK_REFRESH_TOKEN for a new bot token with oauth.v2.access
+	TokenRotationOAuth = "oauth"
+	// TokenRotationSecrets re-reads SLACK_BOT_TOKEN from the secrets provider
+	TokenRotationSecrets = "secrets"
+)
+
+// minRotationInterval stops a token that keeps failing from being rotated on every call
+const minRotationInterval = 30 * time.Second
+
+// slackTokens tracks the current value of the SLACK_BOT_TOKEN bot token as it is rotated
+var slackTokens = &tokenRotation{}
+
+// tokenRotation replaces the SLACK_BOT_TOKEN bot token when Slack rejects it. Clients keep the token they were
+// created with and their requests are rewritten to carry the current one, so a call that failed can be retried
+// on the same client.
+type tokenRotation struct {
+	mu          sync.Mutex
+	redisClient *redis.Client
+	configured  string
+	current     string
+	rotatedAt   time.Time
+}
+
+// start loads the latest rotated token, which another instance or an earlier run may have stored in Redis
+func (t *tokenRotation) start(ctx context.Context, redisClient *redis.Client, config *Config) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.redisClient = redisClient
+	t.configured = config.SlackBotToken
+	if config.SlackTokenRotation != TokenRotationOAuth {
+		return nil
+	}
+
+	stored, err := redisClient.HGet(ctx, config.SlackTokenKey, "access_token").Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.SlackTokenKey, err)
+	}
+	t.current = stored
+	return nil
+}
+
+// token returns the token to send in place of a client's configured token
+func (t *tokenRotation) token(configured string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if configured == t.configured {
+		return t.latest()
+	}
+	return configured
+}
+
+// latest returns the token in use for SLACK_BOT_TOKEN. The caller must hold t.mu.
+func (t *tokenRotation) latest() string {
+	if t.current != "" {
+		return t.current
+	}
+	return t.configured
+}
+
+// rotate fetches a new bot token with the configured mechanism. It reports false when rotation is disabled or
+// the token was rotated too recently to try again.
+func (t *tokenRotation) rotate(ctx context.Context) (bool, error) {
+	config := currentConfig()
+	if config.SlackTokenRotation == "" {
+		return false, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.rotatedAt) < minRotationInterval {
+		return false, nil
+	}
+	t.rotatedAt = time.Now()
+
+	var token string
+	var err error
+	switch config.SlackTokenRotation {
+	case TokenRotationOAuth:
+		token, err = t.refreshOAuth(ctx, config)
+	case TokenRotationSecrets:
+		token, err = t.reloadSecret(ctx, config)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	t.current = token
+	metrics.Add("slack_token_rotations", 1)
+	logInfo("Rotated the Slack bot token using %s", config.SlackTokenRotation)
+	return true, nil
+}
+
+// refreshOAuth adopts a token another instance stored since ours failed, or exchanges the refresh token for a
+// new one and stores both for the other instances
+func (t *tokenRotation) refreshOAuth(ctx context.Context, config *Config) (string, error) {
+	stored, err := t.redisClient.HGetAll(ctx, config.SlackTokenKey).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", config.SlackTokenKey, err)
+	}
+	if token := stored["access_token"]; token != "" && token != t.latest() {
+		return token, nil
+	}
+
+	refreshToken := stored["refresh_token"]
+	if refreshToken == "" {
+		refreshToken = config.SlackRefreshToken
+	}
+	response, err := slack.RefreshOAuthV2TokenContext(ctx, http.DefaultClient, config.SlackClientID, config.SlackClientSecret, refreshToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh the Slack bot token: %w", err)
+	}
+
+	err = t.redisClient.HSet(ctx, config.SlackTokenKey,
+		"access_token", response.AccessToken,
+		"refresh_token", response.RefreshToken,
+		"expires_at", time.Now().Add(time.Duration(response.ExpiresIn)*time.Second).UTC().Format(time.RFC3339),
+	).Err()
+	if err != nil {
+		// The refresh token has already been used, so carry on with the new token regardless
+		logError("Failed to store the rotated Slack token in %s: %v", config.SlackTokenKey, err)
+	}
+	return response.AccessToken, nil
+}
+
+// reloadSecret re-reads SLACK_BOT_TOKEN from the secrets provider
+func (t *tokenRotation) reloadSecret(ctx context.Context, config *Config) (string, error) {
+	updated := *config
+	if err := loadSecrets(ctx, &updated); err != nil {
+		return "", err
+	}
+	if updated.SlackBotToken == t.latest() {
+		return "", fmt.Errorf("the secrets provider still has the rejected SLACK_BOT_TOKEN")
+	}
+	return updated.SlackBotToken, nil
+}
+
+// isInvalidToken reports whether Slack rejected a call because of the token
+func isInvalidToken(err error) bool {
+	var slackErr slack.SlackErrorResponse
+	if !errors.As(err, &slackErr) {
+		return false
+	}
+	return slackErr.Err == "invalid_auth" || slackErr.Err == "token_expired" || slackErr.Err == "token_revoked"
+}
+
+// rotatingTokenTransport sends a client's requests with the current value of its token. slack-go passes the token
+// in the Authorization header, the query string or the form body depending on the method, so all three are checked.
+type rotatingTokenTransport struct {
+	configured string
+}
+
+func (r rotatingTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := slackTokens.token(r.configured)
+	if token == r.configured {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	// Only replace the configured token, leaving requests made with other tokens alone
+	if req.Header.Get("Authorization") == "Bearer "+r.configured {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if query := req.URL.Query(); query.Get("token") == r.configured {
+		query.Set("token", token)
+		req.URL.RawQuery = query.Encode()
+	}
+	if req.Body != nil && strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if form, err := url.ParseQuery(string(body)); err == nil && form.Get("token") == r.configured {
+			form.Set("token", token)
+			body = []byte(form.Encode())
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
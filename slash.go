@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+const slashHelp = "Usage: `/vibemerge status` | `pause [reason]` | `resume` | `queue list` | `merge <pr-url>`"
+
+func processSlashCommands(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	receiveMessages(ctx, redisClient, clients, currentConfig().SlashChannel, func(payload string) {
+		if err := handleSlashCommand(context.WithoutCancel(ctx), payload, redisClient, clients, currentConfig()); err != nil {
+			logError("Error handling slash command: %v", err)
+		}
+	})
+}
+
+func handleSlashCommand(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, config *Config) error {
+	var cmd slack.SlashCommand
+	if err := json.Unmarshal([]byte(payload), &cmd); err != nil {
+		return fmt.Errorf("failed to unmarshal slash command: %w", err)
+	}
+
+	if cmd.Command != config.SlashCommand {
+		logDebug("Ignoring slash command: %s", cmd.Command)
+		return nil
+	}
+
+	args := strings.Fields(cmd.Text)
+	if len(args) == 0 {
+		return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral, slashHelp)
+	}
+
+	logInfo("Processing %s %s from user %s in channel %s", cmd.Command, cmd.Text, cmd.UserID, cmd.ChannelID)
+
+	switch strings.ToLower(args[0]) {
+	case "status":
+		status, err := describeStatus(ctx, redisClient, config)
+		if err != nil {
+			return err
+		}
+		return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral, status)
+
+	case "pause":
+		if !config.isAuthorized(cmd.UserID) {
+			return denyMergeControl(ctx, cmd, "pause")
+		}
+		reason := fmt.Sprintf("paused by <@%s>", cmd.UserID)
+		if len(args) > 1 {
+			reason = fmt.Sprintf("%s: %s", reason, strings.Join(args[1:], " "))
+		}
+		if err := pauseMerging(ctx, redisClient, config, reason); err != nil {
+			return err
+		}
+		return respondToSlashCommand(ctx, cmd, slack.ResponseTypeInChannel,
+			fmt.Sprintf(":double_vertical_bar: <@%s> paused merging. Reactions will not queue merges until resumed.", cmd.UserID))
+
+	case "resume":
+		if !config.isAuthorized(cmd.UserID) {
+			return denyMergeControl(ctx, cmd, "resume")
+		}
+		if err := resumeMerging(ctx, redisClient, config); err != nil {
+			return err
+		}
+		return respondToSlashCommand(ctx, cmd, slack.ResponseTypeInChannel,
+			fmt.Sprintf(":arrow_forward: <@%s> resumed merging.", cmd.UserID))
+
+	case "queue":
+		listing, err := describeQueue(ctx, redisClient, config)
+		if err != nil {
+			return err
+		}
+		return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral, listing)
+
+	case "merge":
+		if len(args) < 2 {
+			return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral, "Usage: `/vibemerge merge <pr-url>`")
+		}
+		metadata, err := parsePRURL(args[1], config)
+		if err != nil {
+			return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral, fmt.Sprintf(":warning: %v", err))
+		}
+
+		decision, _, err := requestManualMerge(ctx, redisClient, clients, config, metadata, "slash", cmd.TeamID, cmd.UserID, cmd.ChannelID)
+		if err != nil {
+			return err
+		}
+
+		note := decision.Note
+		if note == "" {
+			note = fmt.Sprintf(":heart_eyes_cat: Queued merge of %s#%d.", metadata.Repository, metadata.PRNumber)
+		}
+		return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral, note)
+
+	default:
+		return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral, slashHelp)
+	}
+}
+
+// requestManualMerge submits a merge of a PR given by URL rather than by reaction, recording it in the audit log
+// under source
+func requestManualMerge(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, metadata *PRMetadata, source, teamID, user, channel string) (Decision, MergeJob, error) {
+	job, err := manualMergeJob(ctx, redisClient, clients, config, metadata, teamID, user, channel, "")
+	if err != nil {
+		return Decision{}, MergeJob{}, err
+	}
+	audit := AuditEntry{
+		EventTime:     job.EventTime,
+		Source:        source,
+		User:          user,
+		TeamID:        teamID,
+		GitHubUser:    job.Payload.GitHubUser,
+		CorrelationID: job.Payload.CorrelationID,
+		Channel:       channel,
+		Repository:    metadata.Repository,
+		PRNumber:      metadata.PRNumber,
+	}
+	decision, err := submitMerge(ctx, redisClient, config, job)
+	audit.finish(ctx, redisClient, config, decision, err)
+	return decision, job, err
+}
+
+// manualMergeJob builds the merge job for a PR the user asked to merge other than by reacting, with the workspace's
+// merge commands
+func manualMergeJob(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, metadata *PRMetadata, teamID, user, channel, timestamp string) (MergeJob, error) {
+	workspace := config.workspace(teamID)
+	templates, _ := config.commandTemplates(metadata.Repository, workspace.TargetEmoji, config.DefaultCommands)
+	requested := withGitHubLogin(ctx, redisClient, clients.forWorkspace(workspace), config, metadata, user)
+	requested, err := withSquashFlags(ctx, clients.forWorkspace(workspace), config, requested, user)
+	if err != nil {
+		return MergeJob{}, err
+	}
+	job, err := newMergeJob(config, requested, templates, teamID, user, channel, timestamp)
+	if err != nil {
+		return MergeJob{}, err
+	}
+	job.EventTime = time.Now().UTC()
+	return job, nil
+}
+
+// denyMergeControl refuses to pause or resume merging for a user outside AUTHORIZED_USERS, who could otherwise stop
+// every merge or lift a freeze
+func denyMergeControl(ctx context.Context, cmd slack.SlashCommand, action string) error {
+	logInfo("User %s is not authorized to %s merging", cmd.UserID, action)
+	return respondToSlashCommand(ctx, cmd, slack.ResponseTypeEphemeral,
+		fmt.Sprintf(":lock: Sorry <@%s>, you're not on the list of people who can merge with VibeMerge, so you can't %s merging.", cmd.UserID, action))
+}
+
+// respondToSlashCommand replies to the user via the command's response_url
+func respondToSlashCommand(ctx context.Context, cmd slack.SlashCommand, responseType, text string) error {
+	if cmd.ResponseURL == "" {
+		return fmt.Errorf("slash command from user %s has no response_url", cmd.UserID)
+	}
+
+	msg := &slack.WebhookMessage{
+		ResponseType: responseType,
+		Text:         text,
+	}
+	if err := slack.PostWebhookContext(ctx, cmd.ResponseURL, msg); err != nil {
+		return fmt.Errorf("failed to respond to slash command: %w", err)
+	}
+	return nil
+}
+
+func describeStatus(ctx context.Context, redisClient *redis.Client, config *Config) (string, error) {
+	var b strings.Builder
+	b.WriteString("*VibeMerge status*\n")
+
+	paused, reason, err := pauseState(ctx, redisClient, config)
+	if err != nil {
+		return "", err
+	}
+	if paused {
+		fmt.Fprintf(&b, "• Merging: paused (%s)\n", reason)
+	} else {
+		b.WriteString("• Merging: active\n")
+	}
+
+	if until, blocked := config.frozenUntil(time.Now().In(config.Timezone)); blocked {
+		fmt.Fprintf(&b, "• Blackout: in effect until %s\n", until.Format("Mon 15:04 MST"))
+	} else {
+		b.WriteString("• Blackout: none\n")
+	}
+
+	queued, err := readPoppitQueueLength(ctx, redisClient, config)
+	if err != nil {
+		return "", err
+	}
+	deferred, err := redisClient.ZCard(ctx, config.DeferredQueue).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to read length of %s: %w", config.DeferredQueue, err)
+	}
+	if config.commandsInRedis() {
+		fmt.Fprintf(&b, "• Poppit queue length: %d\n", queued)
+	} else {
+		fmt.Fprintf(&b, "• Poppit queue length: unknown, commands go over %s\n", config.Transport)
+	}
+	fmt.Fprintf(&b, "• Deferred merges: %d", deferred)
+
+	return b.String(), nil
+}
+
+func describeQueue(ctx context.Context, redisClient *redis.Client, config *Config) (string, error) {
+	queued, err := readPoppitQueues(ctx, redisClient, config)
+	if err != nil {
+		return "", err
+	}
+	deferred, err := redisClient.ZRangeWithScores(ctx, config.DeferredQueue, 0, -1).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", config.DeferredQueue, err)
+	}
+
+	var b strings.Builder
+	b.WriteString("*Queued for Poppit*\n")
+	count := 0
+	for _, entry := range queued {
+		var payload PoppitPayload
+		if err := json.Unmarshal([]byte(entry), &payload); err != nil || payload.Type != "vibe-merge" || len(payload.Commands) == 0 {
+			continue
+		}
+		count++
+		fmt.Fprintf(&b, "• %s: `%s`\n", payload.Repo, payload.Commands[len(payload.Commands)-1])
+	}
+	if !config.commandsInRedis() {
+		fmt.Fprintf(&b, "• not listed, commands go over %s\n", config.Transport)
+	} else if count == 0 {
+		b.WriteString("• nothing queued\n")
+	}
+
+	b.WriteString("*Deferred*\n")
+	if len(deferred) == 0 {
+		b.WriteString("• nothing deferred")
+	}
+	for _, entry := range deferred {
+		var job MergeJob
+		member, _ := entry.Member.(string)
+		if err := json.Unmarshal([]byte(member), &job); err != nil {
+			continue
+		}
+		releaseAt := time.Unix(int64(entry.Score), 0).In(config.Timezone)
+		fmt.Fprintf(&b, "• %s#%d at %s\n", job.Payload.Repo, job.PRNumber, releaseAt.Format("Mon 15:04 MST"))
+	}
+
+	return strings.TrimRight(b.String(), "\n"), nil
+}
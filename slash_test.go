@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+func TestSlashPauseResumeAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       string
+		command    string
+		paused     bool
+		wantPaused bool
+		wantType   string
+	}{
+		{"unauthorized pause is refused", "U_OTHER", "pause", false, false, slack.ResponseTypeEphemeral},
+		{"unauthorized resume is refused", "U_OTHER", "resume", true, true, slack.ResponseTypeEphemeral},
+		{"authorized pause", "U_ADMIN", "pause", false, true, slack.ResponseTypeInChannel},
+		{"authorized resume", "U_ADMIN", "resume", true, false, slack.ResponseTypeInChannel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer redisClient.Close()
+
+			var reply slack.WebhookMessage
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&reply); err != nil {
+					t.Errorf("failed to decode the slash command reply: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			config := &Config{
+				SlashCommand:    "/vibemerge",
+				PauseKey:        "vibemerge:paused",
+				AuthorizedUsers: []string{"U_ADMIN"},
+			}
+			if tt.paused {
+				mr.Set(config.PauseKey, "paused by <@U_ADMIN>")
+			}
+
+			payload, err := json.Marshal(slack.SlashCommand{
+				Command:     "/vibemerge",
+				Text:        tt.command,
+				UserID:      tt.user,
+				ResponseURL: server.URL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := handleSlashCommand(context.Background(), string(payload), redisClient, nil, config); err != nil {
+				t.Fatalf("handleSlashCommand() error = %v", err)
+			}
+
+			if paused := mr.Exists(config.PauseKey); paused != tt.wantPaused {
+				t.Errorf("paused = %v, want %v", paused, tt.wantPaused)
+			}
+			if reply.ResponseType != tt.wantType {
+				t.Errorf("response type = %q, want %q", reply.ResponseType, tt.wantType)
+			}
+			if tt.wantType == slack.ResponseTypeEphemeral && !strings.Contains(reply.Text, ":lock:") {
+				t.Errorf("reply = %q, want a denial", reply.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// Input modes select where reactions and slash commands come from
+const (
+	InputModeRelay  = "relay"
+	InputModeSocket = "socket"
+)
+
+// processSocketMode receives reactions, slash commands, interactions, mentions and App Home events directly from Slack over Socket Mode,
+// in place of the slack-relay Redis channels
+func processSocketMode(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	config := currentConfig()
+	api := slack.New(config.SlackBotToken, slack.OptionAppLevelToken(config.SlackAppToken))
+	client := socketmode.New(api)
+
+	dispatcher := newReactionDispatcher(config.WorkerCount)
+	// Let queued and in-flight events finish even if shutdown begins while they are pending
+	defer dispatcher.close()
+
+	// RunContext reconnects on its own and only returns once ctx is cancelled or authentication fails
+	go func() {
+		if err := client.RunContext(ctx); err != nil && ctx.Err() == nil {
+			logError("Socket Mode connection stopped: %v", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-client.Events:
+			switch evt.Type {
+			case socketmode.EventTypeConnected:
+				logInfo("Connected to Slack over Socket Mode")
+
+			case socketmode.EventTypeConnectionError:
+				logError("Socket Mode connection error: %v", evt.Data)
+
+			case socketmode.EventTypeEventsAPI:
+				// Acknowledge straight away so Slack doesn't redeliver while the event is handled
+				client.Ack(*evt.Request)
+
+				payload := string(evt.Request.Payload)
+				var reactionEvent ReactionEvent
+				if err := json.Unmarshal(evt.Request.Payload, &reactionEvent); err != nil {
+					logError("Error decoding Socket Mode event: %v", err)
+					continue
+				}
+				if reactionEvent.Event.Type == "app_home_opened" && currentConfig().AppHome {
+					if err := handleAppHomeOpened(context.WithoutCancel(ctx), payload, redisClient, clients, currentConfig()); err != nil {
+						logError("Error publishing App Home: %v", err)
+					}
+					continue
+				}
+				if reactionEvent.Event.Type == "app_mention" && currentConfig().ScheduledMerges {
+					if err := handleMention(context.WithoutCancel(ctx), payload, redisClient, clients, currentConfig()); err != nil {
+						logError("Error handling mention: %v", err)
+					}
+					continue
+				}
+				if reactionEvent.Event.Type != "reaction_added" {
+					logDebug("Ignoring Socket Mode event: %s", reactionEvent.Event.Type)
+					continue
+				}
+
+				dispatcher.submit(ctx, payload, redisClient, clients, func() {})
+
+			case socketmode.EventTypeInteractive:
+				client.Ack(*evt.Request)
+
+				if !currentConfig().interactive() {
+					continue
+				}
+				if err := handleInteraction(context.WithoutCancel(ctx), string(evt.Request.Payload), redisClient, clients, currentConfig()); err != nil {
+					logError("Error handling Slack interaction: %v", err)
+				}
+
+			case socketmode.EventTypeSlashCommand:
+				client.Ack(*evt.Request)
+
+				cmd, ok := evt.Data.(slack.SlashCommand)
+				if !ok {
+					continue
+				}
+				payload, err := json.Marshal(cmd)
+				if err != nil {
+					logError("Error encoding Socket Mode slash command: %v", err)
+					continue
+				}
+				if err := handleSlashCommand(context.WithoutCancel(ctx), string(payload), redisClient, clients, currentConfig()); err != nil {
+					logError("Error handling slash command: %v", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// sqsRequestTimeout bounds each SQS and SNS request on top of the long poll of SQS_WAIT_TIME
+const sqsRequestTimeout = 30 * time.Second
+
+// sqsTransport carries events over SQS queues and Poppit commands over SQS or SNS, with the AWS SDK's credential
+// chain like the AWS Secrets Manager provider. Each channel is the queue named SQS_QUEUE_PREFIX plus the channel; a
+// message is deleted once its event is handled, so events sent while VibeMerge is down or failing over are handled
+// once it is back. With SNS_TOPIC_ARN set, Poppit commands are published to that topic instead of the POPPIT_QUEUE
+// queue.
+type sqsTransport struct {
+	queuePrefix string
+	waitTime    int32
+	topicARN    string
+	sqs         *sqs.Client
+	sns         *sns.Client
+
+	mu        sync.Mutex
+	queueURLs map[string]string
+}
+
+func newSQSTransport(config *Config) (*sqsTransport, error) {
+	if config.AWSRegion == "" {
+		return nil, fmt.Errorf("TRANSPORT=%s requires AWS_REGION", TransportSQS)
+	}
+	httpClient := awshttp.NewBuildableClient().WithTimeout(sqsRequestTimeout + time.Duration(config.SQSWaitTime)*time.Second)
+	awsConfig, err := loadAWSConfig(context.Background(), config, awsconfig.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimSuffix(config.AWSEndpointURL, "/")
+	return &sqsTransport{
+		queuePrefix: config.SQSQueuePrefix,
+		waitTime:    int32(config.SQSWaitTime),
+		topicARN:    config.SNSTopicARN,
+		sqs: sqs.NewFromConfig(awsConfig, func(o *sqs.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		}),
+		sns: sns.NewFromConfig(awsConfig, func(o *sns.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		}),
+		queueURLs: make(map[string]string),
+	}, nil
+}
+
+// queueURL looks up the URL of a channel's queue, caching it
+func (t *sqsTransport) queueURL(ctx context.Context, channel string) (string, error) {
+	t.mu.Lock()
+	queueURL, ok := t.queueURLs[channel]
+	t.mu.Unlock()
+	if ok {
+		return queueURL, nil
+	}
+
+	output, err := t.sqs.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(t.queuePrefix + channel)})
+	if err != nil {
+		return "", fmt.Errorf("SQS GetQueueUrl failed: %w", err)
+	}
+	queueURL = aws.ToString(output.QueueUrl)
+	t.mu.Lock()
+	t.queueURLs[channel] = queueURL
+	t.mu.Unlock()
+	return queueURL, nil
+}
+
+// Receive long-polls a channel's queue for one message at a time, deleting each once its event has been handled,
+// even when that happens on a worker. Requests that fail are retried with backoff.
+func (t *sqsTransport) Receive(ctx context.Context, channel string, handle func(payload string, done func())) {
+	backoff := resubscribeMinBackoff
+	logged := false
+	for ctx.Err() == nil {
+		err := t.receiveOne(ctx, channel, handle)
+		if err == nil {
+			if !logged {
+				logInfo("Consuming %s from SQS", channel)
+				logged = true
+			}
+			backoff = resubscribeMinBackoff
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		logError("Failed to consume %s from SQS: %v (retrying in %s)", channel, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, resubscribeMaxBackoff)
+	}
+}
+
+func (t *sqsTransport) receiveOne(ctx context.Context, channel string, handle func(payload string, done func())) error {
+	queueURL, err := t.queueURL(ctx, channel)
+	if err != nil {
+		return err
+	}
+	output, err := t.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(queueURL),
+		MaxNumberOfMessages: 1,
+		WaitTimeSeconds:     t.waitTime,
+		// Hidden from other instances while it is handled, as a pending NATS message is
+		VisibilityTimeout: int32(currentConfig().EventTimeout + 60),
+	})
+	if err != nil {
+		return fmt.Errorf("SQS ReceiveMessage failed: %w", err)
+	}
+
+	for _, msg := range output.Messages {
+		handleAndWait(unwrapSNSMessage(aws.ToString(msg.Body)), handle)
+		// Delete even during shutdown, so the event just handled isn't handled again
+		deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sqsRequestTimeout)
+		_, err := t.sqs.DeleteMessage(deleteCtx, &sqs.DeleteMessageInput{QueueUrl: aws.String(queueURL), ReceiptHandle: msg.ReceiptHandle})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("SQS DeleteMessage failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// unwrapSNSMessage returns the message of an SNS notification delivered to SQS without raw message delivery, and
+// any other body as it is
+func unwrapSNSMessage(body string) string {
+	var notification struct {
+		Type     string `json:"Type"`
+		TopicArn string `json:"TopicArn"`
+		Message  string `json:"Message"`
+	}
+	if json.Unmarshal([]byte(body), &notification) == nil && notification.Type == "Notification" && notification.TopicArn != "" {
+		return notification.Message
+	}
+	return body
+}
+
+// Publish sends a message to a channel's queue
+func (t *sqsTransport) Publish(ctx context.Context, channel, payload string) error {
+	queueURL, err := t.queueURL(ctx, channel)
+	if err != nil {
+		return err
+	}
+	input := &sqs.SendMessageInput{QueueUrl: aws.String(queueURL), MessageBody: aws.String(payload)}
+	if strings.HasSuffix(queueURL, ".fifo") {
+		input.MessageGroupId = aws.String("vibemerge")
+		input.MessageDeduplicationId = aws.String(deduplicationID(payload))
+	}
+	if _, err := t.sqs.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("SQS SendMessage failed: %w", err)
+	}
+	return nil
+}
+
+// Push sends a Poppit command to SNS_TOPIC_ARN when set, or else to the queue's SQS queue
+func (t *sqsTransport) Push(ctx context.Context, queue, payload string) error {
+	if t.topicARN == "" {
+		return t.Publish(ctx, queue, payload)
+	}
+
+	input := &sns.PublishInput{TopicArn: aws.String(t.topicARN), Message: aws.String(payload)}
+	if strings.HasSuffix(t.topicARN, ".fifo") {
+		input.MessageGroupId = aws.String("vibemerge")
+		input.MessageDeduplicationId = aws.String(deduplicationID(payload))
+	}
+	if _, err := t.sns.Publish(ctx, input); err != nil {
+		return fmt.Errorf("SNS Publish to %s failed: %w", t.topicARN, err)
+	}
+	return nil
+}
+
+// deduplicationID identifies a message sent to a FIFO queue or topic by its content
+func deduplicationID(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/slack-go/slack"
+)
+
+// parseSquashTemplate parses SQUASH_SUBJECT or SQUASH_BODY, the squash commit's title or body. It's a template
+// like the comments in COMMENTS_FILE; an empty value leaves that part of the commit message to GitHub.
+func parseSquashTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// withSquashFlags returns a copy of a PR's metadata with the squash commit message rendered into the
+// --subject and --body flags of `gh pr merge`, which merge command templates use as {{.SquashFlags}}. The PR's title
+// is read from GitHub when the message doesn't carry it and GITHUB_TOKEN or a GitHub App is set up.
+func withSquashFlags(ctx context.Context, slackClient *slack.Client, config *Config, metadata *PRMetadata, slackUser string) (*PRMetadata, error) {
+	requested := *metadata
+	if config.SquashSubject == nil && config.SquashBody == nil {
+		return &requested, nil
+	}
+
+	if requested.Title == "" && config.hasGitHubAPI() {
+		pr, err := getPullRequest(ctx, config, requested.Repository, requested.PRNumber)
+		if err != nil {
+			logWarning("Failed to read the title of PR %d in %s: %v", requested.PRNumber, requested.Repository, err)
+		}
+		requested.Title = pr.Title
+	}
+
+	data := CommentContext{PRMetadata: &requested, SlackUser: slackUser, ctx: ctx, slackClient: slackClient}
+	var flags []string
+	for _, part := range []struct {
+		flag string
+		tmpl *template.Template
+	}{{"--subject", config.SquashSubject}, {"--body", config.SquashBody}} {
+		if part.tmpl == nil {
+			continue
+		}
+		var b bytes.Buffer
+		if err := part.tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", part.tmpl.Name(), err)
+		}
+		flags = append(flags, part.flag+" "+shellQuote(b.String()))
+	}
+	requested.SquashFlags = strings.Join(flags, " ")
+	return &requested, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// maxStackDepth bounds how far down a stack STACK_EMOJI walks, in case the recorded parents loop
+const maxStackDepth = 20
+
+// checkStackEmoji rejects a stack emoji that already does something else
+func (c *Config) checkStackEmoji() error {
+	switch c.StackEmoji {
+	case "":
+		return nil
+	case c.TargetEmoji, c.ReadyEmoji, c.ApproveEmoji, c.CloseEmoji, c.RevertEmoji, c.CancelEmoji, c.SizeOverrideEmoji, c.UrgentEmoji:
+		return fmt.Errorf("STACK_EMOJI %q is already a merge, ready, approve, close, revert, cancel, size override or urgent emoji", c.StackEmoji)
+	}
+	if _, ok := c.Reactions[c.StackEmoji]; ok {
+		return fmt.Errorf("STACK_EMOJI %q has actions in REACTIONS_FILE or COMMENTS_FILE", c.StackEmoji)
+	}
+	if _, ok := c.Workflows[c.StackEmoji]; ok {
+		return fmt.Errorf("STACK_EMOJI %q has a workflow in WORKFLOWS_FILE", c.StackEmoji)
+	}
+	return nil
+}
+
+func stackKey(config *Config, repo string, prNumber int) string {
+	return fmt.Sprintf("%s:%s#%d", config.StackKeyPrefix, repo, prNumber)
+}
+
+// trackStack remembers the parent a PR's message names in depends_on, so the stack can be walked from a PR above it
+func trackStack(ctx context.Context, redisClient *redis.Client, config *Config, metadata *PRMetadata) {
+	if metadata.DependsOn == 0 || redisClient == nil {
+		return
+	}
+	key := stackKey(config, metadata.Repository, metadata.PRNumber)
+	if err := redisClient.Set(ctx, key, metadata.DependsOn, time.Duration(config.StackTTL)*time.Second).Err(); err != nil {
+		logWarning("Failed to set %s: %v", key, err)
+	}
+}
+
+// stackParent returns the PR a PR is stacked on, 0 for none: the depends_on recorded from its message, or else the
+// open PR whose branch it merges into, when GITHUB_TOKEN or a GitHub App is set up
+func stackParent(ctx context.Context, redisClient *redis.Client, config *Config, repo string, prNumber int) (int, error) {
+	key := stackKey(config, repo, prNumber)
+	parent, err := redisClient.Get(ctx, key).Result()
+	if err == nil {
+		return strconv.Atoi(parent)
+	}
+	if !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if !config.hasGitHubAPI() {
+		return 0, nil
+	}
+
+	pr, err := getPullRequest(ctx, config, repo, prNumber)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PR %d in %s: %w", prNumber, repo, err)
+	}
+	owner, _, _ := strings.Cut(repo, "/")
+	var parents []githubPullRequest
+	path := fmt.Sprintf("/repos/%s/pulls?state=open&head=%s", repo, url.QueryEscape(owner+":"+pr.Base.Ref))
+	if err := githubRequest(ctx, config, http.MethodGet, path, nil, &parents); err != nil {
+		return 0, fmt.Errorf("failed to find the PR of %s in %s: %w", pr.Base.Ref, repo, err)
+	}
+	if len(parents) == 0 {
+		return 0, nil
+	}
+	return parents[0].Number, nil
+}
+
+// checkStack denies a merge of a PR stacked on a parent that isn't merged yet, since merging it would land it on the
+// parent's branch rather than the base branch
+func checkStack(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, bool, error) {
+	if job.DependsOn == 0 {
+		return Decision{}, false, nil
+	}
+	sha, err := mergeCommit(ctx, redisClient, config, job.Payload.Repo, job.DependsOn)
+	if err != nil {
+		return Decision{}, false, err
+	}
+	if sha != "" {
+		return Decision{}, false, nil
+	}
+
+	logInfo("PR %d in %s depends on PR %d, which isn't merged", job.PRNumber, job.Payload.Repo, job.DependsOn)
+	note := fmt.Sprintf(":link: PR #%d is stacked on PR #%d, which isn't merged yet, so it was not queued.", job.PRNumber, job.DependsOn)
+	if config.StackEmoji != "" {
+		note += fmt.Sprintf(" React with :%s: to merge the whole stack from the bottom up.", config.StackEmoji)
+	}
+	return Decision{
+		Outcome: OutcomeDenied,
+		Reason:  fmt.Sprintf("parent PR %d not merged", job.DependsOn),
+		Note:    note,
+	}, true, nil
+}
+
+// handleStackReaction merges the stack under a PR bottom up, one PR at a time like an all-or-nothing digest. The
+// stack is walked down from the PR through the parents that aren't merged yet.
+func handleStackReaction(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, reactionEvent ReactionEvent, metadata *PRMetadata, audit AuditEntry) error {
+	trackStack(ctx, redisClient, config, metadata)
+	stack, denied, err := walkStack(ctx, redisClient, config, metadata)
+	if err != nil || denied != "" {
+		audit.Source = "stack"
+		audit.Repository, audit.PRNumber = metadata.Repository, metadata.PRNumber
+		decision := Decision{Outcome: OutcomeDenied, Reason: denied}
+		audit.finish(context.WithoutCancel(ctx), redisClient, config, decision, err)
+		if err != nil {
+			return err
+		}
+		notifyThread(ctx, slackClient, audit.Channel, audit.Ts, fmt.Sprintf(":link: The stack under PR #%d can't be merged: %s.", metadata.PRNumber, denied))
+		return nil
+	}
+
+	var numbers []string
+	for _, entry := range stack {
+		numbers = append(numbers, fmt.Sprintf("#%d", entry.PRNumber))
+	}
+	logInfo("Merging the stack of PR %d in %s bottom up: %s", metadata.PRNumber, metadata.Repository, strings.Join(numbers, ", "))
+	if len(stack) > 1 {
+		notifyThread(ctx, slackClient, audit.Channel, audit.Ts, fmt.Sprintf(":link: Merging the stack from the bottom up, each PR once the one below it is merged: %s.", strings.Join(numbers, " → ")))
+	}
+
+	// Each PR of the stack merges like the target emoji, and the event is kept in Redis without its verification token
+	reactionEvent.Event.Reaction = config.workspace(reactionEvent.TeamID).TargetEmoji
+	reactionEvent.Token = ""
+	fanOut := FanOut{Event: reactionEvent, Audit: audit, Entries: stack, Source: "stack"}
+	return fanOut.run(ctx, redisClient, slackClient, config)
+}
+
+// walkStack lists the PRs of the stack under a PR that aren't merged, bottom first, the PR itself last. It returns
+// why the stack can't be merged instead when one of them is closed.
+func walkStack(ctx context.Context, redisClient *redis.Client, config *Config, metadata *PRMetadata) ([]PRMetadata, string, error) {
+	top := *metadata
+	top.PRs = nil
+	if top.DependsOn == 0 {
+		parent, err := stackParent(ctx, redisClient, config, top.Repository, top.PRNumber)
+		if err != nil {
+			return nil, "", err
+		}
+		top.DependsOn = parent
+	}
+
+	stack := []PRMetadata{top}
+	seen := []int{top.PRNumber}
+	for parent := top.DependsOn; parent != 0; {
+		if slices.Contains(seen, parent) || len(stack) >= maxStackDepth {
+			return nil, fmt.Sprintf("its parents loop or go deeper than %d PRs", maxStackDepth), nil
+		}
+		seen = append(seen, parent)
+		sha, err := mergeCommit(ctx, redisClient, config, top.Repository, parent)
+		if err != nil {
+			return nil, "", err
+		}
+		if sha != "" {
+			break
+		}
+
+		entry := PRMetadata{Repository: top.Repository, PRNumber: parent, EventAction: top.EventAction}
+		if config.hasGitHubAPI() {
+			pr, err := getPullRequest(ctx, config, top.Repository, parent)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to read PR %d in %s: %w", parent, top.Repository, err)
+			}
+			if pr.State == "closed" {
+				return nil, fmt.Sprintf("PR #%d below it was closed without being merged", parent), nil
+			}
+			entry.Author, entry.Branch, entry.BaseBranch, entry.Title = pr.User.Login, pr.Head.Ref, pr.Base.Ref, pr.Title
+		}
+		if entry.DependsOn, err = stackParent(ctx, redisClient, config, top.Repository, parent); err != nil {
+			return nil, "", err
+		}
+		stack = append([]PRMetadata{entry}, stack...)
+		parent = entry.DependsOn
+	}
+	return stack, "", nil
+}
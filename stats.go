@@ -0,0 +1,187 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fields of the per-day stats hashes
+const (
+	statsTotalField = "total"
+	statsRepoPrefix = "repo:"
+	statsUserPrefix = "user:"
+)
+
+// DayStats counts the merges handed to Poppit on one day
+type DayStats struct {
+	Date  string           `json:"date"`
+	Total int64            `json:"total"`
+	Repos map[string]int64 `json:"repos"`
+	Users map[string]int64 `json:"users"`
+}
+
+func statsKey(config *Config, day string) string {
+	return fmt.Sprintf("%s:%s", config.StatsKeyPrefix, day)
+}
+
+// recordMergeStats counts a merge handed to Poppit against its day, repository and requester
+func recordMergeStats(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) error {
+	user := job.Payload.GitHubUser
+	if user == "" {
+		user = job.RequestedBy
+	}
+	key := statsKey(config, time.Now().In(config.Timezone).Format(time.DateOnly))
+
+	pipe := redisClient.TxPipeline()
+	pipe.HIncrBy(ctx, key, statsTotalField, 1)
+	pipe.HIncrBy(ctx, key, statsRepoPrefix+job.Payload.Repo, 1)
+	pipe.HIncrBy(ctx, key, statsUserPrefix+user, 1)
+	pipe.Expire(ctx, key, time.Duration(config.StatsRetentionDays)*24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update %s: %w", key, err)
+	}
+	return nil
+}
+
+// readStats returns the counters for the given number of days up to and including today, oldest first
+func readStats(ctx context.Context, redisClient *redis.Client, config *Config, days int) ([]DayStats, error) {
+	today := time.Now().In(config.Timezone)
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, days)
+	dates := make([]string, days)
+	for i := range days {
+		dates[i] = today.AddDate(0, 0, i-days+1).Format(time.DateOnly)
+		cmds[i] = pipe.HGetAll(ctx, statsKey(config, dates[i]))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read stats: %w", err)
+	}
+
+	stats := make([]DayStats, days)
+	for i, cmd := range cmds {
+		stats[i] = DayStats{Date: dates[i], Repos: make(map[string]int64), Users: make(map[string]int64)}
+		for field, value := range cmd.Val() {
+			count, _ := strconv.ParseInt(value, 10, 64)
+			if repo, ok := strings.CutPrefix(field, statsRepoPrefix); ok {
+				stats[i].Repos[repo] = count
+			} else if user, ok := strings.CutPrefix(field, statsUserPrefix); ok {
+				stats[i].Users[user] = count
+			} else if field == statsTotalField {
+				stats[i].Total = count
+			}
+		}
+	}
+	return stats, nil
+}
+
+// parseStatsDays validates the number of days asked for against what is retained
+func parseStatsDays(value string, config *Config) (int, error) {
+	if value == "" {
+		return 7, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > config.StatsRetentionDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", config.StatsRetentionDays)
+	}
+	return days, nil
+}
+
+// statsHandler serves the merge counters as JSON, e.g. GET /stats?days=30
+func statsHandler(redisClient *redis.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		config := currentConfig()
+		days, err := parseStatsDays(r.URL.Query().Get("days"), config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stats, err := readStats(r.Context(), redisClient, config, days)
+		if err != nil {
+			logError("Error reading stats: %v", err)
+			http.Error(w, "failed to read stats", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
+}
+
+// runStatsCommand implements `vibemerge stats`, printing merge counts per day, repository and requester
+func runStatsCommand(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	days := flags.String("days", "7", "number of days to show, ending today")
+	asJSON := flags.Bool("json", false, "print the counters as JSON, as served at /stats")
+	flags.Parse(args)
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	n, err := parseStatsDays(*days, config)
+	if err != nil {
+		return fmt.Errorf("invalid -days: %w", err)
+	}
+
+	ctx := context.Background()
+	redisClient := newRedisClient(config)
+	defer redisClient.Close()
+
+	stats, err := readStats(ctx, redisClient, config, n)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(stats)
+	}
+
+	var total int64
+	repos := make(map[string]int64)
+	users := make(map[string]int64)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tMERGES")
+	for _, day := range stats {
+		fmt.Fprintf(w, "%s\t%d\n", day.Date, day.Total)
+		total += day.Total
+		for repo, count := range day.Repos {
+			repos[repo] += count
+		}
+		for user, count := range day.Users {
+			users[user] += count
+		}
+	}
+	fmt.Fprintf(w, "total\t%d\n\nREPOSITORY\tMERGES\n", total)
+	writeStatsCounts(w, repos)
+	fmt.Fprintln(w, "\nREQUESTER\tMERGES")
+	writeStatsCounts(w, users)
+	return w.Flush()
+}
+
+// writeStatsCounts prints counts as table rows, largest first
+func writeStatsCounts(w *tabwriter.Writer, counts map[string]int64) {
+	names := slices.SortedFunc(maps.Keys(counts), func(x, y string) int {
+		return cmp.Or(cmp.Compare(counts[y], counts[x]), cmp.Compare(x, y))
+	})
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%d\n", name, counts[name])
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Merge statuses shown on a PR's Slack message with MERGE_STATUS_UPDATES
+const (
+	MergeStatusQueued  = "queued"
+	MergeStatusMerging = "merging"
+	MergeStatusMerged  = "merged"
+	MergeStatusFailed  = "failed"
+)
+
+// PoppitStateStarted marks a Poppit result published when Poppit starts running a payload rather than finishing it
+const PoppitStateStarted = "started"
+
+// statusChange is the processMessageCleanups mode of a queued status update
+const statusChange = "status"
+
+// mergeStatusBlockID identifies the status block VibeMerge adds to a message, so each update replaces the last
+const mergeStatusBlockID = "vibemerge_merge_status"
+
+// queueMergeStatus queues a status update for a merge's Slack message. A digest shares its message with the other
+// PRs in it, so it shows the status of whichever changed last.
+func queueMergeStatus(ctx context.Context, redisClient *redis.Client, config *Config, cleanup MessageCleanup, status, detail string) {
+	if !config.MergeStatusUpdates || cleanup.Ts == "" {
+		return
+	}
+	cleanup.Status = status
+	cleanup.StatusDetail = detail
+	if err := queueMessageChange(ctx, redisClient, config, cleanup); err != nil {
+		logWarning("Failed to queue %s status for message %s: %v", status, cleanup.Ts, err)
+	}
+}
+
+// queueResultStatus queues the status of a merge reported by Poppit, finding its Slack message through the audit log
+func queueResultStatus(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult, status, detail string) {
+	if !config.MergeStatusUpdates {
+		return
+	}
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		logWarning("Failed to find the message of merge %s: %v", result.CorrelationID, err)
+		return
+	}
+	if found && mergeRequest(requested) {
+		queueMergeStatus(ctx, redisClient, config, auditMessage(requested), status, detail)
+	}
+}
+
+// mergeRequest reports whether an audit entry requested a merge, rather than a PR being marked ready, approved,
+// closed or reverted, or an emoji's actions being taken on it
+func mergeRequest(entry AuditEntry) bool {
+	switch entry.Source {
+	case "ready", "approve", "action", "close", "revert":
+		return false
+	}
+	return true
+}
+
+// auditMessage is the Slack message of the request recorded in an audit entry
+func auditMessage(entry AuditEntry) MessageCleanup {
+	return MessageCleanup{
+		TeamID:      entry.TeamID,
+		Repository:  entry.Repository,
+		PRNumber:    entry.PRNumber,
+		RequestedBy: entry.User,
+		Channel:     entry.Channel,
+		Ts:          entry.Ts,
+	}
+}
+
+// showMergeStatus updates a message with its merge status as a context block after its own blocks. The message is
+// read back first so its content and PR metadata are kept; a plain text message gets its text as a section block.
+func showMergeStatus(ctx context.Context, slackClient *slack.Client, cleanup MessageCleanup) error {
+	message, err := getMessage(ctx, slackClient, cleanup.Channel, cleanup.Ts)
+	if err != nil {
+		return err
+	}
+
+	var blocks []slack.Block
+	for _, block := range message.Blocks.BlockSet {
+		if block.ID() != mergeStatusBlockID {
+			blocks = append(blocks, block)
+		}
+	}
+	if len(blocks) == 0 && message.Text != "" {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, message.Text, false, false), nil, nil))
+	}
+	blocks = append(blocks, slack.NewContextBlock(mergeStatusBlockID,
+		slack.NewTextBlockObject(slack.MarkdownType, mergeStatusText(cleanup), false, false)))
+
+	options := []slack.MsgOption{slack.MsgOptionText(message.Text, false), slack.MsgOptionBlocks(blocks...)}
+	if message.Metadata.EventType != "" {
+		options = append(options, slack.MsgOptionMetadata(message.Metadata))
+	}
+	return callSlack(ctx, "chat.update", func() error {
+		_, _, _, err := slackClient.UpdateMessageContext(ctx, cleanup.Channel, cleanup.Ts, options...)
+		return err
+	})
+}
+
+// mergeStatusText renders a merge status for the status block
+func mergeStatusText(cleanup MessageCleanup) string {
+	switch cleanup.Status {
+	case MergeStatusQueued:
+		if cleanup.RequestedBy != "" {
+			return fmt.Sprintf(":hourglass_flowing_sand: Merge of PR #%d queued by <@%s>", cleanup.PRNumber, cleanup.RequestedBy)
+		}
+		return fmt.Sprintf(":hourglass_flowing_sand: Merge of PR #%d queued", cleanup.PRNumber)
+	case MergeStatusMerging:
+		return fmt.Sprintf(":arrows_counterclockwise: Merging PR #%d", cleanup.PRNumber)
+	case MergeStatusMerged:
+		if cleanup.StatusDetail != "" {
+			return fmt.Sprintf(":white_check_mark: Merged PR #%d (`%s`)", cleanup.PRNumber, shortSHA(cleanup.StatusDetail))
+		}
+		return fmt.Sprintf(":white_check_mark: Merged PR #%d", cleanup.PRNumber)
+	default:
+		return fmt.Sprintf(":x: Merge of PR #%d failed: %s", cleanup.PRNumber, cleanup.StatusDetail)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// failureReason summarises a failed Poppit result from the last line of its output, which is usually the error
+func failureReason(result PoppitResult) string {
+	reason := fmt.Sprintf("exit code %d", result.ExitCode)
+	lines := strings.Split(strings.TrimSpace(result.Output), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		if runes := []rune(last); len(runes) > 200 {
+			last = string(runes[:200]) + "…"
+		}
+		reason += ": " + last
+	}
+	return reason
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Durable store drivers
+const (
+	StoreDriverSQLite   = "sqlite"
+	StoreDriverPostgres = "postgres"
+)
+
+// Store keeps a durable record of every merge decision, the payload handed to Poppit and the outcome Poppit
+// reported, so the history survives a Redis flush
+type Store interface {
+	// RecordDecision saves an audit entry
+	RecordDecision(ctx context.Context, entry AuditEntry) error
+	// RecordMerge saves the payload of a merge handed to Poppit
+	RecordMerge(ctx context.Context, job MergeJob) error
+	// RecordOutcome saves the result Poppit reported for a merge
+	RecordOutcome(ctx context.Context, result PoppitResult) error
+	Close() error
+}
+
+// store is the durable store, or nil when STORE_DRIVER is empty
+var store Store
+
+// storeSchemas create the tables for each driver. The statements that write to them are shared.
+var storeSchemas = map[string][]string{
+	StoreDriverSQLite: {
+		`CREATE TABLE IF NOT EXISTS decisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time TIMESTAMP NOT NULL,
+			event_time TIMESTAMP,
+			source TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			github_user TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			reaction TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			ts TEXT NOT NULL,
+			repository TEXT NOT NULL,
+			pr_number INTEGER NOT NULL,
+			decision TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			correlation_id TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS merges (
+			correlation_id TEXT PRIMARY KEY,
+			repository TEXT NOT NULL,
+			pr_number INTEGER NOT NULL,
+			requested_by TEXT NOT NULL,
+			github_user TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			ts TEXT NOT NULL,
+			payload TEXT,
+			queued_at TIMESTAMP,
+			success BOOLEAN,
+			exit_code INTEGER,
+			output TEXT,
+			finished_at TIMESTAMP
+		)`,
+	},
+	StoreDriverPostgres: {
+		`CREATE TABLE IF NOT EXISTS decisions (
+			id BIGSERIAL PRIMARY KEY,
+			time TIMESTAMPTZ NOT NULL,
+			event_time TIMESTAMPTZ,
+			source TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			github_user TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			reaction TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			ts TEXT NOT NULL,
+			repository TEXT NOT NULL,
+			pr_number INTEGER NOT NULL,
+			decision TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			correlation_id TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS merges (
+			correlation_id TEXT PRIMARY KEY,
+			repository TEXT NOT NULL,
+			pr_number INTEGER NOT NULL,
+			requested_by TEXT NOT NULL,
+			github_user TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			ts TEXT NOT NULL,
+			payload JSONB,
+			queued_at TIMESTAMPTZ,
+			success BOOLEAN,
+			exit_code INTEGER,
+			output TEXT,
+			finished_at TIMESTAMPTZ
+		)`,
+	},
+}
+
+// storeIndexes are created for both drivers once the tables exist
+var storeIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS decisions_time ON decisions (time)`,
+	`CREATE INDEX IF NOT EXISTS decisions_repository ON decisions (repository, pr_number)`,
+	`CREATE INDEX IF NOT EXISTS decisions_correlation_id ON decisions (correlation_id)`,
+	`CREATE INDEX IF NOT EXISTS merges_repository ON merges (repository, pr_number)`,
+}
+
+const insertDecisionSQL = `INSERT INTO decisions
+	(time, event_time, source, user_id, github_user, team_id, reaction, channel, ts, repository, pr_number, decision, reason, correlation_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// A result can arrive for a merge queued before the store was enabled, so both statements upsert
+const upsertMergeSQL = `INSERT INTO merges
+	(correlation_id, repository, pr_number, requested_by, github_user, team_id, channel, ts, payload, queued_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (correlation_id) DO UPDATE SET
+		repository = excluded.repository, pr_number = excluded.pr_number, requested_by = excluded.requested_by,
+		github_user = excluded.github_user, team_id = excluded.team_id, channel = excluded.channel, ts = excluded.ts,
+		payload = excluded.payload, queued_at = excluded.queued_at`
+
+const upsertOutcomeSQL = `INSERT INTO merges
+	(correlation_id, repository, pr_number, requested_by, github_user, team_id, channel, ts, success, exit_code, output, finished_at)
+	VALUES (?, ?, 0, '', '', '', '', '', ?, ?, ?, ?)
+	ON CONFLICT (correlation_id) DO UPDATE SET
+		success = excluded.success, exit_code = excluded.exit_code, output = excluded.output, finished_at = excluded.finished_at`
+
+// sqlStore is a Store backed by SQLite or Postgres
+type sqlStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// openStore connects to the configured database and creates the tables if needed
+func openStore(ctx context.Context, config *Config) (*sqlStore, error) {
+	driverName := map[string]string{StoreDriverSQLite: "sqlite", StoreDriverPostgres: "pgx"}[config.StoreDriver]
+	db, err := sql.Open(driverName, config.StoreDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the %s store: %w", config.StoreDriver, err)
+	}
+	if config.StoreDriver == StoreDriverSQLite {
+		// SQLite allows a single writer, so share one connection rather than fail with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+
+	s := &sqlStore{db: db, driver: config.StoreDriver}
+	for _, statement := range append(storeSchemas[config.StoreDriver], storeIndexes...) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create the %s store tables: %w", config.StoreDriver, err)
+		}
+	}
+	return s, nil
+}
+
+// rebind rewrites ? placeholders as $1, $2, … for Postgres
+func (s *sqlStore) rebind(query string) string {
+	if s.driver != StoreDriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *sqlStore) RecordDecision(ctx context.Context, entry AuditEntry) error {
+	var eventTime *time.Time
+	if !entry.EventTime.IsZero() {
+		eventTime = &entry.EventTime
+	}
+	_, err := s.db.ExecContext(ctx, s.rebind(insertDecisionSQL),
+		entry.Time, eventTime, entry.Source, entry.User, entry.GitHubUser, entry.TeamID, entry.Reaction,
+		entry.Channel, entry.Ts, entry.Repository, entry.PRNumber, entry.Decision, entry.Reason, entry.CorrelationID)
+	if err != nil {
+		return fmt.Errorf("failed to store decision: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) RecordMerge(ctx context.Context, job MergeJob) error {
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Poppit payload: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, s.rebind(upsertMergeSQL),
+		job.Payload.CorrelationID, job.Payload.Repo, job.PRNumber, job.RequestedBy, job.Payload.GitHubUser,
+		job.TeamID, job.Channel, job.Ts, string(payload), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to store merge %s: %w", job.Payload.CorrelationID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) RecordOutcome(ctx context.Context, result PoppitResult) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(upsertOutcomeSQL),
+		result.CorrelationID, result.Repo, result.Success, result.ExitCode, result.Output, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to store the outcome of merge %s: %w", result.CorrelationID, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// maxSummaryFailures bounds the failures listed individually in the daily summary
+const maxSummaryFailures = 10
+
+// processDailySummary posts the daily merge summary once SUMMARY_TIME has passed each day
+func processDailySummary(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			config := currentConfig()
+			if config.SummaryChannel == "" {
+				continue
+			}
+			if err := postDailySummary(context.WithoutCancel(ctx), redisClient, clients, config, time.Now().In(config.Timezone)); err != nil {
+				logError("Error posting daily merge summary: %v", err)
+			}
+		}
+	}
+}
+
+// postDailySummary posts the summary of the last 24 hours if today's is due and no instance has posted it yet
+func postDailySummary(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, now time.Time) error {
+	if now.Hour()*60+now.Minute() < config.SummaryMinute {
+		return nil
+	}
+
+	slackClient := clients.forWorkspace(config.workspace(""))
+	if slackClient == nil {
+		return fmt.Errorf("SLACK_BOT_TOKEN is required to post the summary to %s", config.SummaryChannel)
+	}
+
+	// Claim today's summary so restarts and other replicas don't post it again
+	key := fmt.Sprintf("%s:%s", config.SummaryKeyPrefix, now.Format(time.DateOnly))
+	claimed, err := redisClient.SetNX(ctx, key, now.UTC().Format(time.RFC3339), 48*time.Hour).Result()
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	since := now.Add(-24 * time.Hour)
+	messages, err := redisClient.XRange(ctx, config.AuditStream, strconv.FormatInt(since.UnixMilli(), 10), "+").Result()
+	if err != nil {
+		redisClient.Del(ctx, key)
+		return fmt.Errorf("failed to read %s: %w", config.AuditStream, err)
+	}
+	entries := make([]AuditEntry, 0, len(messages))
+	for _, msg := range messages {
+		entries = append(entries, parseAuditEntry(msg))
+	}
+
+	text := formatDailySummary(entries)
+	err = callSlack(ctx, "chat.postMessage", func() error {
+		_, _, err := slackClient.PostMessageContext(ctx, config.SummaryChannel, slack.MsgOptionText(text, false))
+		return err
+	})
+	if err != nil {
+		// Release the claim so the summary is retried on the next tick
+		redisClient.Del(ctx, key)
+		return fmt.Errorf("failed to post summary to %s: %w", config.SummaryChannel, err)
+	}
+
+	logInfo("Posted daily merge summary of %d audit entries to %s", len(entries), config.SummaryChannel)
+	return nil
+}
+
+// formatDailySummary counts the merges handed to Poppit by repository and requester, and lists the failures
+func formatDailySummary(entries []AuditEntry) string {
+	byRepo := make(map[string]int)
+	byUser := make(map[string]int)
+	var merged, deferred int
+	var failures []AuditEntry
+
+	for _, entry := range entries {
+		switch {
+		case entry.Decision == OutcomeFailed:
+			failures = append(failures, entry)
+		case entry.Decision == OutcomeDeferred:
+			deferred++
+		case entry.Decision == OutcomeQueued && mergeRequest(entry):
+			merged++
+			byRepo[entry.Repository]++
+			user := "<@" + entry.User + ">"
+			if entry.GitHubUser != "" {
+				user = entry.GitHubUser
+			}
+			byUser[user]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*VibeMerge daily summary*\n• Merges queued in the last 24 hours: %d\n", merged)
+	writeCounts(&b, "By repository", byRepo)
+	writeCounts(&b, "By requester", byUser)
+	if deferred > 0 {
+		fmt.Fprintf(&b, "• Deferred by blackout windows or rate limits: %d\n", deferred)
+	}
+
+	fmt.Fprintf(&b, "• Failures: %d\n", len(failures))
+	for i, entry := range failures {
+		if i == maxSummaryFailures {
+			fmt.Fprintf(&b, "    ◦ …and %d more\n", len(failures)-maxSummaryFailures)
+			break
+		}
+		name := entry.Repository
+		if entry.PRNumber != 0 {
+			name = fmt.Sprintf("%s#%d", entry.Repository, entry.PRNumber)
+		}
+		fmt.Fprintf(&b, "    ◦ %s: %s\n", name, entry.Reason)
+	}
+	return b.String()
+}
+
+// writeCounts lists counts under a heading, largest first
+func writeCounts(b *strings.Builder, heading string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "• %s:\n", heading)
+	names := slices.SortedFunc(maps.Keys(counts), func(x, y string) int {
+		return cmp.Or(cmp.Compare(counts[y], counts[x]), cmp.Compare(x, y))
+	})
+	for _, name := range names {
+		fmt.Fprintf(b, "    ◦ %s: %d\n", name, counts[name])
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TrainStop is a merge train stopped after a merge, waiting for CI on the base branch it merged into
+type TrainStop struct {
+	// CorrelationID is the merge that just finished, which still holds the repository's merge lock
+	CorrelationID string    `json:"correlation_id"`
+	Base          string    `json:"base"`
+	Since         time.Time `json:"since"`
+}
+
+// serializeMerges reports whether merges in a repository run one at a time, which a merge train always does
+func (c *Config) serializeMerges() bool {
+	return c.mergeTrain() || flagEnabled(FlagSerializeMerges, c.SerializeMerges)
+}
+
+func (c *Config) mergeTrain() bool {
+	return flagEnabled(FlagMergeTrain, c.MergeTrain)
+}
+
+// stopTrain keeps the repository's merge lock after a successful merge, so the next merge in the train waits for CI
+// on the base branch. It reports false when the train can't stop, e.g. when the base branch can't be found, and the
+// lock should be released straight away.
+func stopTrain(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) (bool, error) {
+	lockKey := repoLockKey(config, result.Repo)
+	holder, err := redisClient.Get(ctx, lockKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", lockKey, err)
+	}
+	// Ready, approve and other pipelines go through Poppit without the lock
+	if holder != result.CorrelationID {
+		return false, nil
+	}
+
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil || !found {
+		logWarning("Failed to find the request for merge %s, not waiting for CI on its base branch: %v", result.CorrelationID, err)
+		return false, nil
+	}
+	pr, err := getPullRequest(ctx, config, result.Repo, requested.PRNumber)
+	if err != nil {
+		logWarning("Failed to read PR %d in %s, not waiting for CI on its base branch: %v", requested.PRNumber, result.Repo, err)
+		return false, nil
+	}
+
+	stopJSON, err := json.Marshal(TrainStop{CorrelationID: result.CorrelationID, Base: pr.Base.Ref, Since: time.Now().UTC()})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal train stop: %w", err)
+	}
+	if err := redisClient.HSet(ctx, config.MergeTrainKey, result.Repo, string(stopJSON)).Err(); err != nil {
+		return false, fmt.Errorf("failed to set %s: %w", config.MergeTrainKey, err)
+	}
+	// Waiting for CI gets a full lock timeout of its own
+	if err := redisClient.Expire(ctx, lockKey, time.Duration(config.MergeLockTimeout)*time.Second).Err(); err != nil {
+		logWarning("Failed to extend %s: %v", lockKey, err)
+	}
+
+	logInfo("Merge train in %s waiting for CI on %s after merge %s", result.Repo, pr.Base.Ref, result.CorrelationID)
+	return true, nil
+}
+
+// processMergeTrains moves stopped merge trains on once CI on their base branch is green
+func processMergeTrains(ctx context.Context, redisClient *redis.Client) {
+	ticker := time.NewTicker(time.Duration(currentConfig().DeferredPollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := moveMergeTrains(context.WithoutCancel(ctx), redisClient, currentConfig(), time.Now()); err != nil {
+				logError("Error moving merge trains: %v", err)
+			}
+		}
+	}
+}
+
+// moveMergeTrains releases the next merge of every train whose base branch CI passed. A train whose CI fails keeps
+// waiting for a fix on the base branch, until the merge lock expires and sweepRepoQueues moves it on.
+func moveMergeTrains(ctx context.Context, redisClient *redis.Client, config *Config, now time.Time) error {
+	stops, err := redisClient.HGetAll(ctx, config.MergeTrainKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.MergeTrainKey, err)
+	}
+
+	for repo, stopJSON := range stops {
+		var stop TrainStop
+		if err := json.Unmarshal([]byte(stopJSON), &stop); err != nil {
+			logError("Dropping malformed merge train stop for %s: %v", repo, err)
+			redisClient.HDel(ctx, config.MergeTrainKey, repo)
+			continue
+		}
+
+		holder, err := redisClient.Get(ctx, repoLockKey(config, repo)).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			logError("Error reading merge lock for %s: %v", repo, err)
+			continue
+		}
+		if holder != stop.CorrelationID {
+			// The lock expired and the train moved on without CI
+			redisClient.HDel(ctx, config.MergeTrainKey, repo)
+			continue
+		}
+
+		// Give CI a poll interval to report on the merge before an empty status counts as green
+		if now.Sub(stop.Since) < time.Duration(config.DeferredPollInterval)*time.Second {
+			continue
+		}
+		state, err := commitCIState(ctx, config, repo, url.PathEscape(stop.Base))
+		if err != nil {
+			logWarning("Failed to read CI on %s in %s: %v", stop.Base, repo, err)
+			continue
+		}
+		if state != CIStateSuccess {
+			logDebug("Merge train in %s still waiting for CI on %s (%s)", repo, stop.Base, state)
+			continue
+		}
+
+		// Only the caller that removes the stop gets to move the train on
+		removed, err := redisClient.HDel(ctx, config.MergeTrainKey, repo).Result()
+		if err != nil {
+			return fmt.Errorf("failed to remove from %s: %w", config.MergeTrainKey, err)
+		}
+		if removed == 0 {
+			continue
+		}
+		logInfo("CI on %s in %s is green, moving the merge train on", stop.Base, repo)
+		if err := releaseRepo(ctx, redisClient, config, repo, stop.CorrelationID); err != nil {
+			logError("Error releasing merge train for %s: %v", repo, err)
+		}
+	}
+	return nil
+}
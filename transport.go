@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Message transports selectable with TRANSPORT
+const (
+	TransportRedis = "redis"
+	TransportNATS  = "nats"
+	TransportKafka = "kafka"
+	TransportSQS   = "sqs"
+)
+
+// EventSource delivers inbound events: relayed Slack events, Poppit results and admin commands
+type EventSource interface {
+	// Receive passes each message published on a channel to handle until ctx is cancelled. handle calls done once
+	// the message's event has been handled, which may be later on a worker; a durable transport only acknowledges
+	// the message then.
+	Receive(ctx context.Context, channel string, handle func(payload string, done func()))
+}
+
+// CommandSink takes commands for the services that work through queues, such as Poppit
+type CommandSink interface {
+	// Push appends a command payload to a queue
+	Push(ctx context.Context, queue, payload string) error
+}
+
+// Notifier publishes messages on channels, such as replies to admin commands and TimeBomb notifications
+type Notifier interface {
+	// Publish sends a message on a channel
+	Publish(ctx context.Context, channel, payload string) error
+}
+
+// Transport is a message bus carrying all three. Redis pub/sub and lists are the default; state such as pending
+// merges and the audit log always stays in Redis.
+type Transport interface {
+	EventSource
+	CommandSink
+	Notifier
+}
+
+var (
+	// transport carries VibeMerge's inbound events, the commands it hands Poppit and admin replies. It is the
+	// Transport selected by TRANSPORT, or nil for Redis through the caller's client.
+	transport Transport
+	// serviceTransport reaches the services that only speak Redis: TimeBomb, deploy and cancel notifications. It is
+	// nil for Redis through the caller's client, and only replaced to observe them, e.g. in a harness.
+	serviceTransport Transport
+)
+
+func orRedis(t Transport, redisClient *redis.Client, clients *slackClients) Transport {
+	if t != nil {
+		return t
+	}
+	return redisTransport{redisClient: redisClient, clients: clients}
+}
+
+// eventSource returns where inbound events come from
+func eventSource(redisClient *redis.Client, clients *slackClients) EventSource {
+	return orRedis(transport, redisClient, clients)
+}
+
+// commandSink returns where Poppit commands go
+func commandSink(redisClient *redis.Client) CommandSink {
+	return orRedis(transport, redisClient, nil)
+}
+
+// replyNotifier returns where replies to inbound events go
+func replyNotifier(redisClient *redis.Client) Notifier {
+	return orRedis(transport, redisClient, nil)
+}
+
+// serviceNotifier returns where TimeBomb, cancel and deploy notifications are published
+func serviceNotifier(redisClient *redis.Client) Notifier {
+	return orRedis(serviceTransport, redisClient, nil)
+}
+
+// serviceSink returns where commands for the deploy queues go
+func serviceSink(redisClient *redis.Client) CommandSink {
+	return orRedis(serviceTransport, redisClient, nil)
+}
+
+// commandsInRedis reports whether Poppit commands go to Redis lists, which VibeMerge can read back to cancel a
+// queued merge, hold merges back while Poppit is backed up, and list and measure the queues
+func (c *Config) commandsInRedis() bool {
+	return c.Transport == TransportRedis
+}
+
+// checkTransport rejects the settings that rely on reading Poppit commands back from Redis when TRANSPORT sends them
+// elsewhere, and warns that the cancel emoji can't take back merges already sent
+func (c *Config) checkTransport() error {
+	if c.commandsInRedis() {
+		return nil
+	}
+	if c.PoppitMaxQueueLength > 0 {
+		return fmt.Errorf("POPPIT_MAX_QUEUE_LENGTH can't be used with TRANSPORT=%s, whose queue length VibeMerge can't read", c.Transport)
+	}
+	if c.PoppitQueueAlertLength > 0 {
+		return fmt.Errorf("POPPIT_QUEUE_ALERT_LENGTH can't be used with TRANSPORT=%s, whose queue length VibeMerge can't read", c.Transport)
+	}
+	// The cancel emoji still withdraws merges VibeMerge holds on to, such as deferred or waiting ones, so it stays
+	// on, but a command sent to Poppit can't be taken back
+	if c.MergeDelay > 0 {
+		return nil
+	}
+	teamIDs := []string{""}
+	for teamID := range c.Workspaces {
+		teamIDs = append(teamIDs, teamID)
+	}
+	for _, teamID := range teamIDs {
+		if c.workspace(teamID).CancelEmoji != "" {
+			// Use standard log here since logging system may not be initialized yet
+			log.Printf("[WARNING] CANCEL_EMOJI can't take back merges already sent to Poppit over TRANSPORT=%s, only deferred, waiting and scheduled ones; set MERGE_DELAY_SECONDS to leave a window to cancel in, or CANCEL_EMOJI= to turn it off", c.Transport)
+			break
+		}
+	}
+	return nil
+}
+
+// openTransport connects the transport TRANSPORT selects, returning a function that closes it
+func openTransport(ctx context.Context, config *Config) (func(), error) {
+	switch config.Transport {
+	case TransportRedis:
+		transport = nil
+		return func() {}, nil
+	case TransportNATS:
+		nats, err := newNATSTransport(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		transport = nats
+		logInfo("Receiving events and queueing Poppit commands over NATS stream %s", config.NATSStream)
+		return nats.Close, nil
+	case TransportKafka:
+		kafka, err := newKafkaTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		transport = kafka
+		logInfo("Receiving events and queueing Poppit commands over Kafka as group %s", config.KafkaGroupID)
+		return kafka.Close, nil
+	case TransportSQS:
+		sqs, err := newSQSTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		transport = sqs
+		logInfo("Receiving events and queueing Poppit commands over SQS in %s", config.AWSRegion)
+		return func() {}, nil
+	default:
+		return nil, fmt.Errorf("unknown TRANSPORT %q", config.Transport)
+	}
+}
+
+// redisTransport carries events over Redis pub/sub and Poppit commands on Redis lists
+type redisTransport struct {
+	redisClient *redis.Client
+	clients     *slackClients
+}
+
+// Receive ignores done, since a message published on a Redis channel is gone once delivered
+func (t redisTransport) Receive(ctx context.Context, channel string, handle func(payload string, done func())) {
+	receiveRedisMessages(ctx, t.redisClient, t.clients, channel, func(payload string) {
+		handle(payload, func() {})
+	})
+}
+
+// handleAndWait passes a message to handle and waits until its event has been handled, for the durable transports
+// that acknowledge one message before receiving the next
+func handleAndWait(payload string, handle func(payload string, done func())) {
+	handled := make(chan struct{})
+	handle(payload, sync.OnceFunc(func() { close(handled) }))
+	<-handled
+}
+
+func (t redisTransport) Publish(ctx context.Context, channel, payload string) error {
+	return t.redisClient.Publish(ctx, channel, payload).Err()
+}
+
+func (t redisTransport) Push(ctx context.Context, queue, payload string) error {
+	return t.redisClient.RPush(ctx, queue, payload).Err()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CI states of a commit, from its commit statuses and check runs together
+const (
+	CIStatePending = "pending"
+	CIStateSuccess = "success"
+	CIStateFailure = "failure"
+)
+
+// UpdatingMerge is a merge waiting in UPDATE_BRANCH_QUEUE for its PR's branch to be updated and CI to pass on it
+type UpdatingMerge struct {
+	Job MergeJob `json:"job"`
+	// HeadSHA is the PR's head before the update, so the update is seen landing when the head moves
+	HeadSHA string `json:"head_sha"`
+}
+
+// updateBranch updates a PR's branch when it is behind its base and parks the merge until CI passes on the update.
+// It reports false when the merge can go ahead, including when GitHub can't be asked, since Poppit's merge command
+// still fails on a branch that must be up to date.
+func updateBranch(ctx context.Context, redisClient *redis.Client, config *Config, job MergeJob) (Decision, bool, error) {
+	if !config.UpdateBranch {
+		return Decision{}, false, nil
+	}
+
+	pr, behindBy, err := behindBase(ctx, config, job.Payload.Repo, job.PRNumber)
+	if err != nil {
+		logWarning("Failed to compare PR %d in %s with its base, merging without updating its branch: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{}, false, nil
+	}
+	if behindBy == 0 {
+		return Decision{}, false, nil
+	}
+
+	path := fmt.Sprintf("/repos/%s/pulls/%d/update-branch", job.Payload.Repo, job.PRNumber)
+	if err := githubRequest(ctx, config, http.MethodPut, path, map[string]string{"expected_head_sha": pr.Head.SHA}, nil); err != nil {
+		var apiErr *githubAPIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+			return Decision{
+				Outcome: OutcomeDenied,
+				Reason:  "branch update failed: " + apiErr.Message,
+				Note: fmt.Sprintf(":warning: PR #%d is %d commits behind %s and GitHub couldn't update it (%s), so it was not queued.",
+					job.PRNumber, behindBy, pr.Base.Ref, apiErr.Message),
+			}, true, nil
+		}
+		logWarning("Failed to update the branch of PR %d in %s, merging without updating it: %v", job.PRNumber, job.Payload.Repo, err)
+		return Decision{}, false, nil
+	}
+
+	if err := parkUpdatingMerge(ctx, redisClient, config, UpdatingMerge{Job: job, HeadSHA: pr.Head.SHA}); err != nil {
+		return Decision{}, false, err
+	}
+	logInfo("Updated the branch of PR %d in %s, %d commits behind %s, merging once CI passes", job.PRNumber, job.Payload.Repo, behindBy, pr.Base.Ref)
+	return Decision{
+		Outcome: OutcomeDeferred,
+		Reason:  fmt.Sprintf("updating branch, %d commits behind %s", behindBy, pr.Base.Ref),
+		Note: fmt.Sprintf(":arrows_counterclockwise: PR #%d was %d commits behind %s, so I've updated its branch. It will be merged once CI passes on the update.",
+			job.PRNumber, behindBy, pr.Base.Ref),
+	}, true, nil
+}
+
+// behindBase reads a PR and counts the commits its base branch has that its head doesn't
+func behindBase(ctx context.Context, config *Config, repo string, number int) (githubPullRequest, int, error) {
+	pr, err := getPullRequest(ctx, config, repo, number)
+	if err != nil {
+		return pr, 0, err
+	}
+	var comparison struct {
+		BehindBy int `json:"behind_by"`
+	}
+	path := fmt.Sprintf("/repos/%s/compare/%s...%s", repo, url.PathEscape(pr.Base.Ref), pr.Head.SHA)
+	if err := githubRequest(ctx, config, http.MethodGet, path, nil, &comparison); err != nil {
+		return pr, 0, fmt.Errorf("failed to compare with %s: %w", pr.Base.Ref, err)
+	}
+	return pr, comparison.BehindBy, nil
+}
+
+// parkUpdatingMerge adds a merge to UPDATE_BRANCH_QUEUE, scored by when it stops waiting for CI
+func parkUpdatingMerge(ctx context.Context, redisClient *redis.Client, config *Config, updating UpdatingMerge) error {
+	updatingJSON, err := json.Marshal(updating)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updating merge: %w", err)
+	}
+
+	deadline := time.Now().Add(time.Duration(config.UpdateBranchTimeout) * time.Second)
+	member := redis.Z{Score: float64(deadline.Unix()), Member: string(updatingJSON)}
+	if err := redisClient.ZAdd(ctx, config.UpdateBranchQueue, member).Err(); err != nil {
+		return fmt.Errorf("failed to add to %s: %w", config.UpdateBranchQueue, err)
+	}
+
+	if err := trackPendingMerge(ctx, redisClient, config, updating.Job, PendingMerge{UpdatingMember: string(updatingJSON)}); err != nil {
+		logWarning("Failed to track updating merge, it can't be cancelled: %v", err)
+	}
+	return nil
+}
+
+func processBranchUpdates(ctx context.Context, redisClient *redis.Client, clients *slackClients) {
+	ticker := time.NewTicker(time.Duration(currentConfig().DeferredPollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := checkBranchUpdates(context.WithoutCancel(ctx), redisClient, clients, currentConfig(), time.Now()); err != nil {
+				logError("Error checking branch updates: %v", err)
+			}
+		}
+	}
+}
+
+// checkBranchUpdates queues the merges whose updated branch passed CI, and drops those whose CI failed or that
+// waited longer than UPDATE_BRANCH_TIMEOUT, telling the thread why
+func checkBranchUpdates(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, now time.Time) error {
+	entries, err := redisClient.ZRangeWithScores(ctx, config.UpdateBranchQueue, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", config.UpdateBranchQueue, err)
+	}
+	paused, _, err := pauseState(ctx, redisClient, config)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		member := entry.Member.(string)
+		var updating UpdatingMerge
+		if err := json.Unmarshal([]byte(member), &updating); err != nil {
+			logError("Dropping malformed updating merge: %v", err)
+			redisClient.ZRem(ctx, config.UpdateBranchQueue, member)
+			continue
+		}
+		job := updating.Job
+
+		state := CIStatePending
+		pr, err := getPullRequest(ctx, config, job.Payload.Repo, job.PRNumber)
+		if err != nil {
+			logWarning("Failed to read PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+		} else if pr.Head.SHA != updating.HeadSHA {
+			// The update landed, so CI runs on the new head
+			if state, err = commitCIState(ctx, config, job.Payload.Repo, pr.Head.SHA); err != nil {
+				logWarning("Failed to read CI of PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+				state = CIStatePending
+			}
+		}
+
+		var note string
+		switch {
+		case state == CIStateSuccess && paused:
+			// Held until merging is resumed
+			continue
+		case state == CIStateSuccess:
+		case state == CIStateFailure:
+			note = fmt.Sprintf(":x: CI failed on the updated branch of PR #%d, so it was not merged. React again once it's fixed.", job.PRNumber)
+		case now.Unix() >= int64(entry.Score):
+			note = fmt.Sprintf(":hourglass: CI on the updated branch of PR #%d didn't pass within %s, so it was not merged. React again once it has.",
+				job.PRNumber, time.Duration(config.UpdateBranchTimeout)*time.Second)
+		default:
+			continue
+		}
+
+		// Only the caller that removes the entry gets to act on it
+		removed, err := redisClient.ZRem(ctx, config.UpdateBranchQueue, member).Result()
+		if err != nil {
+			return fmt.Errorf("failed to remove from %s: %w", config.UpdateBranchQueue, err)
+		}
+		if removed == 0 {
+			continue
+		}
+
+		if decision, closed := closedSinceQueued(ctx, redisClient, config, job); closed {
+			note = decision.Note
+		}
+		if note != "" {
+			logInfo("Dropping merge of PR %d in %s after updating its branch (CI %s)", job.PRNumber, job.Payload.Repo, state)
+			if slackClient := clients.forWorkspace(config.workspace(job.TeamID)); slackClient != nil && job.Ts != "" {
+				notifyThread(ctx, slackClient, job.Channel, job.Ts, note)
+			}
+			continue
+		}
+		logInfo("CI passed on the updated branch of PR %d in %s, queueing its merge", job.PRNumber, job.Payload.Repo)
+		if _, err := queueMerge(ctx, redisClient, config, job); err != nil {
+			logError("Error queueing merge of PR %d in %s: %v", job.PRNumber, job.Payload.Repo, err)
+		}
+	}
+	return nil
+}
+
+// commitCIState combines a commit's statuses and check runs: failure when any failed, pending while any is still
+// running, success otherwise. A commit without any CI is a success.
+func commitCIState(ctx context.Context, config *Config, repo, sha string) (string, error) {
+	var status struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	if err := githubRequest(ctx, config, http.MethodGet, fmt.Sprintf("/repos/%s/commits/%s/status", repo, sha), nil, &status); err != nil {
+		return "", fmt.Errorf("failed to read commit status: %w", err)
+	}
+	var runs struct {
+		CheckRuns []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	path := fmt.Sprintf("/repos/%s/commits/%s/check-runs?per_page=100", repo, sha)
+	if err := githubRequest(ctx, config, http.MethodGet, path, nil, &runs); err != nil {
+		return "", fmt.Errorf("failed to read check runs: %w", err)
+	}
+
+	state := CIStateSuccess
+	if status.TotalCount > 0 {
+		switch status.State {
+		case "failure", "error":
+			return CIStateFailure, nil
+		case "pending":
+			state = CIStatePending
+		}
+	}
+	for _, run := range runs.CheckRuns {
+		if slices.Contains(failedConclusions, run.Conclusion) {
+			return CIStateFailure, nil
+		}
+		if run.Status != "completed" {
+			state = CIStatePending
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// vaultSecrets reads secrets from a HashiCorp Vault KV version 2 secret, whose keys are the environment
+// variable names they replace
+type vaultSecrets struct {
+	addr      string
+	token     string
+	namespace string
+	// path is the API path of the secret, e.g. secret/data/vibemerge
+	path   string
+	client *http.Client
+}
+
+func newVaultSecrets(config *Config) (*vaultSecrets, error) {
+	if config.VaultAddr == "" || config.VaultSecretPath == "" {
+		return nil, fmt.Errorf("SECRETS_PROVIDER=%s requires VAULT_ADDR and VAULT_SECRET_PATH", SecretsProviderVault)
+	}
+
+	token := config.VaultToken
+	if config.VaultTokenFile != "" {
+		// Re-read on every use, since a Vault agent rewrites the file as it renews the token
+		data, err := os.ReadFile(config.VaultTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return nil, fmt.Errorf("SECRETS_PROVIDER=%s requires VAULT_TOKEN or VAULT_TOKEN_FILE", SecretsProviderVault)
+	}
+
+	return &vaultSecrets{
+		addr:      strings.TrimSuffix(config.VaultAddr, "/"),
+		token:     token,
+		namespace: config.VaultNamespace,
+		path:      fmt.Sprintf("%s/data/%s", strings.Trim(config.VaultKVMount, "/"), strings.Trim(config.VaultSecretPath, "/")),
+		client:    &http.Client{Timeout: secretsTimeout},
+	}, nil
+}
+
+// Secrets reads the latest version of the secret
+func (v *vaultSecrets) Secrets(ctx context.Context) (map[string]string, error) {
+	var response struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, v.path, &response); err != nil {
+		return nil, err
+	}
+	return response.Data.Data, nil
+}
+
+// Renew extends the lease of the Vault token, so tokens with a TTL outlive the process's first day
+func (v *vaultSecrets) Renew(ctx context.Context) error {
+	return v.do(ctx, http.MethodPost, "auth/token/renew-self", nil)
+}
+
+func (v *vaultSecrets) do(ctx context.Context, method, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/v1/%s", v.addr, path), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read vault response from %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault returned %s for %s: %s", resp.Status, path, strings.TrimSpace(string(body)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse vault response from %s: %w", path, err)
+	}
+	return nil
+}
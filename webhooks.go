@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Events outbound webhooks can subscribe to
+const (
+	WebhookMergeQueued    = "merge.queued"
+	WebhookMergeConfirmed = "merge.confirmed"
+	WebhookMergeFailed    = "merge.failed"
+	WebhookDeadLettered   = "event.dead_lettered"
+)
+
+var webhookEvents = []string{WebhookMergeQueued, WebhookMergeConfirmed, WebhookMergeFailed, WebhookDeadLettered}
+
+// webhookTimeout bounds each delivery attempt, and webhookAttempts the attempts made before a delivery is dropped
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 3
+)
+
+var webhookHTTPClient = &http.Client{Timeout: webhookTimeout}
+
+// WebhookConfig is an outbound webhook from WEBHOOKS_FILE. Deliveries are signed with Secret, when set, in the
+// X-VibeMerge-Signature-256 header, the same way GitHub signs its webhooks.
+type WebhookConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events are the events delivered to the webhook; empty means all of them
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookEvent is the JSON body of a webhook delivery
+type WebhookEvent struct {
+	Event         string    `json:"event"`
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Repository    string    `json:"repository,omitempty"`
+	PRNumber      int       `json:"pr_number,omitempty"`
+	Branch        string    `json:"branch,omitempty"`
+	Author        string    `json:"author,omitempty"`
+	SlackUser     string    `json:"slack_user,omitempty"`
+	GitHubUser    string    `json:"github_user,omitempty"`
+	TeamID        string    `json:"team_id,omitempty"`
+	Channel       string    `json:"channel,omitempty"`
+	Ts            string    `json:"ts,omitempty"`
+	Reaction      string    `json:"reaction,omitempty"`
+	Commands      []string  `json:"commands,omitempty"`
+	// ApprovedBy is the Slack user who approved the merge, with CONFIRM_MERGES
+	ApprovedBy string `json:"approved_by,omitempty"`
+	// SHA is the merge commit of a confirmed merge, when Poppit reports it
+	SHA string `json:"sha,omitempty"`
+	// Reason says why a merge failed or an event was dead-lettered
+	Reason string `json:"reason,omitempty"`
+}
+
+// loadWebhooks reads the outbound webhooks file, a JSON list of webhooks
+func loadWebhooks(path string) ([]WebhookConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WEBHOOKS_FILE: %w", err)
+	}
+	var webhooks []WebhookConfig
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return nil, fmt.Errorf("failed to parse WEBHOOKS_FILE: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("WEBHOOKS_FILE: %q is not an http or https URL", webhook.URL)
+		}
+		for _, event := range webhook.Events {
+			if !slices.Contains(webhookEvents, event) {
+				return nil, fmt.Errorf("WEBHOOKS_FILE: unknown event %q for %s, expected one of %v", event, webhook.URL, webhookEvents)
+			}
+		}
+	}
+	return webhooks, nil
+}
+
+// wants reports whether a webhook subscribes to an event
+func (w WebhookConfig) wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// jobWebhookEvent describes a merge job for webhooks
+func jobWebhookEvent(event string, job MergeJob) WebhookEvent {
+	return WebhookEvent{
+		Event:         event,
+		Time:          time.Now().UTC(),
+		CorrelationID: job.Payload.CorrelationID,
+		Repository:    job.Payload.Repo,
+		PRNumber:      job.PRNumber,
+		Branch:        job.Payload.Branch,
+		Author:        job.Author,
+		SlackUser:     job.RequestedBy,
+		GitHubUser:    job.Payload.GitHubUser,
+		ApprovedBy:    job.ApprovedBy,
+		TeamID:        job.TeamID,
+		Channel:       job.Channel,
+		Ts:            job.Ts,
+		Commands:      job.Payload.Commands,
+	}
+}
+
+// auditWebhookEvent describes the merge an audit entry requested for webhooks
+func auditWebhookEvent(event string, entry AuditEntry) WebhookEvent {
+	return WebhookEvent{
+		Event:         event,
+		Time:          time.Now().UTC(),
+		CorrelationID: entry.CorrelationID,
+		Repository:    entry.Repository,
+		PRNumber:      entry.PRNumber,
+		SlackUser:     entry.User,
+		GitHubUser:    entry.GitHubUser,
+		TeamID:        entry.TeamID,
+		Channel:       entry.Channel,
+		Ts:            entry.Ts,
+		Reaction:      entry.Reaction,
+	}
+}
+
+// sendWebhooks delivers an event to every webhook subscribed to it in the background, so the merge being reported
+// isn't held up by slow receivers. Failed deliveries are retried a few times, then logged and dropped.
+func sendWebhooks(config *Config, event WebhookEvent) {
+	var targets []WebhookConfig
+	for _, webhook := range config.Webhooks {
+		if webhook.wants(event.Event) {
+			targets = append(targets, webhook)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	if dryRun != nil {
+		for _, webhook := range targets {
+			dryRun.add("%s webhook to %s", event.Event, webhook.URL)
+		}
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		logError("Failed to marshal %s webhook event: %v", event.Event, err)
+		return
+	}
+
+	delivery := newCorrelationID()
+	for _, webhook := range targets {
+		go func() {
+			for attempt := 1; ; attempt++ {
+				err := deliverWebhook(webhook, event.Event, delivery, body)
+				if err == nil {
+					metrics.Add("webhooks_delivered", 1)
+					return
+				}
+				if attempt == webhookAttempts {
+					metrics.Add("webhooks_failed", 1)
+					logError("Dropping %s webhook delivery %s to %s after %d attempts: %v", event.Event, delivery, webhook.URL, attempt, err)
+					return
+				}
+				logWarning("Retrying %s webhook delivery %s to %s: %v", event.Event, delivery, webhook.URL, err)
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+		}()
+	}
+}
+
+// deliverWebhook POSTs one webhook delivery, failing on any response but 2xx
+func deliverWebhook(webhook WebhookConfig, event, delivery string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "VibeMerge")
+	req.Header.Set("X-VibeMerge-Event", event)
+	req.Header.Set("X-VibeMerge-Delivery", delivery)
+	if webhook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-VibeMerge-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(text))
+	}
+	return nil
+}
+
+// sendResultWebhooks reports a merge Poppit completed or failed to the webhooks. Results of the ready, approve,
+// close, revert and action emoji and of workflow steps aren't merges and aren't reported.
+func sendResultWebhooks(ctx context.Context, redisClient *redis.Client, config *Config, result PoppitResult) {
+	if len(config.Webhooks) == 0 {
+		return
+	}
+	name := WebhookMergeConfirmed
+	if !result.Success {
+		name = WebhookMergeFailed
+	}
+
+	requested, found, err := findAuditEntry(ctx, redisClient, config, result.CorrelationID)
+	if err != nil {
+		logWarning("Failed to find the request for merge %s, not sending webhooks: %v", result.CorrelationID, err)
+		return
+	}
+	if !found || !mergeRequest(requested) {
+		return
+	}
+	event := auditWebhookEvent(name, requested)
+	event.Repository = result.Repo
+	if result.Success {
+		event.SHA = result.SHA
+	} else {
+		event.Reason = failureReason(result)
+	}
+	sendWebhooks(config, event)
+}
+
+// sendDeadLetterWebhooks reports a reaction event parked in DEAD_LETTER_QUEUE to the webhooks
+func sendDeadLetterWebhooks(config *Config, payload, reason string) {
+	if len(config.Webhooks) == 0 {
+		return
+	}
+	var reactionEvent ReactionEvent
+	if err := json.Unmarshal([]byte(payload), &reactionEvent); err != nil {
+		logWarning("Failed to unmarshal dead-lettered event for webhooks: %v", err)
+	}
+	sendWebhooks(config, WebhookEvent{
+		Event:     WebhookDeadLettered,
+		Time:      time.Now().UTC(),
+		SlackUser: reactionEvent.Event.User,
+		TeamID:    reactionEvent.TeamID,
+		Channel:   reactionEvent.Event.Item.Channel,
+		Ts:        reactionEvent.Event.Item.Ts,
+		Reaction:  reactionEvent.Event.Reaction,
+		Reason:    reason,
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// workerPool runs event handlers on a fixed number of goroutines. Events sharing a key always
+// land on the same worker, so they are handled one at a time in the order they arrived.
+type workerPool struct {
+	lanes []chan func()
+	wg    sync.WaitGroup
+}
+
+func newWorkerPool(size int) *workerPool {
+	pool := &workerPool{lanes: make([]chan func(), size)}
+	for i := range pool.lanes {
+		lane := make(chan func(), 64)
+		pool.lanes[i] = lane
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			defer reportPanic()
+			for task := range lane {
+				task()
+			}
+		}()
+	}
+	return pool
+}
+
+// submit hands a task to the worker owning key, blocking while that worker's backlog is full
+func (p *workerPool) submit(key string, task func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	p.lanes[h.Sum32()%uint32(len(p.lanes))] <- task
+}
+
+// close stops accepting tasks and waits for every submitted task to finish
+func (p *workerPool) close() {
+	for _, lane := range p.lanes {
+		close(lane)
+	}
+	p.wg.Wait()
+}
+
+// eventContext bounds the handling of a single event. It is detached from ctx so an in-flight
+// event still finishes when shutdown begins.
+func eventContext(ctx context.Context, config *Config) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), time.Duration(config.EventTimeout)*time.Second)
+}
+
+// reactionDispatcher hands each reaction event to the worker owning the repository of the PR it acts on, so a
+// repository's merges are handled one at a time in order even when its PRs are announced in several channels.
+// Finding the repository takes the Slack message, which is fetched first on a worker sharded by channel, keeping
+// each channel's events in order, and handed on with the event so it isn't fetched twice.
+type reactionDispatcher struct {
+	resolvers *workerPool
+	handlers  *workerPool
+}
+
+func newReactionDispatcher(size int) *reactionDispatcher {
+	return &reactionDispatcher{resolvers: newWorkerPool(size), handlers: newWorkerPool(size)}
+}
+
+// submit queues a reaction event, calling done once it has been handled
+func (d *reactionDispatcher) submit(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, done func()) {
+	d.resolvers.submit(reactionChannel(payload), func() {
+		repo, resolvedCtx := resolveReactionRepo(ctx, payload, redisClient, clients, currentConfig())
+		d.handlers.submit(repo, func() {
+			defer done()
+			handleReaction(resolvedCtx, payload, redisClient, clients)
+		})
+	})
+}
+
+// close waits for every submitted event to be handled, resolvers first as they hand events on to the handlers
+func (d *reactionDispatcher) close() {
+	d.resolvers.close()
+	d.handlers.close()
+}
+
+func reactionChannel(payload string) string {
+	var reactionEvent ReactionEvent
+	if err := json.Unmarshal([]byte(payload), &reactionEvent); err != nil {
+		return ""
+	}
+	return reactionEvent.Event.Item.Channel
+}
+
+// resolveReactionRepo finds the repository a reaction event acts on: the repository of the pending merge it
+// cancels, or of the PR in its message's metadata. Events whose repository can't be told, such as digests or
+// messages without metadata, fall back to their channel. The returned context carries the fetched metadata.
+func resolveReactionRepo(ctx context.Context, payload string, redisClient *redis.Client, clients *slackClients, config *Config) (string, context.Context) {
+	if validateReactionEvent(payload) != nil {
+		return "", ctx
+	}
+	var reactionEvent ReactionEvent
+	if err := json.Unmarshal([]byte(payload), &reactionEvent); err != nil {
+		return "", ctx
+	}
+	channel := reactionEvent.Event.Item.Channel
+	timestamp := reactionEvent.Event.Item.Ts
+
+	workspace := config.workspace(reactionEvent.TeamID)
+	if !workspace.allowsChannel(channel) {
+		return channel, ctx
+	}
+	eventCtx, cancel := eventContext(ctx, config)
+	defer cancel()
+
+	if reaction := workspace.normalizeReaction(reactionEvent.Event.Reaction); workspace.CancelEmoji != "" && reaction == workspace.CancelEmoji {
+		pendingJSON, err := redisClient.Get(eventCtx, pendingKey(config, channel, timestamp)).Result()
+		var pending PendingMerge
+		if err != nil || json.Unmarshal([]byte(pendingJSON), &pending) != nil || pending.Repository == "" {
+			return channel, ctx
+		}
+		return pending.Repository, ctx
+	}
+
+	slackClient := clients.forWorkspace(workspace)
+	if slackClient == nil {
+		return channel, ctx
+	}
+	// A message that can't be fetched now is fetched again, and its error reported, when the event is handled
+	metadata, err := getMessageMetadata(eventCtx, slackClient, config, channel, timestamp)
+	if err != nil {
+		return channel, ctx
+	}
+	ctx = withPrefetchedMetadata(ctx, channel, timestamp, metadata)
+	if metadata == nil || metadata.Repository == "" {
+		return channel, ctx
+	}
+	return metadata.Repository, ctx
+}
+
+type prefetchedMetadataKey struct{}
+
+type prefetchedMetadataValue struct {
+	channel, timestamp string
+	metadata           *PRMetadata
+}
+
+func withPrefetchedMetadata(ctx context.Context, channel, timestamp string, metadata *PRMetadata) context.Context {
+	return context.WithValue(ctx, prefetchedMetadataKey{}, prefetchedMetadataValue{channel, timestamp, metadata})
+}
+
+// prefetchedMetadata returns the metadata resolveReactionRepo fetched for a message, which may be nil when the
+// message has none, and whether it was fetched
+func prefetchedMetadata(ctx context.Context, channel, timestamp string) (*PRMetadata, bool) {
+	value, ok := ctx.Value(prefetchedMetadataKey{}).(prefetchedMetadataValue)
+	if !ok || value.channel != channel || value.timestamp != timestamp {
+		return nil, false
+	}
+	return value.metadata, true
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slack-go/slack"
+)
+
+// Step types of a workflow in WORKFLOWS_FILE
+const (
+	WorkflowStepPoppit  = "poppit"
+	WorkflowStepMerge   = "merge"
+	WorkflowStepHTTP    = "http"
+	WorkflowStepSlack   = "slack"
+	WorkflowStepRelease = "release"
+)
+
+// What a workflow does when one of its steps fails
+const (
+	WorkflowAbort    = "abort"
+	WorkflowContinue = "continue"
+	WorkflowNotify   = "notify"
+)
+
+// workflowTimeout bounds each request of an http step
+const workflowTimeout = 10 * time.Second
+
+var workflowHTTPClient = &http.Client{Timeout: workflowTimeout}
+
+// WorkflowStep is one step of an emoji's workflow, e.g. {"type": "poppit", "commands": ["gh pr ..."]},
+// {"type": "merge"}, {"type": "http", "url": "https://...", "body": "..."}, {"type": "slack", "text": "..."} or
+// {"type": "release", "scheme": "semver"}
+type WorkflowStep struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	// Commands are a poppit step's command templates, a merge step's in place of the merge emoji's, or a release
+	// step's in place of defaultReleaseCommands
+	Commands []string `json:"commands,omitempty"`
+	// Method, URL, Headers and Body make an http step's request; URL and Body are templates
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// Text is the template of a slack step's reply in the PR message's thread
+	Text string `json:"text,omitempty"`
+	// Scheme, TagPrefix, DateFormat, MajorLabels and MinorLabels pick a release step's version, see release.go
+	Scheme      string   `json:"scheme,omitempty"`
+	TagPrefix   *string  `json:"tag_prefix,omitempty"`
+	DateFormat  string   `json:"date_format,omitempty"`
+	MajorLabels []string `json:"major_labels,omitempty"`
+	MinorLabels []string `json:"minor_labels,omitempty"`
+	// OnFailure is abort, the default, continue or notify
+	OnFailure string `json:"on_failure,omitempty"`
+
+	commands []*template.Template
+	url      *template.Template
+	body     *template.Template
+	text     *template.Template
+}
+
+// Workflows maps an emoji to the steps of its workflow, run in order
+type Workflows map[string][]WorkflowStep
+
+// WorkflowRunStep is a workflow step rendered for a PR
+type WorkflowRunStep struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	OnFailure string            `json:"on_failure"`
+	Commands  []string          `json:"commands,omitempty"`
+	Method    string            `json:"method,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	// Release is set for a release step, whose commands are only rendered once its version is picked
+	Release *ReleaseStep `json:"release,omitempty"`
+}
+
+// WorkflowRun is a workflow in progress on a PR, kept in Redis while Poppit runs one of its steps
+type WorkflowRun struct {
+	Emoji string            `json:"emoji"`
+	Steps []WorkflowRunStep `json:"steps"`
+	// Next is the step to run next, or the one Poppit is running
+	Next int `json:"next"`
+	// Job is the PR's merge job, submitted by the merge step. Poppit steps reuse its payload with their own
+	// commands and correlation ID.
+	Job MergeJob `json:"job"`
+}
+
+// loadWorkflows reads the per-emoji workflows file
+func loadWorkflows(path string) (Workflows, error) {
+	workflows := make(Workflows)
+	if path == "" {
+		return workflows, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WORKFLOWS_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, &workflows); err != nil {
+		return nil, fmt.Errorf("failed to parse WORKFLOWS_FILE: %w", err)
+	}
+
+	for emoji, steps := range workflows {
+		if len(steps) == 0 {
+			return nil, fmt.Errorf("WORKFLOWS_FILE: no steps for emoji %q", emoji)
+		}
+		merges := 0
+		for i := range steps {
+			if err := steps[i].parse(fmt.Sprintf("%s[%d]", emoji, i)); err != nil {
+				return nil, fmt.Errorf("WORKFLOWS_FILE: %w", err)
+			}
+			if steps[i].Type == WorkflowStepMerge {
+				merges++
+			}
+		}
+		// The merge step is tracked by the request's correlation ID, like any merge
+		if merges > 1 {
+			return nil, fmt.Errorf("WORKFLOWS_FILE: workflow for emoji %q has more than one merge step", emoji)
+		}
+	}
+	return workflows, nil
+}
+
+// parse checks a step's parameters and parses its templates
+func (s *WorkflowStep) parse(name string) error {
+	parse := func(text string) (*template.Template, error) {
+		return template.New(name).Option("missingkey=error").Funcs(deployFuncs).Parse(text)
+	}
+
+	switch s.OnFailure {
+	case "":
+		s.OnFailure = WorkflowAbort
+	case WorkflowAbort, WorkflowContinue, WorkflowNotify:
+	default:
+		return fmt.Errorf("step %s: unknown on_failure %q, expected abort, continue or notify", name, s.OnFailure)
+	}
+
+	switch s.Type {
+	case WorkflowStepPoppit, WorkflowStepMerge:
+		if s.Type == WorkflowStepPoppit && len(s.Commands) == 0 {
+			return fmt.Errorf("step %s: poppit step needs commands", name)
+		}
+		for _, command := range s.Commands {
+			tmpl, err := parse(command)
+			if err != nil {
+				return fmt.Errorf("step %s: invalid command template: %w", name, err)
+			}
+			s.commands = append(s.commands, tmpl)
+		}
+	case WorkflowStepHTTP:
+		if s.URL == "" {
+			return fmt.Errorf("step %s: http step needs a url", name)
+		}
+		if s.Method == "" {
+			s.Method = http.MethodPost
+		}
+		s.Method = strings.ToUpper(s.Method)
+		var err error
+		if s.url, err = parse(s.URL); err != nil {
+			return fmt.Errorf("step %s: invalid url template: %w", name, err)
+		}
+		if s.body, err = parse(s.Body); err != nil {
+			return fmt.Errorf("step %s: invalid body template: %w", name, err)
+		}
+	case WorkflowStepSlack:
+		if strings.TrimSpace(s.Text) == "" {
+			return fmt.Errorf("step %s: slack step needs text", name)
+		}
+		var err error
+		if s.text, err = parse(s.Text); err != nil {
+			return fmt.Errorf("step %s: invalid text template: %w", name, err)
+		}
+	case WorkflowStepRelease:
+		return s.parseRelease(name)
+	default:
+		return fmt.Errorf("step %s: unknown type %q, expected poppit, merge, http, slack or release", name, s.Type)
+	}
+	return nil
+}
+
+// render executes a step's templates with a PR's metadata
+func (s *WorkflowStep) render(index int, metadata *PRMetadata) (WorkflowRunStep, error) {
+	execute := func(tmpl *template.Template) (string, error) {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, metadata); err != nil {
+			return "", fmt.Errorf("failed to render workflow step %s: %w", tmpl.Name(), err)
+		}
+		return b.String(), nil
+	}
+
+	step := WorkflowRunStep{Name: s.Name, Type: s.Type, OnFailure: s.OnFailure, Method: s.Method, Headers: s.Headers}
+	if step.Name == "" {
+		step.Name = fmt.Sprintf("%d (%s)", index+1, s.Type)
+	}
+	if s.Type == WorkflowStepRelease {
+		step.Release = s.releaseStep()
+		return step, nil
+	}
+	for _, tmpl := range s.commands {
+		command, err := execute(tmpl)
+		if err != nil {
+			return WorkflowRunStep{}, err
+		}
+		step.Commands = append(step.Commands, command)
+	}
+	var err error
+	if s.url != nil {
+		if step.URL, err = execute(s.url); err != nil {
+			return WorkflowRunStep{}, err
+		}
+		if u, err := url.Parse(step.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return WorkflowRunStep{}, fmt.Errorf("workflow step %s: %q is not an http or https URL", step.Name, step.URL)
+		}
+	}
+	if s.body != nil {
+		if step.Body, err = execute(s.body); err != nil {
+			return WorkflowRunStep{}, err
+		}
+	}
+	if s.text != nil {
+		if step.Text, err = execute(s.text); err != nil {
+			return WorkflowRunStep{}, err
+		}
+	}
+	return step, nil
+}
+
+// checkWorkflowEmoji rejects workflow emoji that already do something else, since a reaction runs one pipeline only
+func (c *Config) checkWorkflowEmoji() error {
+	for emoji := range c.Workflows {
+		switch emoji {
+		case c.TargetEmoji, c.ReadyEmoji, c.ApproveEmoji, c.CloseEmoji, c.RevertEmoji, c.CancelEmoji, c.SizeOverrideEmoji:
+			return fmt.Errorf("emoji %q is already a merge, ready, approve, close, revert, cancel or size override emoji", emoji)
+		}
+		if _, ok := c.Reactions[emoji]; ok {
+			return fmt.Errorf("emoji %q has a workflow and actions in REACTIONS_FILE or COMMENTS_FILE", emoji)
+		}
+		if _, ok := c.Commands[emoji]; ok {
+			return fmt.Errorf("emoji %q has a workflow and commands in COMMANDS_FILE", emoji)
+		}
+		if target, ok := c.EmojiAliases[emoji]; ok {
+			return fmt.Errorf("emoji %q has a workflow but is an alias of %q", emoji, target)
+		}
+	}
+	return nil
+}
+
+func workflowKey(config *Config, correlationID string) string {
+	return fmt.Sprintf("%s:%s", config.WorkflowKeyPrefix, correlationID)
+}
+
+// requestWorkflow follows requestPR for an emoji from WORKFLOWS_FILE, rendering its steps for the PR and starting
+// them
+func requestWorkflow(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, reactionEvent ReactionEvent, metadata *PRMetadata, steps []WorkflowStep, batch bool, audit *AuditEntry) (Decision, error) {
+	audit.Source = "workflow"
+	workspace := config.workspace(reactionEvent.TeamID)
+	requested := withGitHubLogin(ctx, redisClient, slackClient, config, metadata, reactionEvent.Event.User)
+	mergeStep := -1
+	for i, step := range steps {
+		if step.Type == WorkflowStepMerge {
+			mergeStep = i
+		}
+	}
+	if mergeStep >= 0 {
+		var err error
+		if requested, err = withSquashFlags(ctx, slackClient, config, requested, reactionEvent.Event.User); err != nil {
+			return Decision{}, err
+		}
+	}
+
+	templates, _ := config.commandTemplates(metadata.Repository, workspace.TargetEmoji, config.DefaultCommands)
+	job, err := newMergeJob(config, requested, templates, reactionEvent.TeamID, reactionEvent.Event.User, reactionEvent.Event.Item.Channel, reactionEvent.Event.Item.Ts)
+	if err != nil {
+		return Decision{}, err
+	}
+	job.Batch = batch
+	job.EventTime = audit.EventTime
+	audit.GitHubUser = job.Payload.GitHubUser
+	audit.CorrelationID = job.Payload.CorrelationID
+
+	run := WorkflowRun{Emoji: reactionEvent.Event.Reaction, Job: job}
+	for i := range steps {
+		step, err := steps[i].render(i, requested)
+		if err != nil {
+			return Decision{}, err
+		}
+		if i == mergeStep && len(step.Commands) > 0 {
+			run.Job.Payload.Commands = step.Commands
+		}
+		run.Steps = append(run.Steps, step)
+	}
+	return submitWorkflow(ctx, redisClient, slackClient, config, run)
+}
+
+// submitWorkflow starts a workflow on a PR. Like submitReady only authorization and the PR's state are checked up
+// front; a merge step goes through every check of submitMerge when its turn comes.
+func submitWorkflow(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, run WorkflowRun) (Decision, error) {
+	job := run.Job
+	if decision, denied := checkAuthorized(job, config); denied {
+		logInfo("User %s is not authorized to run the %s workflow on PR %d in %s", job.RequestedBy, run.Emoji, job.PRNumber, job.Payload.Repo)
+		return decision, nil
+	}
+	decision, closed, err := checkPRState(ctx, redisClient, config, job)
+	if err != nil {
+		return Decision{}, err
+	}
+	if closed {
+		return decision, nil
+	}
+
+	logInfo("Starting the %s workflow on PR %d in %s", run.Emoji, job.PRNumber, job.Payload.Repo)
+	if err := runWorkflow(ctx, redisClient, slackClient, config, run); err != nil {
+		return Decision{}, err
+	}
+	return Decision{Outcome: OutcomeQueued, Reason: "workflow " + run.Emoji}, nil
+}
+
+// runWorkflow runs a workflow's steps from run.Next until one is handed to Poppit, which advanceWorkflow picks up
+// from once Poppit reports on it, or until the workflow finishes or is stopped by a failing step
+func runWorkflow(ctx context.Context, redisClient *redis.Client, slackClient *slack.Client, config *Config, run WorkflowRun) error {
+	job := run.Job
+	for ; run.Next < len(run.Steps); run.Next++ {
+		step := run.Steps[run.Next]
+		var stepErr error
+		switch step.Type {
+		case WorkflowStepPoppit, WorkflowStepRelease:
+			payload := job.Payload
+			payload.Commands = step.Commands
+			if step.Release != nil {
+				if payload.Commands, stepErr = releaseCommands(ctx, config, job, *step.Release); stepErr != nil {
+					break
+				}
+			}
+			payload.CorrelationID = newCorrelationID()
+			waiting, err := awaitPoppit(ctx, redisClient, config, run, payload.CorrelationID)
+			if err != nil {
+				return err
+			}
+			if stepErr = pushPoppitPayload(ctx, redisClient, config, payload); stepErr == nil {
+				logInfo("Queued step %s of the %s workflow on PR %d in %s", step.Name, run.Emoji, job.PRNumber, job.Payload.Repo)
+				return nil
+			}
+			redisClient.Del(ctx, waiting)
+
+		case WorkflowStepMerge:
+			waiting, err := awaitPoppit(ctx, redisClient, config, run, job.Payload.CorrelationID)
+			if err != nil {
+				return err
+			}
+			decision, err := submitMerge(ctx, redisClient, config, job)
+			if err != nil {
+				redisClient.Del(ctx, waiting)
+				return err
+			}
+			if decision.Note != "" && slackClient != nil && job.Ts != "" {
+				postDecisionNote(ctx, slackClient, job.Channel, job.Ts, decision)
+			}
+			if decision.Outcome == OutcomeQueued || decision.Outcome == OutcomeDeferred {
+				return nil
+			}
+			redisClient.Del(ctx, waiting)
+			stepErr = fmt.Errorf("merge %s: %s", decision.Outcome, decision.Reason)
+
+		case WorkflowStepHTTP:
+			stepErr = callWorkflowHTTP(ctx, step)
+
+		case WorkflowStepSlack:
+			if slackClient == nil || job.Ts == "" {
+				stepErr = fmt.Errorf("no Slack thread to reply in")
+			} else {
+				stepErr = callSlack(ctx, "chat.postMessage", func() error {
+					_, _, err := slackClient.PostMessageContext(ctx, job.Channel, slack.MsgOptionText(step.Text, false), slack.MsgOptionTS(job.Ts))
+					return err
+				})
+			}
+		}
+
+		if stepErr != nil && !run.stepFailed(ctx, slackClient, stepErr) {
+			return nil
+		}
+	}
+	logInfo("Finished the %s workflow on PR %d in %s", run.Emoji, job.PRNumber, job.Payload.Repo)
+	return nil
+}
+
+// awaitPoppit keeps a workflow run in Redis under the correlation ID of the Poppit payload running its next step
+func awaitPoppit(ctx context.Context, redisClient *redis.Client, config *Config, run WorkflowRun, correlationID string) (string, error) {
+	runJSON, err := json.Marshal(run)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal workflow run: %w", err)
+	}
+	key := workflowKey(config, correlationID)
+	if err := redisClient.Set(ctx, key, string(runJSON), time.Duration(config.WorkflowTTL)*time.Second).Err(); err != nil {
+		return "", fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return key, nil
+}
+
+func pushPoppitPayload(ctx context.Context, redisClient *redis.Client, config *Config, payload PoppitPayload) error {
+	payloadJSON, err := marshalPoppitPayload(ctx, config, payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal poppit payload: %w", err)
+	}
+	queue := config.poppitQueue(payload.Repo)
+	if err := commandSink(redisClient).Push(ctx, queue, string(payloadJSON)); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", queue, err)
+	}
+	return nil
+}
+
+func callWorkflowHTTP(ctx context.Context, step WorkflowRunStep) error {
+	if dryRun != nil {
+		dryRun.add("workflow step %s %s", step.Method, step.URL)
+		return nil
+	}
+	var body io.Reader
+	if step.Body != "" {
+		body = strings.NewReader(step.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, step.Method, step.URL, body)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if step.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range step.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := workflowHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", step.Method, step.URL, resp.Status, bytes.TrimSpace(text))
+	}
+	return nil
+}
+
+// stepFailed applies the failing step's on_failure, reporting whether the workflow carries on
+func (run WorkflowRun) stepFailed(ctx context.Context, slackClient *slack.Client, err error) bool {
+	step := run.Steps[run.Next]
+	job := run.Job
+	logWarning("Step %s of the %s workflow on PR %d in %s failed: %v", step.Name, run.Emoji, job.PRNumber, job.Payload.Repo, err)
+
+	switch step.OnFailure {
+	case WorkflowContinue:
+		return true
+	case WorkflowNotify:
+		if slackClient != nil && job.Ts != "" {
+			notifyThread(ctx, slackClient, job.Channel, job.Ts, fmt.Sprintf(":x: Step %s of the :%s: workflow on PR #%d failed (%v), so the rest of it was skipped.",
+				step.Name, run.Emoji, job.PRNumber, err))
+		}
+	}
+	return false
+}
+
+// advanceWorkflow carries on with the workflow waiting for a Poppit result, if any
+func advanceWorkflow(ctx context.Context, redisClient *redis.Client, clients *slackClients, config *Config, result PoppitResult) error {
+	key := workflowKey(config, result.CorrelationID)
+	runJSON, err := redisClient.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	// Only the caller that removes the run gets to advance it
+	removed, err := redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	if removed == 0 {
+		return nil
+	}
+
+	var run WorkflowRun
+	if err := json.Unmarshal([]byte(runJSON), &run); err != nil {
+		return fmt.Errorf("failed to unmarshal workflow run: %w", err)
+	}
+	slackClient := clients.forWorkspace(config.workspace(run.Job.TeamID))
+	if !result.Success && !run.stepFailed(ctx, slackClient, fmt.Errorf("Poppit exited with code %d", result.ExitCode)) {
+		return nil
+	}
+	run.Next++
+	return runWorkflow(ctx, redisClient, slackClient, config, run)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// WorkspaceConfig holds the settings for one Slack workspace. Unset fields fall back to the global configuration.
+type WorkspaceConfig struct {
+	BotToken              *string  `json:"bot_token,omitempty"`
+	TargetEmoji           *string  `json:"target_emoji,omitempty"`
+	ReadyEmoji            *string  `json:"ready_emoji,omitempty"`
+	ApproveEmoji          *string  `json:"approve_emoji,omitempty"`
+	CloseEmoji            *string  `json:"close_emoji,omitempty"`
+	RevertEmoji           *string  `json:"revert_emoji,omitempty"`
+	CancelEmoji           *string  `json:"cancel_emoji,omitempty"`
+	TimeBombChannel       *string  `json:"timebomb_channel,omitempty"`
+	TimeBombCancelChannel *string  `json:"timebomb_cancel_channel,omitempty"`
+	Channels              []string `json:"channels,omitempty"`
+	// EmojiAliases are added to EMOJI_ALIASES for this workspace's custom emoji
+	EmojiAliases map[string]string `json:"emoji_aliases,omitempty"`
+}
+
+// WorkspaceSettings is the effective configuration for a single Slack workspace
+type WorkspaceSettings struct {
+	BotToken              string
+	TargetEmoji           string
+	ReadyEmoji            string
+	ApproveEmoji          string
+	CloseEmoji            string
+	RevertEmoji           string
+	CancelEmoji           string
+	TimeBombChannel       string
+	TimeBombCancelChannel string
+	// Channels limits VibeMerge to these Slack channel IDs; empty allows every channel
+	Channels []string
+	// EmojiAliases maps the names reactions arrive with to configured emoji
+	EmojiAliases map[string]string
+}
+
+// loadWorkspaceConfigs reads the per-workspace settings file, keyed by Slack team ID
+func loadWorkspaceConfigs(path string) (map[string]WorkspaceConfig, error) {
+	workspaces := make(map[string]WorkspaceConfig)
+	if path == "" {
+		return workspaces, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WORKSPACES_FILE: %w", err)
+	}
+	if err := json.Unmarshal(data, &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to parse WORKSPACES_FILE: %w", err)
+	}
+	return workspaces, nil
+}
+
+// workspace resolves the settings for a Slack workspace from its overrides and the global configuration
+func (c *Config) workspace(teamID string) WorkspaceSettings {
+	settings := WorkspaceSettings{
+		BotToken:              c.SlackBotToken,
+		TargetEmoji:           c.TargetEmoji,
+		ReadyEmoji:            c.ReadyEmoji,
+		ApproveEmoji:          c.ApproveEmoji,
+		CloseEmoji:            c.CloseEmoji,
+		RevertEmoji:           c.RevertEmoji,
+		CancelEmoji:           c.CancelEmoji,
+		TimeBombChannel:       c.TimeBombChannel,
+		TimeBombCancelChannel: c.TimeBombCancelChannel,
+		EmojiAliases:          c.EmojiAliases,
+	}
+
+	override, ok := c.Workspaces[teamID]
+	if !ok {
+		return settings
+	}
+	if override.BotToken != nil {
+		settings.BotToken = *override.BotToken
+	}
+	var targets map[string]string
+	if override.TargetEmoji != nil {
+		settings.TargetEmoji, targets = splitTargetEmoji(*override.TargetEmoji)
+	}
+	if override.ReadyEmoji != nil {
+		settings.ReadyEmoji = *override.ReadyEmoji
+	}
+	if override.ApproveEmoji != nil {
+		settings.ApproveEmoji = *override.ApproveEmoji
+	}
+	if override.CloseEmoji != nil {
+		settings.CloseEmoji = *override.CloseEmoji
+	}
+	if override.RevertEmoji != nil {
+		settings.RevertEmoji = *override.RevertEmoji
+	}
+	if override.CancelEmoji != nil {
+		settings.CancelEmoji = *override.CancelEmoji
+	}
+	if override.TimeBombChannel != nil {
+		settings.TimeBombChannel = *override.TimeBombChannel
+	}
+	if override.TimeBombCancelChannel != nil {
+		settings.TimeBombCancelChannel = *override.TimeBombCancelChannel
+	}
+	settings.Channels = override.Channels
+	settings.EmojiAliases = c.emojiAliases(targets, override.EmojiAliases)
+	return settings
+}
+
+// allowsChannel reports whether VibeMerge acts on messages in a Slack channel of this workspace
+func (w WorkspaceSettings) allowsChannel(channel string) bool {
+	return len(w.Channels) == 0 || slices.Contains(w.Channels, channel)
+}
+
+// slackClients hands out one Slack client per bot token, so workspaces added by reload-config get a client too
+type slackClients struct {
+	mu      sync.Mutex
+	clients map[string]*slack.Client
+	// options are added to every client, such as the API URL of the stand-in Slack of a simulation
+	options []slack.Option
+}
+
+func newSlackClients() *slackClients {
+	return &slackClients{clients: make(map[string]*slack.Client)}
+}
+
+// forWorkspace returns the client for a workspace's bot token, or nil when it has none
+func (s *slackClients) forWorkspace(workspace WorkspaceSettings) *slack.Client {
+	if workspace.BotToken == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[workspace.BotToken]
+	if !ok {
+		transport := rotatingTokenTransport{configured: workspace.BotToken}
+		options := append([]slack.Option{slack.OptionHTTPClient(&http.Client{Transport: transport})}, s.options...)
+		client = slack.New(workspace.BotToken, options...)
+		s.clients[workspace.BotToken] = client
+	}
+	return client
+}